@@ -2,4 +2,5 @@ package all
 
 import (
 	_ "github.com/CorentinB/Zeno/cmd/get"
+	_ "github.com/CorentinB/Zeno/cmd/verify"
 )
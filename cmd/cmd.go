@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -22,12 +23,34 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "User agent to use when requesting URLs",
 		Destination: &config.App.Flags.UserAgent,
 	},
+	&cli.StringFlag{
+		Name:        "accept",
+		Value:       "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+		Usage:       "Accept header sent with the requests, the default is the one of a browser, set it to a specific type like application/json to ask content negotiating servers for that format",
+		Destination: &config.App.Flags.Accept,
+	},
+	&cli.BoolFlag{
+		Name:        "send-referer",
+		Usage:       "Send the URL of the parent page in the Referer header when capturing assets, for the sites with hotlink protection, the outlinks always send it",
+		Destination: &config.App.Flags.SendReferer,
+	},
+	&cli.BoolFlag{
+		Name:        "send-request-id",
+		Usage:       "Send a unique ID in the X-Crawler-Request-ID header of every request, and log it with the capture, so the site owners can trace a request back to the crawl",
+		Destination: &config.App.Flags.SendRequestID,
+	},
 	&cli.StringFlag{
 		Name:        "job",
 		Value:       "",
 		Usage:       "Job name to use, will determine the path for the persistent queue, seencheck database, and WARC files",
 		Destination: &config.App.Flags.Job,
 	},
+	&cli.StringFlag{
+		Name:        "retry-failed",
+		Value:       "",
+		Usage:       "Path to the failed.jsonl file of a previous job, its items will be added to the seeds with their original hop and parent URL, the URL or the seed list of get url and get list can then be omitted",
+		Destination: &config.App.Flags.RetryFailed,
+	},
 	&cli.IntFlag{
 		Name:        "workers",
 		Aliases:     []string{"w"},
@@ -35,12 +58,36 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Number of concurrent workers to run",
 		Destination: &config.App.Flags.Workers,
 	},
+	&cli.IntFlag{
+		Name:        "max-inflight",
+		Value:       0,
+		Usage:       "Number of items dequeued from the frontier and held in memory waiting for a worker, a higher value smooths the throughput but every item held uses memory and is lost if Zeno crashes, 0 means the number of workers",
+		Destination: &config.App.Flags.MaxInflight,
+	},
+	&cli.IntFlag{
+		Name:        "stage-buffer-size",
+		Value:       0,
+		Usage:       "Number of items buffered between the stages of the crawl: the discovered URLs waiting to be queued, the records waiting to be written in the WARC and the URLs waiting to be sent to Kafka. A bigger buffer lets fast stages continue while a slower one catches up instead of blocking right away, at the cost of the memory used by the buffered items, that are lost if Zeno crashes. 0 keeps the default sizes",
+		Destination: &config.App.Flags.StageBufferSize,
+	},
+	&cli.IntFlag{
+		Name:        "postprocessor-concurrency",
+		Value:       0,
+		Usage:       "Number of pages whose outlinks and assets are extracted at the same time, extraction is CPU-bound unlike the capture, 0 means the number of workers",
+		Destination: &config.App.Flags.PostprocessorConcurrency,
+	},
 	&cli.UintFlag{
 		Name:        "max-hops",
 		Value:       0,
 		Usage:       "Maximum number of hops to execute",
 		Destination: &config.App.Flags.MaxHops,
 	},
+	&cli.Int64Flag{
+		Name:        "max-pages-per-host",
+		Value:       0,
+		Usage:       "Maximum number of pages to capture per host, assets are not counted, 0 means no limit",
+		Destination: &config.App.Flags.MaxPagesPerHost,
+	},
 	&cli.BoolFlag{
 		Name:        "live-stats",
 		Usage:       "Print live statistics instead of crawl logs",
@@ -57,6 +104,57 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Simple seen check to avoid re-crawling of URIs",
 		Destination: &config.App.Flags.Seencheck,
 	},
+	&cli.StringFlag{
+		Name:        "seencheck-backend",
+		Value:       "local",
+		Usage:       "Backend used for the seencheck, either local (a database in the job directory) or redis, to share it between several Zeno instances",
+		Destination: &config.App.Flags.SeencheckBackend,
+	},
+	&cli.StringFlag{
+		Name:        "redis-addr",
+		Value:       "localhost:6379",
+		Usage:       "Address of the Redis server used by the redis seencheck backend",
+		Destination: &config.App.Flags.RedisAddr,
+	},
+	&cli.StringFlag{
+		Name:        "redis-key",
+		Value:       "zeno:seencheck",
+		Usage:       "Prefix of the Redis keys used by the redis seencheck backend, instances sharing it share the seencheck",
+		Destination: &config.App.Flags.RedisKey,
+	},
+	&cli.Int64Flag{
+		Name:        "bloom-capacity",
+		Value:       0,
+		Usage:       "Number of URLs a bloom filter in front of the local seencheck is sized for, the URLs it has never seen skip the seencheck lookup, 0 to disable it",
+		Destination: &config.App.Flags.BloomCapacity,
+	},
+	&cli.Float64Flag{
+		Name:        "bloom-fp-rate",
+		Value:       0.01,
+		Usage:       "False positive rate of the bloom filter once it holds --bloom-capacity URLs",
+		Destination: &config.App.Flags.BloomFPRate,
+	},
+	&cli.BoolFlag{
+		Name:        "requeue-shallower-hops",
+		Usage:       "Enqueue again a URL already seen if it is discovered at a lower hop than the first time, so it isn't excluded by --max-hops when it's reachable within the limit, the URL is then captured again",
+		Destination: &config.App.Flags.RequeueShallowerHops,
+	},
+	&cli.DurationFlag{
+		Name:        "seencheck-window",
+		Value:       0,
+		Usage:       "Capture again the URLs discovered again longer than this duration after they were seen, even if their content didn't change, so periodic re-crawls get fresh captures, 0 means that a URL is only captured once. Only the URLs seen with a window are captured again",
+		Destination: &config.App.Flags.SeencheckWindow,
+	},
+	&cli.BoolFlag{
+		Name:        "trailing-slash-equivalence",
+		Usage:       "Consider URLs only differing by a trailing slash as the same URL for the seencheck, not safe for servers distinguishing them",
+		Destination: &config.App.Flags.TrailingSlashEquivalence,
+	},
+	&cli.StringSliceFlag{
+		Name:        "trailing-slash-equivalence-host",
+		Usage:       "Host for which URLs only differing by a trailing slash are considered as the same URL for the seencheck",
+		Destination: &config.App.Flags.TrailingSlashHosts,
+	},
 	&cli.BoolFlag{
 		Name:        "json",
 		Usage:       "Output logs in JSON",
@@ -88,6 +186,18 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "String used as a prefix for the exported Prometheus metrics",
 		Value:       "zeno:",
 	},
+	&cli.StringFlag{
+		Name:        "metrics-dump-file",
+		Value:       "",
+		Usage:       "Path of a file to periodically write the crawl's metrics to, in the OpenMetrics text format, the metrics names are prefixed with --prometheus-prefix",
+		Destination: &config.App.Flags.MetricsDumpFile,
+	},
+	&cli.DurationFlag{
+		Name:        "metrics-dump-interval",
+		Value:       10 * time.Second,
+		Usage:       "Interval at which the metrics are written to --metrics-dump-file",
+		Destination: &config.App.Flags.MetricsDumpInterval,
+	},
 
 	&cli.IntFlag{
 		Name:        "max-redirect",
@@ -95,12 +205,95 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Specifies the maximum number of redirections to follow for a resource",
 		Destination: &config.App.Flags.MaxRedirect,
 	},
+	&cli.IntFlag{
+		Name:        "max-item-depth",
+		Value:       0,
+		Usage:       "Maximum depth of an item in the tree of its seed, counting the outlinks, redirections and assets, after which its children are dropped, to bound the memory used by pathological seeds, 0 for no limit",
+		Destination: &config.App.Flags.MaxItemDepth,
+	},
+	&cli.Int64Flag{
+		Name:        "seed-max-urls",
+		Value:       0,
+		Usage:       "Capture budget of each seed, in URLs captured in its tree, assets and redirections included, after which the outlinks of its tree aren't followed anymore, 0 means no limit",
+		Destination: &config.App.Flags.SeedMaxURLs,
+	},
+	&cli.Int64Flag{
+		Name:        "seed-max-bytes",
+		Value:       0,
+		Usage:       "Capture budget of each seed, in bytes of responses captured in its tree, after which the outlinks of its tree aren't followed anymore, 0 means no limit",
+		Destination: &config.App.Flags.SeedMaxBytes,
+	},
+	&cli.IntFlag{
+		Name:        "max-active-seeds",
+		Value:       0,
+		Usage:       "Maximum number of seed trees captured at the same time, independently of the number of workers, to bound the memory used by the trees of large seeds, a tree is active until its queued outlinks are captured, the new seeds are deferred until a tree ends while the outlinks and assets of the active ones are never held back, 0 means no limit",
+		Destination: &config.App.Flags.MaxActiveSeeds,
+	},
 	&cli.IntFlag{
 		Name:        "max-retry",
 		Value:       20,
 		Usage:       "Number of retry if error happen when executing HTTP request",
 		Destination: &config.App.Flags.MaxRetry,
 	},
+	&cli.IntFlag{
+		Name:        "max-network-retry",
+		Value:       3,
+		Usage:       "Number of immediate retry when a request fails because of a transient network error, like a timeout or a connection reset, also used as the maximum number of times an interrupted download is resumed",
+		Destination: &config.App.Flags.MaxNetworkRetry,
+	},
+	&cli.Float64Flag{
+		Name:        "rate-limit-jitter",
+		Value:       0,
+		Usage:       "Fraction between 0 and 1 of random jitter added to the waits when being rate limited and to --crawl-delay, 0.5 means the wait is between 50% and 150% of its value, 0 disables the jitter",
+		Destination: &config.App.Flags.RateLimitJitter,
+	},
+	&cli.DurationFlag{
+		Name:        "read-idle-timeout",
+		Value:       0,
+		Usage:       "Abort a request when no data is received for this duration, the deadline is pushed back every time data arrives so slow but steady downloads like live stream segments are captured fully, 0 disables it",
+		Destination: &config.App.Flags.ReadIdleTimeout,
+	},
+	&cli.StringFlag{
+		Name:        "politeness",
+		Value:       "normal",
+		Usage:       "Politeness preset to use, either aggressive, normal or gentle, the throttling flags override the preset's values",
+		Destination: &config.App.Flags.Politeness,
+	},
+	&cli.IntFlag{
+		Name:        "max-concurrent-assets",
+		Value:       8,
+		Usage:       "Maximum number of assets captured concurrently for a single page, 0 means no limit",
+		Destination: &config.App.Flags.MaxConcurrentAssets,
+	},
+	&cli.IntFlag{
+		Name:        "global-max-concurrent-assets",
+		Value:       0,
+		Usage:       "Maximum number of assets captured concurrently across all workers, 0 means no limit",
+		Destination: &config.App.Flags.GlobalMaxConcurrentAssets,
+	},
+	&cli.IntFlag{
+		Name:        "max-concurrent-requests-per-host",
+		Value:       0,
+		Usage:       "Maximum number of requests sent concurrently to a single host, until their response headers are received, 0 means no limit",
+		Destination: &config.App.Flags.MaxConcurrentPerHost,
+	},
+	&cli.DurationFlag{
+		Name:        "crawl-delay",
+		Value:       0,
+		Usage:       "Minimum delay between the start of two requests to the same host, the retries included, 0 means no delay",
+		Destination: &config.App.Flags.CrawlDelay,
+	},
+	&cli.Float64Flag{
+		Name:        "max-requests-per-second",
+		Value:       0,
+		Usage:       "Maximum number of requests sent per second across all the hosts, the retries included, 0 means no limit",
+		Destination: &config.App.Flags.MaxRequestsPerSecond,
+	},
+	&cli.BoolFlag{
+		Name:        "coalesce-requests",
+		Usage:       "Capture once the identical assets requested at the same time by several pages, instead of fetching them concurrently before the seencheck catches them",
+		Destination: &config.App.Flags.CoalesceRequests,
+	},
 	&cli.BoolFlag{
 		Name:        "domains-crawl",
 		Usage:       "If this is turned on, seeds will be treated as domains to crawl, therefore same-domain outlinks will be added to the queue as hop=0",
@@ -111,23 +304,246 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Specify HTML tag to not extract assets from",
 		Destination: &config.App.Flags.DisabledHTMLTags,
 	},
+	&cli.StringSliceFlag{
+		Name:        "asset-selector",
+		Usage:       "CSS selector and attribute to extract assets from, either as selector@attribute (div.hero@data-bg) or as a selector ending with the attribute (div.hero[data-bg])",
+		Destination: &config.App.Flags.AssetSelectors,
+	},
+	&cli.StringSliceFlag{
+		Name:        "lazy-load-attribute",
+		Usage:       "Attribute holding the URL of a lazy-loaded asset, looked up on all HTML elements, attributes ending with srcset are parsed as srcset lists (default: data-src, data-lazy-src, data-srcset, data-lazy-srcset, data-original, data-original-set, data-bg, data-background, data-background-image, data-lazy, data-lazyload, data-flickity-bg-lazyload)",
+		Destination: &config.App.Flags.LazyLoadAttributes,
+	},
+	&cli.StringSliceFlag{
+		Name:        "json-data-attribute",
+		Usage:       "Data attribute holding a JSON configuration, looked up on all HTML elements, the URLs of the JSON object are captured as assets (default: data-item, data-config, data-settings, data-options, data-props, data-setup)",
+		Destination: &config.App.Flags.JSONDataAttributes,
+	},
+	&cli.StringSliceFlag{
+		Name:        "allowed-scheme",
+		Usage:       "Scheme of the extracted URLs to capture, URLs with other schemes like mailto: or javascript: are dropped, add ftp to capture files over anonymous FTP (default: http, https)",
+		Destination: &config.App.Flags.AllowedSchemes,
+	},
 	&cli.BoolFlag{
 		Name:        "capture-alternate-pages",
 		Value:       false,
-		Usage:       "If turned on, <link> HTML tags with \"alternate\" values for their \"rel\" attribute will be archived",
+		Usage:       "If turned on, the alternate versions of the pages declared with <link> HTML tags will be archived: their translations and mobile versions (rel=\"alternate\", with a media attribute for mobile versions) and their AMP version (rel=\"amphtml\")",
 		Destination: &config.App.Flags.CaptureAlternatePages,
 	},
+	&cli.BoolFlag{
+		Name:        "same-origin-assets",
+		Value:       false,
+		Usage:       "If turned on, only the assets with the same scheme and host as the seed will be captured",
+		Destination: &config.App.Flags.SameOriginAssets,
+	},
+	&cli.StringSliceFlag{
+		Name:        "cross-origin-assets-host",
+		Usage:       "Host from which assets are still captured when --same-origin-assets is turned on, useful for CDNs",
+		Destination: &config.App.Flags.CrossOriginAssetsHosts,
+	},
+	&cli.BoolFlag{
+		Name:        "extract-ping-and-formaction",
+		Value:       false,
+		Usage:       "If turned on, the URLs in the ping attribute of <a> tags and in the formaction attribute of <button> and <input> tags will be queued as outlinks",
+		Destination: &config.App.Flags.ExtractPingAndFormaction,
+	},
+	&cli.BoolFlag{
+		Name:        "extract-csp",
+		Value:       false,
+		Usage:       "If turned on, the report endpoints and the origins declared in the Content-Security-Policy, Report-To and Reporting-Endpoints headers of the responses will be queued as outlinks",
+		Destination: &config.App.Flags.ExtractCSP,
+	},
+	&cli.BoolFlag{
+		Name:        "location-as-outlink",
+		Value:       false,
+		Usage:       "If turned on, the Location header of the successful responses, that misconfigured servers send instead of redirecting, will be queued as an outlink, it isn't followed as a redirection",
+		Destination: &config.App.Flags.LocationAsOutlink,
+	},
+	&cli.BoolFlag{
+		Name:        "extract-event-handlers",
+		Value:       false,
+		Usage:       "If turned on, the quoted URLs in the inline event handlers of the elements, like onclick=\"location.href='/page'\", will be queued as outlinks",
+		Destination: &config.App.Flags.ExtractEventHandlers,
+	},
+	&cli.BoolFlag{
+		Name:        "extract-javascript-hrefs",
+		Value:       false,
+		Usage:       "If turned on, the quoted http(s) URLs in javascript: links, like javascript:window.open('https://example.com/'), will be queued as outlinks",
+		Destination: &config.App.Flags.ExtractJavascriptHrefs,
+	},
+	&cli.BoolFlag{
+		Name:        "extract-json-paths",
+		Value:       false,
+		Usage:       "If turned on, JSON responses like the ones of REST and GraphQL APIs are parsed, and the URLs and relative paths like /api/items?cursor=abc they contain are queued as outlinks",
+		Destination: &config.App.Flags.ExtractJSONPaths,
+	},
+	&cli.IntFlag{
+		Name:        "max-json-depth",
+		Value:       64,
+		Usage:       "Maximum number of levels of nested JSON objects searched for URLs in the JSON embedded in the pages, the deeper objects are ignored",
+		Destination: &config.App.Flags.MaxJSONDepth,
+	},
+	&cli.BoolFlag{
+		Name:        "charset-detection",
+		Value:       true,
+		Usage:       "Detect the charset of the pages from their Content-Type header or <meta> tags and transcode them to UTF-8 before extracting URLs",
+		Destination: &config.App.Flags.CharsetDetection,
+	},
+	&cli.Float64Flag{
+		Name:        "near-duplicate-threshold",
+		Value:       0,
+		Usage:       "Similarity between 0 and 1 above which a page is flagged as a near-duplicate of a previous page of the same host, in the logs and in a WARC metadata record, 0 disables the detection",
+		Destination: &config.App.Flags.NearDuplicateThreshold,
+	},
+	&cli.BoolFlag{
+		Name:        "follow-canonical",
+		Value:       false,
+		Usage:       "If turned on, the canonical URL declared by a page with <link rel=\"canonical\"> will be queued as an outlink",
+		Destination: &config.App.Flags.FollowCanonical,
+	},
+	&cli.BoolFlag{
+		Name:        "follow-pagination",
+		Value:       false,
+		Usage:       "If turned on, the next page declared by a page with rel=\"next\" is followed without consuming a hop, even past --max-hops",
+		Destination: &config.App.Flags.FollowPagination,
+	},
+	&cli.IntFlag{
+		Name:        "max-pagination-pages",
+		Value:       100,
+		Usage:       "Maximum number of pages followed in a row with --follow-pagination",
+		Destination: &config.App.Flags.MaxPaginationPages,
+	},
 	&cli.StringSliceFlag{
 		Name:        "exclude-host",
 		Usage:       "Exclude a specific host from the crawl, note that it will not exclude the domain if it is encountered as an asset for another web page",
 		Destination: &config.App.Flags.ExcludedHosts,
 	},
+	&cli.StringFlag{
+		Name:        "blocklist-file",
+		Usage:       "File with one host or URL prefix to exclude per line, hosts are excluded with their subdomains, the file is reloaded every 30 seconds if it changed so entries can be added during the crawl",
+		Destination: &config.App.Flags.BlocklistFile,
+	},
+	&cli.IntFlag{
+		Name:        "max-url-length",
+		Value:       0,
+		Usage:       "Maximum length of the outlinks, longer ones are dropped as they usually come from crawler traps, 2048 is a common value, 0 means no limit",
+		Destination: &config.App.Flags.MaxURLLength,
+	},
+	&cli.IntFlag{
+		Name:        "max-path-segment-repetitions",
+		Value:       0,
+		Usage:       "Maximum number of times a segment, or a block of segments, can be repeated in a row in the path of an outlink, URLs like /a/b/a/b/a/b/ are dropped as they usually come from crawler traps, 2 is a common value, 0 means no limit",
+		Destination: &config.App.Flags.MaxPathRepetitions,
+	},
+	&cli.StringSliceFlag{
+		Name:        "skip-mime-types",
+		Usage:       "MIME types of the responses to not write the body of in the WARC, wildcards like video/* are supported",
+		Destination: &config.App.Flags.SkipMIMETypes,
+	},
+	&cli.StringSliceFlag{
+		Name:        "only-mime-types",
+		Usage:       "Only write the body of the responses with these MIME types in the WARC, wildcards like text/* are supported",
+		Destination: &config.App.Flags.OnlyMIMETypes,
+	},
+	&cli.Float64Flag{
+		Name:        "min-space-required",
+		Value:       20,
+		Usage:       "Minimum free disk space in GB, the crawl is paused when the available space goes under it",
+		Destination: &config.App.Flags.MinSpaceRequired,
+	},
+	&cli.BoolFlag{
+		Name:        "sync-writes",
+		Usage:       "Fsync every write to the seencheck database, safer on crash but slower",
+		Destination: &config.App.Flags.SyncWrites,
+	},
+	&cli.DurationFlag{
+		Name:        "sync-interval",
+		Value:       time.Minute,
+		Usage:       "Interval at which the seencheck database is fsynced and the frontier's hosts pool is written to disk",
+		Destination: &config.App.Flags.SyncInterval,
+	},
+	&cli.Int64Flag{
+		Name:        "queue-compaction",
+		Value:       0,
+		Usage:       "Compact the queue on disk every time this number of items have been dequeued, to reclaim the space of the dequeued items on long crawls, 0 disables it",
+		Destination: &config.App.Flags.QueueCompaction,
+	},
+	&cli.IntFlag{
+		Name:        "max-hosts-in-memory",
+		Value:       0,
+		Usage:       "Maximum number of hosts of the hosts pool kept in memory, the least recently used hosts are spilled to disk and loaded back when other hosts are drained, 0 means no limit",
+		Destination: &config.App.Flags.MaxHostsInMemory,
+	},
+	&cli.BoolFlag{
+		Name:        "compress-frontier-dump",
+		Usage:       "Gzip the dump of the hosts pool written in the job's frontier.gob file, it is much smaller with many hosts at a small CPU cost, compressed dumps are loaded even without this flag",
+		Destination: &config.App.Flags.CompressFrontier,
+	},
+	&cli.BoolFlag{
+		Name:        "random-host-selection",
+		Usage:       "Shuffle the order in which the hosts are dequeued from at every round, so the workers spread across the hosts instead of all starting on the same ones",
+		Destination: &config.App.Flags.RandomHostSelection,
+	},
+	&cli.BoolFlag{
+		Name:        "prioritize-seeds",
+		Usage:       "Dequeue the seeds of a host before the URLs discovered during the crawl, so new seeds aren't stuck behind a backlog of discovered URLs",
+		Destination: &config.App.Flags.PrioritizeSeeds,
+	},
+	&cli.IntFlag{
+		Name:        "seed-priority-ratio",
+		Value:       0,
+		Usage:       "With --prioritize-seeds, number of seeds dequeued for each discovered URL of the same host when both are queued, 0 means the discovered URLs wait until there are no more seeds",
+		Destination: &config.App.Flags.SeedPriorityRatio,
+	},
+	&cli.BoolFlag{
+		Name:        "dns-prefetch",
+		Usage:       "Resolve in the background the hosts that are about to be crawled, to warm up the DNS cache",
+		Destination: &config.App.Flags.DNSPrefetch,
+	},
+
+	// Login flags
+	&cli.StringFlag{
+		Name:        "login-url",
+		Value:       "",
+		Usage:       "URL of a login form to POST to before starting the crawl, the session cookies are then used for the whole crawl",
+		Destination: &config.App.Flags.LoginURL,
+	},
+	&cli.StringFlag{
+		Name:        "login-form-data",
+		Value:       "",
+		Usage:       "URL-encoded form data to POST to the login URL, for example: username=foo&password=bar",
+		Destination: &config.App.Flags.LoginFormData,
+	},
+	&cli.StringFlag{
+		Name:        "login-csrf-field",
+		Value:       "",
+		Usage:       "Name of a hidden field holding a CSRF token, if specified the login page is fetched first to extract the token and send it with the form",
+		Destination: &config.App.Flags.LoginCSRFField,
+	},
+	&cli.IntFlag{
+		Name:        "max-cookies",
+		Value:       50,
+		Usage:       "Maximum number of cookies kept for each host during a crawl with --login-url, the oldest ones are dropped first, 0 means no limit",
+		Destination: &config.App.Flags.MaxCookies,
+	},
+	&cli.IntFlag{
+		Name:        "max-cookies-size",
+		Value:       65536,
+		Usage:       "Maximum size in bytes of the names and values of the cookies kept for each host during a crawl with --login-url, the oldest ones are dropped first, 0 means no limit",
+		Destination: &config.App.Flags.MaxCookiesSize,
+	},
+	&cli.StringFlag{
+		Name:        "auth-file",
+		Value:       "",
+		Usage:       "File of per-host credentials, one \"host basic user:password\" or \"host bearer token\" per line, sent in the Authorization header of the requests to that host, the credentials are redacted in the WARC request records",
+		Destination: &config.App.Flags.AuthFile,
+	},
 
 	// Proxy flags
 	&cli.StringFlag{
 		Name:        "proxy",
 		Value:       "",
-		Usage:       "Proxy to use when requesting pages",
+		Usage:       "Proxy to use when requesting pages, FTP files can only go through a SOCKS5 one, .onion URLs are always requested through it so it can be a Tor SOCKS5 proxy like socks5://127.0.0.1:9050",
 		Destination: &config.App.Flags.Proxy,
 	},
 	&cli.StringSliceFlag{
@@ -135,6 +551,34 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Domains that should not be proxied",
 		Destination: &config.App.Flags.BypassProxy,
 	},
+	&cli.StringFlag{
+		Name:        "local-address",
+		Value:       "",
+		Usage:       "Local IP address the connections are made from, on machines with several addresses, it can't be used with --interface",
+		Destination: &config.App.Flags.LocalAddress,
+	},
+	&cli.StringFlag{
+		Name:        "interface",
+		Value:       "",
+		Usage:       "Network interface the connections are made from, using its first IPv4 address, or its first IPv6 address if it has none",
+		Destination: &config.App.Flags.Interface,
+	},
+	&cli.StringFlag{
+		Name:        "local-ipv6-range",
+		Value:       "",
+		Usage:       "IPv6 prefix like 2001:db8::/64 routed to this host, each connection is made from a random address of it to spread the requests, the hosts without IPv6 are reached normally, it can't be used with --local-address or --interface",
+		Destination: &config.App.Flags.LocalIPv6Range,
+	},
+	&cli.BoolFlag{
+		Name:        "cert-validation",
+		Usage:       "Validate the TLS certificates of the servers, the connections to servers with invalid certificates fail",
+		Destination: &config.App.Flags.CertValidation,
+	},
+	&cli.StringSliceFlag{
+		Name:        "insecure-hosts",
+		Usage:       "Hosts for which TLS certificates aren't validated when --cert-validation is turned on, like hosts with self-signed certificates",
+		Destination: &config.App.Flags.InsecureHosts,
+	},
 
 	// WARC flags
 	&cli.BoolFlag{
@@ -149,12 +593,116 @@ var GlobalFlags = []cli.Flag{
 		Usage:       "Prefix to use when naming the WARC files",
 		Destination: &config.App.Flags.WARCPrefix,
 	},
+	&cli.StringFlag{
+		Name:        "warc-size",
+		Value:       "1GB",
+		Usage:       "Size at which the WARC files are rotated, for example 500MB or 1GB",
+		Destination: &config.App.Flags.WARCSize,
+	},
 	&cli.StringFlag{
 		Name:        "warc-operator",
 		Value:       "",
 		Usage:       "Contact informations of the crawl operator to write in the Warc-Info record in each WARC file",
 		Destination: &config.App.Flags.WARCOperator,
 	},
+	&cli.StringFlag{
+		Name:        "warc-description",
+		Value:       "",
+		Usage:       "Description of the crawl to write in the Warc-Info record in each WARC file",
+		Destination: &config.App.Flags.WARCDescription,
+	},
+	&cli.StringFlag{
+		Name:        "warc-robots-policy",
+		Value:       "ignore",
+		Usage:       "Robots policy of the crawl to write in the Warc-Info record in each WARC file",
+		Destination: &config.App.Flags.WARCRobotsPolicy,
+	},
+	&cli.StringFlag{
+		Name:        "warc-collection",
+		Value:       "",
+		Usage:       "Collection identifier written in the WARC-Collection header of each record, defaults to the job name",
+		Destination: &config.App.Flags.WARCCollection,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-record-timing",
+		Usage:       "Write the timing breakdown of each request in a metadata record linked to the response record",
+		Destination: &config.App.Flags.WARCRecordTiming,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-record-canonical",
+		Usage:       "Write the canonical URL declared by a page in a metadata record",
+		Destination: &config.App.Flags.WARCRecordCanonical,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-record-outlinks",
+		Usage:       "Write the outlinks and assets extracted from a page in a metadata record",
+		Destination: &config.App.Flags.WARCRecordOutlinks,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-capture-trailers",
+		Usage:       "Read the whole body of chunked responses before writing them, to preserve their HTTP trailers in the WARC",
+		Destination: &config.App.Flags.WARCCaptureTrailers,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-verify-trailer-digests",
+		Usage:       "Read the whole body of chunked responses before writing them, and verify it against the digests of their trailers (Digest, Content-Digest, X-Content-SHA256), the mismatches are logged and the result is written in a metadata record",
+		Destination: &config.App.Flags.WARCTrailerDigests,
+	},
+	&cli.BoolFlag{
+		Name:        "warc-dedupe-requests",
+		Usage:       "Write identical requests as metadata records referring to the first request record instead of full request records",
+		Destination: &config.App.Flags.WARCDedupeRequests,
+	},
+	&cli.DurationFlag{
+		Name:        "warc-dedupe-requests-window",
+		Value:       0,
+		Usage:       "With --warc-dedupe-requests, the request records written longer than this duration ago are written again in full instead of being referred to, 0 means no window. It only applies to the request records, the responses are always written in full, see --seencheck-window to capture the URLs again",
+		Destination: &config.App.Flags.WARCDedupeReqWindow,
+	},
+	&cli.IntFlag{
+		Name:        "warc-pool-size",
+		Value:       1,
+		Usage:       "Number of WARC files written at the same time, it can be changed while crawling with the API",
+		Destination: &config.App.Flags.WARCPoolSize,
+	},
+	&cli.StringFlag{
+		Name:        "warc-pool-policy",
+		Value:       "block",
+		Usage:       "What to do when all the WARC files of the pool are busy: block to wait for one of them, spill to also write in an extra WARC file",
+		Destination: &config.App.Flags.WARCPoolPolicy,
+	},
+	&cli.BoolFlag{
+		Name:        "capture-tls-certs",
+		Usage:       "Write the TLS certificate chain presented by each host in a resource record, once per host",
+		Destination: &config.App.Flags.CaptureTLSCerts,
+	},
+	&cli.BoolFlag{
+		Name:        "record-tls-errors",
+		Usage:       "Log the captures made over TLS connections whose certificate isn't valid, because it is expired, self-signed or for another host, and write the validation error in a metadata record",
+		Destination: &config.App.Flags.RecordTLSErrors,
+	},
+	&cli.BoolFlag{
+		Name:        "capture-dns",
+		Usage:       "Write the DNS responses received when resolving each host in dns resource records, once per host",
+		Destination: &config.App.Flags.CaptureDNS,
+	},
+	&cli.Int64Flag{
+		Name:        "capture-head-bytes",
+		Value:       0,
+		Usage:       "Only download and write in the WARC the first N bytes of each body, the records are marked as truncated, 0 means no limit",
+		Destination: &config.App.Flags.CaptureHeadBytes,
+	},
+	&cli.Int64Flag{
+		Name:        "max-in-memory-body-size",
+		Value:       4194304,
+		Usage:       "Size in bytes above which the bodies are written to a temporary file on disk instead of being buffered in memory, both for the WARC writing and the extraction, the bodies of unknown size always go to disk",
+		Destination: &config.App.Flags.MaxInMemoryBodySize,
+	},
+	&cli.BoolFlag{
+		Name:        "write-cdx",
+		Usage:       "Write a CDXJ index of each WARC file once it is closed, in the indexes directory of the job, ready to be loaded in pywb",
+		Destination: &config.App.Flags.WriteCDX,
+	},
 
 	// Kafka flags
 	&cli.BoolFlag{
@@ -12,7 +12,7 @@ import (
 func newGetListCmd() *cli.Command {
 	return &cli.Command{
 		Name:      "list",
-		Usage:     "Start crawling with a seed list",
+		Usage:     "Start crawling with a seed list, one URL per line or one JSON object like {\"url\": \"...\", \"method\": \"POST\", \"body\": \"...\"} per line",
 		Action:    cmdGetList,
 		Flags:     []cli.Flag{},
 		UsageText: "<FILE> [ARGUMENTS]",
@@ -29,21 +29,25 @@ func cmdGetList(c *cli.Context) error {
 	// Init crawl using the flags provided
 	crawl := cmd.InitCrawlWithCMD(config.App.Flags)
 
-	// Initialize initial seed list
-	crawl.SeedList, err = frontier.IsSeedList(c.Args().Get(0))
-	if err != nil || len(crawl.SeedList) <= 0 {
+	// Initialize initial seed list, the list can be omitted
+	// when only the failed items of a previous job are retried
+	if c.Args().Len() > 0 || len(config.App.Flags.RetryFailed) == 0 {
+		seeds, err := frontier.IsSeedList(c.Args().Get(0))
+		if err != nil || len(seeds) <= 0 {
+			logrus.WithFields(logrus.Fields{
+				"input": c.Args().Get(0),
+				"error": err.Error(),
+			}).Error("This is not a valid input")
+			return err
+		}
+
 		logrus.WithFields(logrus.Fields{
-			"input": c.Args().Get(0),
-			"error": err.Error(),
-		}).Error("This is not a valid input")
-		return err
+			"input":      c.Args().Get(0),
+			"seedsCount": len(seeds),
+		}).Print("Seed list loaded")
+		crawl.SeedList = append(crawl.SeedList, seeds...)
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"input":      c.Args().Get(0),
-		"seedsCount": len(crawl.SeedList),
-	}).Print("Seed list loaded")
-
 	// Start crawl
 	err = crawl.Start()
 	if err != nil {
@@ -31,17 +31,20 @@ func cmdGetURL(c *cli.Context) error {
 	// Init crawl using the flags provided
 	crawl := cmd.InitCrawlWithCMD(config.App.Flags)
 
-	// Initialize initial seed list
-	input, err := url.Parse(c.Args().Get(0))
-	err = utils.ValidateURL(input)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"input": c.Args().Get(0),
-			"error": err.Error(),
-		}).Error("This is not a valid input")
-		return err
+	// Initialize initial seed list, the URL can be omitted
+	// when only the failed items of a previous job are retried
+	if c.Args().Len() > 0 || len(config.App.Flags.RetryFailed) == 0 {
+		input, err := url.Parse(c.Args().Get(0))
+		err = utils.ValidateURL(input)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"input": c.Args().Get(0),
+				"error": err.Error(),
+			}).Error("This is not a valid input")
+			return err
+		}
+		crawl.SeedList = append(crawl.SeedList, *frontier.NewItem(input, nil, "seed", 0))
 	}
-	crawl.SeedList = append(crawl.SeedList, *frontier.NewItem(input, nil, "seed", 0))
 
 	// Start crawl
 	err = crawl.Start()
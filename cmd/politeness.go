@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/CorentinB/Zeno/config"
+	"github.com/urfave/cli/v2"
+)
+
+// politenessPreset define the values of the throttling flags for a preset
+type politenessPreset struct {
+	MaxConcurrentAssets       int
+	GlobalMaxConcurrentAssets int
+	MaxConcurrentPerHost      int
+	CrawlDelay                time.Duration
+	MaxRequestsPerSecond      float64
+	MaxRetry                  int
+}
+
+// politenessPresets are the presets of --politeness, normal
+// keeps the default values of the throttling flags
+var politenessPresets = map[string]politenessPreset{
+	"aggressive": {
+		MaxConcurrentAssets:       32,
+		GlobalMaxConcurrentAssets: 0,
+		MaxConcurrentPerHost:      0,
+		CrawlDelay:                0,
+		MaxRequestsPerSecond:      0,
+		MaxRetry:                  20,
+	},
+	"normal": {
+		MaxConcurrentAssets:       8,
+		GlobalMaxConcurrentAssets: 0,
+		MaxConcurrentPerHost:      0,
+		CrawlDelay:                0,
+		MaxRequestsPerSecond:      0,
+		MaxRetry:                  20,
+	},
+	"gentle": {
+		MaxConcurrentAssets:       2,
+		GlobalMaxConcurrentAssets: 16,
+		MaxConcurrentPerHost:      2,
+		CrawlDelay:                time.Second,
+		MaxRequestsPerSecond:      20,
+		MaxRetry:                  5,
+	},
+}
+
+// ApplyPolitenessPreset set the throttling flags to the values of the
+// politeness preset, the flags explicitly set by the user are kept untouched
+func ApplyPolitenessPreset(c *cli.Context) error {
+	preset, ok := politenessPresets[config.App.Flags.Politeness]
+	if !ok {
+		return errors.New("unknown politeness preset: " + config.App.Flags.Politeness)
+	}
+
+	if !c.IsSet("max-concurrent-assets") {
+		config.App.Flags.MaxConcurrentAssets = preset.MaxConcurrentAssets
+	}
+
+	if !c.IsSet("global-max-concurrent-assets") {
+		config.App.Flags.GlobalMaxConcurrentAssets = preset.GlobalMaxConcurrentAssets
+	}
+
+	if !c.IsSet("max-concurrent-requests-per-host") {
+		config.App.Flags.MaxConcurrentPerHost = preset.MaxConcurrentPerHost
+	}
+
+	if !c.IsSet("crawl-delay") {
+		config.App.Flags.CrawlDelay = preset.CrawlDelay
+	}
+
+	if !c.IsSet("max-requests-per-second") {
+		config.App.Flags.MaxRequestsPerSecond = preset.MaxRequestsPerSecond
+	}
+
+	if !c.IsSet("max-retry") {
+		config.App.Flags.MaxRetry = preset.MaxRetry
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+// runWithFlags parse the arguments with the global flags,
+// then apply the politeness preset like the app does
+func runWithFlags(arguments ...string) error {
+	app := cli.NewApp()
+	app.Flags = GlobalFlags
+	app.Action = ApplyPolitenessPreset
+
+	return app.Run(append([]string{"zeno"}, arguments...))
+}
+
+func TestApplyPolitenessPreset(t *testing.T) {
+	// The default preset keeps the default values
+	assert.NoError(t, runWithFlags())
+	assert.Equal(t, 8, config.App.Flags.MaxConcurrentAssets)
+	assert.Equal(t, 0, config.App.Flags.MaxConcurrentPerHost)
+	assert.Equal(t, time.Duration(0), config.App.Flags.CrawlDelay)
+	assert.Equal(t, float64(0), config.App.Flags.MaxRequestsPerSecond)
+
+	// A preset sets the per-host concurrency, the delay and the rate limit
+	assert.NoError(t, runWithFlags("--politeness", "gentle"))
+	assert.Equal(t, 2, config.App.Flags.MaxConcurrentAssets)
+	assert.Equal(t, 16, config.App.Flags.GlobalMaxConcurrentAssets)
+	assert.Equal(t, 2, config.App.Flags.MaxConcurrentPerHost)
+	assert.Equal(t, time.Second, config.App.Flags.CrawlDelay)
+	assert.Equal(t, float64(20), config.App.Flags.MaxRequestsPerSecond)
+	assert.Equal(t, 5, config.App.Flags.MaxRetry)
+
+	// The flags explicitly set win over the preset, even set to 0
+	assert.NoError(t, runWithFlags("--politeness", "gentle", "--crawl-delay", "5s", "--max-concurrent-requests-per-host", "0"))
+	assert.Equal(t, 5*time.Second, config.App.Flags.CrawlDelay)
+	assert.Equal(t, 0, config.App.Flags.MaxConcurrentPerHost)
+	assert.Equal(t, float64(20), config.App.Flags.MaxRequestsPerSecond)
+
+	assert.Error(t, runWithFlags("--politeness", "reckless"))
+}
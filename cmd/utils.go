@@ -7,6 +7,7 @@ import (
 	"github.com/CorentinB/Zeno/config"
 	"github.com/CorentinB/Zeno/internal/pkg/crawl"
 	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/paulbellamy/ratecounter"
 	"github.com/remeh/sizedwaitgroup"
@@ -27,6 +28,23 @@ func InitCrawlWithCMD(flags config.Flags) *crawl.Crawl {
 
 	// Frontier
 	c.Frontier = new(frontier.Frontier)
+	c.Frontier.SyncWrites = flags.SyncWrites
+	c.Frontier.QueueCompactionThreshold = flags.QueueCompaction
+	c.Frontier.MaxHostsInMemory = flags.MaxHostsInMemory
+	c.Frontier.RequeueShallowerHops = flags.RequeueShallowerHops
+	c.Frontier.SeencheckWindow = flags.SeencheckWindow
+	c.Frontier.CompressDump = flags.CompressFrontier
+	c.Frontier.RandomHostSelection = flags.RandomHostSelection
+	c.Frontier.PrioritizeSeeds = flags.PrioritizeSeeds
+	c.Frontier.SeedPriorityRatio = flags.SeedPriorityRatio
+	c.Frontier.SeencheckBackend = flags.SeencheckBackend
+	c.Frontier.RedisAddr = flags.RedisAddr
+	c.Frontier.RedisKey = flags.RedisKey
+	c.Frontier.BloomCapacity = flags.BloomCapacity
+	c.Frontier.BloomFPRate = flags.BloomFPRate
+	c.Frontier.TrailingSlashEquivalence = flags.TrailingSlashEquivalence
+	c.Frontier.TrailingSlashHosts = flags.TrailingSlashHosts.Value()
+	c.SyncInterval = flags.SyncInterval
 
 	// If the job name isn't specified, we generate a random name
 	if len(flags.Job) == 0 {
@@ -40,22 +58,145 @@ func InitCrawlWithCMD(flags config.Flags) *crawl.Crawl {
 	}
 	c.JobPath = path.Join("jobs", flags.Job)
 
+	// If asked, add the items that failed in a previous job to the seeds
+	if len(flags.RetryFailed) > 0 {
+		failedItems, err := frontier.LoadFailedItems(flags.RetryFailed)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  flags.RetryFailed,
+				"error": err,
+			}).Fatal("Unable to load failed items")
+		}
+		c.SeedList = append(c.SeedList, failedItems...)
+	}
+
+	// The workers pool isn't bounded, because
+	// the number of workers can be changed at runtime
 	c.Workers = flags.Workers
-	c.WorkerPool = sizedwaitgroup.New(c.Workers)
+	c.WorkerPool = sizedwaitgroup.New(0)
+
+	// The number of items in flight is decoupled from the number of workers,
+	// but defaults to it
+	c.StageBufferSize = flags.StageBufferSize
+	c.Frontier.StageBufferSize = flags.StageBufferSize
+	c.MaxInflight = flags.MaxInflight
+	if c.MaxInflight <= 0 {
+		c.MaxInflight = c.Workers
+	}
+	c.PostprocessorConcurrency = flags.PostprocessorConcurrency
+
+	// Assets are captured concurrently, with a limit per item and a limit
+	// shared by all workers, a limit of 0 means no limit
+	c.MaxConcurrentAssets = flags.MaxConcurrentAssets
+	c.MaxConcurrentPerHost = flags.MaxConcurrentPerHost
+	c.CrawlDelay = flags.CrawlDelay
+	c.MaxRequestsPerSecond = flags.MaxRequestsPerSecond
+	c.GlobalAssetsPool = sizedwaitgroup.New(flags.GlobalMaxConcurrentAssets)
+	c.CoalesceRequests = flags.CoalesceRequests
 
 	c.Seencheck = flags.Seencheck
 	c.MaxRetry = flags.MaxRetry
+	c.MaxNetworkRetry = flags.MaxNetworkRetry
+	c.RateLimitJitter = flags.RateLimitJitter
+	c.ReadIdleTimeout = flags.ReadIdleTimeout
 	c.MaxRedirect = flags.MaxRedirect
+	c.MaxItemDepth = flags.MaxItemDepth
+	c.SeedMaxURLs = flags.SeedMaxURLs
+	c.SeedMaxBytes = flags.SeedMaxBytes
+	c.MaxActiveSeeds = flags.MaxActiveSeeds
 	c.MaxHops = uint8(flags.MaxHops)
+	c.MaxPagesPerHost = flags.MaxPagesPerHost
 	c.DomainsCrawl = flags.DomainsCrawl
 	c.DisabledHTMLTags = flags.DisabledHTMLTags.Value()
+
+	assetSelectorRules, err := crawl.ParseAssetSelectorRules(flags.AssetSelectors.Value())
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	c.AssetSelectorRules = assetSelectorRules
+
+	c.LazyLoadAttributes = flags.LazyLoadAttributes.Value()
+	if len(c.LazyLoadAttributes) == 0 {
+		c.LazyLoadAttributes = crawl.DefaultLazyLoadAttributes
+	}
+
+	c.JSONDataAttributes = flags.JSONDataAttributes.Value()
+	if len(c.JSONDataAttributes) == 0 {
+		c.JSONDataAttributes = crawl.DefaultJSONDataAttributes
+	}
+
+	c.AllowedSchemes = flags.AllowedSchemes.Value()
+	if len(c.AllowedSchemes) == 0 {
+		c.AllowedSchemes = []string{"http", "https"}
+	}
 	c.ExcludedHosts = flags.ExcludedHosts.Value()
+	c.BlocklistFile = flags.BlocklistFile
+	c.MaxURLLength = flags.MaxURLLength
+	c.MaxPathRepetitions = flags.MaxPathRepetitions
+	c.SkipMIMETypes = flags.SkipMIMETypes.Value()
+	c.OnlyMIMETypes = flags.OnlyMIMETypes.Value()
 	c.CaptureAlternatePages = flags.CaptureAlternatePages
+	c.FollowCanonical = flags.FollowCanonical
+	c.FollowPagination = flags.FollowPagination
+	c.MaxPaginationPages = flags.MaxPaginationPages
+
+	// The similarity of two pages is the fraction of the bits
+	// of their SimHash that are the same, from 0 to 1
+	if flags.NearDuplicateThreshold < 0 || flags.NearDuplicateThreshold > 1 {
+		logrus.WithFields(logrus.Fields{
+			"near-duplicate-threshold": flags.NearDuplicateThreshold,
+		}).Fatal("Invalid near-duplicate threshold, it must be between 0 and 1")
+	}
+	c.NearDuplicateThreshold = flags.NearDuplicateThreshold
+
+	c.ExtractPingAndFormaction = flags.ExtractPingAndFormaction
+	c.ExtractCSP = flags.ExtractCSP
+	c.LocationAsOutlink = flags.LocationAsOutlink
+	c.ExtractEventHandlers = flags.ExtractEventHandlers
+	c.ExtractJavascriptHrefs = flags.ExtractJavascriptHrefs
+	c.ExtractJSONPaths = flags.ExtractJSONPaths
+	c.MaxJSONDepth = flags.MaxJSONDepth
+	c.CharsetDetection = flags.CharsetDetection
+	c.SameOriginAssets = flags.SameOriginAssets
+	c.CrossOriginAssetsHosts = flags.CrossOriginAssetsHosts.Value()
 
 	// WARC settings
 	c.WARC = flags.WARC
 	c.WARCPrefix = flags.WARCPrefix
+
+	// The WARC writer expects the size in megabytes
+	WARCSize, err := utils.ParseSize(flags.WARCSize)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"warc-size": flags.WARCSize,
+			"error":     err,
+		}).Fatal("Invalid WARC size")
+	}
+	c.WARCSize = WARCSize / crawl.MB
+
 	c.WARCOperator = flags.WARCOperator
+	c.WARCDescription = flags.WARCDescription
+	c.WARCRobotsPolicy = flags.WARCRobotsPolicy
+	c.WARCCollection = flags.WARCCollection
+	if len(c.WARCCollection) == 0 {
+		c.WARCCollection = c.Job
+	}
+	c.WARCRecordTiming = flags.WARCRecordTiming
+	c.WARCRecordCanonical = flags.WARCRecordCanonical
+	c.WARCRecordOutlinks = flags.WARCRecordOutlinks
+	c.WARCCaptureTrailers = flags.WARCCaptureTrailers
+	c.WARCTrailerDigests = flags.WARCTrailerDigests
+	c.WARCDedupeRequests = flags.WARCDedupeRequests
+	c.WARCDedupeReqWindow = flags.WARCDedupeReqWindow
+	c.WARCPoolSize = flags.WARCPoolSize
+	c.WARCPoolPolicy = flags.WARCPoolPolicy
+	c.CaptureTLSCerts = flags.CaptureTLSCerts
+	c.RecordTLSErrors = flags.RecordTLSErrors
+	c.CaptureDNS = flags.CaptureDNS
+	c.CaptureHeadBytes = flags.CaptureHeadBytes
+	c.MaxInMemoryBodySize = flags.MaxInMemoryBodySize
+	c.WriteCDX = flags.WriteCDX
+	c.Version = config.App.Version
 
 	c.API = flags.API
 	c.APIPort = flags.APIPort
@@ -69,16 +210,44 @@ func InitCrawlWithCMD(flags config.Flags) *crawl.Crawl {
 		c.PrometheusMetrics.Prefix = flags.PrometheusPrefix
 	}
 
+	// Metrics dump settings
+	c.MetricsPrefix = flags.PrometheusPrefix
+	c.MetricsDumpFile = flags.MetricsDumpFile
+	c.MetricsDumpInterval = flags.MetricsDumpInterval
+
 	c.UserAgent = flags.UserAgent
+	c.Accept = flags.Accept
+	c.SendReferer = flags.SendReferer
+	c.SendRequestID = flags.SendRequestID
 	c.Headless = flags.Headless
 	c.LiveStats = flags.LiveStats
 	c.JSONLog = flags.JSON
 	c.Debug = flags.Debug
 
+	c.DNSPrefetch = flags.DNSPrefetch
+	c.MinSpaceRequired = flags.MinSpaceRequired
+
+	// Login settings
+	c.LoginURL = flags.LoginURL
+	c.LoginFormData = flags.LoginFormData
+	c.LoginCSRFField = flags.LoginCSRFField
+	c.MaxCookies = flags.MaxCookies
+	c.MaxCookiesSize = flags.MaxCookiesSize
+	c.AuthFile = flags.AuthFile
+
 	// Proxy settings
 	c.Proxy = flags.Proxy
 	c.BypassProxy = flags.BypassProxy.Value()
 
+	// Local address settings
+	c.LocalAddress = flags.LocalAddress
+	c.Interface = flags.Interface
+	c.LocalIPv6Range = flags.LocalIPv6Range
+
+	// TLS settings
+	c.CertValidation = flags.CertValidation
+	c.InsecureHosts = flags.InsecureHosts.Value()
+
 	// Kafka settings
 	c.UseKafka = flags.Kafka
 	c.KafkaConsumerGroup = flags.KafkaConsumerGroup
@@ -0,0 +1,54 @@
+package verify
+
+import (
+	"errors"
+
+	"github.com/CorentinB/Zeno/cmd"
+	"github.com/CorentinB/Zeno/config"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd.RegisterCommand(
+		cli.Command{
+			Name:   "verify",
+			Usage:  "Fetch again the URLs archived in WARC files or CDXJ indexes and report which ones changed, without writing new WARCs",
+			Action: cmdVerify,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "report",
+					Value: "verify-report.jsonl",
+					Usage: "File in which the result of the verification of each URL is written, as one JSON object per line",
+				},
+			},
+			UsageText: "<WARC or CDXJ FILES> [ARGUMENTS]",
+		})
+}
+
+func cmdVerify(c *cli.Context) error {
+	// Log as JSON instead of the default ASCII formatter.
+	if config.App.Flags.JSON {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	if c.NArg() == 0 {
+		err := errors.New("no WARC or CDXJ file to verify")
+		logrus.Error(err)
+		return err
+	}
+
+	// Init crawl using the flags provided
+	crawl := cmd.InitCrawlWithCMD(config.App.Flags)
+
+	err := crawl.Verify(c.Args().Slice(), c.String("report"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("Verification exited due to error")
+		return err
+	}
+
+	return nil
+}
@@ -1,37 +1,143 @@
 package config
 
-import "github.com/urfave/cli/v2"
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
 
 type Flags struct {
-	Pprof     bool
-	UserAgent string
-	Job       string
-	Workers   int
-	MaxHops   uint
-	Headless  bool
-	Seencheck bool
-	LiveStats bool
-	JSON      bool
-	Debug     bool
-
-	DisabledHTMLTags      cli.StringSlice
-	ExcludedHosts         cli.StringSlice
-	DomainsCrawl          bool
-	CaptureAlternatePages bool
-	MaxRedirect           int
-	MaxRetry              int
+	Pprof            bool
+	UserAgent        string
+	Accept           string
+	SendReferer      bool
+	SendRequestID    bool
+	Job              string
+	RetryFailed      string
+	Workers          int
+	MaxInflight      int
+	StageBufferSize  int
+	MaxHops          uint
+	MaxPagesPerHost  int64
+	Headless         bool
+	Seencheck        bool
+	SeencheckBackend string
+	RedisAddr        string
+	RedisKey         string
+	BloomCapacity    int64
+	BloomFPRate      float64
+	LiveStats        bool
+	JSON             bool
+	Debug            bool
+
+	DisabledHTMLTags         cli.StringSlice
+	AllowedSchemes           cli.StringSlice
+	AssetSelectors           cli.StringSlice
+	LazyLoadAttributes       cli.StringSlice
+	JSONDataAttributes       cli.StringSlice
+	ExcludedHosts            cli.StringSlice
+	BlocklistFile            string
+	MaxURLLength             int
+	MaxPathRepetitions       int
+	TrailingSlashEquivalence bool
+	RequeueShallowerHops     bool
+	SeencheckWindow          time.Duration
+	TrailingSlashHosts       cli.StringSlice
+	SkipMIMETypes            cli.StringSlice
+	OnlyMIMETypes            cli.StringSlice
+	DomainsCrawl             bool
+	CaptureAlternatePages    bool
+	SameOriginAssets         bool
+	CrossOriginAssetsHosts   cli.StringSlice
+	FollowCanonical          bool
+	FollowPagination         bool
+	MaxPaginationPages       int
+	NearDuplicateThreshold   float64
+	ExtractPingAndFormaction bool
+	ExtractCSP               bool
+	LocationAsOutlink        bool
+	ExtractEventHandlers     bool
+	ExtractJavascriptHrefs   bool
+	ExtractJSONPaths         bool
+	MaxJSONDepth             int
+	CharsetDetection         bool
+	MaxRedirect              int
+	MaxItemDepth             int
+	SeedMaxURLs              int64
+	SeedMaxBytes             int64
+	MaxActiveSeeds           int
+	MaxRetry                 int
+	MaxNetworkRetry          int
+	RateLimitJitter          float64
+	ReadIdleTimeout          time.Duration
+
+	Politeness                string
+	MaxConcurrentAssets       int
+	GlobalMaxConcurrentAssets int
+	MaxConcurrentPerHost      int
+	CrawlDelay                time.Duration
+	MaxRequestsPerSecond      float64
+	CoalesceRequests          bool
+	PostprocessorConcurrency  int
+	RandomHostSelection       bool
+	PrioritizeSeeds           bool
+	SeedPriorityRatio         int
+
+	DNSPrefetch      bool
+	SyncWrites       bool
+	SyncInterval     time.Duration
+	QueueCompaction  int64
+	MaxHostsInMemory int
+	CompressFrontier bool
+	MinSpaceRequired float64
+
+	LoginURL       string
+	LoginFormData  string
+	LoginCSRFField string
+	MaxCookies     int
+	MaxCookiesSize int
+	AuthFile       string
 
 	Proxy       string
 	BypassProxy cli.StringSlice
 
+	LocalAddress   string
+	Interface      string
+	LocalIPv6Range string
+
+	CertValidation bool
+	InsecureHosts  cli.StringSlice
+
 	API              bool
 	APIPort          string
 	Prometheus       bool
 	PrometheusPrefix string
 
-	WARC         bool
-	WARCPrefix   string
-	WARCOperator string
+	MetricsDumpFile     string
+	MetricsDumpInterval time.Duration
+
+	WARC                bool
+	WARCPrefix          string
+	WARCSize            string
+	WARCOperator        string
+	WARCDescription     string
+	WARCRobotsPolicy    string
+	WARCCollection      string
+	WARCRecordTiming    bool
+	WARCRecordCanonical bool
+	WARCRecordOutlinks  bool
+	WARCCaptureTrailers bool
+	WARCTrailerDigests  bool
+	WARCDedupeRequests  bool
+	WARCDedupeReqWindow time.Duration
+	WARCPoolSize        int
+	WARCPoolPolicy      string
+	CaptureTLSCerts     bool
+	RecordTLSErrors     bool
+	CaptureDNS          bool
+	CaptureHeadBytes    int64
+	MaxInMemoryBodySize int64
+	WriteCDX            bool
 
 	Kafka              bool
 	KafkaFeedTopic     string
@@ -41,7 +147,8 @@ type Flags struct {
 }
 
 type Application struct {
-	Flags Flags
+	Flags   Flags
+	Version string
 }
 
 var App *Application
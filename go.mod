@@ -5,6 +5,7 @@ go 1.15
 require (
 	github.com/CorentinB/warc v0.5.9
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/andybalholm/cascadia v1.1.0
 	github.com/asaskevich/govalidator v0.0.0-20200907205600-7a23bdc65eef
 	github.com/beeker1121/goque v2.1.0+incompatible
 	github.com/confluentinc/confluent-kafka-go v1.4.2
@@ -31,9 +32,12 @@ require (
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/afero v1.4.1
 	github.com/stretchr/testify v1.6.1
-	github.com/syndtr/goleveldb v1.0.0 // indirect
+	github.com/syndtr/goleveldb v1.0.0
 	github.com/tebeka/strftime v0.1.5 // indirect
 	github.com/urfave/cli/v2 v2.2.0
 	github.com/zeebo/xxh3 v0.8.2
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974
+	golang.org/x/sync v0.0.0-20220907140024-f12130a52804
+	golang.org/x/text v0.3.3
 	mvdan.cc/xurls/v2 v2.2.0
 )
@@ -0,0 +1,209 @@
+package crawl
+
+import (
+	"sync"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// activeSeeds holds the number of pending items of each seed tree, that
+// are the items being captured, queued or about to be queued. A tree is
+// active as long as it has pending items, and the new seeds are deferred
+// until a tree ends when --max-active-seeds trees are already active.
+type activeSeeds struct {
+	sync.Mutex
+	trees    map[string]int
+	deferred []*frontier.Item
+	// session is written in the items counted in the pending items of
+	// their tree, the items queued by a previous session weren't counted
+	session string
+	// admitted receive the deferred seeds once a tree ended, for
+	// the workers to capture them, sending holds the seeds admitted
+	// that no worker received yet
+	admitted chan *frontier.Item
+	sending  []*frontier.Item
+	stopped  chan struct{}
+}
+
+func newActiveSeeds() *activeSeeds {
+	return &activeSeeds{
+		trees:    make(map[string]int),
+		session:  uuid.NewV4().String(),
+		admitted: make(chan *frontier.Item),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Count return the number of seed trees being captured
+func (seeds *activeSeeds) Count() int {
+	seeds.Lock()
+	defer seeds.Unlock()
+
+	return len(seeds.trees)
+}
+
+// Deferred return the number of seeds waiting for a tree
+// to end or for a worker to capture them
+func (seeds *activeSeeds) Deferred() int {
+	seeds.Lock()
+	defer seeds.Unlock()
+
+	return len(seeds.deferred) + len(seeds.sending)
+}
+
+// stop stop sending the admitted seeds to the workers, and return the
+// seeds that are still deferred or that no worker received, they were
+// already dequeued so they have to be queued again not to be lost
+func (seeds *activeSeeds) stop() (pending []*frontier.Item) {
+	seeds.Lock()
+	defer seeds.Unlock()
+
+	select {
+	case <-seeds.stopped:
+		return nil
+	default:
+		close(seeds.stopped)
+	}
+
+	pending = append(seeds.sending, seeds.deferred...)
+	seeds.sending, seeds.deferred = nil, nil
+
+	return pending
+}
+
+// count add a pending item to the tree of the seed, and mark the
+// item as counted in this session, the lock must be held
+func (seeds *activeSeeds) count(seed string, item *frontier.Item) {
+	seeds.trees[seed]++
+	item.SeedTreeSession = seeds.session
+}
+
+// admitSeedTree count a dequeued item in the pending items of its seed
+// tree if it wasn't counted when it was queued, and return true if it
+// can be captured. A seed starting a new tree while --max-active-seeds
+// trees are already active is deferred, it is sent to the workers once
+// a tree ends. The items of the active trees are never deferred, so
+// the trees always end.
+func (c *Crawl) admitSeedTree(item *frontier.Item) bool {
+	if c.MaxActiveSeeds <= 0 {
+		return true
+	}
+
+	seed := item.Seed().URL.String()
+
+	c.ActiveSeeds.Lock()
+	defer c.ActiveSeeds.Unlock()
+
+	// The outlinks were counted when they were queued, unless
+	// they were queued by a previous session
+	if item.ParentItem != nil {
+		if item.SeedTreeSession != c.ActiveSeeds.session {
+			c.ActiveSeeds.count(seed, item)
+		}
+		return true
+	}
+
+	if c.ActiveSeeds.trees[seed] == 0 && len(c.ActiveSeeds.trees) >= c.MaxActiveSeeds {
+		logInfo.WithFields(logrus.Fields{
+			"seed":   seed,
+			"active": len(c.ActiveSeeds.trees),
+		}).Debug("Maximum number of active seeds reached, deferring seed")
+		c.ActiveSeeds.deferred = append(c.ActiveSeeds.deferred, item)
+		return false
+	}
+
+	c.ActiveSeeds.count(seed, item)
+
+	return true
+}
+
+// retainSeedTree add a pending item to the seed tree of the item, it has
+// to be released with releaseSeedTree once it is captured or dropped
+func (c *Crawl) retainSeedTree(item *frontier.Item) {
+	if c.MaxActiveSeeds <= 0 {
+		return
+	}
+
+	c.ActiveSeeds.Lock()
+	c.ActiveSeeds.count(item.Seed().URL.String(), item)
+	c.ActiveSeeds.Unlock()
+}
+
+// releaseSeedTree remove a pending item from the seed tree of the item,
+// if it was counted in this session. The tree ends when it was its last
+// pending item, and the deferred seeds are then admitted until
+// --max-active-seeds trees are active again.
+func (c *Crawl) releaseSeedTree(item *frontier.Item) {
+	if c.MaxActiveSeeds <= 0 {
+		return
+	}
+
+	c.ActiveSeeds.Lock()
+	defer c.ActiveSeeds.Unlock()
+
+	if item.SeedTreeSession != c.ActiveSeeds.session {
+		return
+	}
+
+	seed := item.Seed().URL.String()
+
+	c.ActiveSeeds.trees[seed]--
+	if c.ActiveSeeds.trees[seed] > 0 {
+		return
+	}
+	delete(c.ActiveSeeds.trees, seed)
+
+	for len(c.ActiveSeeds.deferred) > 0 && len(c.ActiveSeeds.trees) < c.MaxActiveSeeds {
+		next := c.ActiveSeeds.deferred[0]
+		c.ActiveSeeds.deferred = c.ActiveSeeds.deferred[1:]
+		c.ActiveSeeds.count(next.URL.String(), next)
+		c.ActiveSeeds.sending = append(c.ActiveSeeds.sending, next)
+
+		// The seed is sent in the background, the worker releasing
+		// the tree may be the only one, until the crawl finishes
+		go c.sendAdmittedSeed(next)
+	}
+}
+
+// sendAdmittedSeed send an admitted seed to the workers, the seed is
+// left in the pending seeds if the crawl finishes before it is received
+func (c *Crawl) sendAdmittedSeed(seed *frontier.Item) {
+	select {
+	case c.ActiveSeeds.admitted <- seed:
+	case <-c.ActiveSeeds.stopped:
+	}
+}
+
+// received remove an admitted seed from the pending seeds, it is
+// called by the worker that received it, before it can stop. It
+// return false if the seed was already returned by stop, it must
+// then not be captured as it is queued again.
+func (seeds *activeSeeds) received(seed *frontier.Item) bool {
+	seeds.Lock()
+	defer seeds.Unlock()
+
+	for i, sending := range seeds.sending {
+		if sending == seed {
+			seeds.sending = append(seeds.sending[:i], seeds.sending[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// requeueDeferredSeeds stop admitting the deferred seeds and queue them
+// again, so they are captured when the job is resumed
+func (c *Crawl) requeueDeferredSeeds() {
+	for _, seed := range c.ActiveSeeds.stop() {
+		err := c.Frontier.Requeue(seed)
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"url":   seed.URL.String(),
+				"error": err,
+			}).Warning("Unable to queue again a deferred seed")
+		}
+	}
+}
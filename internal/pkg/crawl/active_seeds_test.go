@@ -0,0 +1,286 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/stretchr/testify/assert"
+	"mvdan.cc/xurls/v2"
+)
+
+func TestAdmitSeedTreeMaxActiveSeeds(t *testing.T) {
+	c := newTestCrawl()
+	c.MaxActiveSeeds = 1
+
+	newSeed := func(path string) *frontier.Item {
+		URL, _ := url.Parse("https://example.com/" + path)
+		return frontier.NewItem(URL, nil, "seed", 0)
+	}
+
+	first, second := newSeed("first"), newSeed("second")
+	assert.True(t, c.admitSeedTree(first))
+
+	// The second seed is deferred
+	assert.False(t, c.admitSeedTree(second))
+	assert.Equal(t, 1, c.ActiveSeeds.Count())
+	assert.Equal(t, 1, c.ActiveSeeds.Deferred())
+
+	// The outlink queued by the first seed keeps its tree active
+	// after the seed is captured, until the outlink is captured too
+	outlinkURL, _ := url.Parse("https://example.com/first/outlink")
+	outlink := frontier.NewItem(outlinkURL, first, "seed", 1)
+	c.retainSeedTree(outlink)
+	c.releaseSeedTree(first)
+
+	select {
+	case <-c.ActiveSeeds.admitted:
+		t.Fatal("the second seed was admitted while the first tree is active")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The outlinks are never deferred
+	assert.True(t, c.admitSeedTree(outlink))
+	c.releaseSeedTree(outlink)
+
+	select {
+	case seed := <-c.ActiveSeeds.admitted:
+		assert.True(t, c.ActiveSeeds.received(seed))
+		assert.Equal(t, second, seed)
+	case <-time.After(time.Second):
+		t.Fatal("the second seed wasn't admitted once the first tree ended")
+	}
+	assert.Eventually(t, func() bool {
+		return c.ActiveSeeds.Deferred() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, c.ActiveSeeds.Count())
+
+	// Without limit, the trees aren't tracked
+	c = newTestCrawl()
+	assert.True(t, c.admitSeedTree(first))
+	assert.Equal(t, 0, c.ActiveSeeds.Count())
+}
+
+func TestAdmitSeedTreePreviousSession(t *testing.T) {
+	c := newTestCrawl()
+	c.MaxActiveSeeds = 1
+
+	seedURL, _ := url.Parse("https://example.com/first")
+	seed := frontier.NewItem(seedURL, nil, "seed", 0)
+	assert.True(t, c.admitSeedTree(seed))
+
+	// An outlink queued in this session, and one queued by a
+	// previous session, that wasn't counted when it was queued
+	outlinkURL, _ := url.Parse("https://example.com/first/outlink")
+	outlink := frontier.NewItem(outlinkURL, seed, "seed", 1)
+	c.retainSeedTree(outlink)
+
+	previousURL, _ := url.Parse("https://example.com/first/previous")
+	previous := frontier.NewItem(previousURL, seed, "seed", 1)
+	previous.SeedTreeSession = "previous"
+
+	otherURL, _ := url.Parse("https://example.com/other")
+	assert.False(t, c.admitSeedTree(frontier.NewItem(otherURL, nil, "seed", 0)))
+
+	assert.True(t, c.admitSeedTree(previous))
+	c.releaseSeedTree(seed)
+	c.releaseSeedTree(previous)
+
+	// The tree still has the outlink of this session pending
+	assert.Equal(t, 1, c.ActiveSeeds.Count())
+	select {
+	case <-c.ActiveSeeds.admitted:
+		t.Fatal("a seed was admitted while the first tree is active")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Items that were never counted don't release anything
+	c.releaseSeedTree(frontier.NewItem(previousURL, seed, "seed", 1))
+	assert.Equal(t, 1, c.ActiveSeeds.Count())
+
+	c.releaseSeedTree(outlink)
+	select {
+	case seed := <-c.ActiveSeeds.admitted:
+		assert.True(t, c.ActiveSeeds.received(seed))
+		assert.Equal(t, otherURL.String(), seed.URL.String())
+	case <-time.After(time.Second):
+		t.Fatal("the other seed wasn't admitted once the first tree ended")
+	}
+}
+
+func TestRequeueDeferredSeeds(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-deferred-seeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.MaxActiveSeeds = 1
+
+	err = c.Frontier.Init(jobPath, logInfo, logWarning, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Frontier.Queue.Close()
+
+	newSeed := func(path string) *frontier.Item {
+		URL, _ := url.Parse("https://example.com/" + path)
+		return frontier.NewItem(URL, nil, "seed", 0)
+	}
+
+	// A seed is deferred, and another one is admitted
+	// without any worker to receive it
+	first := newSeed("first")
+	assert.True(t, c.admitSeedTree(first))
+	assert.False(t, c.admitSeedTree(newSeed("second")))
+	assert.False(t, c.admitSeedTree(newSeed("third")))
+	c.releaseSeedTree(first)
+	assert.Equal(t, 2, c.ActiveSeeds.Deferred())
+
+	// When the crawl finishes, they are queued again, even
+	// though the seencheck already saw them
+	c.requeueDeferredSeeds()
+	assert.Equal(t, 0, c.ActiveSeeds.Deferred())
+	assert.Equal(t, int64(2), c.Frontier.QueueCount.Value())
+
+	var requeued []string
+	for i := 0; i < 2; i++ {
+		queueItem, err := c.Frontier.Queue.DequeueString("example.com")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var item *frontier.Item
+		assert.NoError(t, queueItem.ToObject(&item))
+		requeued = append(requeued, item.URL.String())
+	}
+	assert.ElementsMatch(t, []string{"https://example.com/second", "https://example.com/third"}, requeued)
+
+	// The admitted seed isn't captured anymore, and its
+	// sender stops instead of waiting for a worker
+	select {
+	case seed := <-c.ActiveSeeds.admitted:
+		assert.False(t, c.ActiveSeeds.received(seed))
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case <-c.ActiveSeeds.admitted:
+		t.Fatal("an admitted seed was sent twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMaxActiveSeedsThroughQueue(t *testing.T) {
+	var requestsMutex sync.Mutex
+	var requests []string
+
+	// Each seed links to pages of its own tree, that are slow to capture
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsMutex.Lock()
+		requests = append(requests, r.URL.Path)
+		requestsMutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if strings.Count(r.URL.Path, "/") == 1 {
+			w.Write([]byte(`<html><body>` +
+				`<a href="` + r.URL.Path + `/1">1</a>` +
+				`<a href="` + r.URL.Path + `/2">2</a>` +
+				`<a href="` + r.URL.Path + `/3">3</a>` +
+				`</body></html>`))
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`<html><body>page</body></html>`))
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno-active-seeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	regexOutlinks = xurls.Relaxed()
+
+	c := newTestCrawl()
+	c.MaxActiveSeeds = 1
+	c.MaxHops = 1
+	c.Workers = 4
+	c.AllowedSchemes = []string{"http", "https"}
+	c.PagesPerHost = new(frontier.HostPool)
+	c.PagesPerHost.Mutex = new(sync.Mutex)
+	c.PagesPerHost.Hosts = make(map[string]*ratecounter.Counter)
+
+	err = c.Frontier.Init(jobPath, logInfo, logWarning, c.Workers, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Frontier.Dropped = c.releaseSeedTree
+	c.Frontier.Start()
+
+	c.WorkerPool = sizedwaitgroup.New(c.Workers)
+	for i := 0; i < c.Workers; i++ {
+		c.WorkerPool.Add()
+		go c.Worker(&c.WorkerPool, nil)
+	}
+
+	// The frontier is stopped like when the crawl finishes,
+	// so none of its goroutines outlive the test
+	defer func() {
+		c.Frontier.FinishingQueueReader.Set(true)
+		for c.Frontier.IsQueueReaderActive.Get() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(c.Frontier.PullChan)
+		c.WorkerPool.Wait()
+
+		c.Frontier.FinishingQueueWriter.Set(true)
+		close(c.Frontier.PushChan)
+		for c.Frontier.IsQueueWriterActive.Get() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.Frontier.Queue.Close()
+	}()
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		URL, _ := url.Parse(server.URL + path)
+		c.Frontier.PushChan <- frontier.NewItem(URL, nil, "seed", 0)
+	}
+
+	assert.Eventually(t, func() bool {
+		requestsMutex.Lock()
+		defer requestsMutex.Unlock()
+
+		return len(requests) == 12
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The trees are captured one after the other, in any order,
+	// as a tree is active until its queued outlinks are captured
+	requestsMutex.Lock()
+	defer requestsMutex.Unlock()
+
+	var trees []string
+	for _, path := range requests {
+		tree := strings.SplitN(path, "/", 3)[1]
+		if len(trees) == 0 || trees[len(trees)-1] != tree {
+			trees = append(trees, tree)
+		}
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, trees, "requests: %v", requests)
+
+	assert.Equal(t, 0, c.ActiveSeeds.Deferred())
+	assert.Eventually(t, func() bool {
+		return c.ActiveSeeds.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+}
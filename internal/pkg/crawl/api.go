@@ -3,6 +3,7 @@ package crawl
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/pprof"
@@ -10,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
 func (crawl *Crawl) startAPI() {
@@ -29,6 +31,141 @@ func (crawl *Crawl) startAPI() {
 			"crawled":      crawl.Crawled.Value(),
 			"queued":       crawl.Frontier.QueueCount.Value(),
 			"running_time": fmt.Sprintf("%s", time.Since(crawl.StartTime)),
+			"errors":       crawl.ErrorStats.Snapshot(),
+		})
+	})
+
+	r.GET("/hosts", func(c *gin.Context) {
+		c.JSON(200, crawl.Frontier.HostStats.Snapshot())
+	})
+
+	// The composition of the queue by host, as JSON or as CSV with
+	// ?format=csv, limited to the hosts with the most items with ?limit=
+	r.GET("/frontier/hosts", func(c *gin.Context) {
+		var limit int
+		if c.Query("limit") != "" {
+			var err error
+			limit, err = strconv.Atoi(c.Query("limit"))
+			if err != nil {
+				c.JSON(400, gin.H{
+					"error": "invalid limit parameter",
+				})
+				return
+			}
+		}
+
+		composition := crawl.queueComposition(limit)
+
+		switch c.DefaultQuery("format", "json") {
+		case "json":
+			c.JSON(200, composition)
+		case "csv":
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Status(200)
+			writeFrontierCompositionCSV(c.Writer, composition)
+		default:
+			c.JSON(400, gin.H{
+				"error": "invalid format parameter, expected json or csv",
+			})
+		}
+	})
+
+	r.GET("/workers", func(c *gin.Context) {
+		c.JSON(200, crawl.WorkerStates.Snapshot())
+	})
+
+	r.GET("/worker/:id", func(c *gin.Context) {
+		ID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "invalid worker ID",
+			})
+			return
+		}
+
+		state, ok := crawl.WorkerStates.Get(ID)
+		if !ok {
+			c.JSON(404, gin.H{
+				"error": "no such worker",
+			})
+			return
+		}
+
+		c.JSON(200, state)
+	})
+
+	r.POST("/workers/scale", func(c *gin.Context) {
+		count, err := strconv.Atoi(c.Query("count"))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "invalid count parameter",
+			})
+			return
+		}
+
+		err = crawl.ScaleWorkers(count)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"workers": crawl.GetWorkersCount(),
+		})
+	})
+
+	r.GET("/warc", func(c *gin.Context) {
+		if crawl.WARCPool == nil {
+			c.JSON(404, gin.H{
+				"error": "WARC writing is disabled",
+			})
+			return
+		}
+
+		c.JSON(200, crawl.WARCPool.Snapshot())
+	})
+
+	r.POST("/warc/scale", func(c *gin.Context) {
+		count, err := strconv.Atoi(c.Query("count"))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "invalid count parameter",
+			})
+			return
+		}
+
+		err = crawl.ScaleWARCWriters(count)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(200, crawl.WARCPool.Snapshot())
+	})
+
+	r.POST("/checkpoint", func(c *gin.Context) {
+		checkpointPath, err := crawl.Checkpoint()
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning("Unable to write checkpoint")
+
+			c.JSON(500, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		logInfo.WithFields(logrus.Fields{
+			"path": checkpointPath,
+		}).Info("Checkpoint written")
+
+		c.JSON(200, gin.H{
+			"path": checkpointPath,
 		})
 	})
 
@@ -1,25 +1,190 @@
 package crawl
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"net/url"
+	"regexp"
 	"strings"
 
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
 )
 
-func parseURLFromJSON(value interface{}) (URLs []string) {
+// maxBase64BlobSize is the maximum size of a base64 blob
+// that we try to decode to extract URLs from it
+const maxBase64BlobSize = 1 * MB
+
+var regexBase64 = regexp.MustCompile(`^[A-Za-z0-9+/]{16,}={0,2}$`)
+
+// DefaultLazyLoadAttributes are the attributes used by the most common
+// lazy-loading libraries to hold the URL of the asset to load
+var DefaultLazyLoadAttributes = []string{
+	"data-src",
+	"data-lazy-src",
+	"data-srcset",
+	"data-lazy-srcset",
+	"data-original",
+	"data-original-set",
+	"data-bg",
+	"data-background",
+	"data-background-image",
+	"data-lazy",
+	"data-lazyload",
+	"data-flickity-bg-lazyload",
+}
+
+// DefaultJSONDataAttributes are the data attributes in which the most
+// common widgets, sliders and players store their JSON configuration
+var DefaultJSONDataAttributes = []string{
+	"data-item",
+	"data-config",
+	"data-settings",
+	"data-options",
+	"data-props",
+	"data-setup",
+}
+
+// DefaultMaxJSONDepth is the default number of levels
+// of nested JSON objects searched for URLs
+const DefaultMaxJSONDepth = 64
+
+// regexCSSURL match the url() functions of CSS declarations, with
+// the URL either single-quoted, double-quoted or unquoted
+var regexCSSURL = regexp.MustCompile(`(?i)url\(\s*(?:'([^']*)'|"([^"]*)"|([^'"()\s]+))\s*\)`)
+
+// extractURLsFromCSS return the URLs of all the url() functions of a piece of
+// CSS, whatever the property using them (background, mask, border-image..)
+func extractURLsFromCSS(CSS string) (URLs []string) {
+	for _, match := range regexCSSURL.FindAllStringSubmatch(CSS, -1) {
+		URL := strings.TrimSpace(match[1] + match[2] + match[3])
+
+		// Inline data and references to elements of the document
+		// like url(#mask) aren't URLs to capture
+		if URL == "" || strings.HasPrefix(URL, "#") || strings.HasPrefix(strings.ToLower(URL), "data:") {
+			continue
+		}
+
+		URLs = append(URLs, URL)
+	}
+
+	return URLs
+}
+
+// parseSrcset return the URLs of a srcset attribute, that is a comma-separated
+// list of URLs each optionally followed by a width or density descriptor
+func parseSrcset(srcset string) (URLs []string) {
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			URLs = append(URLs, fields[0])
+		}
+	}
+
+	return URLs
+}
+
+// extractLazyLoadedURLs return the URLs held by the lazy-loading
+// attributes of an element, whatever its tag
+func extractLazyLoadedURLs(item *goquery.Selection, attributes []string) (URLs []string) {
+	for _, attribute := range attributes {
+		value, exists := item.Attr(attribute)
+		if !exists || strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(attribute, "srcset") || strings.HasSuffix(attribute, "-set"):
+			URLs = append(URLs, parseSrcset(value)...)
+		case strings.Contains(value, "url("):
+			// Some libraries put a CSS background value in the attribute
+			URLs = append(URLs, extractURLsFromCSS(value)...)
+		default:
+			URLs = append(URLs, strings.TrimSpace(value))
+		}
+	}
+
+	return URLs
+}
+
+// extractJSONDataURLs return the URLs found in the JSON objects held by
+// the given data attributes of an element, the attributes that don't
+// hold a JSON object are ignored
+func extractJSONDataURLs(item *goquery.Selection, attributes []string, maxDepth int) (URLs []string) {
+	for _, attribute := range attributes {
+		value, exists := item.Attr(attribute)
+		if !exists || !strings.HasPrefix(strings.TrimSpace(value), "{") {
+			continue
+		}
+
+		var result map[string]interface{}
+		err := json.Unmarshal([]byte(value), &result)
+		if err != nil {
+			continue
+		}
+
+		URLs = append(URLs, parseURLFromJSON(result, maxDepth)...)
+	}
+
+	return URLs
+}
+
+// parseURLFromBase64 decode a string if it looks like a base64-encoded JSON
+// blob, and return the URLs found in the decoded JSON, up to maxDepth levels
+func parseURLFromBase64(value string, maxDepth int) (URLs []string) {
+	value = strings.TrimSpace(value)
+	if len(value) > maxBase64BlobSize || !regexBase64.MatchString(value) {
+		return nil
+	}
+
+	var encoding = base64.StdEncoding
+	if !strings.HasSuffix(value, "=") && len(value)%4 != 0 {
+		encoding = base64.RawStdEncoding
+	}
+
+	decoded, err := encoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+
+	var result map[string]interface{}
+	err = json.Unmarshal(decoded, &result)
+	if err != nil {
+		return nil
+	}
+
+	return parseURLFromJSON(result, maxDepth)
+}
+
+// jsonDepth return the maximum depth of the JSON
+// objects searched for URLs, with a default of DefaultMaxJSONDepth
+func (c *Crawl) jsonDepth() int {
+	if c.MaxJSONDepth <= 0 {
+		return DefaultMaxJSONDepth
+	}
+	return c.MaxJSONDepth
+}
+
+// parseURLFromJSON return the strings starting with http in a decoded JSON
+// object and its nested objects, the objects nested deeper than maxDepth
+// levels are ignored so that pathological inputs can't recurse endlessly
+func parseURLFromJSON(value interface{}, maxDepth int) (URLs []string) {
+	if maxDepth <= 0 {
+		return nil
+	}
+
 	switch JSON := value.(type) {
 	case map[string]interface{}:
 		for _, v := range JSON {
 			switch vChild := v.(type) {
 			case string:
-				if strings.HasPrefix(vChild, "http") {
+				if strings.HasPrefix(vChild, "http") || utils.IsProtocolRelativeURL(vChild) {
 					URLs = append(URLs, vChild)
 				}
 			case map[string]interface{}:
-				URLs = append(URLs, parseURLFromJSON(vChild)...)
+				URLs = append(URLs, parseURLFromJSON(vChild, maxDepth-1)...)
 			}
 		}
 	default:
@@ -28,6 +193,77 @@ func parseURLFromJSON(value interface{}) (URLs []string) {
 	return URLs
 }
 
+// isPreloadRelation return true if the rel attribute of a <link> tag
+// declares a resource to preload, prefetch or modulepreload
+func isPreloadRelation(relation string) bool {
+	for _, value := range strings.Fields(strings.ToLower(relation)) {
+		if value == "preload" || value == "prefetch" || value == "modulepreload" {
+			return true
+		}
+	}
+	return false
+}
+
+// isAlternateRelation return true if the rel attribute of a <link> tag
+// declares an alternate version of the page: rel="alternate" is used for
+// translations, feeds and mobile versions (with a media attribute), and
+// rel="amphtml" for the AMP version of the page
+func isAlternateRelation(relation string) bool {
+	for _, value := range strings.Fields(strings.ToLower(relation)) {
+		if value == "alternate" || value == "amphtml" {
+			return true
+		}
+	}
+	return false
+}
+
+// metaMediaProperties are the OpenGraph and Twitter card properties of
+// the <meta> tags whose content is a media of the page, like its preview
+var metaMediaProperties = []string{
+	"og:image", "og:image:url", "og:image:secure_url",
+	"og:video", "og:video:url", "og:video:secure_url",
+	"og:audio", "og:audio:url", "og:audio:secure_url",
+	"twitter:image", "twitter:image:src", "twitter:player:stream",
+}
+
+// isMetaMedia return true if a <meta> tag is an OpenGraph or Twitter card
+// media, OpenGraph uses the property attribute and Twitter the name one,
+// but both are found in the wild
+func isMetaMedia(item *goquery.Selection) bool {
+	for _, attribute := range []string{"property", "name"} {
+		value, exists := item.Attr(attribute)
+		if exists && utils.StringInSlice(strings.ToLower(strings.TrimSpace(value)), metaMediaProperties) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCrossOriginAssets drop the assets that don't have the same scheme
+// and host as the seed the item comes from, except the ones on hosts
+// explicitly allowed with --cross-origin-assets-host
+func (c *Crawl) filterCrossOriginAssets(item *frontier.Item, assets []url.URL) (filtered []url.URL) {
+	var seed = item
+	for seed.ParentItem != nil {
+		seed = seed.ParentItem
+	}
+
+	for _, asset := range assets {
+		if (asset.Scheme == seed.URL.Scheme && asset.Host == seed.URL.Host) ||
+			utils.IsHostExcluded(asset.Host, c.CrossOriginAssetsHosts) {
+			filtered = append(filtered, asset)
+			continue
+		}
+
+		logInfo.WithFields(logrus.Fields{
+			"url":  asset.String(),
+			"seed": seed.URL.String(),
+		}).Debug("Cross-origin asset, skipping")
+	}
+
+	return filtered
+}
+
 func (c *Crawl) extractAssets(base *url.URL, doc *goquery.Document) (assets []url.URL, err error) {
 	var rawAssets []string
 
@@ -41,6 +277,22 @@ func (c *Crawl) extractAssets(base *url.URL, doc *goquery.Document) (assets []ur
 		})
 	}
 
+	// Image submit buttons display an image like img does, so
+	// disabling either input or img disables them
+	if !utils.StringInSlice("input", c.DisabledHTMLTags) && !utils.StringInSlice("img", c.DisabledHTMLTags) {
+		doc.Find("input").Each(func(index int, item *goquery.Selection) {
+			inputType, _ := item.Attr("type")
+			if !strings.EqualFold(strings.TrimSpace(inputType), "image") {
+				return
+			}
+
+			link, exists := item.Attr("src")
+			if exists {
+				rawAssets = append(rawAssets, link)
+			}
+		})
+	}
+
 	if !utils.StringInSlice("video", c.DisabledHTMLTags) {
 		doc.Find("video").Each(func(index int, item *goquery.Selection) {
 			link, exists := item.Attr("src")
@@ -65,38 +317,64 @@ func (c *Crawl) extractAssets(base *url.URL, doc *goquery.Document) (assets []ur
 
 					// Unmarshal or Decode the JSON to the interface.
 					json.Unmarshal([]byte(item.Text()), &result)
-					rawAssets = append(rawAssets, parseURLFromJSON(result)...)
+					rawAssets = append(rawAssets, parseURLFromJSON(result, c.jsonDepth())...)
 					return
 				}
 			}
 
+			// Some scripts only contain a base64-encoded JSON configuration
+			base64Links := parseURLFromBase64(item.Text(), c.jsonDepth())
+			if len(base64Links) > 0 {
+				rawAssets = append(rawAssets, base64Links...)
+				return
+			}
+
 			// Apply regex on the script's HTML to extract potential assets
 			outerHTML, err := goquery.OuterHtml(item)
 			if err != nil {
 				logWarning.Warning(err)
 			} else {
-				scriptLinks := utils.DedupeStrings(regexOutlinks.FindAllString(outerHTML, -1))
-				for _, scriptLink := range scriptLinks {
-					if strings.HasPrefix(scriptLink, "http") {
-						rawAssets = append(rawAssets, scriptLink)
-					}
-				}
+				rawAssets = append(rawAssets, extractScriptURLs(outerHTML)...)
 			}
 		})
 	}
 
 	if !utils.StringInSlice("link", c.DisabledHTMLTags) {
 		doc.Find("link").Each(func(index int, item *goquery.Selection) {
-			if !c.CaptureAlternatePages {
-				relation, exists := item.Attr("rel")
-				if exists && relation == "alternate" {
-					return
+			relation, _ := item.Attr("rel")
+
+			// Responsive images are preloaded with the candidates of
+			// their srcset in imagesrcset, the href is then optional
+			if isPreloadRelation(relation) {
+				if imageSrcset, exists := item.Attr("imagesrcset"); exists {
+					rawAssets = append(rawAssets, parseSrcset(imageSrcset)...)
 				}
 			}
+
 			link, exists := item.Attr("href")
-			if exists {
+			if !exists {
+				return
+			}
+
+			// Preloaded and prefetched resources are assets that the page
+			// will use, so they are always captured
+			if isPreloadRelation(relation) {
 				rawAssets = append(rawAssets, link)
+				return
 			}
+
+			// Alternate versions of the page, like its translations, its
+			// mobile version or its AMP version, are only captured if asked
+			if isAlternateRelation(relation) && !c.CaptureAlternatePages {
+				return
+			}
+
+			// The canonical URL is a page, not an asset, it is handled
+			// separately and can be followed as an outlink
+			if strings.EqualFold(strings.TrimSpace(relation), "canonical") {
+				return
+			}
+			rawAssets = append(rawAssets, link)
 		})
 	}
 
@@ -117,13 +395,29 @@ func (c *Crawl) extractAssets(base *url.URL, doc *goquery.Document) (assets []ur
 			}
 			link, exists = item.Attr("content")
 			if exists {
-				if strings.Contains(link, "http") {
+				// The OpenGraph and Twitter card medias are captured even if
+				// their URL is relative, the other contents only if absolute
+				if isMetaMedia(item) {
+					if link = strings.TrimSpace(link); link != "" {
+						rawAssets = append(rawAssets, link)
+					}
+				} else if strings.Contains(link, "http") {
 					rawAssets = append(rawAssets, link)
 				}
 			}
 		})
 	}
 
+	// Links with a download attribute point to files, not pages
+	if !utils.StringInSlice("a", c.DisabledHTMLTags) {
+		doc.Find("a[download]").Each(func(index int, item *goquery.Selection) {
+			link, exists := item.Attr("href")
+			if exists {
+				rawAssets = append(rawAssets, link)
+			}
+		})
+	}
+
 	if !utils.StringInSlice("source", c.DisabledHTMLTags) {
 		doc.Find("source").Each(func(index int, item *goquery.Selection) {
 			link, exists := item.Attr("src")
@@ -133,6 +427,32 @@ func (c *Crawl) extractAssets(base *url.URL, doc *goquery.Document) (assets []ur
 		})
 	}
 
+	// Extract the URLs used in inline styles, like background or mask images
+	if !utils.StringInSlice("style", c.DisabledHTMLTags) {
+		doc.Find("[style]").Each(func(index int, item *goquery.Selection) {
+			style, _ := item.Attr("style")
+			rawAssets = append(rawAssets, extractURLsFromCSS(style)...)
+		})
+	}
+
+	// Extract assets using the user-defined selector rules
+	rawAssets = append(rawAssets, extractAssetsFromSelectorRules(doc, c.AssetSelectorRules)...)
+
+	// Extract URLs from the lazy-loading attributes, from the JSON
+	// configurations and from the base64-encoded JSON blobs in data attributes
+	doc.Find("*").Each(func(index int, item *goquery.Selection) {
+		rawAssets = append(rawAssets, extractLazyLoadedURLs(item, c.LazyLoadAttributes)...)
+		rawAssets = append(rawAssets, extractJSONDataURLs(item, c.JSONDataAttributes, c.jsonDepth())...)
+
+		for _, node := range item.Nodes {
+			for _, attribute := range node.Attr {
+				if strings.HasPrefix(attribute.Key, "data-") {
+					rawAssets = append(rawAssets, parseURLFromBase64(attribute.Val, c.jsonDepth())...)
+				}
+			}
+		}
+	})
+
 	// Turn strings into url.URL
 	assets = utils.StringSliceToURLSlice(rawAssets)
 
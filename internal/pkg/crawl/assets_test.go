@@ -0,0 +1,327 @@
+package crawl
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"mvdan.cc/xurls/v2"
+)
+
+// extractTestAssets run the assets extraction on a HTML string and return
+// the extracted assets as strings
+func extractTestAssets(t *testing.T, c *Crawl, html string) (assets []string) {
+	regexOutlinks = xurls.Relaxed()
+
+	base, _ := url.Parse("https://example.com/page")
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	URLs, err := c.extractAssets(base, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, URL := range URLs {
+		assets = append(assets, URL.String())
+	}
+
+	return assets
+}
+
+func TestExtractAssetsPreloadLinks(t *testing.T) {
+	for _, relation := range []string{"preload", "prefetch", "modulepreload"} {
+		html := `<html><head><link rel="` + relation + `" as="script" href="/app.js"></head></html>`
+
+		// Preload links must be captured even if alternate pages aren't
+		c := new(Crawl)
+		c.CaptureAlternatePages = false
+
+		assets := extractTestAssets(t, c, html)
+		assert.Contains(t, assets, "https://example.com/app.js", relation)
+	}
+}
+
+func TestExtractAssetsPreloadImageSrcset(t *testing.T) {
+	html := `<html><head>
+		<link rel="preload" as="image" href="/hero-800.jpg" imagesrcset="/hero-400.jpg 400w, /hero-800.jpg 800w, https://cdn.example.com/hero-1600.jpg 1600w" imagesizes="100vw">
+		<link rel="preload" as="image" imagesrcset="/banner.webp 1x, /banner@2x.webp 2x">
+	</head></html>`
+
+	assets := extractTestAssets(t, new(Crawl), html)
+	assert.Contains(t, assets, "https://example.com/hero-400.jpg")
+	assert.Contains(t, assets, "https://example.com/hero-800.jpg")
+	assert.Contains(t, assets, "https://cdn.example.com/hero-1600.jpg")
+	assert.Contains(t, assets, "https://example.com/banner.webp")
+	assert.Contains(t, assets, "https://example.com/banner@2x.webp")
+}
+
+func TestExtractAssetsAlternateLinks(t *testing.T) {
+	html := `<html><head><link rel="alternate" href="/fr/page"></head></html>`
+
+	c := new(Crawl)
+	assert.NotContains(t, extractTestAssets(t, c, html), "https://example.com/fr/page")
+
+	c.CaptureAlternatePages = true
+	assert.Contains(t, extractTestAssets(t, c, html), "https://example.com/fr/page")
+}
+
+func TestExtractAssetsBase64JSON(t *testing.T) {
+	blob := base64.StdEncoding.EncodeToString([]byte(`{"config":{"logo":"https://cdn.example.com/logo.png"}}`))
+	html := `<html><body><div data-state="` + blob + `"></div><script>` + blob + `</script></body></html>`
+
+	assets := extractTestAssets(t, new(Crawl), html)
+	assert.Contains(t, assets, "https://cdn.example.com/logo.png")
+}
+
+func TestParseURLFromBase64(t *testing.T) {
+	// Not base64
+	assert.Empty(t, parseURLFromBase64("this is not base64 at all", 64))
+
+	// Base64, but not JSON
+	assert.Empty(t, parseURLFromBase64(base64.StdEncoding.EncodeToString([]byte("just some plain text here")), 64))
+
+	// Base64-encoded JSON without padding
+	blob := base64.RawStdEncoding.EncodeToString([]byte(`{"url":"https://example.com/a"}`))
+	assert.Equal(t, []string{"https://example.com/a"}, parseURLFromBase64(blob, 64))
+}
+
+func TestParseURLFromJSONMaxDepth(t *testing.T) {
+	// Build {"url": "https://example.com/0", "child": {"url": ".../1", "child": ...}}
+	var JSON = map[string]interface{}{}
+	var current = JSON
+	for i := 0; i < 10000; i++ {
+		current["url"] = "https://example.com/" + strconv.Itoa(i)
+		child := map[string]interface{}{}
+		current["child"] = child
+		current = child
+	}
+
+	URLs := parseURLFromJSON(JSON, 3)
+	assert.ElementsMatch(t, []string{"https://example.com/0", "https://example.com/1", "https://example.com/2"}, URLs)
+
+	assert.Len(t, parseURLFromJSON(JSON, DefaultMaxJSONDepth), DefaultMaxJSONDepth)
+	assert.Empty(t, parseURLFromJSON(JSON, 0))
+}
+
+func TestFilterCrossOriginAssets(t *testing.T) {
+	logInfo = logrus.New()
+
+	seedURL, _ := url.Parse("https://example.com/")
+	pageURL, _ := url.Parse("https://example.com/page")
+	seed := frontier.NewItem(seedURL, nil, "seed", 0)
+	page := frontier.NewItem(pageURL, seed, "seed", 1)
+
+	var assets []url.URL
+	for _, rawURL := range []string{
+		"https://example.com/style.css",
+		"http://example.com/insecure.js",
+		"https://fonts.example.net/font.woff",
+		"https://cdn.example.org/app.js",
+	} {
+		asset, _ := url.Parse(rawURL)
+		assets = append(assets, *asset)
+	}
+
+	c := new(Crawl)
+	c.CrossOriginAssetsHosts = []string{"cdn.example.org"}
+
+	var filtered []string
+	for _, asset := range c.filterCrossOriginAssets(page, assets) {
+		filtered = append(filtered, asset.String())
+	}
+
+	assert.Equal(t, []string{"https://example.com/style.css", "https://cdn.example.org/app.js"}, filtered)
+}
+
+func TestExtractURLsFromCSS(t *testing.T) {
+	URLs := extractURLsFromCSS(`mask-image: url("/mask.svg"); -webkit-mask: url( '/webkit-mask.svg' ) no-repeat;` +
+		`border-image-source: URL(/border.png); background: url(/a.png), url("/b.png") , url(data:image/png;base64,AAAA);` +
+		`mask: url(#svgmask)`)
+
+	assert.Equal(t, []string{"/mask.svg", "/webkit-mask.svg", "/border.png", "/a.png", "/b.png"}, URLs)
+}
+
+func TestExtractAssetsStyleAttribute(t *testing.T) {
+	html := `<html><body>
+		<div style="mask-image: url('/mask.svg'); border-image: url(/border.png) 30 round"></div>
+		<div style="background: url(/one.png) no-repeat, url(&quot;/two.png&quot;) repeat-x"></div>
+	</body></html>`
+
+	c := new(Crawl)
+	assets := extractTestAssets(t, c, html)
+	for _, asset := range []string{"mask.svg", "border.png", "one.png", "two.png"} {
+		assert.Contains(t, assets, "https://example.com/"+asset)
+	}
+
+	c.DisabledHTMLTags = []string{"style"}
+	assert.NotContains(t, extractTestAssets(t, c, html), "https://example.com/mask.svg")
+}
+
+func TestExtractAssetsImageInput(t *testing.T) {
+	html := `<html><body><form action="/search">
+		<input type="image" src="/submit.png" alt="Search">
+		<input type="IMAGE" src="/upper.png">
+		<input type="text" src="/text.png">
+	</form></body></html>`
+
+	c := new(Crawl)
+	assets := extractTestAssets(t, c, html)
+	assert.Contains(t, assets, "https://example.com/submit.png")
+	assert.Contains(t, assets, "https://example.com/upper.png")
+	assert.NotContains(t, assets, "https://example.com/text.png")
+
+	for _, tag := range []string{"input", "img"} {
+		c.DisabledHTMLTags = []string{tag}
+		assert.NotContains(t, extractTestAssets(t, c, html), "https://example.com/submit.png")
+	}
+}
+
+func TestExtractAssetsProtocolRelative(t *testing.T) {
+	html := `<html><head>
+		<script src="//static.example.org/lib.js"></script>
+		<script>
+			// Load the widget
+			var widget = "//widgets.example.net/widget.js";
+			var absolute = "http://absolute.example.com/app.js";
+		</script>
+		<script type="application/json">{"image": "//images.example.com/cover.jpg"}</script>
+	</head><body><img src="//cdn.example.com/logo.png"></body></html>`
+
+	for _, scheme := range []string{"http", "https"} {
+		regexOutlinks = xurls.Relaxed()
+		base, _ := url.Parse(scheme + "://example.com/page")
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		URLs, err := new(Crawl).extractAssets(base, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var assets []string
+		for _, URL := range URLs {
+			assets = append(assets, URL.String())
+		}
+
+		// The protocol-relative URLs take the scheme of the page
+		for _, asset := range []string{"static.example.org/lib.js", "widgets.example.net/widget.js", "images.example.com/cover.jpg", "cdn.example.com/logo.png"} {
+			assert.Contains(t, assets, scheme+"://"+asset)
+		}
+		assert.Contains(t, assets, "http://absolute.example.com/app.js")
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	URLs := parseSrcset("/small.jpg 480w, /medium.jpg 800w,/large.jpg 2x, /plain.jpg")
+
+	assert.Equal(t, []string{"/small.jpg", "/medium.jpg", "/large.jpg", "/plain.jpg"}, URLs)
+}
+
+func TestExtractAssetsLazyLoadAttributes(t *testing.T) {
+	html := `<html><body>
+		<img src="/placeholder.gif" data-src="/lazy.jpg" data-srcset="/lazy-1x.jpg 1x, /lazy-2x.jpg 2x">
+		<div data-original="/original.jpg"></div>
+		<section data-bg="url('/background.jpg')"></section>
+		<div class="carousel-cell" data-flickity-bg-lazyload="/slide.jpg"></div>
+		<div data-custom-lazy="/custom.jpg"></div>
+	</body></html>`
+
+	c := new(Crawl)
+	c.LazyLoadAttributes = DefaultLazyLoadAttributes
+	assets := extractTestAssets(t, c, html)
+	for _, asset := range []string{"lazy.jpg", "lazy-1x.jpg", "lazy-2x.jpg", "original.jpg", "background.jpg", "slide.jpg"} {
+		assert.Contains(t, assets, "https://example.com/"+asset)
+	}
+	assert.NotContains(t, assets, "https://example.com/custom.jpg")
+
+	c.LazyLoadAttributes = []string{"data-custom-lazy"}
+	assets = extractTestAssets(t, c, html)
+	assert.Contains(t, assets, "https://example.com/custom.jpg")
+	assert.NotContains(t, assets, "https://example.com/lazy.jpg")
+}
+
+func TestExtractAssetsJSONDataAttributes(t *testing.T) {
+	html := `<html><body>
+		<div class="slider" data-settings='{"autoplay":true,"slides":{"first":"https://cdn.example.com/slide-1.jpg"}}'></div>
+		<video-js data-setup="{&quot;poster&quot;:&quot;//cdn.example.com/poster.jpg&quot;,&quot;fluid&quot;:true}"></video-js>
+		<div data-config="not json https://example.com/ignored.jpg"></div>
+		<div data-widget='{"image":"https://example.com/widget.jpg"}'></div>
+	</body></html>`
+
+	c := new(Crawl)
+	c.JSONDataAttributes = DefaultJSONDataAttributes
+	assets := extractTestAssets(t, c, html)
+	assert.Contains(t, assets, "https://cdn.example.com/slide-1.jpg")
+	assert.Contains(t, assets, "https://cdn.example.com/poster.jpg")
+	assert.NotContains(t, assets, "https://example.com/ignored.jpg")
+	assert.NotContains(t, assets, "https://example.com/widget.jpg")
+
+	c.JSONDataAttributes = []string{"data-widget"}
+	assets = extractTestAssets(t, c, html)
+	assert.Contains(t, assets, "https://example.com/widget.jpg")
+	assert.NotContains(t, assets, "https://cdn.example.com/slide-1.jpg")
+}
+
+func TestExtractAssetsMetaMedia(t *testing.T) {
+	html := `<html><head>
+		<meta property="og:url" content="https://example.com/page">
+		<meta property="og:image" content="/images/preview.jpg">
+		<meta property="og:image:secure_url" content="//cdn.example.com/preview.jpg">
+		<meta property="OG:Video" content=" videos/clip.mp4 ">
+		<meta name="twitter:image" content="https://cdn.example.com/card.jpg">
+		<meta property="twitter:image:src" content="/images/card-large.jpg">
+		<meta property="og:image" content="">
+		<meta property="og:title" content="/not/an/asset">
+		<meta name="description" content="A page">
+	</head></html>`
+
+	assets := extractTestAssets(t, new(Crawl), html)
+	assert.Contains(t, assets, "https://example.com/images/preview.jpg")
+	assert.Contains(t, assets, "https://cdn.example.com/preview.jpg")
+	assert.Contains(t, assets, "https://example.com/videos/clip.mp4")
+	assert.Contains(t, assets, "https://cdn.example.com/card.jpg")
+	assert.Contains(t, assets, "https://example.com/images/card-large.jpg")
+
+	// The absolute URLs of the other tags are still captured, not the
+	// relative ones that may just be text
+	assert.Contains(t, assets, "https://example.com/page")
+	assert.NotContains(t, assets, "https://example.com/not/an/asset")
+
+	// The meta tags can be disabled like the other tags
+	c := new(Crawl)
+	c.DisabledHTMLTags = []string{"meta"}
+	assert.NotContains(t, extractTestAssets(t, c, html), "https://example.com/images/preview.jpg")
+}
+
+func TestExtractAssetsPageVariants(t *testing.T) {
+	html := `<html><head>
+		<link rel="amphtml" href="/amp/page">
+		<link rel="alternate" media="only screen and (max-width: 640px)" href="https://m.example.com/page">
+		<link rel="Alternate nofollow" hreflang="de" href="/de/page">
+		<link rel="stylesheet" href="/style.css">
+	</head></html>`
+
+	c := new(Crawl)
+	assets := extractTestAssets(t, c, html)
+	assert.Contains(t, assets, "https://example.com/style.css")
+	for _, variant := range []string{"https://example.com/amp/page", "https://m.example.com/page", "https://example.com/de/page"} {
+		assert.NotContains(t, assets, variant)
+	}
+
+	c.CaptureAlternatePages = true
+	assets = extractTestAssets(t, c, html)
+	for _, variant := range []string{"https://example.com/amp/page", "https://m.example.com/page", "https://example.com/de/page"} {
+		assert.Contains(t, assets, variant)
+	}
+}
@@ -0,0 +1,119 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hostCredential is the Authorization header sent to a host, it is never
+// printed as is so the credentials don't end up in the logs
+type hostCredential struct {
+	Scheme string
+	Value  string
+}
+
+// String return the scheme of the credential with its value redacted
+func (credential hostCredential) String() string {
+	return credential.Scheme + " [REDACTED]"
+}
+
+// header return the value of the Authorization header
+func (credential hostCredential) header() string {
+	return credential.Scheme + " " + credential.Value
+}
+
+// readHostCredentials parse a credentials file, it has one host per line
+// followed by "basic user:password" or "bearer token", lines starting with
+// # are comments. Hosts are matched exactly, with their port if they have
+// one, so the credentials aren't sent to their subdomains.
+func readHostCredentials(path string) (credentials map[string]hostCredential, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	credentials = make(map[string]hostCredential)
+
+	var line int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line++
+
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		// The errors don't include the entry, as it holds the credentials
+		fields := strings.Fields(entry)
+		if len(fields) != 3 {
+			return nil, errors.New("invalid credentials on line " + strconv.Itoa(line) + ", expected: host basic|bearer credentials")
+		}
+
+		host := strings.ToLower(fields[0])
+		switch strings.ToLower(fields[1]) {
+		case "basic":
+			if !strings.Contains(fields[2], ":") {
+				return nil, errors.New("invalid basic credentials on line " + strconv.Itoa(line) + ", expected: user:password")
+			}
+			credentials[host] = hostCredential{
+				Scheme: "Basic",
+				Value:  base64.StdEncoding.EncodeToString([]byte(fields[2])),
+			}
+		case "bearer":
+			credentials[host] = hostCredential{
+				Scheme: "Bearer",
+				Value:  fields[2],
+			}
+		default:
+			return nil, errors.New("unknown authentication scheme on line " + strconv.Itoa(line) + ", expected basic or bearer")
+		}
+	}
+
+	return credentials, scanner.Err()
+}
+
+// redactAuthorization return the request to dump in the WARC, if it
+// carries credentials of --auth-file they are replaced by their scheme
+// followed by [REDACTED], so the WARC files don't hold them in plaintext
+func (c *Crawl) redactAuthorization(req *http.Request) *http.Request {
+	authorization := req.Header.Get("Authorization")
+	if len(c.HostCredentials) == 0 || authorization == "" {
+		return req
+	}
+
+	for _, credential := range c.HostCredentials {
+		if authorization == credential.header() {
+			redacted := req.Clone(req.Context())
+			redacted.Header.Set("Authorization", credential.String())
+			return redacted
+		}
+	}
+
+	return req
+}
+
+// setAuthorization set the Authorization header of a request to the
+// credentials of its host, if there are some and if the request doesn't
+// already have an Authorization header. It is done for every request,
+// so a redirection to another host doesn't carry the credentials over.
+func (c *Crawl) setAuthorization(req *http.Request) {
+	if len(c.HostCredentials) == 0 || req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	credential, ok := c.HostCredentials[strings.ToLower(req.URL.Host)]
+	if !ok {
+		credential, ok = c.HostCredentials[strings.ToLower(req.URL.Hostname())]
+	}
+
+	if ok {
+		req.Header.Set("Authorization", credential.header())
+	}
+}
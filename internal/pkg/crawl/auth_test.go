@@ -0,0 +1,125 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadHostCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zeno-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	authPath := path.Join(dir, "auth.txt")
+	err = ioutil.WriteFile(authPath, []byte("# Partners\nExample.com basic user:secret\n\napi.example.org:8443 bearer token123\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := readHostCredentials(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, credentials, 2)
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", credentials["example.com"].header())
+	assert.Equal(t, "Bearer token123", credentials["api.example.org:8443"].header())
+
+	// The credentials are never printed
+	assert.Equal(t, "Bearer [REDACTED]", fmt.Sprint(credentials["api.example.org:8443"]))
+	assert.NotContains(t, fmt.Sprintf("%v", credentials), "token123")
+
+	// Invalid entries are rejected without echoing them
+	err = ioutil.WriteFile(authPath, []byte("example.com digest hunter2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readHostCredentials(authPath)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "hunter2")
+}
+
+func TestCaptureSendsHostCredentials(t *testing.T) {
+	var authorizations = make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations[r.URL.Path] = r.Header.Get("Authorization")
+		if r.URL.Path == "/private" {
+			// Redirect to the same server under another host name
+			http.Redirect(w, r, "http://localhost:"+r.Host[len("127.0.0.1:"):]+"/public", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	c := newTestCrawl()
+	c.MaxRedirect = 1
+	c.HostCredentials = map[string]hostCredential{
+		serverURL.Host: {Scheme: "Bearer", Value: "token123"},
+	}
+
+	URL, _ := url.Parse(server.URL + "/private")
+	c.Capture(frontier.NewItem(URL, nil, "seed", 0))
+
+	// The credentials aren't sent after a redirection to another host
+	assert.Equal(t, "Bearer token123", authorizations["/private"])
+	assert.Contains(t, authorizations, "/public")
+	assert.Equal(t, "", authorizations["/public"])
+}
+
+func TestWARCRedactsHostCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	c := newTestCrawl()
+	c.WARC = true
+	c.WARCWriter = make(chan *warc.RecordBatch)
+	c.HostCredentials = map[string]hostCredential{
+		serverURL.Host: {Scheme: "Basic", Value: "dXNlcjpwYXNzd29yZA=="},
+	}
+
+	var requests []string
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				if record.Header.Get("WARC-Type") == "request" {
+					content, _ := ioutil.ReadAll(record.Content)
+					requests = append(requests, string(content))
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	c.Capture(frontier.NewItem(serverURL, nil, "seed", 0))
+	close(c.WARCWriter)
+	<-done
+
+	// The credentials are sent, but not written in the WARC
+	if assert.Len(t, requests, 1) {
+		assert.Contains(t, requests[0], "Authorization: Basic [REDACTED]")
+		assert.NotContains(t, requests[0], "dXNlcjpwYXNzd29yZA==")
+	}
+}
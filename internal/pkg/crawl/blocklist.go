@@ -0,0 +1,162 @@
+package crawl
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// blocklistReloadInterval is the interval at which
+// the blocklist file is checked for changes
+const blocklistReloadInterval = 30 * time.Second
+
+// blocklist holds the hosts and URL prefixes read from the --blocklist-file,
+// the entries are swapped as a whole when the file changes
+type blocklist struct {
+	sync.RWMutex
+	path        string
+	modTime     time.Time
+	hosts       []string
+	URLPrefixes []string
+}
+
+// newBlocklist read the blocklist file at the given path
+func newBlocklist(path string) (*blocklist, error) {
+	list := &blocklist{path: path}
+
+	_, err := list.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// readBlocklist parse a blocklist file, it has one entry per line, the
+// entries starting with http:// or https:// are URL prefixes and the
+// others are hosts, excluded with their subdomains, lines starting
+// with # are comments
+func readBlocklist(path string) (hosts, URLPrefixes []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		lowerEntry := strings.ToLower(entry)
+		if strings.HasPrefix(lowerEntry, "http://") || strings.HasPrefix(lowerEntry, "https://") {
+			URLPrefixes = append(URLPrefixes, entry)
+		} else {
+			hosts = append(hosts, lowerEntry)
+		}
+	}
+
+	return hosts, URLPrefixes, scanner.Err()
+}
+
+// reload read the blocklist file again if it was modified since it was
+// last read, it returns true if the entries were replaced
+func (list *blocklist) reload() (bool, error) {
+	info, err := os.Stat(list.path)
+	if err != nil {
+		return false, err
+	}
+
+	list.RLock()
+	unchanged := info.ModTime().Equal(list.modTime)
+	list.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	hosts, URLPrefixes, err := readBlocklist(list.path)
+	if err != nil {
+		return false, err
+	}
+
+	list.Lock()
+	list.modTime = info.ModTime()
+	list.hosts = hosts
+	list.URLPrefixes = URLPrefixes
+	list.Unlock()
+
+	return true, nil
+}
+
+// isBlocked return true if the URL's host or one of its
+// parent domains, or the beginning of the URL, is blocklisted
+func (list *blocklist) isBlocked(URL *url.URL) bool {
+	list.RLock()
+	defer list.RUnlock()
+
+	if utils.IsHostExcluded(strings.ToLower(URL.Hostname()), list.hosts) {
+		return true
+	}
+
+	if len(list.URLPrefixes) > 0 {
+		rawURL := URL.String()
+		for _, prefix := range list.URLPrefixes {
+			if strings.HasPrefix(rawURL, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isBlocklisted return true if the URL is excluded by the
+// --blocklist-file, it is always false without a blocklist
+func (c *Crawl) isBlocklisted(URL *url.URL) bool {
+	if c.Blocklist == nil {
+		return false
+	}
+
+	if c.Blocklist.isBlocked(URL) {
+		logInfo.WithFields(logrus.Fields{
+			"url": URL.String(),
+		}).Debug("URL is blocklisted, skipping")
+		return true
+	}
+
+	return false
+}
+
+// blocklistReloader periodically reload the blocklist file if it changed,
+// so hosts and URLs can be excluded without restarting the crawl
+func (c *Crawl) blocklistReloader() {
+	for !c.Finished.Get() {
+		time.Sleep(blocklistReloadInterval)
+
+		reloaded, err := c.Blocklist.reload()
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+				"file":  c.BlocklistFile,
+			}).Warning("Unable to reload the blocklist, keeping the previous one")
+			continue
+		}
+
+		if reloaded {
+			c.Blocklist.RLock()
+			logInfo.WithFields(logrus.Fields{
+				"hosts": len(c.Blocklist.hosts),
+				"urls":  len(c.Blocklist.URLPrefixes),
+			}).Info("Blocklist reloaded")
+			c.Blocklist.RUnlock()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocklistReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zeno-blocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocklistPath := path.Join(dir, "blocklist.txt")
+	err = ioutil.WriteFile(blocklistPath, []byte("# Complaints\nexample.org\n\nhttps://example.com/private/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := newBlocklist(blocklistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isBlocked := func(rawURL string) bool {
+		URL, _ := url.Parse(rawURL)
+		return list.isBlocked(URL)
+	}
+
+	assert.True(t, isBlocked("https://example.org/"))
+	assert.True(t, isBlocked("https://www.example.org/page"))
+	assert.True(t, isBlocked("https://example.com/private/page"))
+	assert.False(t, isBlocked("https://example.com/public/page"))
+	assert.False(t, isBlocked("https://example.net/"))
+
+	// An unchanged file isn't read again
+	reloaded, err := list.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Entries added to the file replace the previous ones
+	err = ioutil.WriteFile(blocklistPath, []byte("example.net\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(blocklistPath, later, later)
+
+	reloaded, err = list.reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.True(t, isBlocked("https://example.net/"))
+	assert.False(t, isBlocked("https://example.org/"))
+
+	// The previous entries are kept if the file can't be read
+	os.Remove(blocklistPath)
+	_, err = list.reload()
+	assert.Error(t, err)
+	assert.True(t, isBlocked("https://example.net/"))
+}
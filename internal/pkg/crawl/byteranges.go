@@ -0,0 +1,182 @@
+package crawl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxByterangesSize is the maximum size of a multipart/byteranges
+// response that we reassemble for the extraction
+const maxByterangesSize = 10 * MB
+
+// byteRange is a part of a multipart/byteranges response
+type byteRange struct {
+	Start       int64
+	End         int64
+	Total       int64
+	ContentType string
+	Content     []byte
+}
+
+// byterangesBoundary return the boundary of a multipart/byteranges
+// response, or an empty string if the response isn't one
+func byterangesBoundary(resp *http.Response) string {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		return ""
+	}
+
+	return params["boundary"]
+}
+
+// parseContentRange parse a Content-Range header like bytes 0-499/1234,
+// total is -1 if the complete length is unknown
+func parseContentRange(value string) (start, end, total int64, err error) {
+	var invalid = errors.New("invalid Content-Range: " + value)
+
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(parts) != 2 || len(bounds) != 2 {
+		return 0, 0, 0, invalid
+	}
+
+	start, err = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+	if err != nil {
+		return 0, 0, 0, invalid
+	}
+	end, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+	if err != nil || end < start {
+		return 0, 0, 0, invalid
+	}
+
+	total = -1
+	if parts[1] != "*" {
+		total, err = strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil || total <= end {
+			return 0, 0, 0, invalid
+		}
+	}
+
+	return start, end, total, nil
+}
+
+// readByteranges read the parts of a multipart/byteranges body
+func readByteranges(body io.Reader, boundary string) (ranges []byteRange, err error) {
+	reader := multipart.NewReader(body, boundary)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return ranges, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var byteRange byteRange
+		byteRange.Start, byteRange.End, byteRange.Total, err = parseContentRange(part.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		byteRange.ContentType = part.Header.Get("Content-Type")
+
+		byteRange.Content, err = ioutil.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(byteRange.Content)) != byteRange.End-byteRange.Start+1 {
+			return nil, fmt.Errorf("part of %d bytes for the range %d-%d", len(byteRange.Content), byteRange.Start, byteRange.End)
+		}
+
+		ranges = append(ranges, byteRange)
+	}
+}
+
+// reassembleByteranges return the whole resource if the ranges, that
+// may overlap, cover it from its first to its last byte
+func reassembleByteranges(ranges []byteRange) (body []byte, contentType string, ok bool) {
+	if len(ranges) == 0 || ranges[0].Total <= 0 {
+		return nil, "", false
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	var total = ranges[0].Total
+	var resource bytes.Buffer
+	for _, byteRange := range ranges {
+		if byteRange.Total != total || byteRange.Start > int64(resource.Len()) {
+			return nil, "", false
+		}
+
+		if byteRange.End >= int64(resource.Len()) {
+			resource.Write(byteRange.Content[int64(resource.Len())-byteRange.Start:])
+		}
+
+		if contentType == "" {
+			contentType = byteRange.ContentType
+		}
+	}
+
+	if int64(resource.Len()) != total {
+		return nil, "", false
+	}
+
+	return resource.Bytes(), contentType, true
+}
+
+// reassembleResponse return a copy of a multipart/byteranges response
+// whose body is the whole resource, for the extraction, or false if the
+// parts don't hold the whole resource. The response itself is written
+// in the WARC as it was received.
+func reassembleResponse(resp *http.Response, respPath string) (*http.Response, bool, error) {
+	body, err := readResponseBody(resp, respPath, maxByterangesSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ranges, err := readByteranges(bytes.NewReader(body), byterangesBoundary(resp))
+	if err != nil {
+		return nil, false, err
+	}
+
+	resource, contentType, ok := reassembleByteranges(ranges)
+	if !ok {
+		return nil, false, nil
+	}
+
+	reassembled := new(http.Response)
+	*reassembled = *resp
+	reassembled.Header = resp.Header.Clone()
+	reassembled.Header.Set("Content-Type", contentType)
+	reassembled.Header.Del("Content-Range")
+	reassembled.ContentLength = int64(len(resource))
+	reassembled.Body = ioutil.NopCloser(bytes.NewReader(resource))
+
+	return reassembled, true, nil
+}
+
+// byterangesPartBody is the body of the first part of a
+// multipart/byteranges response, closing it closes the response
+type byterangesPartBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *byterangesPartBody) Close() error {
+	return b.body.Close()
+}
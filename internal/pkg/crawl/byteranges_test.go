@@ -0,0 +1,134 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeByteranges answer with a multipart/byteranges response holding
+// the given inclusive ranges of content
+func writeByteranges(w http.ResponseWriter, content string, ranges [][2]int) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, byteRange := range ranges {
+		part, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {"text/html"},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", byteRange[0], byteRange[1], len(content))},
+		})
+		part.Write([]byte(content[byteRange[0] : byteRange[1]+1]))
+	}
+	writer.Close()
+
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+writer.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(body.Bytes())
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, end, total, err := parseContentRange("bytes 0-499/1234")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 499, 1234}, []int64{start, end, total})
+
+	start, end, total, err = parseContentRange("bytes 500-999/*")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{500, 999, -1}, []int64{start, end, total})
+
+	for _, value := range []string{"", "bytes */1234", "bytes 10-5/20", "bytes 0-20/20", "items 0-1/2", "bytes 0-a/10"} {
+		_, _, _, err = parseContentRange(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestReassembleResponse(t *testing.T) {
+	var content = `<html><body><a href="/next">Next</a></body></html>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/full":
+			// Overlapping and out of order parts covering the whole resource
+			writeByteranges(w, content, [][2]int{{20, len(content) - 1}, {0, 24}})
+		case "/partial":
+			writeByteranges(w, content, [][2]int{{0, 9}, {20, 29}})
+		}
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+
+	resp, err := c.Client.Get(server.URL + "/full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reassembled, ok, err := reassembleResponse(resp, "")
+	assert.NoError(t, err)
+	if assert.True(t, ok) {
+		body, _ := ioutil.ReadAll(reassembled.Body)
+		assert.Equal(t, content, string(body))
+		assert.Equal(t, "text/html", reassembled.Header.Get("Content-Type"))
+		assert.Equal(t, int64(len(content)), reassembled.ContentLength)
+		assert.Equal(t, resp.Request, reassembled.Request)
+	}
+
+	// The original response keeps its headers, as it was written in the WARC
+	assert.Contains(t, resp.Header.Get("Content-Type"), "multipart/byteranges")
+
+	resp, err = c.Client.Get(server.URL + "/partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	_, ok, err = reassembleResponse(resp, "")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestResumableBodyByteranges(t *testing.T) {
+	var content = strings.Repeat("0123456789", 10000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		// Answer range requests with a multipart/byteranges response
+		if rangeHeader := r.Header.Get("Range"); len(rangeHeader) > 0 {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+			writeByteranges(w, content, [][2]int{{start, len(content) - 1}})
+			return
+		}
+
+		// Interrupt the first download halfway
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+
+	resp, err := c.Client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body = newResumableBody(c.Client, resp, 3)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(body))
+}
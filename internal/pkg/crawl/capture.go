@@ -1,20 +1,50 @@
 package crawl
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 
 	"github.com/CorentinB/Zeno/internal/pkg/frontier"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/remeh/sizedwaitgroup"
 	"github.com/sirupsen/logrus"
 )
 
+// errOnionWithoutProxy is returned when an onion service is
+// encountered while no proxy to reach Tor is configured
+var errOnionWithoutProxy = errors.New("onion services can only be crawled through a Tor proxy specified with --proxy")
+
+// errCaptureCancelled is returned when the captures in progress are
+// cancelled during the shutdown, before the response was fully written
+var errCaptureCancelled = errors.New("capture cancelled during the shutdown, nothing was written in the WARC")
+
+// httpClient return the client to request the given URL with, the proxied
+// one unless the host is in --bypass-proxy. Onion services are always
+// requested through the proxy, that is expected to be Tor, whatever
+// --bypass-proxy says, so they never leak.
+func (c *Crawl) httpClient(URL *url.URL) (*http.Client, error) {
+	if utils.IsOnionHost(URL.Host) {
+		if c.ClientProxied == nil {
+			return nil, errOnionWithoutProxy
+		}
+		return c.ClientProxied, nil
+	}
+
+	if c.ClientProxied != nil && !utils.StringContainsSliceElements(URL.Host, c.BypassProxy) {
+		return c.ClientProxied, nil
+	}
+
+	return c.Client, nil
+}
+
 func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *http.Response, respPath string, err error) {
 	var newItem *frontier.Item
 	var newReq *http.Request
@@ -26,16 +56,42 @@ func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *
 	}
 
 	// Execute GET request
-	if c.ClientProxied == nil || utils.StringContainsSliceElements(req.URL.Host, c.BypassProxy) {
-		resp, err = c.Client.Do(req)
-		if err != nil {
-			return resp, respPath, err
-		}
-	} else {
-		resp, err = c.ClientProxied.Do(req)
-		if err != nil {
-			return resp, respPath, err
+	client, err := c.httpClient(req.URL)
+	if err != nil {
+		return resp, respPath, err
+	}
+
+	// The captures in progress can be cancelled during the shutdown
+	if c.captureContext != nil {
+		req = req.WithContext(c.captureContext)
+	}
+
+	// If asked, the request is aborted when no data is received for a
+	// while, instead of after a fixed total duration
+	var requestContext = req.Context()
+	var idleTimer *idleTimer
+	if c.ReadIdleTimeout > 0 {
+		req, idleTimer = withIdleTimeout(req, c.ReadIdleTimeout)
+	}
+
+	resp, err = client.Do(req)
+	if err != nil {
+		if idleTimer != nil {
+			idleTimer.stop()
 		}
+		return resp, respPath, c.cancellationError(err)
+	}
+
+	if idleTimer != nil {
+		resp.Body = &idleTimeoutBody{body: resp.Body, timer: idleTimer}
+	}
+
+	// If the server supports range requests, we make it possible to resume
+	// the download of the body if it gets interrupted
+	if isResumable(resp) {
+		resumable := newResumableBody(client, resp, c.MaxNetworkRetry)
+		resumable.context, resumable.idleTimeout = requestContext, c.ReadIdleTimeout
+		resp.Body = resumable
 	}
 
 	// Write response and request to WARC.
@@ -43,7 +99,11 @@ func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *
 		respPath, err = c.writeWARC(resp)
 		if err != nil {
 			resp.Body.Close()
-			return resp, respPath, err
+			return resp, respPath, c.cancellationError(err)
+		}
+
+		if c.CaptureTLSCerts {
+			c.writeTLSCertificates(resp.Request.URL, resp.TLS)
 		}
 
 		if c.Prometheus {
@@ -53,19 +113,35 @@ func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *
 		c.Crawled.Incr(1)
 	}
 
-	// If a redirection is catched, then we execute the redirection
-	if isRedirection(resp.StatusCode) {
-		if resp.Header.Get("location") == req.URL.String() || parentItem.Redirect >= c.MaxRedirect {
+	// The captures are counted in the budget of the seed of the item
+	c.consumeSeedBudget(parentItem, responseSize(resp, respPath))
+
+	// If asked, the captures made over a TLS connection whose
+	// certificate isn't valid are logged and documented in the WARC
+	if c.RecordTLSErrors {
+		c.recordTLSErrors(resp)
+	}
+
+	// If a redirection is catched, then we execute the redirection, the
+	// Refresh headers with a small delay are redirections too
+	if location := redirectLocation(resp); location != "" {
+		if location == req.URL.String() || parentItem.Redirect >= c.MaxRedirect || c.isItemTreeTooDeep(parentItem) {
 			return resp, respPath, nil
 		}
 
-		defer markTempFileDone(respPath)
-
-		URL, err = url.Parse(utils.CleanURL(resp.Header.Get("location")))
+		// The location may be relative to the redirecting URL
+		URL, err = req.URL.Parse(utils.CleanURL(location))
 		if err != nil {
 			return resp, respPath, err
 		}
 
+		// A page refreshing itself isn't a redirection
+		if !isRedirection(resp.StatusCode) && URL.String() == req.URL.String() {
+			return resp, respPath, nil
+		}
+
+		defer markTempFileDone(respPath)
+
 		newItem = frontier.NewItem(URL, parentItem, parentItem.Type, parentItem.Hop)
 		newItem.Redirect = parentItem.Redirect + 1
 
@@ -75,8 +151,13 @@ func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *
 			return resp, respPath, err
 		}
 
-		req.Header.Set("User-Agent", c.UserAgent)
-		req.Header.Set("Referer", newItem.ParentItem.URL.String())
+		newReq.Header.Set("User-Agent", c.UserAgent)
+		c.setReferer(newReq, newItem)
+		c.setRequestID(newReq, newItem)
+
+		// The redirection response has been fully written in the WARC
+		// at this point, so its body can be released before the next hop
+		resp.Body.Close()
 
 		resp, respPath, err = c.executeGET(newItem, newReq)
 		if err != nil {
@@ -86,41 +167,97 @@ func (c *Crawl) executeGET(parentItem *frontier.Item, req *http.Request) (resp *
 	return resp, respPath, nil
 }
 
-func (c *Crawl) captureAsset(item *frontier.Item) error {
+// setReferer set the Referer header of the request of an item to the URL
+// of its parent. The outlinks always send it, the assets and the pages
+// followed without consuming a hop only with --send-referer, for the
+// sites that only serve them with the Referer of their page. Like
+// browsers do, it is never sent from HTTPS to HTTP.
+func (c *Crawl) setReferer(req *http.Request, item *frontier.Item) {
+	if item.ParentItem == nil || item.ParentItem.URL == nil {
+		return
+	}
+
+	if !c.SendReferer && (item.Hop == 0 || item.Type == "asset") {
+		return
+	}
+
+	if item.ParentItem.URL.Scheme == "https" && req.URL.Scheme == "http" {
+		return
+	}
+
+	referer := *item.ParentItem.URL
+	referer.User = nil
+	referer.Fragment = ""
+	req.Header.Set("Referer", referer.String())
+}
+
+// captureAsset capture an asset, and return the assets that this asset
+// references itself, like the icons of a web app manifest
+func (c *Crawl) captureAsset(item *frontier.Item) (subAssets []url.URL, err error) {
 	var executionStart = time.Now()
 	var resp *http.Response
 
 	// If --seencheck is enabled, then we check if the URI is in the
 	// seencheck DB before doing anything. If it is in it, we skip the item
-	if c.Seencheck {
-		hash := strconv.FormatUint(item.Hash, 10)
-		found, _, err := c.Frontier.Seencheck.IsSeen(hash)
-		if err != nil {
-			c.Frontier.Seencheck.Seen(hash, item.Type)
-		}
-		if found {
-			return nil
-		}
-		c.Frontier.Seencheck.Seen(hash, item.Type)
+	if c.Seencheck && !c.Frontier.CheckSeen(item) {
+		return nil, nil
+	}
+
+	// FTP isn't HTTP, so files served over FTP are captured separately
+	if item.URL.Scheme == "ftp" {
+		return nil, c.captureFTP(item)
 	}
 
 	// Prepare GET request
 	req, err := http.NewRequest("GET", item.URL.String(), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	c.setReferer(req, item)
+	requestID := c.setRequestID(req, item)
 
 	resp, respPath, err := c.executeGET(item, req)
 	if err != nil {
 		markTempFileDone(respPath)
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	defer markTempFileDone(respPath)
 
-	c.logCrawlSuccess(executionStart, resp.StatusCode, item)
+	c.logCrawlSuccess(executionStart, resp.StatusCode, item, requestID)
+	c.ErrorStats.Incr(classifyError(nil, resp))
 
-	return nil
+	// Web app manifests reference the icons of the app, that are assets
+	// too, only the manifests referenced by pages are parsed
+	if !hasNoBody(resp.StatusCode) && isWebManifest(resp) && (item.ParentItem == nil || item.ParentItem.Type != "asset") {
+		return c.handleWebManifest(item, resp, respPath), nil
+	}
+
+	return nil, nil
+}
+
+// captureAssetOnce capture an asset, with --coalesce-requests the identical
+// assets requested at the same time by other items share the capture of
+// the first one, that alone reports its error and returns its sub-assets
+func (c *Crawl) captureAssetOnce(item *frontier.Item) (subAssets []url.URL, err error) {
+	if !c.CoalesceRequests {
+		return c.captureAsset(item)
+	}
+
+	var captured bool
+	_, err, _ = c.inflightAssets.Do(c.Frontier.SeencheckHash(item), func() (interface{}, error) {
+		captured = true
+		subAssets, err = c.captureAsset(item)
+		return nil, err
+	})
+	if !captured {
+		logInfo.WithFields(logrus.Fields{
+			"parent_url": item.ParentItem.URL.String(),
+		}).Debug("Asset already being captured " + item.URL.String())
+		return nil, nil
+	}
+
+	return subAssets, err
 }
 
 // Capture capture the URL and return the outlinks
@@ -128,8 +265,36 @@ func (c *Crawl) Capture(item *frontier.Item) {
 	var executionStart = time.Now()
 	var resp *http.Response
 
-	// Prepare GET request
-	req, err := http.NewRequest("GET", item.URL.String(), nil)
+	// The assets retried from a failed items file are captured as the
+	// assets of their parent, so their outlinks aren't followed
+	if item.Type == "asset" && item.ParentItem != nil {
+		c.captureAssets(item.ParentItem, []url.URL{*item.URL})
+		return
+	}
+
+	// FTP isn't HTTP, so files served over FTP are captured separately
+	if item.URL.Scheme == "ftp" {
+		err := c.captureFTP(item)
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning(item.URL.String())
+			c.writeFailedItem(item, err)
+		}
+		return
+	}
+
+	// Prepare the request, seeds can specify another method than GET
+	var method = http.MethodGet
+	var body io.Reader
+	if item.Method != "" {
+		method = item.Method
+	}
+	if item.Body != "" {
+		body = strings.NewReader(item.Body)
+	}
+
+	req, err := http.NewRequest(method, item.URL.String(), body)
 	if err != nil {
 		logWarning.WithFields(logrus.Fields{
 			"error": err,
@@ -137,22 +302,80 @@ func (c *Crawl) Capture(item *frontier.Item) {
 		return
 	}
 
-	if item.Hop > 0 && len(item.ParentItem.URL.String()) > 0 {
-		req.Header.Set("Referer", item.ParentItem.URL.String())
+	if item.ContentType != "" {
+		req.Header.Set("Content-Type", item.ContentType)
 	}
 
+	c.setReferer(req, item)
+	requestID := c.setRequestID(req, item)
+
 	resp, respPath, err := c.executeGET(item, req)
 	if err != nil {
-		logWarning.WithFields(logrus.Fields{
+		logWarning.WithFields(withRequestID(logrus.Fields{
 			"error": err,
-		}).Warning(item.URL.String())
+		}, requestID)).Warning(item.URL.String())
+		c.writeFailedItem(item, err)
 		markTempFileDone(respPath)
 		return
 	}
 	defer resp.Body.Close()
 	defer markTempFileDone(respPath)
 
-	c.logCrawlSuccess(executionStart, resp.StatusCode, item)
+	c.logCrawlSuccess(executionStart, resp.StatusCode, item, requestID)
+	c.ErrorStats.Incr(classifyError(nil, resp))
+
+	// If asked, the report endpoints and origins declared in the
+	// security headers of the response are queued as outlinks
+	if c.ExtractCSP && item.Hop < c.MaxHops {
+		if headerLinks := extractCSPURLs(resp.Request.URL, resp.Header); len(headerLinks) > 0 {
+			c.queueOutlinksAsync(c.filterSchemes(headerLinks), item)
+		}
+	}
+
+	// If asked, the Location header of the successful responses, that
+	// some servers send instead of redirecting, is queued as an outlink
+	if c.LocationAsOutlink && item.Hop < c.MaxHops {
+		if location := locationHint(resp); location != nil {
+			c.queueOutlinksAsync(c.filterSchemes([]url.URL{*location}), item)
+		}
+	}
+
+	// Responses without a body have nothing to extract, even
+	// if they have the Content-Type of the original resource
+	if hasNoBody(resp.StatusCode) {
+		return
+	}
+
+	// The multipart/byteranges responses that hold the whole resource are
+	// reassembled, so the resource is extracted like a regular response
+	if resp.StatusCode == http.StatusPartialContent && byterangesBoundary(resp) != "" {
+		reassembled, ok, err := reassembleResponse(resp, respPath)
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning("Unable to read multipart/byteranges response " + item.URL.String())
+			c.ErrorStats.Incr(errorCategoryParse)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		resp, respPath = reassembled, ""
+	}
+
+	// RSS and Atom feeds are parsed as XML, the links to their
+	// items are queued and their enclosures are captured
+	if feedAssets, isFeed := c.handleFeed(item, resp, respPath); isFeed {
+		c.captureAssets(item, feedAssets)
+		return
+	}
+
+	// If asked, JSON responses are parsed and the URLs and
+	// relative paths they contain are queued as outlinks
+	if c.ExtractJSONPaths && c.handleJSON(item, resp, respPath) {
+		return
+	}
 
 	// If the response isn't a text/*, we do not scrape it, and we delete the
 	// temporary file if it exists
@@ -160,6 +383,12 @@ func (c *Crawl) Capture(item *frontier.Item) {
 		return
 	}
 
+	// Wait for a slot to extract the outlinks and assets of the page,
+	// the slot is released before capturing the assets
+	c.ExtractionPool.Add()
+	var releaseExtraction sync.Once
+	defer releaseExtraction.Do(c.ExtractionPool.Done)
+
 	// Store the base URL to turn relative links into absolute links later
 	base, err := url.Parse(utils.CleanURL(resp.Request.URL.String()))
 	if err != nil {
@@ -182,39 +411,88 @@ func (c *Crawl) Capture(item *frontier.Item) {
 			return
 		}
 
-		doc, err = goquery.NewDocumentFromReader(file)
+		doc, err = c.newDocument(file, resp.Header.Get("Content-Type"))
 		if err != nil {
 			logWarning.WithFields(logrus.Fields{
 				"error": err,
 				"url":   item.URL.String(),
 				"path":  respPath,
 			}).Warning("Error making goquery document from temporary file")
+			c.ErrorStats.Incr(errorCategoryParse)
 			return
 		}
 		_ = doc
 		file.Close()
 		markTempFileDone(respPath)
 	} else {
-		doc, err = goquery.NewDocumentFromResponse(resp)
+		doc, err = c.newDocument(resp.Body, resp.Header.Get("Content-Type"))
 		if err != nil {
 			logWarning.WithFields(logrus.Fields{
 				"error": err,
 			}).Warning(item.URL.String())
+			c.ErrorStats.Incr(errorCategoryParse)
 			return
 		}
 		_ = doc
 	}
 
+	// Flag the page if it is a near-duplicate of a previously captured page
+	if c.NearDuplicateThreshold > 0 {
+		hash := utils.SimHash(doc.Find("body").Text())
+		duplicateOf, similarity := c.NearDuplicates.check(item.Host, item.URL.String(), hash, c.NearDuplicateThreshold)
+		if len(duplicateOf) > 0 {
+			logInfo.WithFields(logrus.Fields{
+				"near_duplicate_of": duplicateOf,
+				"similarity":        similarity,
+			}).Info(item.URL.String())
+
+			if c.WARC {
+				c.writeMetadataRecord(item.URL.String(), nearDuplicateMetadata(hash, duplicateOf, similarity))
+			}
+		}
+	}
+
+	// Extract the canonical URL of the page
+	item.Canonical = extractCanonical(base, doc)
+	if item.Canonical != nil && c.WARC && c.WARCRecordCanonical {
+		c.writeMetadataRecord(item.URL.String(), "canonical: "+item.Canonical.String()+"\r\n")
+	}
+
+	// Extract the next page, it is followed separately
+	// from the outlinks as it doesn't consume a hop
+	var nextPage *url.URL
+	if c.FollowPagination {
+		nextPage = extractNextPage(base, doc)
+		if nextPage != nil && nextPage.String() != item.URL.String() {
+			c.retainSeedTree(item)
+			go func() {
+				defer c.releaseSeedTree(item)
+				c.queueNextPage(*nextPage, item)
+			}()
+		}
+	}
+
 	// Extract outlinks
+	var outlinks []url.URL
 	if item.Hop < c.MaxHops {
-		outlinks, err := extractOutlinks(base, doc)
+		outlinks, err = c.extractOutlinks(base, doc)
 		if err != nil {
 			logWarning.WithFields(logrus.Fields{
 				"error": err,
 			}).Warning(item.URL.String())
 			return
 		}
-		go c.queueOutlinks(outlinks, item)
+
+		if item.Canonical != nil && c.FollowCanonical && item.Canonical.String() != item.URL.String() {
+			outlinks = append(outlinks, *item.Canonical)
+		}
+
+		if nextPage != nil {
+			outlinks = removeURL(outlinks, *nextPage)
+		}
+
+		outlinks = c.filterSchemes(outlinks)
+		c.queueOutlinksAsync(outlinks, item)
 	}
 
 	// Extract and capture assets
@@ -226,30 +504,80 @@ func (c *Crawl) Capture(item *frontier.Item) {
 		return
 	}
 
+	// If asked, only capture the assets from the same origin as the seed
+	if c.SameOriginAssets {
+		assets = c.filterCrossOriginAssets(item, assets)
+	}
+	assets = c.filterSchemes(assets)
+
+	releaseExtraction.Do(c.ExtractionPool.Done)
+
+	// If asked, write the extracted outlinks and assets in a metadata
+	// record linked to the response record
+	if c.WARC && c.WARCRecordOutlinks {
+		c.writeOutlinksRecord(resp, outlinks, assets)
+	}
+
+	c.captureAssets(item, assets)
+}
+
+// captureAssets capture the assets of an item concurrently, the number of
+// assets captured at the same time is limited for the item itself and for
+// the whole crawl, so one page with a lot of assets can't starve the others
+func (c *Crawl) captureAssets(item *frontier.Item, assets []url.URL) {
+	if len(assets) == 0 || c.isItemTreeTooDeep(item) {
+		return
+	}
+
+	var itemAssetsPool = sizedwaitgroup.New(c.MaxConcurrentAssets)
+	var subAssetsMutex sync.Mutex
+	var subAssets = make(map[*frontier.Item][]url.URL)
+
 	c.Frontier.QueueCount.Incr(int64(len(assets)))
 	for _, asset := range assets {
+		asset := asset
 		c.Frontier.QueueCount.Incr(-1)
 
 		// Make sure we do not over archive or archive an excluded host
-		if item.URL.String() == asset.String() || utils.IsHostExcluded(asset.Host, c.ExcludedHosts) {
+		if item.URL.String() == asset.String() || utils.IsHostExcluded(asset.Host, c.ExcludedHosts) || c.isBlocklisted(&asset) {
 			continue
 		}
 
-		newAsset := frontier.NewItem(&asset, item, "asset", item.Hop)
-		err = c.captureAsset(newAsset)
-		if err != nil {
-			logWarning.WithFields(logrus.Fields{
-				"error":          err,
-				"queued":         c.Frontier.QueueCount.Value(),
-				"crawled":        c.Crawled.Value(),
-				"rate":           c.URIsPerSecond.Rate(),
-				"active_workers": c.ActiveWorkers.Value(),
-				"parent_hop":     item.Hop,
-				"parent_url":     item.URL.String(),
-				"type":           "asset",
-			}).Warning(asset.String())
-			continue
-		}
+		itemAssetsPool.Add()
+		c.GlobalAssetsPool.Add()
+		go func() {
+			defer itemAssetsPool.Done()
+			defer c.GlobalAssetsPool.Done()
+
+			newAsset := frontier.NewItem(&asset, item, "asset", item.Hop)
+			newSubAssets, err := c.captureAssetOnce(newAsset)
+			if len(newSubAssets) > 0 {
+				subAssetsMutex.Lock()
+				subAssets[newAsset] = newSubAssets
+				subAssetsMutex.Unlock()
+			}
+			if err != nil {
+				logWarning.WithFields(logrus.Fields{
+					"error":          err,
+					"queued":         c.Frontier.QueueCount.Value(),
+					"crawled":        c.Crawled.Value(),
+					"rate":           c.URIsPerSecond.Rate(),
+					"active_workers": c.ActiveWorkers.Value(),
+					"parent_hop":     item.Hop,
+					"parent_url":     item.URL.String(),
+					"type":           "asset",
+				}).Warning(asset.String())
+				c.writeFailedItem(newAsset, err)
+			}
+		}()
+	}
+
+	itemAssetsPool.Wait()
+
+	// Capture the assets referenced by the assets themselves once
+	// the ones of the item are done, so they don't compete for the pools
+	for asset, assets := range subAssets {
+		c.captureAssets(asset, assets)
 	}
 }
 
@@ -0,0 +1,638 @@
+package crawl
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/CorentinB/warc"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"mvdan.cc/xurls/v2"
+)
+
+// newTestCrawl return a *Crawl with the minimum initialized to capture URLs
+// without WARC writing nor seencheck
+func newTestCrawl() *Crawl {
+	c := new(Crawl)
+
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	c.Paused = new(utils.TAtomBool)
+	c.Finished = new(utils.TAtomBool)
+	c.Crawled = new(ratecounter.Counter)
+	c.ActiveWorkers = new(ratecounter.Counter)
+	c.URIsPerSecond = ratecounter.NewRateCounter(1 * time.Second)
+	c.Frontier = new(frontier.Frontier)
+	c.Frontier.QueueCount = new(ratecounter.Counter)
+	c.Frontier.HostStats = frontier.NewHostStats()
+	c.GlobalAssetsPool = sizedwaitgroup.New(0)
+	c.ExtractionPool = newExtractionPool(0)
+	c.RequestDedupe = newRequestDedupeIndex()
+	c.TLSCertHosts = newTLSCertificateHosts()
+	c.TLSValidations = newTLSValidations()
+	c.SeedBudgets = newSeedBudgets()
+	c.ActiveSeeds = newActiveSeeds()
+	c.Throttle = newRequestThrottle()
+	c.WorkerStates = newWorkerStates()
+	c.ErrorStats = newErrorStats()
+	c.UserAgent = "Zeno"
+
+	c.initHTTPClient()
+
+	return c
+}
+
+// newConcurrencyServer return a server that keep track of the maximum
+// number of requests it had to handle at the same time
+func newConcurrencyServer(current, max *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := atomic.AddInt64(current, 1)
+		for {
+			previous := atomic.LoadInt64(max)
+			if value <= previous || atomic.CompareAndSwapInt64(max, previous, value) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt64(current, -1)
+	}))
+}
+
+func newTestAssets(t *testing.T, server *httptest.Server, count int) (assets []url.URL) {
+	for i := 0; i < count; i++ {
+		asset, err := url.Parse(server.URL + "/asset/" + strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assets = append(assets, *asset)
+	}
+
+	return assets
+}
+
+func TestCaptureAssetsPerItemLimit(t *testing.T) {
+	var current, max int64
+
+	server := newConcurrencyServer(&current, &max)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.MaxConcurrentAssets = 3
+
+	parentURL, _ := url.Parse(server.URL)
+	item := frontier.NewItem(parentURL, nil, "seed", 0)
+
+	c.captureAssets(item, newTestAssets(t, server, 12))
+
+	assert.True(t, atomic.LoadInt64(&max) > 1)
+	assert.True(t, atomic.LoadInt64(&max) <= 3)
+}
+
+func TestCaptureAssetsGlobalLimit(t *testing.T) {
+	var current, max int64
+
+	server := newConcurrencyServer(&current, &max)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.MaxConcurrentAssets = 4
+	c.GlobalAssetsPool = sizedwaitgroup.New(5)
+
+	parentURL, _ := url.Parse(server.URL)
+	assets := newTestAssets(t, server, 8)
+	done := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() {
+			item := frontier.NewItem(parentURL, nil, "seed", 0)
+			c.captureAssets(item, assets)
+			done <- true
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	assert.True(t, atomic.LoadInt64(&max) > 1)
+	assert.True(t, atomic.LoadInt64(&max) <= 5)
+}
+
+func TestCaptureAssetsCoalesceRequests(t *testing.T) {
+	var requests int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("shared"))
+	}))
+	defer server.Close()
+
+	// Several pages reference the same asset at the same time
+	captureConcurrently := func(c *Crawl) {
+		var wg sync.WaitGroup
+		assets := newTestAssets(t, server, 1)
+		for i := 0; i < 5; i++ {
+			parentURL, _ := url.Parse(server.URL + "/page/" + strconv.Itoa(i))
+			item := frontier.NewItem(parentURL, nil, "seed", 0)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.captureAssets(item, assets)
+			}()
+		}
+		wg.Wait()
+	}
+
+	c := newTestCrawl()
+	captureConcurrently(c)
+	assert.Equal(t, int64(5), atomic.LoadInt64(&requests))
+
+	atomic.StoreInt64(&requests, 0)
+	c.CoalesceRequests = true
+	captureConcurrently(c)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+
+	// Once the capture is over, the asset can be requested again
+	captureConcurrently(c)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+}
+
+func TestExecuteGETArchivesEveryRedirectHop(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("final"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.WARC = true
+	c.MaxRedirect = 5
+	c.WARCWriter = make(chan *warc.RecordBatch)
+
+	var responses []*warc.Record
+	var requests []*warc.Record
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				switch record.Header.Get("WARC-Type") {
+				case "response":
+					responses = append(responses, record)
+				case "request":
+					requests = append(requests, record)
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	URL, _ := url.Parse(server.URL + "/a")
+	item := frontier.NewItem(URL, nil, "seed", 0)
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+
+	resp, _, err := c.executeGET(item, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	close(c.WARCWriter)
+	<-done
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, responses, 3)
+	assert.Len(t, requests, 3)
+
+	for i, path := range []string{"/a", "/b", "/c"} {
+		assert.Equal(t, server.URL+path, responses[i].Header.Get("WARC-Target-URI"))
+		assert.Equal(t, responses[i].Header.Get("WARC-Record-ID"), requests[i].Header.Get("WARC-Concurrent-To"))
+	}
+}
+
+func TestCaptureSeedMethodAndBody(t *testing.T) {
+	var method, contentType, body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		payload, _ := ioutil.ReadAll(r.Body)
+		body = string(payload)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+
+	URL, _ := url.Parse(server.URL + "/api")
+	item := frontier.NewItem(URL, nil, "seed", 0)
+	item.Method = "POST"
+	item.Body = `{"page":1}`
+	item.ContentType = "application/json"
+
+	c.Capture(item)
+
+	assert.Equal(t, "POST", method)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, `{"page":1}`, body)
+}
+
+func TestCaptureAssetsSendReferer(t *testing.T) {
+	var referersMutex sync.Mutex
+	var referers = make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referersMutex.Lock()
+		referers[r.URL.Path] = r.Header.Get("Referer")
+		referersMutex.Unlock()
+
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	parentURL, _ := url.Parse(server.URL + "/page#section")
+	item := frontier.NewItem(parentURL, nil, "seed", 0)
+
+	// By default the assets are requested without Referer
+	c := newTestCrawl()
+	c.MaxConcurrentAssets = 1
+	c.captureAssets(item, newTestAssets(t, server, 1))
+	assert.Equal(t, "", referers["/asset/0"])
+
+	// With --send-referer, it is the URL of the page
+	c = newTestCrawl()
+	c.MaxConcurrentAssets = 1
+	c.SendReferer = true
+	c.captureAssets(item, newTestAssets(t, server, 2))
+	assert.Equal(t, server.URL+"/page", referers["/asset/0"])
+	assert.Equal(t, server.URL+"/page", referers["/asset/1"])
+
+	// The outlinks always send it
+	outlinkURL, _ := url.Parse(server.URL + "/outlink")
+	c = newTestCrawl()
+	c.Capture(frontier.NewItem(outlinkURL, item, "seed", 1))
+	assert.Equal(t, server.URL+"/page", referers["/outlink"])
+
+	// The redirections follow the same rules, the seeds don't send it
+	redirectURL, _ := url.Parse(server.URL + "/redirect")
+	c = newTestCrawl()
+	c.MaxRedirect = 5
+	c.Capture(frontier.NewItem(redirectURL, nil, "seed", 0))
+	assert.Equal(t, "", referers["/target"])
+
+	c.Capture(frontier.NewItem(redirectURL, item, "seed", 1))
+	assert.Equal(t, server.URL+"/redirect", referers["/target"])
+
+	// But never from HTTPS to HTTP
+	secureURL, _ := url.Parse("https://example.com/page")
+	req, _ := http.NewRequest("GET", server.URL+"/insecure", nil)
+	c.SendReferer = true
+	c.setReferer(req, frontier.NewItem(outlinkURL, frontier.NewItem(secureURL, nil, "seed", 0), "asset", 0))
+	assert.Equal(t, "", req.Header.Get("Referer"))
+}
+
+func TestExecuteGETOnionThroughProxy(t *testing.T) {
+	var proxied string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("onion"))
+	}))
+	defer proxy.Close()
+
+	URL, _ := url.Parse("http://expyuzz4wqqyqhjn.onion/page")
+	item := frontier.NewItem(URL, nil, "seed", 0)
+
+	// Without a proxy, onion services are never requested
+	c := newTestCrawl()
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+	_, _, err := c.executeGET(item, req)
+	assert.Equal(t, errOnionWithoutProxy, err)
+
+	// With a proxy, they go through it even if the proxy is bypassed
+	c = newTestCrawl()
+	c.Proxy = proxy.URL
+	c.BypassProxy = []string{"onion"}
+	c.initHTTPClient()
+
+	req, _ = http.NewRequest("GET", URL.String(), nil)
+	resp, _, err := c.executeGET(item, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, URL.String(), proxied)
+}
+
+func TestCaptureResponsesWithoutBody(t *testing.T) {
+	var requests int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+
+		status, _ := strconv.Atoi(r.URL.Path[1:])
+		if status == http.StatusResetContent {
+			// Some servers wrongly send a body with a 205
+			w.Header().Set("Content-Length", "7")
+			w.WriteHeader(status)
+			w.Write([]byte("ignored"))
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	for _, status := range []int{http.StatusNoContent, http.StatusResetContent, http.StatusNotModified} {
+		atomic.StoreInt64(&requests, 0)
+
+		c := newTestCrawl()
+		c.WARC = true
+		c.MaxRetry = 3
+		c.WARCWriter = make(chan *warc.RecordBatch)
+
+		var response []byte
+		done := make(chan bool)
+		go func() {
+			for batch := range c.WARCWriter {
+				for _, record := range batch.Records {
+					if record.Header.Get("WARC-Type") == "response" {
+						response, _ = ioutil.ReadAll(record.Content)
+					}
+				}
+				if batch.Done != nil {
+					batch.Done <- true
+				}
+			}
+			done <- true
+		}()
+
+		URL, _ := url.Parse(server.URL + "/" + strconv.Itoa(status))
+		c.Capture(frontier.NewItem(URL, nil, "seed", 0))
+		close(c.WARCWriter)
+		<-done
+
+		// The response is written once, complete and without a body
+		assert.Equal(t, int64(1), atomic.LoadInt64(&requests), status)
+		assert.True(t, strings.HasPrefix(string(response), "HTTP/1.1 "+strconv.Itoa(status)), status)
+		assert.True(t, strings.HasSuffix(string(response), "\r\n\r\n"), status)
+		assert.NotContains(t, string(response), "ignored", status)
+	}
+}
+
+func TestExecuteGETCancelledMidCopy(t *testing.T) {
+	started := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+		w.(http.Flusher).Flush()
+		close(started)
+
+		// The rest of the body never comes
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno-cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+	os.MkdirAll(path.Join(jobPath, "temp"), 0755)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.WARC = true
+	c.WARCWriter = make(chan *warc.RecordBatch)
+	c.captureContext, c.cancelCaptures = context.WithCancel(context.Background())
+
+	var records int
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			records += len(batch.Records)
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	go func() {
+		<-started
+		time.Sleep(50 * time.Millisecond)
+		c.cancelCaptures()
+	}()
+
+	URL, _ := url.Parse(server.URL + "/big")
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+	_, _, err = c.executeGET(frontier.NewItem(URL, nil, "seed", 0), req)
+	close(c.WARCWriter)
+	<-done
+
+	// Nothing is written, not even a partial record, and the temporary file is removed
+	assert.Equal(t, errCaptureCancelled, err)
+	assert.Equal(t, errorCategoryCancelled, classifyError(err, nil))
+	assert.Equal(t, 0, records)
+
+	tempFiles, _ := ioutil.ReadDir(path.Join(jobPath, "temp"))
+	assert.Empty(t, tempFiles)
+}
+
+func TestCaptureWritesOutlinksRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/page" {
+			w.Write([]byte(`<html><body><a href="/next">next</a><img src="/image.png"></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.WARC = true
+	c.WARCRecordOutlinks = true
+	c.AllowedSchemes = []string{"http", "https"}
+	c.MaxHops = 1
+	c.MaxConcurrentAssets = 1
+	c.WARCWriter = make(chan *warc.RecordBatch)
+	c.Frontier.PushChan = make(chan *frontier.Item, 10)
+	regexOutlinks = xurls.Relaxed()
+
+	var responseID, concurrentTo, content string
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				switch {
+				case record.Header.Get("WARC-Type") == "response" && record.Header.Get("WARC-Target-URI") == server.URL+"/page":
+					responseID = record.Header.Get("WARC-Record-ID")
+				case record.Header.Get("WARC-Type") == "metadata":
+					concurrentTo = record.Header.Get("WARC-Concurrent-To")
+					payload, _ := ioutil.ReadAll(record.Content)
+					content = string(payload)
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	URL, _ := url.Parse(server.URL + "/page")
+	c.Capture(frontier.NewItem(URL, nil, "seed", 0))
+	close(c.WARCWriter)
+	<-done
+
+	assert.NotEmpty(t, responseID)
+	assert.Equal(t, responseID, concurrentTo)
+	assert.Equal(t, "outlink: "+server.URL+"/next\r\nasset: "+server.URL+"/image.png\r\n", content)
+}
+
+func TestCaptureLocationAsOutlink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Location", "/intended")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.AllowedSchemes = []string{"http", "https"}
+	c.MaxHops = 1
+	c.Frontier.PushChan = make(chan *frontier.Item, 10)
+	regexOutlinks = xurls.Relaxed()
+
+	URL, _ := url.Parse(server.URL + "/page")
+
+	// By default the Location header of a 200 is ignored
+	c.Capture(frontier.NewItem(URL, nil, "seed", 0))
+
+	c.LocationAsOutlink = true
+	c.Capture(frontier.NewItem(URL, nil, "seed", 0))
+
+	select {
+	case outlink := <-c.Frontier.PushChan:
+		assert.Equal(t, server.URL+"/intended", outlink.URL.String())
+		assert.Equal(t, uint8(1), outlink.Hop)
+		assert.Equal(t, 0, outlink.Redirect)
+	case <-time.After(time.Second):
+		t.Fatal("the Location header wasn't queued as an outlink")
+	}
+	assert.Len(t, c.Frontier.PushChan, 0)
+}
+
+func TestExecuteGETFollowsRefreshHeader(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Refresh", "0; url=/new")
+		w.Write([]byte("moved"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		// A page reloading itself periodically isn't a redirection
+		w.Header().Set("Refresh", "300; url=/new")
+		w.Write([]byte("dashboard"))
+	})
+	mux.HandleFunc("/self", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Refresh", "0")
+		w.Write([]byte("self"))
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.MaxRedirect = 5
+
+	for path, expected := range map[string]string{
+		"/old":  "/new",
+		"/slow": "/slow",
+		"/self": "/self",
+	} {
+		URL, _ := url.Parse(server.URL + path)
+		req, _ := http.NewRequest("GET", URL.String(), nil)
+
+		resp, _, err := c.executeGET(frontier.NewItem(URL, nil, "seed", 0), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, server.URL+expected, resp.Request.URL.String(), path)
+	}
+
+	// The redirections are still limited by --max-redirect
+	c.MaxRedirect = 0
+	URL, _ := url.Parse(server.URL + "/old")
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+	resp, _, err := c.executeGET(frontier.NewItem(URL, nil, "seed", 0), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, server.URL+"/old", resp.Request.URL.String())
+}
+
+func TestCaptureRetriedAsset(t *testing.T) {
+	var requestedMutex sync.Mutex
+	var requested = make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedMutex.Lock()
+		requested[r.URL.Path] = true
+		requestedMutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><img src="/image.png"></body></html>`))
+	}))
+	defer server.Close()
+
+	regexOutlinks = xurls.Relaxed()
+
+	// An asset loaded from a failed items file is captured as an asset,
+	// so the URLs it references aren't captured
+	parentURL, _ := url.Parse(server.URL + "/page")
+	assetURL, _ := url.Parse(server.URL + "/frame.html")
+
+	c := newTestCrawl()
+	c.MaxConcurrentAssets = 1
+	c.Capture(frontier.NewItem(assetURL, frontier.NewItem(parentURL, nil, "seed", 0), "asset", 0))
+
+	assert.True(t, requested["/frame.html"])
+	assert.False(t, requested["/page"])
+	assert.False(t, requested["/image.png"])
+}
@@ -0,0 +1,271 @@
+package crawl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// cdxIndexInterval is the interval at which the WARC files
+// that were closed since the last check are indexed
+const cdxIndexInterval = 10 * time.Second
+
+// cdxjFields are the JSON fields of a CDXJ line, as expected by pywb
+type cdxjFields struct {
+	URL      string `json:"url"`
+	MIME     string `json:"mime,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	Length   string `json:"length"`
+	Offset   string `json:"offset"`
+	Filename string `json:"filename"`
+}
+
+// countingReader count the bytes read from the underlying reader,
+// used to know the offsets of the records in a WARC file
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// indexWARC return the sorted CDXJ lines of the response and resource
+// records of a gzipped WARC file, that has one gzip member per record
+func indexWARC(warcPath string) (lines []string, err error) {
+	err = walkWARC(warcPath, func(fields *recordCDXFields) error {
+		line, err := cdxjLine(fields)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(lines)
+
+	return lines, nil
+}
+
+// walkWARC call walkFn with the CDXJ fields of each response and resource
+// record of a gzipped WARC file, that has one gzip member per record
+func walkWARC(warcPath string, walkFn func(fields *recordCDXFields) error) error {
+	file, err := os.Open(warcPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// The gzip reader reads exactly one member from a reader implementing
+	// io.ByteReader, so the offset of the next record is the number of bytes
+	// read from the file minus the bytes still buffered
+	counter := &countingReader{reader: file}
+	reader := bufio.NewReader(counter)
+	gzipReader := new(gzip.Reader)
+
+	for {
+		offset := counter.count - int64(reader.Buffered())
+		if _, err := reader.Peek(1); err == io.EOF {
+			break
+		}
+
+		err = gzipReader.Reset(reader)
+		if err != nil {
+			return err
+		}
+		gzipReader.Multistream(false)
+
+		fields, err := readCDXFields(bufio.NewReader(gzipReader))
+		if err != nil {
+			return err
+		}
+
+		// Skip what is left of the record to reach the end of the member
+		_, err = io.Copy(ioutil.Discard, gzipReader)
+		if err != nil {
+			return err
+		}
+
+		if fields == nil {
+			continue
+		}
+
+		fields.Offset = strconv.FormatInt(offset, 10)
+		fields.Length = strconv.FormatInt(counter.count-int64(reader.Buffered())-offset, 10)
+		fields.Filename = filepath.Base(warcPath)
+
+		err = walkFn(fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordCDXFields are the CDXJ fields of a record, with its
+// date and whether its payload was truncated
+type recordCDXFields struct {
+	cdxjFields
+	date      string
+	truncated bool
+}
+
+// readCDXFields read the header and the payload of a record to get the
+// fields of its CDXJ line, it return nil for the records that aren't indexed
+func readCDXFields(record *bufio.Reader) (*recordCDXFields, error) {
+	// Skip the version line
+	_, err := record.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := textproto.NewReader(record).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields = new(recordCDXFields)
+	var payload io.Reader
+	fields.URL = header.Get("WARC-Target-URI")
+	fields.date = header.Get("WARC-Date")
+	fields.truncated = header.Get("WARC-Truncated") != ""
+
+	switch header.Get("WARC-Type") {
+	case "response":
+		resp, err := http.ReadResponse(record, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		fields.Status = strconv.Itoa(resp.StatusCode)
+		fields.MIME, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		payload = resp.Body
+	case "resource":
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		fields.MIME, _, _ = mime.ParseMediaType(header.Get("Content-Type"))
+		payload = io.LimitReader(record, length)
+	default:
+		return nil, nil
+	}
+
+	// Truncated records end before the length announced by
+	// their HTTP headers, their payload is hashed as it is
+	hash := sha1.New()
+	_, err = io.Copy(hash, payload)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	fields.Digest = "sha1:" + base32.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	return fields, nil
+}
+
+// cdxjLine format the CDXJ line of a record: its SURT key,
+// its 14-digit timestamp and its fields as JSON
+func cdxjLine(fields *recordCDXFields) (string, error) {
+	URL, err := url.Parse(fields.URL)
+	if err != nil {
+		return "", err
+	}
+
+	captureTime, err := time.Parse(time.RFC3339, fields.date)
+	if err != nil {
+		return "", err
+	}
+
+	JSON, err := json.Marshal(fields.cdxjFields)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.SURT(URL) + " " + captureTime.UTC().Format("20060102150405") + " " + string(JSON), nil
+}
+
+// indexWARCs write the CDXJ index of the closed WARC files that weren't
+// indexed yet, in the indexes directory of the job
+func (c *Crawl) indexWARCs() {
+	c.cdxMutex.Lock()
+	defer c.cdxMutex.Unlock()
+
+	// The WARC files that are still being written end with .open
+	warcPaths, err := filepath.Glob(path.Join(c.JobPath, "warcs", "*.warc.gz"))
+	if err != nil {
+		return
+	}
+
+	os.MkdirAll(path.Join(c.JobPath, "indexes"), os.ModePerm)
+
+	for _, warcPath := range warcPaths {
+		indexPath := path.Join(c.JobPath, "indexes", strings.TrimSuffix(filepath.Base(warcPath), ".warc.gz")+".cdxj")
+		if _, err := os.Stat(indexPath); err == nil {
+			continue
+		}
+
+		lines, err := indexWARC(warcPath)
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+				"warc":  warcPath,
+			}).Warning("Unable to index WARC file")
+			continue
+		}
+
+		// The index is written under a temporary name and renamed once
+		// complete, so a partial index is never taken for a complete one
+		var content string
+		if len(lines) > 0 {
+			content = strings.Join(lines, "\n") + "\n"
+		}
+
+		err = ioutil.WriteFile(indexPath+".open", []byte(content), 0644)
+		if err == nil {
+			err = os.Rename(indexPath+".open", indexPath)
+		}
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+				"index": indexPath,
+			}).Warning("Unable to write CDXJ index")
+		}
+	}
+}
+
+// cdxIndexer periodically index the WARC files that were closed
+func (c *Crawl) cdxIndexer() {
+	for !c.Finished.Get() {
+		time.Sleep(cdxIndexInterval)
+		c.indexWARCs()
+	}
+}
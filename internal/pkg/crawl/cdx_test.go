@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/warc"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexWARCs(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	logWarning = logrus.New()
+
+	var settings = warc.NewRotatorSettings()
+	settings.OutputDirectory = path.Join(jobPath, "warcs")
+	settings.Compression = "GZIP"
+	records, done, err := settings.NewWARCRotator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response = warc.NewRecord()
+	response.Header.Set("WARC-Type", "response")
+	response.Header.Set("WARC-Target-URI", "https://www.example.com/page?a=1")
+	response.Header.Set("Content-Type", "application/http; msgtype=response")
+	response.Content = strings.NewReader("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nContent-Length: 5\r\n\r\nhello")
+
+	var request = warc.NewRecord()
+	request.Header.Set("WARC-Type", "request")
+	request.Header.Set("WARC-Target-URI", "https://www.example.com/page?a=1")
+	request.Content = strings.NewReader("GET /page?a=1 HTTP/1.1\r\nHost: www.example.com\r\n\r\n")
+
+	var resource = warc.NewRecord()
+	resource.Header.Set("WARC-Type", "resource")
+	resource.Header.Set("WARC-Target-URI", "ftp://example.com/file.txt")
+	resource.Header.Set("Content-Type", "text/plain")
+	resource.Content = strings.NewReader("file")
+
+	var batch = warc.NewRecordBatch()
+	batch.Records = append(batch.Records, response, request, resource)
+	records <- batch
+	close(records)
+	<-done
+
+	c := new(Crawl)
+	c.JobPath = jobPath
+	c.indexWARCs()
+
+	indexes, _ := filepath.Glob(path.Join(jobPath, "indexes", "*.cdxj"))
+	if !assert.Len(t, indexes, 1) {
+		return
+	}
+
+	content, err := ioutil.ReadFile(indexes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+
+	timestamp := time.Now().UTC().Format("2006")
+	assert.True(t, strings.HasPrefix(lines[0], "com,example)/file.txt "+timestamp))
+	assert.True(t, strings.HasPrefix(lines[1], "com,example)/page?a=1 "+timestamp))
+	assert.Contains(t, lines[1], `"mime":"text/html","status":"200","digest":"sha1:`)
+
+	// The offsets and lengths point to the gzip members of the records
+	warcFile, err := os.Open(path.Join(jobPath, "warcs", strings.TrimSuffix(filepath.Base(indexes[0]), ".cdxj")+".warc.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer warcFile.Close()
+
+	for _, line := range lines {
+		var fields = new(recordCDXFields)
+		assert.NoError(t, json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &fields.cdxjFields))
+
+		offset, _ := strconv.ParseInt(fields.Offset, 10, 64)
+		length, _ := strconv.ParseInt(fields.Length, 10, 64)
+		reader, err := warc.NewReader(io.NewSectionReader(warcFile, offset, length))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record, err := reader.ReadRecord(false)
+		assert.NoError(t, err)
+		assert.Equal(t, fields.URL, record.Header.Get("WARC-Target-URI"))
+	}
+}
@@ -0,0 +1,36 @@
+package crawl
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// regexMetaCharset matches the <meta> tags declaring the charset of a page,
+// either with a charset attribute or in an http-equiv Content-Type
+var regexMetaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=`)
+
+// newDocument parse an HTML body into a goquery document, if charset detection
+// is enabled then the body is first transcoded to UTF-8 from the charset
+// declared in the Content-Type header or in the <meta> tags of the page. The
+// pages that don't declare their charset are kept as UTF-8, as guessing it
+// from the beginning of the page mangles the UTF-8 found further in it.
+func (c *Crawl) newDocument(body io.Reader, contentType string) (*goquery.Document, error) {
+	if c.CharsetDetection {
+		reader := bufio.NewReader(body)
+		preview, _ := reader.Peek(1024)
+
+		encoding, name, certain := charset.DetermineEncoding(preview, contentType)
+		if name != "utf-8" && (certain || regexMetaCharset.Match(preview)) {
+			body = transform.NewReader(reader, encoding.NewDecoder())
+		} else {
+			body = reader
+		}
+	}
+
+	return goquery.NewDocumentFromReader(body)
+}
@@ -0,0 +1,48 @@
+package crawl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestNewDocumentCharset(t *testing.T) {
+	html := `<html><head><meta charset="windows-1251"></head><body><a href="/новости">Новости</a></body></html>`
+	encoded, err := charmap.Windows1251.NewEncoder().String(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := new(Crawl)
+	c.CharsetDetection = true
+
+	// Charset declared in a <meta> tag
+	doc, err := c.newDocument(bytes.NewReader([]byte(encoded)), "text/html")
+	assert.NoError(t, err)
+	href, _ := doc.Find("a").Attr("href")
+	assert.Equal(t, "/новости", href)
+
+	// Charset declared in the Content-Type header
+	encoded, _ = charmap.Windows1251.NewEncoder().String(`<html><body><a href="/новости">Новости</a></body></html>`)
+	doc, err = c.newDocument(bytes.NewReader([]byte(encoded)), "text/html; charset=windows-1251")
+	assert.NoError(t, err)
+	href, _ = doc.Find("a").Attr("href")
+	assert.Equal(t, "/новости", href)
+
+	// UTF-8 pages are left untouched
+	doc, err = c.newDocument(bytes.NewReader([]byte(`<html><body><a href="/café">Café</a></body></html>`)), "text/html")
+	assert.NoError(t, err)
+	href, _ = doc.Find("a").Attr("href")
+	assert.Equal(t, "/café", href)
+
+	// UTF-8 pages that don't declare their charset and only have non-ASCII
+	// characters after the part used for the detection are untouched too
+	page := `<html><body><p>` + strings.Repeat("a", 2048) + `</p><a href="/café">Café</a></body></html>`
+	doc, err = c.newDocument(bytes.NewReader([]byte(page)), "text/html")
+	assert.NoError(t, err)
+	href, _ = doc.Find("a").Attr("href")
+	assert.Equal(t, "/café", href)
+}
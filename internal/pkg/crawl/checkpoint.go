@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+)
+
+// checkpoint is a point-in-time snapshot of the state of the crawl,
+// written in the job's checkpoints directory for external inspection
+type checkpoint struct {
+	Time          time.Time                        `json:"time"`
+	RunningTime   string                           `json:"running_time"`
+	Crawled       int64                            `json:"crawled"`
+	Queued        int64                            `json:"queued"`
+	Rate          int64                            `json:"rate"`
+	ActiveWorkers int64                            `json:"active_workers"`
+	Paused        bool                             `json:"paused"`
+	QueuedPerHost map[string]int64                 `json:"queued_per_host"`
+	Hosts         map[string]frontier.HostCounters `json:"hosts"`
+	Workers       []workerState                    `json:"workers"`
+	Errors        map[errorCategory]int64          `json:"errors"`
+}
+
+// Checkpoint dump the frontier's hosts pool like it is done periodically,
+// then write a snapshot of the queue statistics and of the workers' state
+// in a timestamped file, without stopping the crawl. It returns the path
+// of the snapshot.
+func (c *Crawl) Checkpoint() (string, error) {
+	c.Frontier.Save()
+
+	var snapshot = checkpoint{
+		Time:          time.Now().UTC(),
+		RunningTime:   time.Since(c.StartTime).String(),
+		Crawled:       c.Crawled.Value(),
+		Queued:        c.Frontier.QueueCount.Value(),
+		Rate:          c.URIsPerSecond.Rate(),
+		ActiveWorkers: c.ActiveWorkers.Value(),
+		Paused:        c.Paused.Get(),
+		QueuedPerHost: c.Frontier.HostPool.Snapshot(),
+		Hosts:         c.Frontier.HostStats.Snapshot(),
+		Workers:       c.WorkerStates.Snapshot(),
+		Errors:        c.ErrorStats.Snapshot(),
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	checkpointsPath := path.Join(c.JobPath, "checkpoints")
+	err = os.MkdirAll(checkpointsPath, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// Like the metrics dump, the snapshot is written to a temporary
+	// file then renamed, so it is never read partially written
+	checkpointPath := path.Join(checkpointsPath, "checkpoint-"+snapshot.Time.Format("20060102T150405.000")+".json")
+	err = ioutil.WriteFile(checkpointPath+".tmp", data, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return checkpointPath, os.Rename(checkpointPath+".tmp", checkpointPath)
+}
@@ -0,0 +1,67 @@
+package crawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.Frontier.JobPath = jobPath
+	c.Frontier.HostPool = new(frontier.HostPool)
+	c.Frontier.HostPool.Mutex = new(sync.Mutex)
+	c.Frontier.HostPool.Hosts = make(map[string]*ratecounter.Counter)
+	c.Frontier.HostPool.Incr("example.com")
+	c.Frontier.HostPool.Incr("example.com")
+	c.Frontier.HostStats.IncrCaptured("example.com")
+	c.Frontier.QueueCount.Incr(2)
+	c.Crawled.Incr(1)
+	c.WorkerStates.register()
+
+	checkpointPath, err := c.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hosts pool is dumped like it is done periodically
+	_, err = os.Stat(path.Join(jobPath, "frontier.gob"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, path.Join(jobPath, "checkpoints"), path.Dir(checkpointPath))
+
+	data, err := ioutil.ReadFile(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot checkpoint
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(1), snapshot.Crawled)
+	assert.Equal(t, int64(2), snapshot.Queued)
+	assert.Equal(t, map[string]int64{"example.com": 2}, snapshot.QueuedPerHost)
+	assert.Equal(t, int64(1), snapshot.Hosts["example.com"].Captured)
+	assert.Len(t, snapshot.Workers, 1)
+
+	// No temporary file is left behind
+	files, _ := ioutil.ReadDir(path.Join(jobPath, "checkpoints"))
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,247 @@
+package crawl
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cookieHostIdleTimeout is the time after which the cookies of a host
+	// that neither set nor sent any cookie are dropped from the jar
+	cookieHostIdleTimeout = time.Hour
+	// minCookieHostsEviction is the number of hosts from
+	// which the idle hosts are evicted from the jar
+	minCookieHostsEviction = 1000
+)
+
+// limitedCookieJar is a cookie jar that keep at most maxCookies cookies
+// and maxSize bytes of cookies for each host that set them, the oldest
+// cookies are dropped when a host goes over the limits, so a server
+// setting thousands of cookies or huge values can't bloat the memory
+// and the following requests. A limit of 0 or less means no limit.
+type limitedCookieJar struct {
+	sync.Mutex
+	jar        http.CookieJar
+	maxCookies int
+	maxSize    int
+	hosts      map[string]*hostCookies
+	// evictAt is the number of hosts at which the idle hosts are evicted,
+	// it grows with the hosts left so the eviction cost is amortized
+	evictAt int
+}
+
+// hostCookies holds the cookies set by a host, from the oldest to the newest
+type hostCookies struct {
+	size     int
+	order    *list.List
+	elements map[string]*list.Element
+	lastUsed time.Time
+}
+
+// storedCookie is a cookie set by a host, with the URL it was set for
+// so it can be expired later, and the time it expires at if it isn't
+// a session cookie
+type storedCookie struct {
+	URL     *url.URL
+	cookie  *http.Cookie
+	expires time.Time
+}
+
+func newLimitedCookieJar(jar http.CookieJar, maxCookies, maxSize int) *limitedCookieJar {
+	return &limitedCookieJar{
+		jar:        jar,
+		maxCookies: maxCookies,
+		maxSize:    maxSize,
+		hosts:      make(map[string]*hostCookies),
+		evictAt:    minCookieHostsEviction,
+	}
+}
+
+// cookieExpiry return the time at which a cookie expires, or the
+// zero time for a session cookie, Max-Age has precedence over Expires
+func cookieExpiry(cookie *http.Cookie, now time.Time) time.Time {
+	if cookie.MaxAge > 0 {
+		return now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	}
+
+	return cookie.Expires
+}
+
+// remove forget a cookie of the host
+func (stored *hostCookies) remove(element *list.Element) storedCookie {
+	removed := stored.order.Remove(element).(storedCookie)
+	delete(stored.elements, cookieKey(removed.cookie))
+	stored.size -= cookieSize(removed.cookie)
+
+	return removed
+}
+
+// removeExpired forget the cookies of the host that expired, the
+// underlying jar doesn't send them anymore so they don't count
+func (stored *hostCookies) removeExpired(now time.Time) {
+	for element := stored.order.Front(); element != nil; {
+		next := element.Next()
+		if expires := element.Value.(storedCookie).expires; !expires.IsZero() && !expires.After(now) {
+			stored.remove(element)
+		}
+		element = next
+	}
+}
+
+// evictIdle forget the hosts without cookies left, and drop the cookies of
+// the hosts that didn't use them for cookieHostIdleTimeout, the lock must
+// be held. It returns the cookies to expire in the underlying jar.
+func (jar *limitedCookieJar) evictIdle(now time.Time) (expired []storedCookie) {
+	for host, stored := range jar.hosts {
+		stored.removeExpired(now)
+
+		if stored.order.Len() > 0 && now.Sub(stored.lastUsed) < cookieHostIdleTimeout {
+			continue
+		}
+
+		for element := stored.order.Front(); element != nil; element = element.Next() {
+			expired = append(expired, element.Value.(storedCookie))
+		}
+		delete(jar.hosts, host)
+	}
+
+	jar.evictAt = 2 * len(jar.hosts)
+	if jar.evictAt < minCookieHostsEviction {
+		jar.evictAt = minCookieHostsEviction
+	}
+
+	return expired
+}
+
+// cookieKey identify a cookie, a cookie set again with the
+// same name, domain and path replaces the previous one
+func cookieKey(cookie *http.Cookie) string {
+	return cookie.Name + ";" + strings.ToLower(cookie.Domain) + ";" + cookie.Path
+}
+
+func cookieSize(cookie *http.Cookie) int {
+	return len(cookie.Name) + len(cookie.Value)
+}
+
+// Cookies implements the http.CookieJar interface, the host is
+// marked as using its cookies so they aren't evicted
+func (jar *limitedCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	jar.Lock()
+	if stored, ok := jar.hosts[strings.ToLower(u.Host)]; ok {
+		stored.lastUsed = time.Now()
+	}
+	jar.Unlock()
+
+	return jar.jar.Cookies(u)
+}
+
+// SetCookies implements the http.CookieJar interface, the cookies are
+// stored in the underlying jar then the oldest ones are expired if
+// the host went over the limits
+func (jar *limitedCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.Lock()
+	defer jar.Unlock()
+
+	var now = time.Now()
+	var expired []storedCookie
+
+	host := strings.ToLower(u.Host)
+	stored, ok := jar.hosts[host]
+	if !ok {
+		if len(jar.hosts) >= jar.evictAt {
+			expired = jar.evictIdle(now)
+		}
+
+		stored = &hostCookies{
+			order:    list.New(),
+			elements: make(map[string]*list.Element),
+		}
+		jar.hosts[host] = stored
+	}
+	stored.lastUsed = now
+	stored.removeExpired(now)
+
+	var accepted []*http.Cookie
+	for _, cookie := range cookies {
+		// When the same cookie is set several times, the last one wins
+		key := cookieKey(cookie)
+		if element, ok := stored.elements[key]; ok {
+			stored.remove(element)
+		}
+
+		// Cookies that are deleted or already expired don't count
+		expires := cookieExpiry(cookie, now)
+		if cookie.MaxAge < 0 || (!expires.IsZero() && !expires.After(now)) {
+			accepted = append(accepted, cookie)
+			continue
+		}
+
+		// A cookie bigger than the size limit can't be stored at all
+		if jar.maxSize > 0 && cookieSize(cookie) > jar.maxSize {
+			logWarning.WithFields(logrus.Fields{
+				"host":   host,
+				"cookie": cookie.Name,
+				"size":   cookieSize(cookie),
+			}).Warning("Cookie bigger than --max-cookies-size, dropping it")
+			continue
+		}
+
+		accepted = append(accepted, cookie)
+		stored.elements[key] = stored.order.PushBack(storedCookie{URL: u, cookie: cookie, expires: expires})
+		stored.size += cookieSize(cookie)
+	}
+
+	// Drop the oldest cookies until the host is within the limits
+	var dropped int
+	for stored.order.Len() > 0 &&
+		((jar.maxCookies > 0 && stored.order.Len() > jar.maxCookies) || (jar.maxSize > 0 && stored.size > jar.maxSize)) {
+		oldest := stored.remove(stored.order.Front())
+		dropped++
+
+		// If the cookie was set by this response, it is simply not stored
+		if removeCookie(&accepted, oldest.cookie) {
+			continue
+		}
+
+		expired = append(expired, oldest)
+	}
+
+	if dropped > 0 {
+		logInfo.WithFields(logrus.Fields{
+			"host":    host,
+			"dropped": dropped,
+		}).Debug("Too many cookies for host, dropping the oldest ones")
+	}
+
+	jar.jar.SetCookies(u, accepted)
+
+	// The cookies are expired with the URL they were set for, as the
+	// domain and path of the cookies that don't specify them depend on it
+	for _, oldest := range expired {
+		jar.jar.SetCookies(oldest.URL, []*http.Cookie{{
+			Name:   oldest.cookie.Name,
+			Domain: oldest.cookie.Domain,
+			Path:   oldest.cookie.Path,
+			MaxAge: -1,
+		}})
+	}
+}
+
+// removeCookie remove a cookie from a slice of cookies, it
+// returns true if the cookie was found in the slice
+func removeCookie(cookies *[]*http.Cookie, removed *http.Cookie) bool {
+	for i, cookie := range *cookies {
+		if cookie == removed {
+			*cookies = append((*cookies)[:i], (*cookies)[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
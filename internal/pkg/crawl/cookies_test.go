@@ -0,0 +1,140 @@
+package crawl
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func cookieNames(cookies []*http.Cookie) (names []string) {
+	for _, cookie := range cookies {
+		names = append(names, cookie.Name)
+	}
+	return names
+}
+
+func TestLimitedCookieJarCount(t *testing.T) {
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	backend, _ := cookiejar.New(nil)
+	jar := newLimitedCookieJar(backend, 3, 0)
+
+	URL, _ := url.Parse("https://example.com/")
+	otherURL, _ := url.Parse("https://example.org/")
+
+	// A cookie bomb only keeps the newest cookies
+	var bomb []*http.Cookie
+	for i := 0; i < 1000; i++ {
+		bomb = append(bomb, &http.Cookie{Name: "c" + strconv.Itoa(i), Value: "x"})
+	}
+	jar.SetCookies(URL, bomb)
+	assert.ElementsMatch(t, []string{"c997", "c998", "c999"}, cookieNames(jar.Cookies(URL)))
+
+	// Setting a cookie again refreshes it instead of adding one
+	jar.SetCookies(URL, []*http.Cookie{{Name: "c997", Value: "y"}})
+	jar.SetCookies(URL, []*http.Cookie{{Name: "new", Value: "z"}})
+	assert.ElementsMatch(t, []string{"c997", "c999", "new"}, cookieNames(jar.Cookies(URL)))
+
+	// Duplicate Set-Cookie in a single response, the last one wins
+	jar.SetCookies(URL, []*http.Cookie{{Name: "dup", Value: "1"}, {Name: "dup", Value: "2"}})
+	cookies := jar.Cookies(URL)
+	assert.ElementsMatch(t, []string{"c997", "new", "dup"}, cookieNames(cookies))
+	for _, cookie := range cookies {
+		if cookie.Name == "dup" {
+			assert.Equal(t, "2", cookie.Value)
+		}
+	}
+
+	// The limits are per host
+	jar.SetCookies(otherURL, []*http.Cookie{{Name: "other", Value: "1"}})
+	assert.Len(t, jar.Cookies(URL), 3)
+	assert.Len(t, jar.Cookies(otherURL), 1)
+}
+
+func TestLimitedCookieJarSize(t *testing.T) {
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	backend, _ := cookiejar.New(nil)
+	jar := newLimitedCookieJar(backend, 0, 100)
+
+	URL, _ := url.Parse("https://example.com/")
+
+	// A single cookie bigger than the limit is dropped
+	jar.SetCookies(URL, []*http.Cookie{{Name: "huge", Value: strings.Repeat("x", 1000)}})
+	assert.Empty(t, jar.Cookies(URL))
+
+	// The oldest cookies are dropped to stay under the limit
+	jar.SetCookies(URL, []*http.Cookie{{Name: "a", Value: strings.Repeat("x", 40)}})
+	jar.SetCookies(URL, []*http.Cookie{{Name: "b", Value: strings.Repeat("x", 40)}})
+	jar.SetCookies(URL, []*http.Cookie{{Name: "c", Value: strings.Repeat("x", 40)}})
+	assert.ElementsMatch(t, []string{"b", "c"}, cookieNames(jar.Cookies(URL)))
+
+	// Deleted cookies free their space
+	jar.SetCookies(URL, []*http.Cookie{{Name: "b", MaxAge: -1}})
+	jar.SetCookies(URL, []*http.Cookie{{Name: "d", Value: strings.Repeat("x", 40)}})
+	assert.ElementsMatch(t, []string{"c", "d"}, cookieNames(jar.Cookies(URL)))
+}
+
+func TestLimitedCookieJarExpiredCookies(t *testing.T) {
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	backend, _ := cookiejar.New(nil)
+	jar := newLimitedCookieJar(backend, 2, 0)
+
+	URL, _ := url.Parse("https://example.com/")
+
+	jar.SetCookies(URL, []*http.Cookie{{Name: "a", Value: "x", MaxAge: 1}, {Name: "b", Value: "x"}})
+
+	// A cookie that expired doesn't count anymore, so it doesn't
+	// make the host drop its other cookies
+	time.Sleep(1100 * time.Millisecond)
+	jar.SetCookies(URL, []*http.Cookie{{Name: "c", Value: "x"}})
+	assert.Equal(t, 2, jar.hosts["example.com"].order.Len())
+	assert.Contains(t, cookieNames(jar.Cookies(URL)), "b")
+
+	// Cookies set already expired aren't stored
+	jar.SetCookies(URL, []*http.Cookie{{Name: "d", Value: "x", Expires: time.Now().Add(-time.Hour)}})
+	assert.Equal(t, 2, jar.hosts["example.com"].order.Len())
+	assert.ElementsMatch(t, []string{"b", "c"}, cookieNames(jar.Cookies(URL)))
+}
+
+func TestLimitedCookieJarEvictIdleHosts(t *testing.T) {
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	backend, _ := cookiejar.New(nil)
+	jar := newLimitedCookieJar(backend, 10, 0)
+
+	idleURL, _ := url.Parse("https://idle.com/")
+	activeURL, _ := url.Parse("https://active.com/")
+	jar.SetCookies(idleURL, []*http.Cookie{{Name: "idle", Value: "x"}})
+	jar.SetCookies(activeURL, []*http.Cookie{{Name: "active", Value: "x"}})
+	jar.hosts["idle.com"].lastUsed = time.Now().Add(-2 * cookieHostIdleTimeout)
+
+	// Hosts whose cookies are all deleted
+	for i := 0; i < minCookieHostsEviction; i++ {
+		URL, _ := url.Parse("https://host-" + strconv.Itoa(i) + ".com/")
+		jar.SetCookies(URL, []*http.Cookie{{Name: "deleted", MaxAge: -1}})
+	}
+
+	// Only the host still using its cookies is left,
+	// with the hosts added after the eviction
+	jar.Lock()
+	assert.True(t, len(jar.hosts) < 10)
+	assert.Contains(t, jar.hosts, "active.com")
+	assert.NotContains(t, jar.hosts, "idle.com")
+	jar.Unlock()
+
+	assert.Empty(t, jar.Cookies(idleURL))
+	assert.Len(t, jar.Cookies(activeURL), 1)
+}
@@ -1,7 +1,10 @@
 package crawl
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/remeh/sizedwaitgroup"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 	"mvdan.cc/xurls/v2"
 )
 
@@ -27,6 +31,7 @@ type PrometheusMetrics struct {
 // Crawl define the parameters of a crawl process
 type Crawl struct {
 	*sync.Mutex
+	Version   string
 	Pprof     bool
 	Debug     bool
 	JSONLog   bool
@@ -40,45 +45,158 @@ type Crawl struct {
 	Frontier *frontier.Frontier
 
 	// Crawl settings
-	WorkerPool            sizedwaitgroup.SizedWaitGroup
-	Client                *http.Client
-	ClientProxied         *http.Client
-	Logger                logrus.Logger
-	DisabledHTMLTags      []string
-	ExcludedHosts         []string
-	UserAgent             string
-	Job                   string
-	JobPath               string
-	MaxHops               uint8
-	MaxRetry              int
-	MaxRedirect           int
-	CaptureAlternatePages bool
-	DomainsCrawl          bool
-	Headless              bool
-	Seencheck             bool
-	Workers               int
+	WorkerPool               sizedwaitgroup.SizedWaitGroup
+	Client                   *http.Client
+	ClientProxied            *http.Client
+	Logger                   logrus.Logger
+	DisabledHTMLTags         []string
+	AssetSelectorRules       []AssetSelectorRule
+	LazyLoadAttributes       []string
+	JSONDataAttributes       []string
+	AllowedSchemes           []string
+	ExcludedHosts            []string
+	BlocklistFile            string
+	Blocklist                *blocklist
+	MaxURLLength             int
+	MaxPathRepetitions       int
+	SkipMIMETypes            []string
+	OnlyMIMETypes            []string
+	UserAgent                string
+	Accept                   string
+	SendReferer              bool
+	SendRequestID            bool
+	Job                      string
+	JobPath                  string
+	MaxHops                  uint8
+	MaxPagesPerHost          int64
+	PagesPerHost             *frontier.HostPool
+	MaxRetry                 int
+	MaxNetworkRetry          int
+	RateLimitJitter          float64
+	ReadIdleTimeout          time.Duration
+	MaxRedirect              int
+	MaxItemDepth             int
+	SeedMaxURLs              int64
+	SeedMaxBytes             int64
+	SeedBudgets              *seedBudgets
+	MaxActiveSeeds           int
+	ActiveSeeds              *activeSeeds
+	MaxConcurrentAssets      int
+	MaxConcurrentPerHost     int
+	CrawlDelay               time.Duration
+	MaxRequestsPerSecond     float64
+	Throttle                 *requestThrottle
+	GlobalAssetsPool         sizedwaitgroup.SizedWaitGroup
+	CoalesceRequests         bool
+	inflightAssets           singleflight.Group
+	CaptureAlternatePages    bool
+	SameOriginAssets         bool
+	CrossOriginAssetsHosts   []string
+	FollowCanonical          bool
+	FollowPagination         bool
+	MaxPaginationPages       int
+	ExtractPingAndFormaction bool
+	ExtractCSP               bool
+	LocationAsOutlink        bool
+	ExtractEventHandlers     bool
+	ExtractJavascriptHrefs   bool
+	ExtractJSONPaths         bool
+	MaxJSONDepth             int
+	CharsetDetection         bool
+	DomainsCrawl             bool
+	Headless                 bool
+	DNSPrefetch              bool
+	DNSCache                 *dnsCache
+	resolver                 *net.Resolver
+	MinSpaceRequired         float64
+	SyncInterval             time.Duration
+	Seencheck                bool
+	FailedItemsFile          *os.File
+	Workers                  int
+	MaxInflight              int
+	StageBufferSize          int
+	PostprocessorConcurrency int
+	ExtractionPool           *extractionPool
+	NearDuplicateThreshold   float64
+	NearDuplicates           *nearDuplicateIndex
+	workerStops              []chan struct{}
+	WorkerStates             *workerStates
+	workersMutex             sync.Mutex
+	captureContext           context.Context
+	cancelCaptures           context.CancelFunc
+
+	// Login settings
+	LoginURL       string
+	LoginFormData  string
+	LoginCSRFField string
+	CookieJar      http.CookieJar
+	MaxCookies     int
+	MaxCookiesSize int
+
+	// Per-host credentials, sent in the Authorization header
+	AuthFile        string
+	HostCredentials map[string]hostCredential
 
 	// Proxy settings
 	Proxy       string
 	BypassProxy []string
 
+	// Local address settings
+	LocalAddress   string
+	Interface      string
+	LocalIPv6Range string
+
+	// TLS settings
+	CertValidation bool
+	InsecureHosts  []string
+
 	// API settings
 	API               bool
 	APIPort           string
 	Prometheus        bool
 	PrometheusMetrics *PrometheusMetrics
 
+	// Metrics dump settings
+	MetricsPrefix       string
+	MetricsDumpFile     string
+	MetricsDumpInterval time.Duration
+
 	// Real time statistics
 	URIsPerSecond *ratecounter.RateCounter
 	ActiveWorkers *ratecounter.Counter
 	Crawled       *ratecounter.Counter
+	ErrorStats    *errorStats
 
 	// WARC settings
-	WARC             bool
-	WARCPrefix       string
-	WARCOperator     string
-	WARCWriter       chan *warc.RecordBatch
-	WARCWriterFinish chan bool
+	WARC                bool
+	WARCPrefix          string
+	WARCSize            float64
+	WARCOperator        string
+	WARCDescription     string
+	WARCRobotsPolicy    string
+	WARCCollection      string
+	WARCRecordTiming    bool
+	WARCRecordCanonical bool
+	WARCRecordOutlinks  bool
+	WARCCaptureTrailers bool
+	WARCTrailerDigests  bool
+	WARCDedupeRequests  bool
+	WARCDedupeReqWindow time.Duration
+	RequestDedupe       *requestDedupeIndex
+	CaptureTLSCerts     bool
+	TLSCertHosts        *tlsCertificateHosts
+	RecordTLSErrors     bool
+	TLSValidations      *tlsValidations
+	CaptureDNS          bool
+	CaptureHeadBytes    int64
+	MaxInMemoryBodySize int64
+	WriteCDX            bool
+	cdxMutex            sync.Mutex
+	WARCWriter          chan *warc.RecordBatch
+	WARCWriterFinish    chan bool
+	WARCPool            *warcPool
+	WARCPoolSize        int
+	WARCPoolPolicy      string
 
 	// Kafka settings
 	UseKafka             bool
@@ -89,6 +207,18 @@ type Crawl struct {
 	KafkaProducerChannel chan *frontier.Item
 }
 
+// stageBufferSize return the size of the buffer of a channel between two
+// stages of the crawl, that is --stage-buffer-size if it is set. When the
+// buffer is full the producing stage blocks until the consuming stage
+// catches up, so a bigger buffer absorbs the slowdowns of the consuming
+// stage but holds more items in memory
+func (c *Crawl) stageBufferSize(defaultSize int) int {
+	if c.StageBufferSize > 0 {
+		return c.StageBufferSize
+	}
+	return defaultSize
+}
+
 // Start fire up the crawling process
 func (c *Crawl) Start() (err error) {
 	c.StartTime = time.Now()
@@ -96,19 +226,98 @@ func (c *Crawl) Start() (err error) {
 	c.Finished = new(utils.TAtomBool)
 	regexOutlinks = xurls.Relaxed()
 
+	// Initialize the counters of the failures by category
+	c.ErrorStats = newErrorStats()
+
+	// Initialize the index used for near-duplicate detection
+	c.NearDuplicates = newNearDuplicateIndex()
+
+	// Initialize the index used to deduplicate the request records
+	c.RequestDedupe = newRequestDedupeIndex()
+
+	// Initialize the hosts whose TLS certificates were captured
+	c.TLSCertHosts = newTLSCertificateHosts()
+	c.TLSValidations = newTLSValidations()
+
+	// Initialize the usage of the budget of the seeds
+	c.SeedBudgets = newSeedBudgets()
+
+	// Initialize the throttling of the requests
+	c.Throttle = newRequestThrottle()
+
+	// Initialize the seed trees being captured
+	c.ActiveSeeds = newActiveSeeds()
+
+	// The extraction of the outlinks and assets is CPU-bound, so the number
+	// of pages processed at the same time can be tuned independently of
+	// the number of workers, but defaults to it
+	if c.PostprocessorConcurrency > 0 {
+		c.ExtractionPool = newExtractionPool(c.PostprocessorConcurrency)
+	} else {
+		c.ExtractionPool = newExtractionPool(c.Workers)
+	}
+
+	// Initialize the per-host pages counter
+	c.PagesPerHost = new(frontier.HostPool)
+	c.PagesPerHost.Mutex = new(sync.Mutex)
+	c.PagesPerHost.Hosts = make(map[string]*ratecounter.Counter, 0)
+
 	// Setup logging
 	logInfo, logWarning = c.SetupLogging()
 
 	// Initialize HTTP client
-	c.initHTTPClient()
+	err = c.initHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	// Open the file in which the items that fail are written
+	err = c.initFailedItemsFile()
+	if err != nil {
+		return err
+	}
+
+	// Load the blocklist, it is reloaded when the file changes
+	if len(c.BlocklistFile) > 0 {
+		c.Blocklist, err = newBlocklist(c.BlocklistFile)
+		if err != nil {
+			return err
+		}
+		go c.blocklistReloader()
+	}
+
+	// Load the credentials of the hosts that require authentication
+	if len(c.AuthFile) > 0 {
+		c.HostCredentials, err = readHostCredentials(c.AuthFile)
+		if err != nil {
+			return err
+		}
+
+		logInfo.WithFields(logrus.Fields{
+			"hosts": len(c.HostCredentials),
+		}).Info("Credentials loaded")
+	}
+
+	// Initialize the context of the captures, it is cancelled
+	// to abort the captures in progress during the shutdown
+	c.captureContext, c.cancelCaptures = context.WithCancel(context.Background())
 
 	// Start the background process that will handle os signals
 	// to exit Zeno, like CTRL+C
 	go c.setupCloseHandler()
 
 	// Initialize the frontier
-	c.Frontier.Init(c.JobPath, logInfo, logWarning, c.Workers, c.Seencheck)
-	c.Frontier.Load()
+	err = c.Frontier.Init(c.JobPath, logInfo, logWarning, c.MaxInflight, c.Seencheck)
+	if err != nil {
+		return err
+	}
+
+	err = c.Frontier.Load()
+	if err != nil {
+		return err
+	}
+
+	c.Frontier.Dropped = c.releaseSeedTree
 	c.Frontier.Start()
 
 	// Start the background process that will periodically check if the disk
@@ -116,22 +325,48 @@ func (c *Crawl) Start() (err error) {
 	go c.handleCrawlPause()
 
 	// Function responsible for writing to disk the frontier's hosts pool
-	// and other stats needed to resume the crawl. The process happen every minute.
+	// and other stats needed to resume the crawl, and for syncing the seencheck
+	// database. The process happen every --sync-interval.
 	// The actual queue used during the crawl and seencheck aren't included in this,
 	// because they are written to disk in real-time.
 	go c.writeFrontierToDisk()
 
+	// Start the background process that resolve the hosts that are
+	// about to be crawled, so their DNS resolution is already cached
+	if c.DNSPrefetch {
+		go c.dnsPrefetcher()
+	}
+
 	// Initialize WARC writer
 	if c.WARC {
 		logrus.Info("Initializing WARC writer pool..")
 		c.initWARCWriter()
 		logrus.Info("WARC writer pool initialized")
+
+		// Start the background process that index the closed WARC files
+		if c.WriteCDX {
+			go c.cdxIndexer()
+		}
+	}
+
+	// If a login URL is specified, we authenticate before crawling anything
+	// so the session cookies are used for the whole crawl
+	if len(c.LoginURL) > 0 {
+		err = c.login()
+		if err != nil {
+			return err
+		}
 	}
 
 	if c.API {
 		go c.startAPI()
 	}
 
+	// Start the process responsible for periodically writing the metrics to a file
+	if len(c.MetricsDumpFile) > 0 {
+		go c.dumpMetrics()
+	}
+
 	// Start the process responsible for printing live stats on the standard output
 	if c.LiveStats {
 		go c.printLiveStats()
@@ -140,7 +375,7 @@ func (c *Crawl) Start() (err error) {
 	// If Kafka parameters are specified, then we start the background
 	// processes responsible for pulling and pushing seeds from and to Kafka
 	if c.UseKafka {
-		c.KafkaProducerChannel = make(chan *frontier.Item, c.Workers)
+		c.KafkaProducerChannel = make(chan *frontier.Item, c.stageBufferSize(c.Workers))
 		go c.kafkaConsumer()
 		if len(c.KafkaOutlinksTopic) > 0 {
 			go c.kafkaProducer()
@@ -157,10 +392,12 @@ func (c *Crawl) Start() (err error) {
 	}
 
 	// Fire up the desired amount of workers
+	c.WorkerStates = newWorkerStates()
+	c.workersMutex.Lock()
 	for i := 0; i < c.Workers; i++ {
-		c.WorkerPool.Add()
-		go c.Worker(&c.WorkerPool)
+		c.startWorker()
 	}
+	c.workersMutex.Unlock()
 
 	// Start the background process that will catch when there
 	// is nothing more to crawl
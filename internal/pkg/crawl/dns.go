@@ -0,0 +1,149 @@
+package crawl
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dnsPrefetchTTL is the time after which an already prefetched host
+	// is resolved again
+	dnsPrefetchTTL = 5 * time.Minute
+	// dnsPrefetchTimeout is the maximum time spent resolving a single host
+	dnsPrefetchTimeout = 5 * time.Second
+)
+
+// dnsCache holds the addresses of the prefetched hosts, the HTTP
+// client dials them directly instead of resolving the hosts again
+type dnsCache struct {
+	sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addresses []string
+	expires   time.Time
+}
+
+func newDNSCache() *dnsCache {
+	return &dnsCache{entries: make(map[string]dnsCacheEntry)}
+}
+
+// get return the cached addresses of a host, or nil if they expired
+func (cache *dnsCache) get(host string) []string {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry, ok := cache.entries[host]
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(cache.entries, host)
+		return nil
+	}
+
+	return entry.addresses
+}
+
+// set cache the addresses of a host for dnsPrefetchTTL
+func (cache *dnsCache) set(host string, addresses []string) {
+	cache.Lock()
+	cache.entries[host] = dnsCacheEntry{addresses: addresses, expires: time.Now().Add(dnsPrefetchTTL)}
+	cache.Unlock()
+}
+
+// removeExpired forget about the hosts that expired, so the cache doesn't grow forever
+func (cache *dnsCache) removeExpired() {
+	cache.Lock()
+	for host, entry := range cache.entries {
+		if time.Now().After(entry.expires) {
+			delete(cache.entries, host)
+		}
+	}
+	cache.Unlock()
+}
+
+// dialContext wrap dial so the cached addresses of a host are dialed one
+// after the other until a connection is made, the hosts that aren't in
+// the cache are resolved by dial as usual
+func (cache *dnsCache) dialContext(dial dnsDialFunc) dnsDialFunc {
+	return func(ctx context.Context, network, address string) (conn net.Conn, err error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return dial(ctx, network, address)
+		}
+
+		addresses := cache.get(host)
+		if len(addresses) == 0 {
+			return dial(ctx, network, address)
+		}
+
+		for _, IP := range addresses {
+			conn, err = dial(ctx, network, net.JoinHostPort(IP, port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+
+		return nil, err
+	}
+}
+
+// dnsPrefetcher periodically look at the hosts the frontier is about to
+// dequeue from, in the order it's going to, and resolve the ones that will
+// soon be crawled. It's best-effort: errors are only logged in debug, and
+// the number of hosts resolved per round is bounded by the number of workers.
+func (c *Crawl) dnsPrefetcher() {
+	for !c.Finished.Get() {
+		for _, host := range c.Frontier.UpcomingHosts(c.GetWorkersCount() * 2) {
+			if c.Finished.Get() {
+				return
+			}
+
+			c.prefetchDNS(host)
+		}
+
+		c.DNSCache.removeExpired()
+
+		time.Sleep(time.Second)
+	}
+}
+
+// prefetchDNS resolve a host with the resolver of the HTTP client,
+// and cache its addresses for the client to dial them
+func (c *Crawl) prefetchDNS(host string) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	// Onion services aren't resolved with the DNS, resolving
+	// them would leak them to the resolver
+	if net.ParseIP(host) != nil || utils.IsOnionHost(host) {
+		return
+	}
+
+	if c.DNSCache.get(host) != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsPrefetchTimeout)
+	defer cancel()
+
+	addresses, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		logInfo.WithFields(logrus.Fields{
+			"host":  host,
+			"error": err,
+		}).Debug("DNS prefetch failed")
+		return
+	}
+
+	c.DNSCache.set(host, addresses)
+}
@@ -0,0 +1,184 @@
+package crawl
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CorentinB/warc"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsDialFunc dial a connection to a DNS server
+type dnsDialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dnsRecorder is a resolver that write the DNS responses it receives in
+// the WARC as dns resource records, once per host and query type for the
+// crawl, A and AAAA responses being written separately
+type dnsRecorder struct {
+	sync.Mutex
+	c        *Crawl
+	resolver *net.Resolver
+	recorded map[string]bool
+}
+
+// newDNSRecorder return a recorder whose resolver uses the pure Go
+// resolver, so it can read the responses, connecting with dial
+func (c *Crawl) newDNSRecorder(dial dnsDialFunc) *dnsRecorder {
+	recorder := &dnsRecorder{
+		c:        c,
+		recorded: make(map[string]bool),
+	}
+
+	recorder.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			// The resolver reads UDP connections one message at a time,
+			// and TCP connections as a stream of length-prefixed messages
+			if _, ok := conn.(net.PacketConn); ok {
+				return &dnsPacketConn{Conn: conn, recorder: recorder}, nil
+			}
+			return &dnsStreamConn{Conn: conn, recorder: recorder}, nil
+		},
+	}
+
+	return recorder
+}
+
+// dnsPacketConn is a UDP connection to a DNS server
+// whose responses are passed to the recorder
+type dnsPacketConn struct {
+	net.Conn
+	recorder *dnsRecorder
+}
+
+func (conn *dnsPacketConn) Read(b []byte) (n int, err error) {
+	n, err = conn.Conn.Read(b)
+	if n > 0 {
+		conn.recorder.record(conn.RemoteAddr(), b[:n])
+	}
+	return n, err
+}
+
+func (conn *dnsPacketConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = conn.Conn.(net.PacketConn).ReadFrom(b)
+	if n > 0 {
+		conn.recorder.record(addr, b[:n])
+	}
+	return n, addr, err
+}
+
+func (conn *dnsPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return conn.Conn.(net.PacketConn).WriteTo(b, addr)
+}
+
+// dnsStreamConn is a TCP connection to a DNS server
+// whose responses are passed to the recorder
+type dnsStreamConn struct {
+	net.Conn
+	recorder *dnsRecorder
+	buffer   []byte
+}
+
+func (conn *dnsStreamConn) Read(b []byte) (n int, err error) {
+	n, err = conn.Conn.Read(b)
+	conn.buffer = append(conn.buffer, b[:n]...)
+
+	for len(conn.buffer) >= 2 {
+		length := int(binary.BigEndian.Uint16(conn.buffer))
+		if len(conn.buffer) < 2+length {
+			break
+		}
+
+		conn.recorder.record(conn.RemoteAddr(), conn.buffer[2:2+length])
+		conn.buffer = conn.buffer[2+length:]
+	}
+
+	return n, err
+}
+
+// formatDNSResponse return the content of the dns record of a response:
+// the time it was fetched, then its answers in the zone file format,
+// it returns false if the response isn't a successful answer
+func formatDNSResponse(message []byte, fetchTime time.Time) (host string, queryType dnsmessage.Type, content string, ok bool) {
+	var parser dnsmessage.Parser
+
+	header, err := parser.Start(message)
+	if err != nil || !header.Response || header.RCode != dnsmessage.RCodeSuccess {
+		return "", 0, "", false
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return "", 0, "", false
+	}
+	parser.SkipAllQuestions()
+
+	answers, err := parser.AllAnswers()
+	if err != nil || len(answers) == 0 {
+		return "", 0, "", false
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fetchTime.UTC().Format("20060102150405") + "\n")
+
+	for _, answer := range answers {
+		var data string
+
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			data = "A\t" + net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			data = "AAAA\t" + net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			data = "CNAME\t" + body.CNAME.String()
+		default:
+			continue
+		}
+
+		fmt.Fprintf(&builder, "%s\t%d\tIN\t%s\n", answer.Header.Name.String(), answer.Header.TTL, data)
+	}
+
+	return strings.TrimSuffix(question.Name.String(), "."), question.Type, builder.String(), true
+}
+
+// record write a DNS response received from server in a resource record,
+// if it's the first successful response for its host and query type
+func (recorder *dnsRecorder) record(server net.Addr, message []byte) {
+	host, queryType, content, ok := formatDNSResponse(message, time.Now())
+	if !ok {
+		return
+	}
+
+	key := host + " " + queryType.String()
+	recorder.Lock()
+	if recorder.recorded[key] {
+		recorder.Unlock()
+		return
+	}
+	recorder.recorded[key] = true
+	recorder.Unlock()
+
+	var resourceRecord = warc.NewRecord()
+	resourceRecord.Header.Set("WARC-Type", "resource")
+	resourceRecord.Header.Set("WARC-Target-URI", "dns:"+host)
+	if serverHost, _, err := net.SplitHostPort(server.String()); err == nil {
+		resourceRecord.Header.Set("WARC-IP-Address", serverHost)
+	}
+	resourceRecord.Header.Set("Content-Type", "text/dns")
+	resourceRecord.Content = strings.NewReader(content)
+
+	var batch = warc.NewRecordBatch()
+	batch.Records = append(batch.Records, resourceRecord)
+	recorder.c.setCollection(batch)
+	recorder.c.WARCWriter <- batch
+}
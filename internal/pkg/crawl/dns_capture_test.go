@@ -0,0 +1,104 @@
+package crawl
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newFakeDNSServer start a UDP DNS server answering 192.0.2.1 to the A
+// queries and nothing to the others, and return its address
+func newFakeDNSServer(t *testing.T) (addr string, close func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, client, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buffer[:n])
+			if err != nil {
+				continue
+			}
+			question, err := parser.Question()
+			if err != nil {
+				continue
+			}
+
+			builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+				ID:                 header.ID,
+				Response:           true,
+				RecursionAvailable: true,
+			})
+			builder.StartQuestions()
+			builder.Question(question)
+			builder.StartAnswers()
+			if question.Type == dnsmessage.TypeA {
+				builder.AResource(dnsmessage.ResourceHeader{
+					Name:  question.Name,
+					Class: dnsmessage.ClassINET,
+					TTL:   300,
+				}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})
+			}
+			response, _ := builder.Finish()
+
+			conn.WriteTo(response, client)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestCaptureDNSOncePerHost(t *testing.T) {
+	server, closeServer := newFakeDNSServer(t)
+	defer closeServer()
+
+	c := newTestCrawl()
+	c.WARCWriter = make(chan *warc.RecordBatch, 10)
+
+	// Every query is sent to the fake server
+	recorder := c.newDNSRecorder(func(ctx context.Context, network, address string) (net.Conn, error) {
+		return new(net.Dialer).DialContext(ctx, "udp", server)
+	})
+
+	for i := 0; i < 2; i++ {
+		addrs, err := recorder.resolver.LookupIPAddr(context.Background(), "example.test.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, addrs, 1) {
+			assert.Equal(t, "192.0.2.1", addrs[0].IP.String())
+		}
+	}
+	close(c.WARCWriter)
+
+	// The AAAA responses have no answer, so only the first A response is written
+	var records []*warc.Record
+	for batch := range c.WARCWriter {
+		records = append(records, batch.Records...)
+	}
+
+	if !assert.Len(t, records, 1) {
+		return
+	}
+
+	assert.Equal(t, "resource", records[0].Header.Get("WARC-Type"))
+	assert.Equal(t, "dns:example.test", records[0].Header.Get("WARC-Target-URI"))
+	assert.Equal(t, "text/dns", records[0].Header.Get("Content-Type"))
+	assert.Equal(t, "127.0.0.1", records[0].Header.Get("WARC-IP-Address"))
+
+	content, _ := ioutil.ReadAll(records[0].Content)
+	assert.Regexp(t, "^[0-9]{14}\nexample\\.test\\.\t300\tIN\tA\t192\\.0\\.2\\.1\n$", string(content))
+}
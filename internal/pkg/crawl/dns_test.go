@@ -0,0 +1,43 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSPrefetchCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	c := newTestCrawl()
+	c.DNSPrefetch = true
+	if err := c.initHTTPClient(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The prefetched addresses are cached
+	c.prefetchDNS("localhost:" + port)
+	assert.NotEmpty(t, c.DNSCache.get("localhost"))
+
+	// The client dials the cached addresses instead of resolving
+	// the host, that couldn't be resolved otherwise
+	c.DNSCache.set("zeno.invalid", []string{"127.0.0.1"})
+
+	resp, err := c.Client.Get("http://zeno.invalid:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "zeno.invalid:"+port, string(body))
+}
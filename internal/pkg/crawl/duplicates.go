@@ -0,0 +1,88 @@
+package crawl
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+)
+
+// maxNearDuplicateHashesPerHost is the number of SimHash kept per host
+// to compare new pages with, the oldest ones are forgotten first
+const maxNearDuplicateHashesPerHost = 256
+
+// maxNearDuplicateHosts is the number of hosts whose SimHash are kept,
+// the hosts that had a page captured the longest ago are forgotten first
+const maxNearDuplicateHosts = 1024
+
+type pageHash struct {
+	URL  string
+	Hash uint64
+}
+
+// hostPageHashes is the SimHash of the last pages captured for a host
+type hostPageHashes struct {
+	host   string
+	hashes []pageHash
+}
+
+// nearDuplicateIndex keep the SimHash of the last pages
+// captured for each host, to detect near-duplicate pages
+type nearDuplicateIndex struct {
+	sync.Mutex
+	hosts map[string]*list.Element
+	// order holds the hosts, from the least recently used to the most
+	order *list.List
+}
+
+func newNearDuplicateIndex() *nearDuplicateIndex {
+	return &nearDuplicateIndex{
+		hosts: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// check add the hash of a page to the index, and return the URL of the most
+// similar page of the same host if its similarity is at least the threshold
+func (index *nearDuplicateIndex) check(host, URL string, hash uint64, threshold float64) (duplicateOf string, similarity float64) {
+	index.Lock()
+	defer index.Unlock()
+
+	element, ok := index.hosts[host]
+	if ok {
+		index.order.MoveToBack(element)
+	} else {
+		element = index.order.PushBack(&hostPageHashes{host: host})
+		index.hosts[host] = element
+
+		if index.order.Len() > maxNearDuplicateHosts {
+			oldest := index.order.Remove(index.order.Front()).(*hostPageHashes)
+			delete(index.hosts, oldest.host)
+		}
+	}
+	stored := element.Value.(*hostPageHashes)
+
+	for _, previous := range stored.hashes {
+		previousSimilarity := utils.SimHashSimilarity(hash, previous.Hash)
+		if previousSimilarity >= threshold && previousSimilarity > similarity && previous.URL != URL {
+			duplicateOf = previous.URL
+			similarity = previousSimilarity
+		}
+	}
+
+	stored.hashes = append(stored.hashes, pageHash{URL: URL, Hash: hash})
+	if len(stored.hashes) > maxNearDuplicateHashesPerHost {
+		stored.hashes = stored.hashes[1:]
+	}
+
+	return duplicateOf, similarity
+}
+
+// nearDuplicateMetadata return the content of the metadata record
+// written for a page that is a near-duplicate of another page
+func nearDuplicateMetadata(hash uint64, duplicateOf string, similarity float64) string {
+	return "simhash: " + strconv.FormatUint(hash, 16) + "\r\n" +
+		"nearDuplicateOf: " + duplicateOf + "\r\n" +
+		"similarity: " + strconv.FormatFloat(similarity, 'f', 4, 64) + "\r\n"
+}
@@ -0,0 +1,32 @@
+package crawl
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearDuplicateIndexEvictsHosts(t *testing.T) {
+	index := newNearDuplicateIndex()
+
+	_, similarity := index.check("first.com", "https://first.com/a", 0xff, 0.9)
+	assert.Equal(t, float64(0), similarity)
+
+	// The first host stays as it keeps being used, the others
+	// are forgotten once there are too many hosts
+	for i := 0; i < maxNearDuplicateHosts*2; i++ {
+		host := "host-" + strconv.Itoa(i) + ".com"
+		index.check(host, "https://"+host+"/", uint64(i), 0.9)
+		index.check("first.com", "https://first.com/"+strconv.Itoa(i), uint64(i)<<32, 0.9)
+	}
+
+	assert.Len(t, index.hosts, maxNearDuplicateHosts)
+	assert.Equal(t, maxNearDuplicateHosts, index.order.Len())
+	assert.Contains(t, index.hosts, "first.com")
+	assert.NotContains(t, index.hosts, "host-0.com")
+
+	last := maxNearDuplicateHosts*2 - 1
+	duplicateOf, _ := index.check("first.com", "https://first.com/b", uint64(last)<<32, 0.9)
+	assert.Equal(t, "https://first.com/"+strconv.Itoa(last), duplicateOf)
+}
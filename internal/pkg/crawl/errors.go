@@ -0,0 +1,145 @@
+package crawl
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// errorCategory is the category of a failure,
+// used to aggregate the failures in the statistics
+type errorCategory string
+
+const (
+	errorCategoryDNS        errorCategory = "dns"
+	errorCategoryTimeout    errorCategory = "timeout"
+	errorCategoryTLS        errorCategory = "tls"
+	errorCategoryConnection errorCategory = "connection"
+	errorCategoryHTTP4xx    errorCategory = "http_4xx"
+	errorCategoryHTTP5xx    errorCategory = "http_5xx"
+	errorCategoryParse      errorCategory = "parse"
+	errorCategoryCancelled  errorCategory = "cancelled"
+	errorCategoryOther      errorCategory = "other"
+)
+
+// classifyError return the category of a failed capture, from the error
+// that occurred or from the status code of the response, it returns an
+// empty category if there is no error and the response is successful
+func classifyError(err error, resp *http.Response) errorCategory {
+	if err == nil {
+		switch {
+		case resp == nil || resp.StatusCode < 400:
+			return ""
+		case resp.StatusCode < 500:
+			return errorCategoryHTTP4xx
+		default:
+			return errorCategoryHTTP5xx
+		}
+	}
+
+	if errors.Is(err, errCaptureCancelled) {
+		return errorCategoryCancelled
+	}
+
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return errorCategoryDNS
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return errorCategoryTimeout
+	}
+
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var certificateInvalidError x509.CertificateInvalidError
+	var hostnameError x509.HostnameError
+	if errors.As(err, &unknownAuthorityError) || errors.As(err, &certificateInvalidError) ||
+		errors.As(err, &hostnameError) || strings.Contains(err.Error(), "tls: ") {
+		return errorCategoryTLS
+	}
+
+	var syntaxError *json.SyntaxError
+	var numError *strconv.NumError
+	var urlError *url.Error
+	if errors.As(err, &syntaxError) || errors.As(err, &numError) ||
+		(errors.As(err, &urlError) && urlError.Op == "parse") || strings.Contains(err.Error(), "malformed") {
+		return errorCategoryParse
+	}
+
+	var opError *net.OpError
+	if errors.As(err, &opError) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		isTransientNetworkError(err) {
+		return errorCategoryConnection
+	}
+
+	return errorCategoryOther
+}
+
+// errorStats count the failures of the crawl by category
+type errorStats struct {
+	sync.Mutex
+	categories map[errorCategory]int64
+}
+
+func newErrorStats() *errorStats {
+	return &errorStats{
+		categories: make(map[errorCategory]int64),
+	}
+}
+
+// Incr increment the counter of a category, empty categories are ignored
+func (stats *errorStats) Incr(category errorCategory) {
+	if category == "" {
+		return
+	}
+
+	stats.Lock()
+	stats.categories[category]++
+	stats.Unlock()
+}
+
+// Snapshot return a copy of the counters
+func (stats *errorStats) Snapshot() map[errorCategory]int64 {
+	stats.Lock()
+	defer stats.Unlock()
+
+	snapshot := make(map[errorCategory]int64, len(stats.categories))
+	for category, count := range stats.categories {
+		snapshot[category] = count
+	}
+
+	return snapshot
+}
+
+// String return the counters as "category: count" pairs,
+// sorted by decreasing count so the dominant failure comes first
+func (stats *errorStats) String() string {
+	snapshot := stats.Snapshot()
+
+	var categories []errorCategory
+	for category := range snapshot {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if snapshot[categories[i]] != snapshot[categories[j]] {
+			return snapshot[categories[i]] > snapshot[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	var pairs []string
+	for _, category := range categories {
+		pairs = append(pairs, string(category)+": "+strconv.FormatInt(snapshot[category], 10))
+	}
+
+	return strings.Join(pairs, ", ")
+}
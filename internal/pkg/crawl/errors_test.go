@@ -0,0 +1,45 @@
+package crawl
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	for category, err := range map[errorCategory]error{
+		errorCategoryDNS:        &url.Error{Op: "Get", URL: "https://example.invalid", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}},
+		errorCategoryTimeout:    &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded},
+		errorCategoryTLS:        &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}},
+		errorCategoryConnection: &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+		errorCategoryParse:      &url.Error{Op: "parse", URL: "https://exa mple.com", Err: errors.New("invalid character \" \" in host name")},
+		errorCategoryOther:      errors.New("something else"),
+	} {
+		assert.Equal(t, category, classifyError(err, nil), err.Error())
+	}
+
+	assert.Equal(t, errorCategoryParse, classifyError(json.Unmarshal([]byte("{"), &struct{}{}), nil))
+	assert.Equal(t, errorCategory(""), classifyError(nil, &http.Response{StatusCode: 200}))
+	assert.Equal(t, errorCategory(""), classifyError(nil, &http.Response{StatusCode: 301}))
+	assert.Equal(t, errorCategoryHTTP4xx, classifyError(nil, &http.Response{StatusCode: 404}))
+	assert.Equal(t, errorCategoryHTTP5xx, classifyError(nil, &http.Response{StatusCode: 503}))
+}
+
+func TestErrorStats(t *testing.T) {
+	stats := newErrorStats()
+	stats.Incr(errorCategoryTimeout)
+	stats.Incr(errorCategoryHTTP5xx)
+	stats.Incr(errorCategoryHTTP5xx)
+	stats.Incr("")
+
+	assert.Equal(t, map[errorCategory]int64{errorCategoryTimeout: 1, errorCategoryHTTP5xx: 2}, stats.Snapshot())
+	assert.Equal(t, "http_5xx: 2, timeout: 1", stats.String())
+}
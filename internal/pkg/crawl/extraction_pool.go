@@ -0,0 +1,50 @@
+package crawl
+
+import "sync"
+
+// extractionPool limit the number of pages whose outlinks and assets are
+// extracted at the same time, unlike a sizedwaitgroup.SizedWaitGroup its
+// size can change while the crawl is running, a size of 0 means no limit
+type extractionPool struct {
+	sync.Mutex
+	available *sync.Cond
+	size      int
+	used      int
+}
+
+func newExtractionPool(size int) *extractionPool {
+	pool := &extractionPool{size: size}
+	pool.available = sync.NewCond(pool)
+
+	return pool
+}
+
+// Add wait for a slot in the pool and take it
+func (pool *extractionPool) Add() {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for pool.size > 0 && pool.used >= pool.size {
+		pool.available.Wait()
+	}
+	pool.used++
+}
+
+// Done release a slot taken with Add
+func (pool *extractionPool) Done() {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.used--
+	pool.available.Signal()
+}
+
+// Resize change the size of the pool, the slots already taken are
+// kept when it shrinks, no new slot is given until enough are released
+func (pool *extractionPool) Resize(size int) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.size = size
+	pool.available.Broadcast()
+}
@@ -0,0 +1,48 @@
+package crawl
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/sirupsen/logrus"
+)
+
+var failedItemsMutex sync.Mutex
+
+// initFailedItemsFile open the failed.jsonl file of the job, in which the
+// items that couldn't be captured are written, one JSON object per line
+func (c *Crawl) initFailedItemsFile() (err error) {
+	c.FailedItemsFile, err = os.OpenFile(path.Join(c.JobPath, "failed.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	return err
+}
+
+// writeFailedItem append an item that couldn't be captured to the
+// failed.jsonl file, it can then be replayed with --retry-failed
+func (c *Crawl) writeFailedItem(item *frontier.Item, captureErr error) {
+	c.Frontier.HostStats.IncrFailed(item.Host)
+	c.ErrorStats.Incr(classifyError(captureErr, nil))
+
+	if c.FailedItemsFile == nil {
+		return
+	}
+
+	line, err := json.Marshal(frontier.NewFailedItem(item, captureErr))
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to marshal failed item")
+		return
+	}
+
+	failedItemsMutex.Lock()
+	_, err = c.FailedItemsFile.Write(append(line, '\n'))
+	failedItemsMutex.Unlock()
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to write failed item")
+	}
+}
@@ -0,0 +1,180 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// maxFeedSize is the maximum size of a RSS or Atom feed that we parse
+const maxFeedSize = 10 * MB
+
+// feedContentType return true if the Content-Type of the response is the one
+// of a RSS or Atom feed, and maybeFeed if it is a generic XML Content-Type,
+// that needs its root element to be checked
+func feedContentType(resp *http.Response) (feed, maybeFeed bool) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/rss+xml", "application/atom+xml":
+		return true, false
+	case "application/xml", "text/xml", "application/rdf+xml":
+		return false, true
+	}
+
+	return false, false
+}
+
+// isFeedRoot return true if the root element of the XML
+// document is the one of a RSS, RDF or Atom feed
+func isFeedRoot(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			switch strings.ToLower(element.Name.Local) {
+			case "rss", "rdf", "feed":
+				return true
+			}
+			return false
+		}
+	}
+}
+
+// extractFromFeed parse a RSS or Atom feed and return the enclosures and
+// images of its items as assets, and the links to the items as outlinks
+func extractFromFeed(base *url.URL, body []byte) (assets, outlinks []url.URL, err error) {
+	var rawAssets, rawOutlinks []string
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		attributes := make(map[string]string)
+		for _, attribute := range element.Attr {
+			attributes[strings.ToLower(attribute.Name.Local)] = attribute.Value
+		}
+
+		switch strings.ToLower(element.Name.Local) {
+		case "link":
+			// Atom links have a href and a relation, enclosures are
+			// files like podcast episodes, RSS links are in the text
+			if href, exists := attributes["href"]; exists {
+				if strings.EqualFold(attributes["rel"], "enclosure") {
+					rawAssets = append(rawAssets, href)
+				} else {
+					rawOutlinks = append(rawOutlinks, href)
+				}
+				continue
+			}
+
+			var text string
+			if decoder.DecodeElement(&text, &element) == nil {
+				rawOutlinks = append(rawOutlinks, strings.TrimSpace(text))
+			}
+		case "guid":
+			// The guid of a RSS item is its URL unless isPermaLink is false
+			var text string
+			if decoder.DecodeElement(&text, &element) == nil && !strings.EqualFold(attributes["ispermalink"], "false") {
+				rawOutlinks = append(rawOutlinks, strings.TrimSpace(text))
+			}
+		case "enclosure", "content", "thumbnail":
+			// RSS enclosures and Media RSS content and thumbnails
+			if link, exists := attributes["url"]; exists {
+				rawAssets = append(rawAssets, link)
+			}
+		case "image":
+			// iTunes podcast images, the RSS channel image has its URL
+			// in a child <url> element that is handled below
+			if href, exists := attributes["href"]; exists {
+				rawAssets = append(rawAssets, href)
+			}
+		case "url", "icon", "logo":
+			var text string
+			if decoder.DecodeElement(&text, &element) == nil {
+				rawAssets = append(rawAssets, strings.TrimSpace(text))
+			}
+		}
+	}
+
+	// The relative URLs of a feed are relative to the feed's URL
+	assets = utils.DedupeURLs(utils.MakeAbsolute(base, utils.StringSliceToURLSlice(rawAssets)))
+	outlinks = utils.DedupeURLs(utils.MakeAbsolute(base, utils.StringSliceToURLSlice(rawOutlinks)))
+
+	return assets, outlinks, nil
+}
+
+// handleFeed extract the URLs of a captured RSS or Atom feed, the links to
+// its items are queued as outlinks and its enclosures are returned so they
+// can be captured as assets, isFeed is false if the response isn't a feed,
+// its body is then left unread
+func (c *Crawl) handleFeed(item *frontier.Item, resp *http.Response, respPath string) (assets []url.URL, isFeed bool) {
+	feed, maybeFeed := feedContentType(resp)
+	if !feed && !maybeFeed {
+		return nil, false
+	}
+
+	body, err := readResponseBody(resp, respPath, maxFeedSize)
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to read feed " + item.URL.String())
+		return nil, feed
+	}
+
+	// Generic XML documents that aren't feeds are
+	// given back their body for the HTML extraction
+	if !feed && !isFeedRoot(body) {
+		if respPath == "" {
+			resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), resp.Body))
+		}
+		return nil, false
+	}
+
+	assets, outlinks, err := extractFromFeed(resp.Request.URL, body)
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to parse feed " + item.URL.String())
+		return nil, true
+	}
+
+	if item.Hop < c.MaxHops && len(outlinks) > 0 {
+		c.queueOutlinksAsync(c.filterSchemes(outlinks), item)
+	}
+
+	if c.SameOriginAssets {
+		assets = c.filterCrossOriginAssets(item, assets)
+	}
+
+	return c.filterSchemes(assets), true
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func feedTestURLs(URLs []url.URL) (rawURLs []string) {
+	for _, URL := range URLs {
+		rawURLs = append(rawURLs, URL.String())
+	}
+	return rawURLs
+}
+
+func TestExtractFromRSSFeed(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+	<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/" xmlns:atom="http://www.w3.org/2005/Atom">
+		<channel>
+			<title>Podcast &amp; blog</title>
+			<link>https://example.com/</link>
+			<atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"/>
+			<image><url>/logo.png</url></image>
+			<itunes:image href="https://cdn.example.com/cover.jpg"/>
+			<item>
+				<title>Episode 1</title>
+				<link>https://example.com/episodes/1</link>
+				<guid isPermaLink="false">episode-1</guid>
+				<enclosure url="https://cdn.example.com/episode-1.mp3" length="123" type="audio/mpeg"/>
+				<media:thumbnail url="/thumbnails/1.jpg"/>
+			</item>
+			<item>
+				<title>Post</title>
+				<guid>https://example.com/posts/2</guid>
+			</item>
+		</channel>
+	</rss>`
+
+	base, _ := url.Parse("https://example.com/feed.xml")
+	assets, outlinks, err := extractFromFeed(base, []byte(feed))
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com/logo.png",
+		"https://cdn.example.com/cover.jpg",
+		"https://cdn.example.com/episode-1.mp3",
+		"https://example.com/thumbnails/1.jpg",
+	}, feedTestURLs(assets))
+	assert.ElementsMatch(t, []string{
+		"https://example.com/",
+		"https://example.com/feed.xml",
+		"https://example.com/episodes/1",
+		"https://example.com/posts/2",
+	}, feedTestURLs(outlinks))
+}
+
+func TestExtractFromAtomFeed(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="utf-8"?>
+	<feed xmlns="http://www.w3.org/2005/Atom">
+		<link href="https://example.org/"/>
+		<icon>/favicon.ico</icon>
+		<entry>
+			<link rel="alternate" href="/2003/12/13/atom03"/>
+			<link rel="enclosure" type="audio/mpeg" href="/audio/atom03.mp3"/>
+		</entry>
+	</feed>`
+
+	base, _ := url.Parse("https://example.org/feed.atom")
+	assets, outlinks, err := extractFromFeed(base, []byte(feed))
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"https://example.org/favicon.ico", "https://example.org/audio/atom03.mp3"}, feedTestURLs(assets))
+	assert.ElementsMatch(t, []string{"https://example.org/", "https://example.org/2003/12/13/atom03"}, feedTestURLs(outlinks))
+}
+
+func TestFeedDetection(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+
+	resp.Header.Set("Content-Type", "application/rss+xml; charset=utf-8")
+	feed, maybeFeed := feedContentType(resp)
+	assert.True(t, feed)
+	assert.False(t, maybeFeed)
+
+	resp.Header.Set("Content-Type", "text/xml")
+	feed, maybeFeed = feedContentType(resp)
+	assert.False(t, feed)
+	assert.True(t, maybeFeed)
+
+	resp.Header.Set("Content-Type", "text/html")
+	feed, maybeFeed = feedContentType(resp)
+	assert.False(t, feed)
+	assert.False(t, maybeFeed)
+
+	assert.True(t, isFeedRoot([]byte(`<?xml version="1.0"?><rss version="2.0"></rss>`)))
+	assert.True(t, isFeedRoot([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"></feed>`)))
+	assert.True(t, isFeedRoot([]byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"></rdf:RDF>`)))
+	assert.False(t, isFeedRoot([]byte(`<urlset><url><loc>https://example.com/</loc></url></urlset>`)))
+}
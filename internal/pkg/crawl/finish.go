@@ -19,7 +19,7 @@ func (crawl *Crawl) catchFinish() {
 
 	for {
 		time.Sleep(time.Second * 5)
-		if crawl.ActiveWorkers.Value() == 0 && crawl.Frontier.QueueCount.Value() == 0 && crawl.Finished.Get() == false && crawl.Crawled.Value() > 0 {
+		if crawl.ActiveWorkers.Value() == 0 && crawl.Frontier.QueueCount.Value() == 0 && crawl.ActiveSeeds.Deferred() == 0 && crawl.Finished.Get() == false && crawl.Crawled.Value() > 0 {
 			logrus.Warning("No additional URL to archive, finishing")
 			crawl.finish()
 			os.Exit(0)
@@ -43,6 +43,9 @@ func (crawl *Crawl) finish() {
 	crawl.WorkerPool.Wait()
 	logrus.Warning("All workers finished")
 
+	// The seeds deferred by --max-active-seeds were already dequeued
+	crawl.requeueDeferredSeeds()
+
 	// Once all workers are done, it means nothing more is actively send to
 	// the PushChan channel, we ask for the queue writer to terminate, and when
 	// it's done we close the channel safely.
@@ -58,32 +61,75 @@ func (crawl *Crawl) finish() {
 		<-crawl.WARCWriterFinish
 		close(crawl.WARCWriterFinish)
 		logrus.Warning("WARC writer closed")
+
+		// Index the last WARC files, that were closed with the writer
+		if crawl.WriteCDX {
+			crawl.indexWARCs()
+			logrus.Warning("WARC files indexed")
+		}
+	}
+
+	// Closing the failed items file
+	if crawl.FailedItemsFile != nil {
+		crawl.FailedItemsFile.Close()
 	}
 
 	// Closing the local queue used by the frontier
+	crawl.Frontier.QueueMutex.Lock()
 	crawl.Frontier.Queue.Close()
+	crawl.Frontier.QueueMutex.Unlock()
 	logrus.Warning("Frontier queue closed")
 
 	// Closing the seencheck database
 	if crawl.Seencheck {
-		crawl.Frontier.Seencheck.SeenDB.Close()
-		logrus.Warning("Seencheck database closed")
+		crawl.Frontier.Seencheck.Close()
+		logrus.Warning("Seencheck closed")
 	}
 
 	// Dumping hosts pool and frontier stats to disk
 	logrus.Warning("Dumping hosts pool and frontier stats to " + path.Join(crawl.Frontier.JobPath, "frontier.gob"))
 	crawl.Frontier.Save()
+	crawl.Frontier.HostPool.Close()
+
+	// Writing the final metrics
+	if len(crawl.MetricsDumpFile) > 0 {
+		err := crawl.writeMetricsFile()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning("Unable to write metrics dump file")
+		}
+	}
 
 	logrus.Warning("Finished")
 }
 
 func (crawl *Crawl) setupCloseHandler() {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 	logrus.Warning("CTRL+C catched.. cleaning up and exiting.")
-	signal.Stop(c)
-	close(c)
+
+	// The shutdown waits for the captures in progress to finish, a second
+	// CTRL+C cancels them, and a third one exits right away
+	go func() {
+		<-c
+		logrus.Warning("CTRL+C catched again.. cancelling the captures in progress.")
+		signal.Stop(c)
+		crawl.cancelCaptures()
+	}()
+
 	crawl.finish()
 	os.Exit(0)
 }
+
+// cancellationError return errCaptureCancelled if an error happened because
+// the captures in progress were cancelled, the partial response is then
+// discarded and the item is written in the failed items to be retried
+func (crawl *Crawl) cancellationError(err error) error {
+	if crawl.captureContext != nil && crawl.captureContext.Err() != nil {
+		return errCaptureCancelled
+	}
+
+	return err
+}
@@ -0,0 +1,84 @@
+package crawl
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// frontierHost is the share of the queue of a host, ranked
+// by the number of items queued for it
+type frontierHost struct {
+	Rank   int     `json:"rank"`
+	Host   string  `json:"host"`
+	Queued int64   `json:"queued"`
+	Share  float64 `json:"share"`
+}
+
+// frontierComposition is the composition of the queue at a given time,
+// the hosts are ordered from the one with the most items queued
+type frontierComposition struct {
+	Time   time.Time      `json:"time"`
+	Queued int64          `json:"queued"`
+	Hosts  []frontierHost `json:"hosts"`
+}
+
+// queueComposition return the number of items queued for each host
+// and its share of the queue, for the limit hosts with the most items
+// queued, a limit of 0 or less means all the hosts. Exported regularly,
+// it shows how the frontier evolves during the crawl.
+func (c *Crawl) queueComposition(limit int) frontierComposition {
+	var composition = frontierComposition{
+		Time:  time.Now().UTC(),
+		Hosts: make([]frontierHost, 0),
+	}
+
+	hosts := c.Frontier.HostPool.Composition()
+	for _, host := range hosts {
+		composition.Queued += host.Queued
+	}
+
+	for index, host := range hosts {
+		if limit > 0 && index >= limit {
+			break
+		}
+
+		composition.Hosts = append(composition.Hosts, frontierHost{
+			Rank:   index + 1,
+			Host:   host.Host,
+			Queued: host.Queued,
+			Share:  float64(host.Queued) / float64(composition.Queued),
+		})
+	}
+
+	return composition
+}
+
+// writeFrontierCompositionCSV write the composition of the queue as CSV,
+// with one row per host, the time column makes it possible to concatenate
+// the exports made during the crawl
+func writeFrontierCompositionCSV(w io.Writer, composition frontierComposition) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"time", "rank", "host", "queued", "share"})
+	if err != nil {
+		return err
+	}
+
+	for _, host := range composition.Hosts {
+		err = writer.Write([]string{
+			composition.Time.Format(time.RFC3339),
+			strconv.Itoa(host.Rank),
+			host.Host,
+			strconv.FormatInt(host.Queued, 10),
+			strconv.FormatFloat(host.Share, 'f', 6, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
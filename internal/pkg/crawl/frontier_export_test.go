@@ -0,0 +1,41 @@
+package crawl
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueComposition(t *testing.T) {
+	c := newTestCrawl()
+	c.Frontier.HostPool = new(frontier.HostPool)
+	c.Frontier.HostPool.Mutex = new(sync.Mutex)
+	c.Frontier.HostPool.Hosts = make(map[string]*ratecounter.Counter)
+
+	for host, count := range map[string]int{"a.com": 6, "b.com": 3, "c.com": 1} {
+		for i := 0; i < count; i++ {
+			c.Frontier.HostPool.Incr(host)
+		}
+	}
+
+	composition := c.queueComposition(2)
+	assert.Equal(t, int64(10), composition.Queued)
+	assert.Equal(t, []frontierHost{
+		{Rank: 1, Host: "a.com", Queued: 6, Share: 0.6},
+		{Rank: 2, Host: "b.com", Queued: 3, Share: 0.3},
+	}, composition.Hosts)
+
+	var output bytes.Buffer
+	assert.NoError(t, writeFrontierCompositionCSV(&output, c.queueComposition(0)))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "time,rank,host,queued,share", lines[0])
+		assert.True(t, strings.HasSuffix(lines[3], ",3,c.com,1,0.100000"))
+	}
+}
@@ -0,0 +1,287 @@
+package crawl
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/CorentinB/warc"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/net/proxy"
+)
+
+// ftpTimeout is the maximum time without any activity
+// on the FTP connections before a transfer is aborted
+const ftpTimeout = 30 * time.Second
+
+// ftpDialFunc open a TCP connection to an address, for the
+// control and data connections of a FTP transfer
+type ftpDialFunc func(address string) (net.Conn, error)
+
+// ftpDial return the function opening the connections of a FTP transfer,
+// they go through --proxy like the HTTP requests, unless the host is in
+// --bypass-proxy. Only a SOCKS5 proxy can carry FTP, so with another proxy
+// the transfer is refused instead of being made outside of the proxy.
+func (c *Crawl) ftpDial(URL *url.URL) (ftpDialFunc, error) {
+	var dialer = &net.Dialer{Timeout: ftpTimeout}
+
+	localAddr, err := c.localAddr()
+	if err != nil {
+		return nil, err
+	}
+	if localAddr != nil {
+		dialer.LocalAddr = localAddr
+	}
+
+	// Onion services are always requested through the proxy
+	var proxied = c.Proxy != "" && !utils.StringContainsSliceElements(URL.Host, c.BypassProxy)
+	if utils.IsOnionHost(URL.Host) {
+		if c.Proxy == "" {
+			return nil, errOnionWithoutProxy
+		}
+		proxied = true
+	}
+
+	if !proxied {
+		return func(address string) (net.Conn, error) {
+			return dialer.Dial("tcp", address)
+		}, nil
+	}
+
+	proxyURL, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" {
+		return nil, errors.New("FTP can't go through a " + proxyURL.Scheme + " proxy, only through a SOCKS5 one")
+	}
+
+	proxyDialer, err := proxy.FromURL(proxyURL, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(address string) (net.Conn, error) {
+		return proxyDialer.Dial("tcp", address)
+	}, nil
+}
+
+// captureFTP download a file over FTP and write it in the WARC as a
+// resource record, because FTP responses aren't HTTP responses
+func (c *Crawl) captureFTP(item *frontier.Item) (err error) {
+	var executionStart = time.Now()
+
+	// Download the file in a temporary file on disk, as we don't
+	// know its size beforehand
+	filePath := filepath.Join(c.JobPath, "temp", uuid.NewV4().String()+".temp")
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer markTempFileDone(filePath)
+
+	dial, err := c.ftpDial(item.URL)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	written, err := ftpRetrieve(item.URL, file, dial)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	c.Frontier.HostStats.AddBytes(item.Host, written)
+
+	if c.WARC {
+		contentType := mime.TypeByExtension(path.Ext(item.URL.Path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		var resourceRecord = warc.NewRecord()
+		resourceRecord.Header.Set("WARC-Type", "resource")
+		resourceRecord.Header.Set("WARC-Target-URI", utils.CleanURL(item.URL.String()))
+		resourceRecord.Header.Set("Content-Type", contentType)
+		resourceRecord.PayloadPath = filePath
+
+		var batch = warc.NewRecordBatch()
+		batch.Records = append(batch.Records, resourceRecord)
+		c.setCollection(batch)
+
+		// Wait for the record to be written before deleting the temporary file
+		batch.Done = make(chan bool)
+		c.WARCWriter <- batch
+		<-batch.Done
+
+		c.Crawled.Incr(1)
+	}
+
+	c.logCrawlSuccess(executionStart, 226, item, "")
+
+	return nil
+}
+
+// ftpRetrieve download the file at the given ftp:// URL and write it to w,
+// logging in anonymously unless the URL contains credentials
+func ftpRetrieve(URL *url.URL, w io.Writer, dial ftpDialFunc) (written int64, err error) {
+	host := URL.Host
+	if URL.Port() == "" {
+		host = net.JoinHostPort(URL.Hostname(), "21")
+	}
+
+	conn, err := dial(host)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ftpTimeout))
+
+	control := textproto.NewConn(conn)
+	_, _, err = control.ReadResponse(220)
+	if err != nil {
+		return 0, err
+	}
+
+	// Log in, anonymously by default
+	username, password := "anonymous", "anonymous@"
+	if URL.User != nil {
+		username = URL.User.Username()
+		if userPassword, set := URL.User.Password(); set {
+			password = userPassword
+		}
+	}
+
+	code, message, err := ftpCommand(control, 0, "USER %s", username)
+	if err != nil {
+		return 0, err
+	}
+
+	if code == 331 {
+		_, _, err = ftpCommand(control, 2, "PASS %s", password)
+		if err != nil {
+			return 0, err
+		}
+	} else if code != 230 {
+		return 0, errors.New("FTP login failed: " + message)
+	}
+
+	_, _, err = ftpCommand(control, 200, "TYPE I")
+	if err != nil {
+		return 0, err
+	}
+
+	// Open the data connection in passive mode
+	data, err := ftpPassive(control, URL.Hostname(), dial)
+	if err != nil {
+		return 0, err
+	}
+	defer data.Close()
+
+	_, _, err = ftpCommand(control, 1, "RETR %s", URL.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Copy the file while pushing back the deadline as long as data is coming
+	buffer := make([]byte, 32*1024)
+	for {
+		data.SetDeadline(time.Now().Add(ftpTimeout))
+		n, readErr := data.Read(buffer)
+		if n > 0 {
+			_, err = w.Write(buffer[:n])
+			if err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+	data.Close()
+
+	conn.SetDeadline(time.Now().Add(ftpTimeout))
+	_, _, err = control.ReadResponse(2)
+	if err != nil {
+		return written, err
+	}
+
+	ftpCommand(control, 221, "QUIT")
+
+	return written, nil
+}
+
+// ftpCommand send a command on the control connection and read its response,
+// see textproto.Conn.ReadResponse for the meaning of expectCode
+func ftpCommand(control *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	_, err := control.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return control.ReadResponse(expectCode)
+}
+
+// ftpPassive ask the server for a passive data connection, with EPSV and
+// then PASV for older servers, and connect to it
+func ftpPassive(control *textproto.Conn, hostname string, dial ftpDialFunc) (net.Conn, error) {
+	var port int
+
+	_, message, err := ftpCommand(control, 229, "EPSV")
+	if err == nil {
+		// 229 Entering Extended Passive Mode (|||port|)
+		start, end := strings.Index(message, "(|||"), strings.LastIndex(message, "|)")
+		if start == -1 || end <= start+4 {
+			return nil, errors.New("invalid EPSV response: " + message)
+		}
+
+		port, err = strconv.Atoi(message[start+4 : end])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_, message, err = ftpCommand(control, 227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+
+		// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2), we ignore the
+		// address and connect to the same host as the control connection
+		start, end := strings.Index(message, "("), strings.LastIndex(message, ")")
+		if start == -1 || end <= start {
+			return nil, errors.New("invalid PASV response: " + message)
+		}
+
+		fields := strings.Split(message[start+1:end], ",")
+		if len(fields) != 6 {
+			return nil, errors.New("invalid PASV response: " + message)
+		}
+
+		high, err := strconv.Atoi(strings.TrimSpace(fields[4]))
+		if err != nil {
+			return nil, err
+		}
+		low, err := strconv.Atoi(strings.TrimSpace(fields[5]))
+		if err != nil {
+			return nil, err
+		}
+		port = high<<8 + low
+	}
+
+	return dial(net.JoinHostPort(hostname, strconv.Itoa(port)))
+}
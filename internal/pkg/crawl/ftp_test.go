@@ -0,0 +1,235 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeFTPServer start a FTP server serving a single file at
+// the given path, allowing only anonymous logins
+func newFakeFTPServer(t *testing.T, filePath, content string) (addr string, close func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var dataListener net.Listener
+
+		fmt.Fprint(conn, "220 Fake FTP\r\n")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.SplitN(strings.TrimSpace(line), " ", 2)
+
+			switch command[0] {
+			case "USER":
+				if command[1] != "anonymous" {
+					fmt.Fprint(conn, "530 Only anonymous\r\n")
+					continue
+				}
+				fmt.Fprint(conn, "331 Password please\r\n")
+			case "PASS":
+				fmt.Fprint(conn, "230 Logged in\r\n")
+			case "TYPE":
+				fmt.Fprint(conn, "200 Binary\r\n")
+			case "EPSV":
+				dataListener, _ = net.Listen("tcp", "127.0.0.1:0")
+				fmt.Fprintf(conn, "229 Entering Extended Passive Mode (|||%d|)\r\n", dataListener.Addr().(*net.TCPAddr).Port)
+			case "RETR":
+				if command[1] != filePath {
+					fmt.Fprint(conn, "550 No such file\r\n")
+					continue
+				}
+				fmt.Fprint(conn, "150 Sending\r\n")
+				data, err := dataListener.Accept()
+				if err != nil {
+					return
+				}
+				data.Write([]byte(content))
+				data.Close()
+				dataListener.Close()
+				fmt.Fprint(conn, "226 Done\r\n")
+			case "QUIT":
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "502 Not implemented\r\n")
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestFTPRetrieve(t *testing.T) {
+	addr, close := newFakeFTPServer(t, "/pub/readme.txt", "hello from FTP")
+	defer close()
+
+	URL, _ := url.Parse("ftp://" + addr + "/pub/readme.txt")
+
+	dial, err := new(Crawl).ftpDial(URL)
+	assert.NoError(t, err)
+
+	var file bytes.Buffer
+	written, err := ftpRetrieve(URL, &file, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(14), written)
+	assert.Equal(t, "hello from FTP", file.String())
+
+	URL, _ = url.Parse("ftp://" + addr + "/pub/missing.txt")
+	_, err = ftpRetrieve(URL, &file, dial)
+	assert.Error(t, err)
+}
+
+// newFakeSOCKS5Proxy start a SOCKS5 proxy without authentication
+// that count the connections it relays
+func newFakeSOCKS5Proxy(t *testing.T) (addr string, relayed *int32, close func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relayed = new(int32)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				// Greeting, answered with "no authentication"
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+
+				// CONNECT request with an IPv4 address or a domain name
+				request := make([]byte, 4)
+				if _, err := io.ReadFull(conn, request); err != nil {
+					return
+				}
+				var host string
+				switch request[3] {
+				case 1:
+					ip := make([]byte, 4)
+					if _, err := io.ReadFull(conn, ip); err != nil {
+						return
+					}
+					host = net.IP(ip).String()
+				case 3:
+					length := make([]byte, 1)
+					if _, err := io.ReadFull(conn, length); err != nil {
+						return
+					}
+					name := make([]byte, length[0])
+					if _, err := io.ReadFull(conn, name); err != nil {
+						return
+					}
+					host = string(name)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				if _, err := io.ReadFull(conn, port); err != nil {
+					return
+				}
+
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(relayed, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String(), relayed, func() { listener.Close() }
+}
+
+func TestFTPRetrieveThroughProxy(t *testing.T) {
+	addr, close := newFakeFTPServer(t, "/pub/readme.txt", "hello from FTP")
+	defer close()
+
+	proxyAddr, relayed, closeProxy := newFakeSOCKS5Proxy(t)
+	defer closeProxy()
+
+	URL, _ := url.Parse("ftp://" + addr + "/pub/readme.txt")
+
+	c := new(Crawl)
+	c.Proxy = "socks5://" + proxyAddr
+
+	dial, err := c.ftpDial(URL)
+	assert.NoError(t, err)
+
+	var file bytes.Buffer
+	_, err = ftpRetrieve(URL, &file, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello from FTP", file.String())
+
+	// Both the control and the data connections went through the proxy
+	assert.Equal(t, int32(2), atomic.LoadInt32(relayed))
+
+	// The bypassed hosts are dialed directly
+	c.BypassProxy = []string{"127.0.0.1"}
+
+	dial, err = c.ftpDial(URL)
+	assert.NoError(t, err)
+
+	file.Reset()
+	_, err = ftpRetrieve(URL, &file, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(relayed))
+}
+
+func TestFTPDialRefusesHTTPProxy(t *testing.T) {
+	URL, _ := url.Parse("ftp://ftp.example.com/pub/readme.txt")
+
+	c := new(Crawl)
+	c.Proxy = "http://127.0.0.1:8080"
+
+	_, err := c.ftpDial(URL)
+	assert.Error(t, err)
+
+	URL, _ = url.Parse("ftp://example.onion/pub/readme.txt")
+
+	_, err = new(Crawl).ftpDial(URL)
+	assert.Equal(t, errOnionWithoutProxy, err)
+}
@@ -0,0 +1,95 @@
+package crawl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+)
+
+// reportToGroup is an endpoint group of a Report-To header
+type reportToGroup struct {
+	Endpoints []struct {
+		URL string `json:"url"`
+	} `json:"endpoints"`
+}
+
+// extractCSPURLs return the URLs of the report endpoints and of the
+// origins allowed by the Content-Security-Policy, Report-To and
+// Reporting-Endpoints headers of a response
+func extractCSPURLs(base *url.URL, header http.Header) (URLs []url.URL) {
+	var rawURLs []string
+
+	policies := append(header.Values("Content-Security-Policy"), header.Values("Content-Security-Policy-Report-Only")...)
+	for _, policy := range policies {
+		for _, directive := range strings.Split(policy, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) < 2 {
+				continue
+			}
+
+			// report-to references a group of the Report-To header by its name
+			name := strings.ToLower(fields[0])
+			if name == "report-to" || name == "sandbox" {
+				continue
+			}
+
+			for _, source := range fields[1:] {
+				if name == "report-uri" {
+					rawURLs = append(rawURLs, source)
+					continue
+				}
+
+				if sourceURL := cspSourceURL(base, source); sourceURL != "" {
+					rawURLs = append(rawURLs, sourceURL)
+				}
+			}
+		}
+	}
+
+	// Report-To is a comma-separated list of JSON objects
+	for _, reportTo := range header.Values("Report-To") {
+		var groups []reportToGroup
+		if json.Unmarshal([]byte("["+reportTo+"]"), &groups) != nil {
+			continue
+		}
+
+		for _, group := range groups {
+			for _, endpoint := range group.Endpoints {
+				rawURLs = append(rawURLs, endpoint.URL)
+			}
+		}
+	}
+
+	// Reporting-Endpoints is a list of name="URL" pairs
+	for _, endpoints := range header.Values("Reporting-Endpoints") {
+		for _, endpoint := range strings.Split(endpoints, ",") {
+			if index := strings.Index(endpoint, "="); index != -1 {
+				rawURLs = append(rawURLs, strings.Trim(strings.TrimSpace(endpoint[index+1:]), `"`))
+			}
+		}
+	}
+
+	URLs = utils.StringSliceToURLSlice(rawURLs)
+	URLs = utils.MakeAbsolute(base, URLs)
+
+	return utils.DedupeURLs(URLs)
+}
+
+// cspSourceURL return the URL of the origin described by a CSP source
+// expression, or an empty string for the keywords, nonces, hashes,
+// scheme-only sources and wildcard hosts that don't describe a single origin
+func cspSourceURL(base *url.URL, source string) string {
+	if strings.HasPrefix(source, "'") || strings.Contains(source, "*") || strings.HasSuffix(source, ":") {
+		return ""
+	}
+
+	// Host sources without a scheme use the scheme of the protected page
+	if !strings.Contains(source, "://") {
+		source = base.Scheme + "://" + source
+	}
+
+	return source
+}
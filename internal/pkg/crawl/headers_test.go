@@ -0,0 +1,35 @@
+package crawl
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractCSPURLs(t *testing.T) {
+	base, _ := url.Parse("https://example.com/page")
+
+	header := make(http.Header)
+	header.Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data: https://img.example.net *.cdn.example.com; "+
+		"script-src 'nonce-abc' static.example.org; report-uri /csp-report; report-to csp-endpoint")
+	header.Set("Report-To", `{"group":"csp-endpoint","max_age":10886400,"endpoints":[{"url":"https://reports.example.com/csp"}]}, `+
+		`{"group":"nel","endpoints":[{"url":"https://reports.example.com/nel"}]}`)
+	header.Set("Reporting-Endpoints", `main="https://reports.example.com/main", default="/default-report"`)
+
+	var URLs []string
+	for _, URL := range extractCSPURLs(base, header) {
+		URLs = append(URLs, URL.String())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"https://img.example.net",
+		"https://static.example.org",
+		"https://example.com/csp-report",
+		"https://reports.example.com/csp",
+		"https://reports.example.com/nel",
+		"https://reports.example.com/main",
+		"https://example.com/default-report",
+	}, URLs)
+}
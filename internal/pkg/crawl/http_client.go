@@ -1,15 +1,24 @@
 package crawl
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptrace"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,6 +27,29 @@ type customTransport struct {
 	c *Crawl
 }
 
+type requestTimingKey struct{}
+
+// requestTiming holds the timing breakdown of a request,
+// filled using httptrace
+type requestTiming struct {
+	Start        time.Time
+	DNSStart     time.Time
+	DNSDone      time.Time
+	ConnectStart time.Time
+	ConnectDone  time.Time
+	TLSStart     time.Time
+	TLSDone      time.Time
+	WroteRequest time.Time
+	FirstByte    time.Time
+}
+
+// getRequestTiming return the timing breakdown attached to
+// a request by the custom transport, if any
+func getRequestTiming(req *http.Request) *requestTiming {
+	timing, _ := req.Context().Value(requestTimingKey{}).(*requestTiming)
+	return timing
+}
+
 func isRedirection(statusCode int) bool {
 	if statusCode == 300 || statusCode == 301 ||
 		statusCode == 302 || statusCode == 307 ||
@@ -27,17 +59,243 @@ func isRedirection(statusCode int) bool {
 	return false
 }
 
+// maxRefreshRedirectDelay is the maximum delay in seconds of a Refresh
+// header followed as a redirection, longer delays are used by pages that
+// reload themselves periodically rather than by redirections
+const maxRefreshRedirectDelay = 5
+
+// parseRefreshHeader parse a Refresh header like "0; url=https://example.com/",
+// the delay can be followed by a semicolon or a comma, the url= is
+// optional and the URL can be quoted
+func parseRefreshHeader(value string) (delay float64, target string, ok bool) {
+	value = strings.TrimSpace(value)
+
+	end := strings.IndexAny(value, ";,")
+	if end == -1 {
+		end = len(value)
+	}
+
+	delay, err := strconv.ParseFloat(strings.TrimSpace(value[:end]), 64)
+	if err != nil || delay < 0 {
+		return 0, "", false
+	}
+
+	if end == len(value) {
+		return delay, "", true
+	}
+
+	target = strings.TrimSpace(value[end+1:])
+	if len(target) >= 4 && strings.EqualFold(target[:3], "url") {
+		if rest := strings.TrimSpace(target[3:]); strings.HasPrefix(rest, "=") {
+			target = strings.TrimSpace(rest[1:])
+		}
+	}
+	target = strings.Trim(target, `"'`)
+
+	return delay, target, true
+}
+
+// redirectLocation return the location a response redirects to, either with
+// a redirection status code and a Location header, or with a Refresh header
+// with a small delay, it returns an empty string if the response isn't
+// a redirection
+func redirectLocation(resp *http.Response) string {
+	if isRedirection(resp.StatusCode) {
+		return resp.Header.Get("location")
+	}
+
+	if refresh := resp.Header.Get("Refresh"); refresh != "" {
+		delay, target, ok := parseRefreshHeader(refresh)
+		if ok && target != "" && delay <= maxRefreshRedirectDelay {
+			return target
+		}
+	}
+
+	return ""
+}
+
+// locationHint return the URL of the Location header of a successful
+// response, that isn't a redirection but may point to the page the server
+// meant to send the client to, or nil if there is none
+func locationHint(resp *http.Response) *url.URL {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil
+	}
+
+	URL, err := resp.Request.URL.Parse(utils.CleanURL(location))
+	if err != nil || URL.String() == resp.Request.URL.String() {
+		return nil
+	}
+
+	return URL
+}
+
+// hasNoBody return true if the status code is one of the
+// responses that never have a body: 204 No Content,
+// 205 Reset Content and 304 Not Modified
+func hasNoBody(statusCode int) bool {
+	return statusCode == http.StatusNoContent ||
+		statusCode == http.StatusResetContent ||
+		statusCode == http.StatusNotModified
+}
+
+// isTransientNetworkError return true if the error is a network error that
+// is likely to not happen again if the request is retried right away, like
+// a timeout, a connection reset or a temporary DNS failure
+func isTransientNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return dnsError.IsTemporary || dnsError.IsTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// withJitter return the duration shifted by a random amount of up to
+// fraction times the duration in either direction, so that the waits
+// don't happen at regular intervals, a fraction of 0 disables the jitter
+func withJitter(duration time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return duration
+	}
+
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	return duration + time.Duration((rand.Float64()*2-1)*fraction*float64(duration))
+}
+
+// isIdempotentMethod return true if sending a request with the method
+// several times has the same effect on the server as sending it once
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// roundTripWithNetworkRetry execute the request, and retry it in place
+// up to --max-network-retry times with a short backoff if it failed
+// because of a transient network error. The requests with a method that
+// isn't idempotent are only retried if none of the request was written,
+// as the server may have processed it.
+func (t *customTransport) roundTripWithNetworkRetry(req *http.Request) (resp *http.Response, err error) {
+	var sleepTime = time.Millisecond * 100
+
+	for i := 0; ; i++ {
+		// Wait for the throttling of the host and of the crawl
+		var release func()
+		release, err = t.c.throttle(req.Context(), req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		var written int32
+		var attempt = req
+		if !isIdempotentMethod(req.Method) {
+			attempt = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				WroteHeaders: func() {
+					atomic.StoreInt32(&written, 1)
+				},
+			}))
+		}
+
+		resp, err = t.Transport.RoundTrip(attempt)
+		release()
+		if err == nil || i >= t.c.MaxNetworkRetry || !isTransientNetworkError(err) || t.c.Finished.Get() {
+			return resp, err
+		}
+
+		if atomic.LoadInt32(&written) == 1 {
+			return resp, err
+		}
+
+		logInfo.WithFields(logrus.Fields{
+			"url":         req.URL.String(),
+			"error":       err,
+			"retry_count": i,
+			"duration":    sleepTime.String(),
+		}).Info("Transient network error, sleeping then retrying..")
+		time.Sleep(sleepTime)
+		sleepTime = sleepTime * 2
+
+		// If the request has a body, we need a fresh copy of it
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
 func (t *customTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	// Use httptrace to increment the URI/s counter on DNS requests.
+	var timing = new(requestTiming)
+
+	// Use httptrace to increment the URI/s counter on DNS requests,
+	// and to keep track of the timing breakdown of the request.
 	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			timing.DNSStart = time.Now()
+		},
 		DNSDone: func(dnsInfo httptrace.DNSDoneInfo) {
+			timing.DNSDone = time.Now()
 			t.c.URIsPerSecond.Incr(1)
 		},
+		ConnectStart: func(network, addr string) {
+			timing.ConnectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			timing.ConnectDone = time.Now()
+		},
+		TLSHandshakeStart: func() {
+			timing.TLSStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timing.TLSDone = time.Now()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			timing.WroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			timing.FirstByte = time.Now()
+		},
 	}
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	ctx := context.WithValue(req.Context(), requestTimingKey{}, timing)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
 
 	req.Header.Set("User-Agent", t.c.UserAgent)
-	req.Header.Set("Accept", "*/*")
+	// The Accept header may have been set for this request already
+	if req.Header.Get("Accept") == "" {
+		if t.c.Accept != "" {
+			req.Header.Set("Accept", t.c.Accept)
+		} else {
+			req.Header.Set("Accept", "*/*")
+		}
+	}
+	t.c.setAuthorization(req)
 
 	// Retry on request errors and rate limiting.
 	var sleepTime = time.Millisecond * 250
@@ -49,7 +307,8 @@ func (t *customTransport) RoundTrip(req *http.Request) (resp *http.Response, err
 			resp.Body.Close()
 		}
 
-		resp, err = t.Transport.RoundTrip(req)
+		timing.Start = time.Now()
+		resp, err = t.roundTripWithNetworkRetry(req)
 		if err != nil {
 			logWarning.WithFields(logrus.Fields{
 				"url":   req.URL.String(),
@@ -65,7 +324,7 @@ func (t *customTransport) RoundTrip(req *http.Request) (resp *http.Response, err
 
 		// Check for status code. When we encounter an error or some rate limiting,
 		// we exponentially backoff between retries.
-		if string(strconv.Itoa(resp.StatusCode)[0]) != "2" && isRedirection(resp.StatusCode) == false {
+		if string(strconv.Itoa(resp.StatusCode)[0]) != "2" && isRedirection(resp.StatusCode) == false && !hasNoBody(resp.StatusCode) {
 			// If we get a 404, we do not waste any time retrying
 			if resp.StatusCode == 404 {
 				return resp, nil
@@ -76,13 +335,14 @@ func (t *customTransport) RoundTrip(req *http.Request) (resp *http.Response, err
 			// TODO: If the response include the "Retry-After" header, we use it to sleep for the appropriate time before retrying.
 			if resp.StatusCode == 429 {
 				sleepTime = sleepTime * time.Duration(exponentFactor)
+				jitteredSleepTime := withJitter(sleepTime, t.c.RateLimitJitter)
 				logInfo.WithFields(logrus.Fields{
 					"url":         req.URL.String(),
-					"duration":    sleepTime.String(),
+					"duration":    jitteredSleepTime.String(),
 					"retry_count": i,
 					"status_code": resp.StatusCode,
 				}).Info("We are being rate limited, sleeping then retrying..")
-				time.Sleep(sleepTime)
+				time.Sleep(jitteredSleepTime)
 				continue
 			}
 
@@ -97,6 +357,35 @@ func (t *customTransport) RoundTrip(req *http.Request) (resp *http.Response, err
 	return resp, nil
 }
 
+// verifyConnection validate the certificate chain of a TLS connection if
+// --cert-validation is turned on and the host isn't in --insecure-hosts
+func (crawl *Crawl) verifyConnection(state tls.ConnectionState) error {
+	if !crawl.CertValidation || utils.IsHostExcluded(state.ServerName, crawl.InsecureHosts) {
+		return nil
+	}
+
+	return verifyCertificateChain(state.PeerCertificates, state.ServerName)
+}
+
+// verifyCertificateChain validate a certificate chain presented for
+// hostname against the system's roots, like the default TLS verification
+func verifyCertificateChain(certificates []*x509.Certificate, hostname string) error {
+	if len(certificates) == 0 {
+		return errors.New("no certificate presented by " + hostname)
+	}
+
+	var options = x509.VerifyOptions{
+		DNSName:       hostname,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, certificate := range certificates[1:] {
+		options.Intermediates.AddCert(certificate)
+	}
+
+	_, err := certificates[0].Verify(options)
+	return err
+}
+
 func (crawl *Crawl) initHTTPClient() (err error) {
 	var customTransport = new(customTransport)
 
@@ -107,14 +396,56 @@ func (crawl *Crawl) initHTTPClient() (err error) {
 	customTransport.TLSHandshakeTimeout = 15 * time.Second
 	customTransport.ExpectContinueTimeout = 1 * time.Second
 	customTransport.TLSNextProto = make(map[string]func(authority string, c *tls.Conn) http.RoundTripper)
+	// The default verification is skipped in favor of verifyConnection,
+	// that only validates the certificates if it is asked for the host
 	customTransport.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: true,
+		VerifyConnection:   crawl.verifyConnection,
 	}
-	customTransport.DialContext = (&net.Dialer{
+	var dialer = &net.Dialer{
 		Timeout:   10 * time.Second,
 		KeepAlive: 30 * time.Second,
 		DualStack: true,
-	}).DialContext
+	}
+
+	// If asked, the connections of both clients are made
+	// from a specific local IP address
+	localAddr, err := crawl.localAddr()
+	if err != nil {
+		return err
+	}
+	if localAddr != nil {
+		dialer.LocalAddr = localAddr
+	}
+
+	// The DNS responses are written in the WARC
+	// by resolving the hosts with a recorder
+	if crawl.WARC && crawl.CaptureDNS {
+		dialer.Resolver = crawl.newDNSRecorder((&net.Dialer{Timeout: 5 * time.Second}).DialContext).resolver
+	}
+	crawl.resolver = dialer.Resolver
+	if crawl.resolver == nil {
+		crawl.resolver = net.DefaultResolver
+	}
+	customTransport.DialContext = dialer.DialContext
+
+	// If asked, every connection is made from
+	// a random address of an IPv6 prefix
+	if crawl.LocalIPv6Range != "" {
+		ipRange, err := parseLocalIPv6Range(crawl.LocalIPv6Range)
+		if err != nil {
+			return err
+		}
+
+		customTransport.DialContext = (&randomLocalIPDialer{dialer: dialer, ipRange: ipRange}).DialContext
+	}
+
+	// The hosts resolved by the DNS prefetcher are dialed
+	// with the addresses it cached, without resolving them again
+	if crawl.DNSPrefetch {
+		crawl.DNSCache = newDNSCache()
+		customTransport.DialContext = crawl.DNSCache.dialContext(customTransport.DialContext)
+	}
 
 	var customClient = &http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -123,6 +454,17 @@ func (crawl *Crawl) initHTTPClient() (err error) {
 		Transport: customTransport,
 	}
 
+	// If we need to login, we keep the cookies in a jar
+	// so the session is shared by all the requests
+	if len(crawl.LoginURL) > 0 {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		crawl.CookieJar = newLimitedCookieJar(jar, crawl.MaxCookies, crawl.MaxCookiesSize)
+		customClient.Jar = crawl.CookieJar
+	}
+
 	crawl.Client = customClient
 
 	// Set proxy if one is specified
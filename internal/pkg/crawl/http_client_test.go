@@ -0,0 +1,181 @@
+package crawl
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientNetworkError(t *testing.T) {
+	assert.False(t, isTransientNetworkError(nil))
+	assert.False(t, isTransientNetworkError(errors.New("some error")))
+
+	// Connection reset
+	assert.True(t, isTransientNetworkError(&net.OpError{
+		Op:  "read",
+		Net: "tcp",
+		Err: os.NewSyscallError("read", syscall.ECONNRESET),
+	}))
+	assert.True(t, isTransientNetworkError(io.ErrUnexpectedEOF))
+
+	// DNS failures
+	assert.True(t, isTransientNetworkError(&net.DNSError{Err: "server misbehaving", IsTemporary: true}))
+	assert.True(t, isTransientNetworkError(&net.DNSError{Err: "i/o timeout", IsTimeout: true}))
+	assert.False(t, isTransientNetworkError(&net.DNSError{Err: "no such host", IsNotFound: true}))
+}
+
+func TestNetworkRetryIdempotentMethods(t *testing.T) {
+	var requests = make(map[string]int)
+	var requestsMutex sync.Mutex
+
+	// The connection is reset once the request is read
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+
+		requestsMutex.Lock()
+		requests[r.Method]++
+		requestsMutex.Unlock()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.MaxNetworkRetry = 2
+
+	for _, method := range []string{"GET", "PUT", "POST"} {
+		req, err := http.NewRequest(method, server.URL, strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Client.Do(req)
+		assert.Error(t, err)
+	}
+
+	// The requests that were written are only retried if they are idempotent
+	requestsMutex.Lock()
+	defer requestsMutex.Unlock()
+
+	assert.Equal(t, 3, requests["GET"])
+	assert.Equal(t, 3, requests["PUT"])
+	assert.Equal(t, 1, requests["POST"])
+}
+
+func TestVerifyConnectionInsecureHosts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The test server's certificate is self-signed
+	URL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	c := newTestCrawl()
+	c.MaxRetry = 0
+
+	// Certificates aren't validated by default
+	resp, err := c.Client.Get(URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	// Connections are verified when established, so the
+	// kept-alive connections must not be reused
+	c.CertValidation = true
+	c.Client.CloseIdleConnections()
+	_, err = c.Client.Get(URL)
+	assert.Error(t, err)
+
+	c.InsecureHosts = []string{"localhost"}
+	resp, err = c.Client.Get(URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	assert.Equal(t, time.Second, withJitter(time.Second, 0))
+
+	for i := 0; i < 100; i++ {
+		duration := withJitter(time.Second, 0.5)
+		assert.True(t, duration >= 500*time.Millisecond)
+		assert.True(t, duration <= 1500*time.Millisecond)
+	}
+
+	// The jitter never makes the wait negative
+	for i := 0; i < 100; i++ {
+		assert.True(t, withJitter(time.Second, 3) >= 0)
+	}
+}
+
+func TestAcceptHeader(t *testing.T) {
+	var accept string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+
+	get := func(req *http.Request) {
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	get(req)
+	assert.Equal(t, "*/*", accept)
+
+	c.Accept = "application/json"
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	get(req)
+	assert.Equal(t, "application/json", accept)
+
+	// A request asking for a specific type keeps it
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Accept", "text/csv")
+	get(req)
+	assert.Equal(t, "text/csv", accept)
+}
+
+func TestParseRefreshHeader(t *testing.T) {
+	for _, test := range []struct {
+		value  string
+		delay  float64
+		target string
+		ok     bool
+	}{
+		{"0; url=https://example.com/new", 0, "https://example.com/new", true},
+		{"0;URL='/new'", 0, "/new", true},
+		{"3, url=\"/new\"", 3, "/new", true},
+		{"0; /new", 0, "/new", true},
+		{"1.5;url=/new", 1.5, "/new", true},
+		{"30", 30, "", true},
+		{"soon; url=/new", 0, "", false},
+		{"-1; url=/new", 0, "", false},
+	} {
+		delay, target, ok := parseRefreshHeader(test.value)
+		assert.Equal(t, test.ok, ok, test.value)
+		assert.Equal(t, test.delay, delay, test.value)
+		assert.Equal(t, test.target, target, test.value)
+	}
+}
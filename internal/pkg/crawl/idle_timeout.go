@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// idleTimer cancel a request when it is not reset before the timeout
+type idleTimer struct {
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+// withIdleTimeout return a copy of the request that is cancelled if no
+// data is received for the given duration, from the connection to the
+// end of the body
+func withIdleTimeout(req *http.Request, timeout time.Duration) (*http.Request, *idleTimer) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	timer := &idleTimer{
+		timer:   time.AfterFunc(timeout, cancel),
+		timeout: timeout,
+		cancel:  cancel,
+	}
+
+	return req.WithContext(ctx), timer
+}
+
+// reset push back the deadline, because data was received
+func (t *idleTimer) reset() {
+	t.timer.Reset(t.timeout)
+}
+
+// stop release the timer and the context of the request
+func (t *idleTimer) stop() {
+	t.timer.Stop()
+	t.cancel()
+}
+
+// idleTimeoutBody is a response body that push back the deadline
+// of its request every time some data is read
+type idleTimeoutBody struct {
+	body  io.ReadCloser
+	timer *idleTimer
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (n int, err error) {
+	n, err = b.body.Read(p)
+	if n > 0 {
+		b.timer.reset()
+	}
+
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.stop()
+	return b.body.Close()
+}
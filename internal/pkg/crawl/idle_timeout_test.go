@@ -0,0 +1,123 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSlowStreamServer return a server that write its body in chunks,
+// waiting for the given pause before each chunk
+func newSlowStreamServer(chunks int, pause time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < chunks; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(pause):
+			}
+
+			w.Write([]byte(strconv.Itoa(i)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func executeTestGET(t *testing.T, c *Crawl, server *httptest.Server) ([]byte, error) {
+	URL, _ := url.Parse(server.URL + "/segment.ts")
+	item := frontier.NewItem(URL, nil, "asset", 0)
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+
+	resp, _, err := c.executeGET(item, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func TestReadIdleTimeoutSteadyStream(t *testing.T) {
+	// The whole download takes longer than the timeout, but data
+	// keeps coming more often than the timeout
+	server := newSlowStreamServer(6, 40*time.Millisecond)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.ReadIdleTimeout = 150 * time.Millisecond
+
+	body, err := executeTestGET(t, c, server)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "012345", string(body))
+}
+
+func TestReadIdleTimeoutStalledStream(t *testing.T) {
+	server := newSlowStreamServer(2, 300*time.Millisecond)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.ReadIdleTimeout = 100 * time.Millisecond
+
+	_, err := executeTestGET(t, c, server)
+
+	assert.Error(t, err)
+}
+
+func TestReadIdleTimeoutResumedDownload(t *testing.T) {
+	var content = strings.Repeat("0123456789", 100)
+	var rangeRequests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", `"zeno"`)
+
+		// The range requests get the rest of the content in chunks,
+		// slower than the idle timeout in total but not between chunks
+		if rangeHeader := r.Header.Get("Range"); len(rangeHeader) > 0 {
+			atomic.AddInt32(&rangeRequests, 1)
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+			w.Header().Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(len(content)-1)+"/"+strconv.Itoa(len(content)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)-start))
+			w.WriteHeader(http.StatusPartialContent)
+
+			rest := content[start:]
+			for len(rest) > 0 {
+				chunk := rest[:len(rest)/4+1]
+				rest = rest[len(chunk):]
+
+				w.Write([]byte(chunk))
+				w.(http.Flusher).Flush()
+				time.Sleep(40 * time.Millisecond)
+			}
+			return
+		}
+
+		// The first download stalls halfway
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.ReadIdleTimeout = 100 * time.Millisecond
+	c.MaxNetworkRetry = 3
+
+	body, err := executeTestGET(t, c, server)
+
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rangeRequests))
+}
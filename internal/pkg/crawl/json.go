@@ -0,0 +1,140 @@
+package crawl
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// maxJSONResponseSize is the maximum size of a JSON response that we parse
+const maxJSONResponseSize = 10 * MB
+
+// maxJSONPathLength is the maximum length of a relative path found in JSON
+const maxJSONPathLength = 2048
+
+// isJSONResponse return true if the Content-Type of the response is JSON,
+// like the responses of REST and GraphQL APIs
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isJSONPath return true if a JSON string looks like a relative path, like
+// /api/items?cursor=abc: it has a leading slash followed by a path segment,
+// at least one letter, and none of the characters that are found in the
+// other strings starting with a slash, like regular expressions, dates,
+// templates or prose
+func isJSONPath(value string) bool {
+	if len(value) < 2 || len(value) > maxJSONPathLength || value[0] != '/' || value[1] == '/' {
+		return false
+	}
+
+	// The first segment has to start like a path name
+	first := rune(value[1])
+	if !unicode.IsLetter(first) && !unicode.IsDigit(first) && first != '_' && first != '.' && first != '~' && first != '-' {
+		return false
+	}
+
+	var hasLetter bool
+	for _, char := range value {
+		if unicode.IsSpace(char) || strings.ContainsRune(`"'<>\^|{}*`+"`", char) {
+			return false
+		}
+
+		if unicode.IsLetter(char) {
+			hasLetter = true
+		}
+	}
+
+	if !hasLetter {
+		return false
+	}
+
+	_, err := url.Parse(value)
+	return err == nil
+}
+
+// extractJSONStrings return the URLs and the relative paths found in the
+// strings of a decoded JSON value, the values nested deeper than maxDepth
+// levels are ignored so that pathological inputs can't recurse endlessly
+func extractJSONStrings(value interface{}, maxDepth int) (rawURLs []string) {
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	switch JSON := value.(type) {
+	case string:
+		if strings.HasPrefix(JSON, "http://") || strings.HasPrefix(JSON, "https://") || utils.IsProtocolRelativeURL(JSON) || isJSONPath(JSON) {
+			rawURLs = append(rawURLs, JSON)
+		}
+	case map[string]interface{}:
+		for _, child := range JSON {
+			rawURLs = append(rawURLs, extractJSONStrings(child, maxDepth-1)...)
+		}
+	case []interface{}:
+		for _, child := range JSON {
+			rawURLs = append(rawURLs, extractJSONStrings(child, maxDepth-1)...)
+		}
+	}
+
+	return rawURLs
+}
+
+// extractFromJSON parse a JSON response and return the URLs and the
+// relative paths it contains, resolved against the response's URL
+func extractFromJSON(base *url.URL, body []byte, maxDepth int) (outlinks []url.URL, err error) {
+	var result interface{}
+
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	rawOutlinks := utils.DedupeStrings(extractJSONStrings(result, maxDepth))
+
+	return utils.DedupeURLs(utils.MakeAbsolute(base, utils.StringSliceToURLSlice(rawOutlinks))), nil
+}
+
+// handleJSON queue the URLs and the relative paths found in a JSON response
+// as outlinks, so the pages of the APIs that paginate with relative paths
+// are followed, isJSON is false if the response isn't JSON, its body is
+// then left unread
+func (c *Crawl) handleJSON(item *frontier.Item, resp *http.Response, respPath string) (isJSON bool) {
+	if !isJSONResponse(resp) || isWebManifest(resp) {
+		return false
+	}
+
+	if item.Hop >= c.MaxHops {
+		return true
+	}
+
+	body, err := readResponseBody(resp, respPath, maxJSONResponseSize)
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to read JSON " + item.URL.String())
+		return true
+	}
+
+	outlinks, err := extractFromJSON(resp.Request.URL, body, c.jsonDepth())
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to parse JSON " + item.URL.String())
+		c.ErrorStats.Incr(errorCategoryParse)
+		return true
+	}
+
+	if len(outlinks) > 0 {
+		c.queueOutlinksAsync(c.filterSchemes(outlinks), item)
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package crawl
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsJSONPath(t *testing.T) {
+	for _, path := range []string{
+		"/api/next?cursor=abc123",
+		"/graphql",
+		"/users/42/posts",
+		"/_next/data/build/page.json",
+		"/v1/items?page=2&sort=desc",
+	} {
+		assert.True(t, isJSONPath(path), path)
+	}
+
+	for _, value := range []string{
+		"/",
+		"//cdn.example.com/app.js",
+		"/ ",
+		"/this is a sentence",
+		"/2021/05/06",
+		"/^[a-z]+$/",
+		"/{id}/edit",
+		"/*",
+		"/|",
+		"api/next",
+		"not a path",
+		"",
+	} {
+		assert.False(t, isJSONPath(value), value)
+	}
+}
+
+func TestExtractFromJSON(t *testing.T) {
+	base, _ := url.Parse("https://example.com/api/items?page=1")
+
+	body := []byte(`{
+		"data": {
+			"items": [
+				{"id": 1, "url": "/items/1", "title": "First"},
+				{"id": 2, "url": "https://cdn.example.org/items/2", "title": "/ not a path"}
+			]
+		},
+		"pageInfo": {"next": "/api/items?cursor=abc", "hasNext": true},
+		"date": "/2021/05/06",
+		"pattern": "/^[a-z]+$/"
+	}`)
+
+	outlinks, err := extractFromJSON(base, body, DefaultMaxJSONDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rawOutlinks []string
+	for _, outlink := range outlinks {
+		rawOutlinks = append(rawOutlinks, outlink.String())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"https://example.com/items/1",
+		"https://cdn.example.org/items/2",
+		"https://example.com/api/items?cursor=abc",
+	}, rawOutlinks)
+
+	// Top-level arrays are parsed too
+	outlinks, err = extractFromJSON(base, []byte(`["/api/items?cursor=def"]`), DefaultMaxJSONDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, outlinks, 1) {
+		assert.Equal(t, "https://example.com/api/items?cursor=def", outlinks[0].String())
+	}
+
+	_, err = extractFromJSON(base, []byte(`{"invalid"`), DefaultMaxJSONDepth)
+	assert.Error(t, err)
+}
@@ -112,7 +112,8 @@ func (crawl *Crawl) kafkaConsumer() {
 			time.Sleep(time.Second)
 		}
 
-		if crawl.ActiveWorkers.Value() >= int64(crawl.Workers-(crawl.Workers/10)) {
+		workers := crawl.GetWorkersCount()
+		if crawl.ActiveWorkers.Value() >= int64(workers-(workers/10)) {
 			time.Sleep(time.Millisecond * 100)
 			continue
 		}
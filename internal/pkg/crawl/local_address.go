@@ -0,0 +1,140 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+)
+
+// interfaceIP return the first IP address of a network interface that
+// can be used to reach other hosts, the IPv4 addresses are preferred
+func interfaceIP(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("no usable IP address on the interface " + name)
+	}
+
+	return found, nil
+}
+
+// localAddr return the local address the outgoing connections are bound
+// to, either the IP of --local-address or an IP of --interface, or nil
+// if the system chooses it
+func (c *Crawl) localAddr() (*net.TCPAddr, error) {
+	if c.LocalAddress != "" && c.Interface != "" {
+		return nil, errors.New("--local-address and --interface can't be used together")
+	}
+
+	if (c.LocalAddress != "" || c.Interface != "") && c.LocalIPv6Range != "" {
+		return nil, errors.New("--local-ipv6-range can't be used with --local-address or --interface")
+	}
+
+	var ip net.IP
+	switch {
+	case c.LocalAddress != "":
+		ip = net.ParseIP(c.LocalAddress)
+		if ip == nil {
+			return nil, errors.New("invalid local address: " + c.LocalAddress)
+		}
+	case c.Interface != "":
+		var err error
+		ip, err = interfaceIP(c.Interface)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+// randomIPInRange return a random address of an IPv6 prefix, other than
+// the first one, that is the Subnet-Router anycast address
+func randomIPInRange(ipRange *net.IPNet) net.IP {
+	var ip = make(net.IP, net.IPv6len)
+	for {
+		for i := range ip {
+			ip[i] = ipRange.IP[i]&ipRange.Mask[i] | byte(rand.Intn(256))&^ipRange.Mask[i]
+		}
+
+		if !ip.Equal(ipRange.IP) || isSingleAddress(ipRange) {
+			return ip
+		}
+	}
+}
+
+// isSingleAddress return true if the prefix holds only one address
+func isSingleAddress(ipRange *net.IPNet) bool {
+	ones, bits := ipRange.Mask.Size()
+	return ones == bits
+}
+
+// parseLocalIPv6Range parse the IPv6 prefix of --local-ipv6-range, and
+// make sure its addresses can be used as source addresses on this host,
+// either because they are routed locally or because binding non-local
+// addresses is allowed, by binding a socket to one of them
+func parseLocalIPv6Range(value string) (*net.IPNet, error) {
+	_, ipRange, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if ipRange.IP.To4() != nil || len(ipRange.IP) != net.IPv6len {
+		return nil, errors.New("not an IPv6 range: " + value)
+	}
+
+	conn, err := net.ListenPacket("udp6", net.JoinHostPort(randomIPInRange(ipRange).String(), "0"))
+	if err != nil {
+		return nil, errors.New("the IPv6 range " + value + " isn't routable on this host: " + err.Error())
+	}
+	conn.Close()
+
+	return ipRange, nil
+}
+
+// randomLocalIPDialer make each connection from a random address of an
+// IPv6 prefix, so the requests are spread over many source addresses.
+// The hosts that can't be reached over IPv6 are dialed normally.
+type randomLocalIPDialer struct {
+	dialer  *net.Dialer
+	ipRange *net.IPNet
+}
+
+func (d *randomLocalIPDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var dialer = *d.dialer
+	dialer.LocalAddr = &net.TCPAddr{IP: randomIPInRange(d.ipRange)}
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	var addrError *net.AddrError
+	if err != nil && errors.As(err, &addrError) {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	return conn, err
+}
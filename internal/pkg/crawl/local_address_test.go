@@ -0,0 +1,144 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer server.Close()
+
+	// The whole 127.0.0.0/8 block is routed to the loopback interface
+	c := newTestCrawl()
+	c.LocalAddress = "127.0.0.2"
+	if err := c.initHTTPClient(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Client.Get(server.URL)
+	if err != nil {
+		t.Skip("unable to bind to 127.0.0.2: ", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "127.0.0.2", string(body))
+}
+
+func TestLocalAddr(t *testing.T) {
+	c := newTestCrawl()
+
+	addr, err := c.localAddr()
+	assert.NoError(t, err)
+	assert.Nil(t, addr)
+
+	c.LocalAddress = "not an IP"
+	_, err = c.localAddr()
+	assert.Error(t, err)
+
+	c.LocalAddress = "127.0.0.1"
+	c.Interface = "lo"
+	_, err = c.localAddr()
+	assert.Error(t, err)
+
+	// The loopback interface has the 127.0.0.1 address
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+
+		c.LocalAddress = ""
+		c.Interface = iface.Name
+		addr, err = c.localAddr()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "127.0.0.1", addr.IP.String())
+		}
+	}
+
+	c.Interface = "zeno-missing0"
+	_, err = c.localAddr()
+	assert.Error(t, err)
+}
+
+func TestRandomIPInRange(t *testing.T) {
+	_, ipRange, _ := net.ParseCIDR("2001:db8:1234:5678::/64")
+
+	var seen = make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		ip := randomIPInRange(ipRange)
+		assert.True(t, ipRange.Contains(ip))
+		assert.False(t, ip.Equal(ipRange.IP))
+		seen[ip.String()] = true
+	}
+	assert.True(t, len(seen) > 90)
+
+	_, single, _ := net.ParseCIDR("::1/128")
+	assert.Equal(t, "::1", randomIPInRange(single).String())
+}
+
+func TestParseLocalIPv6Range(t *testing.T) {
+	for _, value := range []string{"2001:db8::", "10.0.0.0/8", "::ffff:10.0.0.0/104"} {
+		_, err := parseLocalIPv6Range(value)
+		assert.Error(t, err, value)
+	}
+
+	// The documentation prefix isn't routed to any host
+	_, err := parseLocalIPv6Range("2001:db8::/64")
+	assert.Error(t, err)
+
+	c := newTestCrawl()
+	c.Interface = "lo"
+	c.LocalIPv6Range = "::1/128"
+	assert.Error(t, c.initHTTPClient())
+}
+
+func TestRandomLocalIPDialer(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 isn't available: ", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.LocalIPv6Range = "::1/128"
+	if err := c.initHTTPClient(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "::1", string(body))
+
+	// The hosts without IPv6 are reached from the default address
+	ipv4Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ipv4"))
+	}))
+	defer ipv4Server.Close()
+
+	resp, err = c.Client.Get(ipv4Server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+}
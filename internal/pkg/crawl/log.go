@@ -68,8 +68,10 @@ func (c *Crawl) SetupLogging() (logInfo, logWarning *logrus.Logger) {
 	return logInfo, logWarning
 }
 
-func (c *Crawl) logCrawlSuccess(executionStart time.Time, statusCode int, item *frontier.Item) {
-	logInfo.WithFields(logrus.Fields{
+func (c *Crawl) logCrawlSuccess(executionStart time.Time, statusCode int, item *frontier.Item, requestID string) {
+	c.Frontier.HostStats.IncrCaptured(item.Host)
+
+	logInfo.WithFields(withRequestID(logrus.Fields{
 		"status":         c.getCrawlState(),
 		"queued":         c.Frontier.QueueCount.Value(),
 		"crawled":        c.Crawled.Value(),
@@ -79,5 +81,5 @@ func (c *Crawl) logCrawlSuccess(executionStart time.Time, statusCode int, item *
 		"hop":            item.Hop,
 		"type":           item.Type,
 		"execution_time": time.Since(executionStart),
-	}).Info(item.URL.String())
+	}, requestID)).Info(item.URL.String())
 }
@@ -0,0 +1,108 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
+)
+
+// login authenticate the crawler by sending the login form, the session
+// cookies end up in the client's cookie jar and are then sent with every
+// request of the crawl. Both the login page and the login request are archived.
+func (c *Crawl) login() error {
+	loginURL, err := url.Parse(utils.CleanURL(c.LoginURL))
+	if err != nil {
+		return err
+	}
+
+	form, err := url.ParseQuery(c.LoginFormData)
+	if err != nil {
+		return err
+	}
+
+	// If the form is protected by a CSRF token, we get it from the login page
+	if len(c.LoginCSRFField) > 0 {
+		token, err := c.getLoginCSRFToken(loginURL)
+		if err != nil {
+			return err
+		}
+		form.Set(c.LoginCSRFField, token)
+	}
+
+	req, err := http.NewRequest("POST", loginURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, respPath, err := c.executeGET(frontier.NewItem(loginURL, nil, "seed", 0), req)
+	if err != nil {
+		markTempFileDone(respPath)
+		return err
+	}
+	defer resp.Body.Close()
+	defer markTempFileDone(respPath)
+
+	if resp.StatusCode >= 400 {
+		return errors.New("login failed with status code " + strconv.Itoa(resp.StatusCode))
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"url":         loginURL.String(),
+		"status_code": resp.StatusCode,
+	}).Info("Logged in")
+
+	return nil
+}
+
+// getLoginCSRFToken fetch the login page and extract
+// the value of the hidden CSRF token field
+func (c *Crawl) getLoginCSRFToken(loginURL *url.URL) (token string, err error) {
+	req, err := http.NewRequest("GET", loginURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, respPath, err := c.executeGET(frontier.NewItem(loginURL, nil, "seed", 0), req)
+	if err != nil {
+		markTempFileDone(respPath)
+		return "", err
+	}
+	defer resp.Body.Close()
+	defer markTempFileDone(respPath)
+
+	var doc *goquery.Document
+	if respPath != "" {
+		file, err := os.Open(respPath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		doc, err = goquery.NewDocumentFromReader(file)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		doc, err = goquery.NewDocumentFromResponse(resp)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	token, exists := doc.Find(fmt.Sprintf("input[name=%q]", c.LoginCSRFField)).First().Attr("value")
+	if !exists {
+		return "", errors.New("CSRF field " + c.LoginCSRFField + " not found on the login page")
+	}
+
+	return token, nil
+}
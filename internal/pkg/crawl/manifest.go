@@ -0,0 +1,139 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// maxManifestSize is the maximum size of a web app manifest that we parse
+const maxManifestSize = 1 * MB
+
+// webManifest holds the fields of a web app manifest that reference URLs
+type webManifest struct {
+	StartURL    string          `json:"start_url"`
+	Icons       []manifestImage `json:"icons"`
+	Screenshots []manifestImage `json:"screenshots"`
+	Shortcuts   []struct {
+		URL   string          `json:"url"`
+		Icons []manifestImage `json:"icons"`
+	} `json:"shortcuts"`
+}
+
+type manifestImage struct {
+	Src string `json:"src"`
+}
+
+// isWebManifest return true if the response is a web app manifest, based on
+// its Content-Type or on the usual names of the manifest files
+func isWebManifest(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/manifest+json" {
+		return true
+	}
+
+	name := path.Base(resp.Request.URL.Path)
+	return strings.HasSuffix(name, ".webmanifest") || name == "manifest.json"
+}
+
+// readResponseBody return up to limit bytes of the body of a response,
+// reading it from the temporary file if it was dumped on disk
+func readResponseBody(resp *http.Response, respPath string, limit int64) ([]byte, error) {
+	var body io.Reader = resp.Body
+
+	if respPath != "" {
+		file, err := os.Open(respPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		fileResp, err := http.ReadResponse(bufio.NewReader(file), resp.Request)
+		if err != nil {
+			return nil, err
+		}
+		defer fileResp.Body.Close()
+
+		body = fileResp.Body
+	}
+
+	return ioutil.ReadAll(io.LimitReader(body, limit))
+}
+
+// extractFromManifest parse a web app manifest and return the images it
+// references as assets, and its start URL and shortcuts as outlinks
+func extractFromManifest(base *url.URL, body []byte) (assets, outlinks []url.URL, err error) {
+	var manifest webManifest
+	var rawAssets, rawOutlinks []string
+
+	err = json.Unmarshal(body, &manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, image := range append(manifest.Icons, manifest.Screenshots...) {
+		rawAssets = append(rawAssets, image.Src)
+	}
+
+	if manifest.StartURL != "" {
+		rawOutlinks = append(rawOutlinks, manifest.StartURL)
+	}
+
+	for _, shortcut := range manifest.Shortcuts {
+		if shortcut.URL != "" {
+			rawOutlinks = append(rawOutlinks, shortcut.URL)
+		}
+
+		for _, image := range shortcut.Icons {
+			rawAssets = append(rawAssets, image.Src)
+		}
+	}
+
+	// The URLs of a manifest are relative to the manifest's URL
+	assets = utils.DedupeURLs(utils.MakeAbsolute(base, utils.StringSliceToURLSlice(rawAssets)))
+	outlinks = utils.DedupeURLs(utils.MakeAbsolute(base, utils.StringSliceToURLSlice(rawOutlinks)))
+
+	return assets, outlinks, nil
+}
+
+// handleWebManifest extract the URLs of a captured web app manifest, its
+// start URL and shortcuts are queued as outlinks and its images are
+// returned so they can be captured as assets
+func (c *Crawl) handleWebManifest(item *frontier.Item, resp *http.Response, respPath string) (assets []url.URL) {
+	body, err := readResponseBody(resp, respPath, maxManifestSize)
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to read web app manifest " + item.URL.String())
+		return nil
+	}
+
+	assets, outlinks, err := extractFromManifest(resp.Request.URL, body)
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to parse web app manifest " + item.URL.String())
+		return nil
+	}
+
+	if item.Hop < c.MaxHops && len(outlinks) > 0 {
+		c.queueOutlinksAsync(c.filterSchemes(outlinks), item)
+	}
+
+	if c.SameOriginAssets {
+		assets = c.filterCrossOriginAssets(item, assets)
+	}
+
+	return c.filterSchemes(assets)
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractFromManifest(t *testing.T) {
+	manifest := `{
+		"name": "App",
+		"start_url": "/app/?source=pwa",
+		"icons": [{"src": "icons/192.png", "sizes": "192x192"}, {"src": "https://cdn.example.com/512.png"}],
+		"screenshots": [{"src": "/screenshots/home.png"}],
+		"shortcuts": [{"name": "Inbox", "url": "/inbox", "icons": [{"src": "icons/inbox.png"}]}]
+	}`
+
+	base, _ := url.Parse("https://example.com/static/manifest.json")
+	assets, outlinks, err := extractFromManifest(base, []byte(manifest))
+	assert.NoError(t, err)
+
+	var rawAssets, rawOutlinks []string
+	for _, asset := range assets {
+		rawAssets = append(rawAssets, asset.String())
+	}
+	for _, outlink := range outlinks {
+		rawOutlinks = append(rawOutlinks, outlink.String())
+	}
+
+	assert.Equal(t, []string{
+		"https://example.com/static/icons/192.png",
+		"https://cdn.example.com/512.png",
+		"https://example.com/screenshots/home.png",
+		"https://example.com/static/icons/inbox.png",
+	}, rawAssets)
+	assert.Equal(t, []string{"https://example.com/app/?source=pwa", "https://example.com/inbox"}, rawOutlinks)
+
+	_, _, err = extractFromManifest(base, []byte("not JSON"))
+	assert.Error(t, err)
+}
+
+func TestCaptureAssetsWebManifest(t *testing.T) {
+	var iconRequests int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/site.webmanifest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/manifest+json")
+		w.Write([]byte(`{"icons": [{"src": "/icon.png"}]}`))
+	})
+	mux.HandleFunc("/icon.png", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&iconRequests, 1)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.AllowedSchemes = []string{"http", "https"}
+
+	pageURL, _ := url.Parse(server.URL + "/")
+	manifestURL, _ := url.Parse(server.URL + "/site.webmanifest")
+	item := frontier.NewItem(pageURL, nil, "seed", 0)
+
+	c.captureAssets(item, []url.URL{*manifestURL})
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&iconRequests))
+}
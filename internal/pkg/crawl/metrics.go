@@ -0,0 +1,79 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// openMetricsText return the current statistics of the crawl
+// in the OpenMetrics text format
+func (c *Crawl) openMetricsText() string {
+	var metrics strings.Builder
+
+	writeMetric := func(name, metricType, help string, value interface{}) {
+		var sample = name
+		if metricType == "counter" {
+			sample += "_total"
+		}
+
+		fmt.Fprintf(&metrics, "# TYPE %s %s\n", name, metricType)
+		fmt.Fprintf(&metrics, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(&metrics, "%s %v\n", sample, value)
+	}
+
+	writeMetric(c.MetricsPrefix+"crawled", "counter", "The total number of crawled URI", c.Crawled.Value())
+	writeMetric(c.MetricsPrefix+"queued", "gauge", "The number of URI currently queued", c.Frontier.QueueCount.Value())
+	writeMetric(c.MetricsPrefix+"uri_per_second", "gauge", "The number of URI crawled during the last second", c.URIsPerSecond.Rate())
+	writeMetric(c.MetricsPrefix+"active_workers", "gauge", "The number of workers currently capturing an URI", c.ActiveWorkers.Value())
+	if c.Seencheck && c.Frontier.Seencheck != nil {
+		writeMetric(c.MetricsPrefix+"seen", "counter", "The total number of URI marked as seen", c.Frontier.Seencheck.Count())
+	}
+	writeMetric(c.MetricsPrefix+"running_time_seconds", "gauge", "The time since the crawl started", int64(time.Since(c.StartTime).Seconds()))
+
+	metrics.WriteString("# EOF\n")
+
+	return metrics.String()
+}
+
+// dumpMetrics periodically write the current statistics of the crawl
+// in the OpenMetrics text format to --metrics-dump-file
+func (c *Crawl) dumpMetrics() {
+	var interval = c.MetricsDumpInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	for {
+		err := c.writeMetricsFile()
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+				"path":  c.MetricsDumpFile,
+			}).Warning("Unable to write metrics dump file")
+		}
+
+		if c.Finished.Get() {
+			return
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// writeMetricsFile write the metrics to a temporary file then rename it, so
+// the dump file is never read while it is partially written
+func (c *Crawl) writeMetricsFile() error {
+	var tempPath = c.MetricsDumpFile + ".tmp"
+
+	err := ioutil.WriteFile(tempPath, []byte(c.openMetricsText()), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, c.MetricsDumpFile)
+}
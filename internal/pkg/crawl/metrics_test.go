@@ -0,0 +1,23 @@
+package crawl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMetricsText(t *testing.T) {
+	c := newTestCrawl()
+	c.MetricsPrefix = "zeno_"
+	c.Crawled.Incr(42)
+	c.Frontier.QueueCount.Incr(7)
+
+	metrics := c.openMetricsText()
+
+	assert.Contains(t, metrics, "# TYPE zeno_crawled counter\n")
+	assert.Contains(t, metrics, "zeno_crawled_total 42\n")
+	assert.Contains(t, metrics, "# TYPE zeno_queued gauge\n")
+	assert.Contains(t, metrics, "zeno_queued 7\n")
+	assert.True(t, strings.HasSuffix(metrics, "# EOF\n"))
+}
@@ -2,23 +2,178 @@ package crawl
 
 import (
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/CorentinB/Zeno/internal/pkg/frontier"
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/sirupsen/logrus"
 )
 
-func extractOutlinks(base *url.URL, doc *goquery.Document) (outlinks []url.URL, err error) {
+// regexEventHandlerURL match the quoted strings of inline event handlers
+// that look like URLs: absolute or protocol-relative URLs, paths, and
+// file names with the extension of a page
+var regexEventHandlerURL = regexp.MustCompile(`(?i)['"]((?:https?:)?//[^'"\s]+|\.{0,2}/[^'"\s]*|[^'"\s/]+\.(?:s?html?|php|aspx?|jsp|cgi)(?:[?#][^'"\s]*)?)['"]`)
+
+// regexJavascriptHrefURL match the quoted absolute or protocol-relative
+// http(s) URLs in javascript: hrefs, like javascript:window.open('...')
+var regexJavascriptHrefURL = regexp.MustCompile(`(?i)['"]((?:https?:)?//[^'"\s<>]{1,1000})['"]`)
+
+// extractJavascriptHrefURLs return the URLs quoted
+// in a javascript: href, if the href is one
+func extractJavascriptHrefURLs(href string) (URLs []string) {
+	href = strings.TrimSpace(href)
+	if len(href) < len("javascript:") || !strings.EqualFold(href[:len("javascript:")], "javascript:") {
+		return nil
+	}
+
+	// The code of javascript: URLs may be percent-encoded
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+
+	for _, match := range regexJavascriptHrefURL.FindAllStringSubmatch(href, -1) {
+		URLs = append(URLs, match[1])
+	}
+
+	return URLs
+}
+
+// extractEventHandlerURLs return the URLs quoted in the inline event handlers
+// of an element, like onclick="location.href='/page'", only the attributes
+// starting with "on" are scanned
+func extractEventHandlerURLs(item *goquery.Selection) (URLs []string) {
+	for _, node := range item.Nodes {
+		for _, attribute := range node.Attr {
+			if !strings.HasPrefix(strings.ToLower(attribute.Key), "on") {
+				continue
+			}
+
+			for _, match := range regexEventHandlerURL.FindAllStringSubmatch(attribute.Val, -1) {
+				URLs = append(URLs, match[1])
+			}
+		}
+	}
+
+	return URLs
+}
+
+// extractCanonical return the absolute URL declared
+// with <link rel="canonical">, or nil if there is none
+func extractCanonical(base *url.URL, doc *goquery.Document) *url.URL {
+	var canonical *url.URL
+
+	doc.Find("link").EachWithBreak(func(index int, item *goquery.Selection) bool {
+		relation, _ := item.Attr("rel")
+		if !strings.EqualFold(strings.TrimSpace(relation), "canonical") {
+			return true
+		}
+
+		link, exists := item.Attr("href")
+		if !exists {
+			return true
+		}
+
+		URL, err := url.Parse(utils.CleanURL(link))
+		if err != nil {
+			return true
+		}
+
+		canonical = base.ResolveReference(URL)
+		return false
+	})
+
+	return canonical
+}
+
+// extractNextPage return the absolute URL of the next page declared with
+// <link rel="next"> or <a rel="next">, or nil if there is none
+func extractNextPage(base *url.URL, doc *goquery.Document) *url.URL {
+	var next *url.URL
+
+	doc.Find("link, a").EachWithBreak(func(index int, item *goquery.Selection) bool {
+		relation, _ := item.Attr("rel")
+		if !isNextRelation(relation) {
+			return true
+		}
+
+		link, exists := item.Attr("href")
+		if !exists || strings.TrimSpace(link) == "" {
+			return true
+		}
+
+		URL, err := url.Parse(utils.CleanURL(link))
+		if err != nil {
+			return true
+		}
+
+		next = base.ResolveReference(URL)
+		return false
+	})
+
+	return next
+}
+
+// isNextRelation return true if the rel attribute of a
+// link contains the "next" token, like rel="next nofollow"
+func isNextRelation(relation string) bool {
+	for _, value := range strings.Fields(strings.ToLower(relation)) {
+		if value == "next" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Crawl) extractOutlinks(base *url.URL, doc *goquery.Document) (outlinks []url.URL, err error) {
 	var rawOutlinks []string
 
 	// Extract outlinks
 	doc.Find("a").Each(func(index int, item *goquery.Selection) {
+		// Links with a download attribute point to files,
+		// they are captured as assets instead
+		if _, isDownload := item.Attr("download"); isDownload {
+			return
+		}
+
 		link, exists := item.Attr("href")
 		if exists {
 			rawOutlinks = append(rawOutlinks, link)
+
+			// Links wrapped in JavaScript, the javascript: href
+			// itself is then dropped with the other schemes
+			if c.ExtractJavascriptHrefs {
+				rawOutlinks = append(rawOutlinks, extractJavascriptHrefURLs(link)...)
+			}
+		}
+
+		// The ping attribute is a space-separated list of URLs
+		if c.ExtractPingAndFormaction {
+			ping, exists := item.Attr("ping")
+			if exists {
+				rawOutlinks = append(rawOutlinks, strings.Fields(ping)...)
+			}
 		}
 	})
+
+	if c.ExtractPingAndFormaction {
+		doc.Find("button[formaction], input[formaction]").Each(func(index int, item *goquery.Selection) {
+			link, exists := item.Attr("formaction")
+			if exists {
+				rawOutlinks = append(rawOutlinks, link)
+			}
+		})
+	}
+
+	// Inline event handlers sometimes navigate to other pages,
+	// the URLs are found heuristically so it's optional
+	if c.ExtractEventHandlers {
+		doc.Find("*").Each(func(index int, item *goquery.Selection) {
+			rawOutlinks = append(rawOutlinks, extractEventHandlerURLs(item)...)
+		})
+	}
+
 	// Turn strings into url.URL
 	outlinks = utils.StringSliceToURLSlice(rawOutlinks)
 
@@ -34,30 +189,95 @@ func extractOutlinks(base *url.URL, doc *goquery.Document) (outlinks []url.URL,
 	return utils.DedupeURLs(outlinks), nil
 }
 
+// shouldQueueOutlink return true if an outlink can be queued, that is
+// if its host isn't excluded, it isn't blocklisted, it doesn't look like
+// it comes from a crawler trap and its host didn't reach --max-pages-per-host.
+// The checks on the item it comes from are done once for all its outlinks.
+func (c *Crawl) shouldQueueOutlink(outlink *url.URL) bool {
+	if utils.StringInSlice(outlink.Host, c.ExcludedHosts) || c.isBlocklisted(outlink) {
+		return false
+	}
+
+	if c.isCrawlerTrap(outlink) {
+		return false
+	}
+
+	if c.isHostPagesLimitReached(outlink.Host) {
+		logInfo.WithFields(logrus.Fields{
+			"url":  outlink.String(),
+			"host": outlink.Host,
+		}).Debug("Maximum number of pages reached for host, dropping outlink")
+		return false
+	}
+
+	return true
+}
+
 func (c *Crawl) queueOutlinks(outlinks []url.URL, item *frontier.Item) {
+	if c.isItemTreeTooDeep(item) || c.isSeedBudgetExhausted(item) {
+		return
+	}
+
 	// Send the outlinks to the pool of workers
 	for _, outlink := range outlinks {
 		outlink := outlink
 
-		// If the host of the outlink is in the host exclusion list, we ignore it
-		if utils.StringInSlice(outlink.Host, c.ExcludedHosts) {
+		if !c.shouldQueueOutlink(&outlink) {
 			continue
 		}
 
 		if c.DomainsCrawl && strings.Contains(item.Host, outlink.Host) && item.Hop == 0 {
-			newItem := frontier.NewItem(&outlink, item, "seed", 0)
-			if c.UseKafka && len(c.KafkaOutlinksTopic) > 0 {
-				c.KafkaProducerChannel <- newItem
-			} else {
-				c.Frontier.PushChan <- newItem
-			}
+			c.pushOutlink(frontier.NewItem(&outlink, item, "seed", 0))
 		} else {
-			newItem := frontier.NewItem(&outlink, item, "seed", item.Hop+1)
-			if c.UseKafka && len(c.KafkaOutlinksTopic) > 0 {
-				c.KafkaProducerChannel <- newItem
-			} else {
-				c.Frontier.PushChan <- newItem
-			}
+			c.pushOutlink(frontier.NewItem(&outlink, item, "seed", item.Hop+1))
 		}
 	}
 }
+
+// queueNextPage queue the next page of a paginated item with the same
+// hop as the item, so following the pagination doesn't consume hops,
+// the number of pages followed that way is limited by --max-pagination-pages
+func (c *Crawl) queueNextPage(next url.URL, item *frontier.Item) {
+	if item.PaginationPage >= c.MaxPaginationPages {
+		logInfo.WithFields(logrus.Fields{
+			"url":  next.String(),
+			"page": item.PaginationPage,
+		}).Debug("Maximum number of pagination pages reached, not following next page")
+		return
+	}
+
+	if c.isItemTreeTooDeep(item) || c.isSeedBudgetExhausted(item) {
+		return
+	}
+
+	// The outlinks are filtered on their scheme before being queued
+	if len(c.filterSchemes([]url.URL{next})) == 0 || !c.shouldQueueOutlink(&next) {
+		return
+	}
+
+	newItem := frontier.NewItem(&next, item, "seed", item.Hop)
+	newItem.PaginationPage = item.PaginationPage + 1
+	c.pushOutlink(newItem)
+}
+
+// pushOutlink send a new item to Kafka if the outlinks
+// are produced to a topic, or to the frontier otherwise
+func (c *Crawl) pushOutlink(newItem *frontier.Item) {
+	if c.UseKafka && len(c.KafkaOutlinksTopic) > 0 {
+		c.KafkaProducerChannel <- newItem
+	} else {
+		// The outlink is pending in the seed tree until it is captured
+		c.retainSeedTree(newItem)
+		c.Frontier.PushChan <- newItem
+	}
+}
+
+// queueOutlinksAsync queue the outlinks of an item in the background,
+// its seed tree stays active until they are all pushed to the frontier
+func (c *Crawl) queueOutlinksAsync(outlinks []url.URL, item *frontier.Item) {
+	c.retainSeedTree(item)
+	go func() {
+		defer c.releaseSeedTree(item)
+		c.queueOutlinks(outlinks, item)
+	}()
+}
@@ -0,0 +1,191 @@
+package crawl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"mvdan.cc/xurls/v2"
+)
+
+func TestExtractCanonical(t *testing.T) {
+	base, _ := url.Parse("https://example.com/page?utm_source=foo")
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><link rel="canonical" href="/page"></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonical := extractCanonical(base, doc)
+	if assert.NotNil(t, canonical) {
+		assert.Equal(t, "https://example.com/page", canonical.String())
+	}
+
+	// The canonical link isn't an asset
+	assert.NotContains(t, extractTestAssets(t, new(Crawl), `<html><head><link rel="canonical" href="/page"></head></html>`), "https://example.com/page")
+
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<html><head></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, extractCanonical(base, doc))
+}
+
+// extractTestOutlinks run the outlinks extraction on a HTML string and
+// return the extracted outlinks as strings
+func extractTestOutlinks(t *testing.T, c *Crawl, html string) (outlinks []string) {
+	regexOutlinks = xurls.Relaxed()
+
+	base, _ := url.Parse("https://example.com/page")
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	URLs, err := c.extractOutlinks(base, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, URL := range URLs {
+		outlinks = append(outlinks, URL.String())
+	}
+
+	return outlinks
+}
+
+func TestExtractOutlinksPingAndFormaction(t *testing.T) {
+	html := `<html><body>
+		<a href="/next" ping="/track/a https://tracker.example.net/b">Next</a>
+		<form><button formaction="/submit/button">Go</button><input type="submit" formaction="/submit/input"></form>
+	</body></html>`
+
+	// Disabled by default
+	c := new(Crawl)
+	outlinks := extractTestOutlinks(t, c, html)
+	assert.Contains(t, outlinks, "https://example.com/next")
+	assert.NotContains(t, outlinks, "https://example.com/track/a")
+	assert.NotContains(t, outlinks, "https://example.com/submit/button")
+
+	c.ExtractPingAndFormaction = true
+	outlinks = extractTestOutlinks(t, c, html)
+	assert.Contains(t, outlinks, "https://example.com/track/a")
+	assert.Contains(t, outlinks, "https://tracker.example.net/b")
+	assert.Contains(t, outlinks, "https://example.com/submit/button")
+	assert.Contains(t, outlinks, "https://example.com/submit/input")
+}
+
+func TestExtractDownloadLinks(t *testing.T) {
+	html := `<html><body>
+		<a href="/page">Page</a>
+		<a href="/files/report.pdf" download>Report</a>
+	</body></html>`
+
+	outlinks := extractTestOutlinks(t, new(Crawl), html)
+	assert.Contains(t, outlinks, "https://example.com/page")
+	assert.NotContains(t, outlinks, "https://example.com/files/report.pdf")
+
+	assets := extractTestAssets(t, new(Crawl), html)
+	assert.Contains(t, assets, "https://example.com/files/report.pdf")
+	assert.NotContains(t, assets, "https://example.com/page")
+}
+
+func TestExtractOutlinksEventHandlers(t *testing.T) {
+	html := `<html><body>
+		<div onclick="location.href='/products/1'">Product</div>
+		<p onClick="window.location = &quot;details.php?id=2&quot;">Details</p>
+		<button onclick="window.open('https://other.example.net/popup', '_blank', 'width=200')">Open</button>
+		<span onmouseover="highlight('menu', 'active')" data-note="'/not-a-handler'">Menu</span>
+	</body></html>`
+
+	// Disabled by default
+	c := new(Crawl)
+	outlinks := extractTestOutlinks(t, c, html)
+	assert.NotContains(t, outlinks, "https://example.com/products/1")
+
+	c.ExtractEventHandlers = true
+	outlinks = extractTestOutlinks(t, c, html)
+	assert.Contains(t, outlinks, "https://example.com/products/1")
+	assert.Contains(t, outlinks, "https://example.com/details.php?id=2")
+	assert.Contains(t, outlinks, "https://other.example.net/popup")
+	assert.NotContains(t, outlinks, "https://example.com/menu")
+	assert.NotContains(t, outlinks, "https://example.com/_blank")
+	assert.NotContains(t, outlinks, "https://example.com/not-a-handler")
+}
+
+func TestExtractJavascriptHrefURLs(t *testing.T) {
+	assert.Equal(t, []string{"https://real.example.com/page"}, extractJavascriptHrefURLs(`javascript:window.open('https://real.example.com/page')`))
+	assert.Equal(t, []string{"http://example.org/a?b=c"}, extractJavascriptHrefURLs(`JavaScript: location.href="http://example.org/a?b=c";`))
+	assert.Equal(t, []string{"//cdn.example.net/popup"}, extractJavascriptHrefURLs(`javascript:openPopup('//cdn.example.net/popup', 'name', 'width=200')`))
+	assert.Equal(t, []string{"https://example.com/encoded"}, extractJavascriptHrefURLs(`javascript:window.open(%27https://example.com/encoded%27)`))
+
+	// Only quoted absolute URLs of javascript: hrefs are extracted
+	assert.Empty(t, extractJavascriptHrefURLs(`javascript:void(0)`))
+	assert.Empty(t, extractJavascriptHrefURLs(`javascript:show('menu')`))
+	assert.Empty(t, extractJavascriptHrefURLs(`javascript:go(https://unquoted.example.com)`))
+	assert.Empty(t, extractJavascriptHrefURLs(`https://example.com/'https://other.example.com/'`))
+}
+
+func TestExtractOutlinksJavascriptHrefs(t *testing.T) {
+	html := `<html><body><a href="javascript:window.open('https://real.example.com/page')">Open</a></body></html>`
+
+	// Disabled by default
+	c := new(Crawl)
+	assert.NotContains(t, extractTestOutlinks(t, c, html), "https://real.example.com/page")
+
+	c.ExtractJavascriptHrefs = true
+	assert.Contains(t, extractTestOutlinks(t, c, html), "https://real.example.com/page")
+}
+
+func TestExtractNextPage(t *testing.T) {
+	base, _ := url.Parse("https://example.com/list?page=1")
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+		<link rel="stylesheet" href="/style.css">
+		<link rel="next" href="?page=2">
+	</head><body><a rel="next nofollow" href="/list?page=3">Next</a></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://example.com/list?page=2", extractNextPage(base, doc).String())
+
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<a href="/other">Other</a><a rel="Next" href="/list?page=2">Next</a>
+	</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://example.com/list?page=2", extractNextPage(base, doc).String())
+
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<html><body><a href="/other">Other</a></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, extractNextPage(base, doc))
+}
+
+func TestQueueNextPage(t *testing.T) {
+	c := newTestCrawl()
+	c.AllowedSchemes = []string{"http", "https"}
+	c.MaxPaginationPages = 2
+	c.Frontier.PushChan = make(chan *frontier.Item, 1)
+
+	URL, _ := url.Parse("https://example.com/list")
+	next, _ := url.Parse("https://example.com/list?page=2")
+	item := frontier.NewItem(URL, nil, "seed", 3)
+
+	// The next page keeps the hop of the item
+	c.queueNextPage(*next, item)
+	newItem := <-c.Frontier.PushChan
+	assert.Equal(t, next.String(), newItem.URL.String())
+	assert.Equal(t, uint8(3), newItem.Hop)
+	assert.Equal(t, 1, newItem.PaginationPage)
+
+	// The chain stops after the maximum number of pages
+	newItem.PaginationPage = 2
+	c.queueNextPage(*next, newItem)
+	assert.Len(t, c.Frontier.PushChan, 0)
+}
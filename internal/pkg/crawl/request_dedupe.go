@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRequestHashes is the number of request hashes kept to find identical
+// requests, the oldest ones are forgotten first
+const maxRequestHashes = 100000
+
+// writtenRequest is a request record already written in the WARC
+type writtenRequest struct {
+	RecordID  string
+	TargetURI string
+	Time      time.Time
+}
+
+// requestDedupeIndex keep the hash of the last request records written,
+// to replace the identical requests by a reference to the first one
+type requestDedupeIndex struct {
+	sync.Mutex
+	requests map[string]writtenRequest
+	order    []string
+}
+
+func newRequestDedupeIndex() *requestDedupeIndex {
+	return &requestDedupeIndex{
+		requests: make(map[string]writtenRequest),
+	}
+}
+
+// hashRequest return the hash of a dumped request, it covers the method,
+// the URI, the headers and the body of the request. The ID sent with
+// --send-request-id is left out, as it is different for every request.
+func hashRequest(requestDump []byte) string {
+	hash := sha1.New()
+
+	head, body := requestDump, []byte(nil)
+	if end := bytes.Index(requestDump, []byte("\r\n\r\n")); end != -1 {
+		head, body = requestDump[:end], requestDump[end:]
+	}
+
+	for i, line := range bytes.Split(head, []byte("\r\n")) {
+		name := strings.SplitN(string(line), ":", 2)[0]
+		if i > 0 && strings.EqualFold(strings.TrimSpace(name), requestIDHeader) {
+			continue
+		}
+
+		hash.Write(line)
+		hash.Write([]byte("\r\n"))
+	}
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// check return the request record previously written for an identical
+// request, or add the request to the index if it wasn't seen yet. With a
+// window, the requests written more than window before the request aren't
+// duplicates anymore, the request then replaces them in the index.
+func (index *requestDedupeIndex) check(hash string, request writtenRequest, window time.Duration) (original writtenRequest, duplicate bool) {
+	index.Lock()
+	defer index.Unlock()
+
+	if original, duplicate = index.requests[hash]; duplicate {
+		if window <= 0 || request.Time.Sub(original.Time) < window {
+			return original, true
+		}
+
+		index.requests[hash] = request
+		return request, false
+	}
+
+	index.requests[hash] = request
+	index.order = append(index.order, hash)
+	if len(index.order) > maxRequestHashes {
+		delete(index.requests, index.order[0])
+		index.order = index.order[1:]
+	}
+
+	return request, false
+}
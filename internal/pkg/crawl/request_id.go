@@ -0,0 +1,57 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDHeader is the header identifying each request sent, it is
+// logged with the capture so a request can be traced from end to end
+const requestIDHeader = "X-Crawler-Request-ID"
+
+// newRequestID return a unique ID for a request of an item, made of the
+// hash of the item, so the item can be found back from the ID, and of a
+// random part, as an item can be requested several times
+func newRequestID(item *frontier.Item) string {
+	return fmt.Sprintf("%016x-%08x", item.Hash, rand.Uint32())
+}
+
+// requestIDItemHash return the hash of the item a request ID was made for
+func requestIDItemHash(requestID string) (uint64, error) {
+	parts := strings.SplitN(requestID, "-", 2)
+	if len(parts) != 2 {
+		return 0, errors.New("invalid request ID: " + requestID)
+	}
+
+	return strconv.ParseUint(parts[0], 16, 64)
+}
+
+// setRequestID set the X-Crawler-Request-ID header of the request of an
+// item if --send-request-id is turned on, and return the ID
+func (c *Crawl) setRequestID(req *http.Request, item *frontier.Item) string {
+	if !c.SendRequestID {
+		return ""
+	}
+
+	requestID := newRequestID(item)
+	req.Header.Set(requestIDHeader, requestID)
+
+	return requestID
+}
+
+// withRequestID add the ID of the request to the fields of a log line,
+// if the request has one
+func withRequestID(fields logrus.Fields, requestID string) logrus.Fields {
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+
+	return fields
+}
@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/stretchr/testify/assert"
+	"mvdan.cc/xurls/v2"
+)
+
+func TestCaptureSendRequestID(t *testing.T) {
+	var requestIDsMutex sync.Mutex
+	var requestIDs = make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDsMutex.Lock()
+		requestIDs[r.URL.Path] = r.Header.Get(requestIDHeader)
+		requestIDsMutex.Unlock()
+
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	regexOutlinks = xurls.Relaxed()
+
+	pageURL, _ := url.Parse(server.URL + "/page")
+	item := frontier.NewItem(pageURL, nil, "seed", 0)
+
+	// By default no ID is sent
+	c := newTestCrawl()
+	c.Capture(item)
+	assert.Equal(t, "", requestIDs["/page"])
+
+	// With --send-request-id, the ID leads back to the item
+	c = newTestCrawl()
+	c.SendRequestID = true
+	c.Capture(item)
+	hash, err := requestIDItemHash(requestIDs["/page"])
+	assert.NoError(t, err)
+	assert.Equal(t, item.Hash, hash)
+
+	// Every request of an item has its own ID, the redirections too
+	first := requestIDs["/page"]
+	c.Capture(item)
+	assert.NotEmpty(t, requestIDs["/page"])
+	assert.NotEqual(t, first, requestIDs["/page"])
+
+	c.MaxRedirect = 5
+	redirectURL, _ := url.Parse(server.URL + "/redirect")
+	c.Capture(frontier.NewItem(redirectURL, nil, "seed", 0))
+	assert.NotEmpty(t, requestIDs["/redirect"])
+	assert.NotEmpty(t, requestIDs["/target"])
+	assert.NotEqual(t, requestIDs["/redirect"], requestIDs["/target"])
+
+	// The assets send it too
+	c.MaxConcurrentAssets = 1
+	c.captureAssets(item, newTestAssets(t, server, 1))
+	assert.NotEmpty(t, requestIDs["/asset/0"])
+
+	_, err = requestIDItemHash("invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// resumableBody is a response body that, if reading it fails before the
+// end, send a range request to get the rest of the body from where it
+// stopped, so the response can be written as a single complete record
+type resumableBody struct {
+	client      *http.Client
+	request     *http.Request
+	body        io.ReadCloser
+	validator   string
+	read        int64
+	total       int64
+	attempts    int
+	maxAttempts int
+	err         error
+
+	// context is the context of the range requests, the one of the
+	// interrupted request may have been cancelled by its idle timer,
+	// the range requests then get their own idle timer of idleTimeout
+	context     context.Context
+	idleTimeout time.Duration
+}
+
+// isResumable return true if the body of a response can be resumed with
+// range requests: the server advertised that it accepts byte ranges, and we
+// know the total length of the body
+func isResumable(resp *http.Response) bool {
+	return resp.Request != nil &&
+		resp.Request.Method == "GET" &&
+		resp.StatusCode == 200 &&
+		resp.ContentLength > 0 &&
+		strings.EqualFold(strings.TrimSpace(resp.Header.Get("Accept-Ranges")), "bytes")
+}
+
+func newResumableBody(client *http.Client, resp *http.Response, maxAttempts int) *resumableBody {
+	body := new(resumableBody)
+
+	body.client = client
+	body.request = resp.Request
+	body.body = resp.Body
+	body.total = resp.ContentLength
+	body.maxAttempts = maxAttempts
+	body.context = resp.Request.Context()
+
+	// The validator is sent with If-Range, so if the resource changed
+	// in the meantime we don't concatenate two different versions
+	body.validator = resp.Header.Get("ETag")
+	if len(body.validator) == 0 || strings.HasPrefix(body.validator, "W/") {
+		body.validator = resp.Header.Get("Last-Modified")
+	}
+
+	return body
+}
+
+func (b *resumableBody) Read(p []byte) (n int, err error) {
+	// If resuming the download failed, the body stays in error
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	n, err = b.body.Read(p)
+	b.read += int64(n)
+
+	if err == nil || err == io.EOF || b.read >= b.total || b.attempts >= b.maxAttempts {
+		return n, err
+	}
+
+	resumeErr := b.resume()
+	if resumeErr != nil {
+		logWarning.WithFields(logrus.Fields{
+			"url":   b.request.URL.String(),
+			"error": resumeErr,
+			"read":  b.read,
+			"total": b.total,
+		}).Warning("Unable to resume interrupted download")
+		b.err = err
+		return n, err
+	}
+
+	return n, nil
+}
+
+func (b *resumableBody) Close() error {
+	return b.body.Close()
+}
+
+// resume send a range request for the rest of the body, and replace the
+// interrupted body by the body of the range response
+func (b *resumableBody) resume() error {
+	b.attempts++
+	b.body.Close()
+
+	req := b.request.Clone(b.context)
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(b.read, 10)+"-")
+	if len(b.validator) > 0 {
+		req.Header.Set("If-Range", b.validator)
+	}
+
+	var idleTimer *idleTimer
+	if b.idleTimeout > 0 {
+		req, idleTimer = withIdleTimeout(req, b.idleTimeout)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if idleTimer != nil {
+			idleTimer.stop()
+		}
+		b.body = http.NoBody
+		return err
+	}
+
+	// Some servers answer with a multipart/byteranges response even for
+	// a single range, the rest of the body is then its first part
+	var contentRange = resp.Header.Get("Content-Range")
+	var body io.ReadCloser = resp.Body
+	if boundary := byterangesBoundary(resp); resp.StatusCode == http.StatusPartialContent && boundary != "" {
+		part, err := multipart.NewReader(resp.Body, boundary).NextPart()
+		if err == nil {
+			contentRange = part.Header.Get("Content-Range")
+			body = &byterangesPartBody{Reader: part, body: resp.Body}
+		}
+	}
+
+	// If the server doesn't answer with the range we asked for, because the
+	// resource changed or it doesn't support ranges after all, we give up
+	expectedRange := fmt.Sprintf("bytes %d-", b.read)
+	if resp.StatusCode != http.StatusPartialContent || !strings.HasPrefix(contentRange, expectedRange) {
+		if idleTimer != nil {
+			idleTimer.stop()
+		}
+		resp.Body.Close()
+		b.body = http.NoBody
+		return errors.New("unexpected response to range request: " + resp.Status)
+	}
+
+	if idleTimer != nil {
+		body = &idleTimeoutBody{body: body, timer: idleTimer}
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"url":     b.request.URL.String(),
+		"read":    b.read,
+		"total":   b.total,
+		"attempt": b.attempts,
+	}).Info("Resuming interrupted download")
+
+	b.body = body
+
+	return nil
+}
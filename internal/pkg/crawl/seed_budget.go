@@ -0,0 +1,103 @@
+package crawl
+
+import (
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/sirupsen/logrus"
+)
+
+// seedUsage is what was captured in the tree of a seed
+type seedUsage struct {
+	URLs      int64
+	Bytes     int64
+	Exhausted bool
+}
+
+// seedBudgets holds what was captured in the tree of each seed, so the
+// seeds that exhausted their budget stop expanding their outlinks. The
+// usage is kept in memory, it starts over when the job is resumed.
+type seedBudgets struct {
+	sync.Mutex
+	seeds map[string]*seedUsage
+}
+
+func newSeedBudgets() *seedBudgets {
+	return &seedBudgets{
+		seeds: make(map[string]*seedUsage),
+	}
+}
+
+// responseSize return the number of bytes of a response written in the
+// WARC, or its Content-Length if it wasn't dumped on disk
+func responseSize(resp *http.Response, respPath string) int64 {
+	if respPath != "" {
+		if fileInfo, err := os.Stat(respPath); err == nil {
+			return fileInfo.Size()
+		}
+	}
+
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+
+	return 0
+}
+
+// isSeedBudgetEnabled return true if --seed-max-urls
+// or --seed-max-bytes limit the tree of the seeds
+func (c *Crawl) isSeedBudgetEnabled() bool {
+	return c.SeedMaxURLs > 0 || c.SeedMaxBytes > 0
+}
+
+// consumeSeedBudget count a capture of the item in the budget of its
+// seed, and log the seeds that exhaust their budget with it
+func (c *Crawl) consumeSeedBudget(item *frontier.Item, bytes int64) {
+	if !c.isSeedBudgetEnabled() {
+		return
+	}
+
+	seed := item.Seed().URL.String()
+
+	c.SeedBudgets.Lock()
+	usage, ok := c.SeedBudgets.seeds[seed]
+	if !ok {
+		usage = new(seedUsage)
+		c.SeedBudgets.seeds[seed] = usage
+	}
+
+	usage.URLs++
+	usage.Bytes += bytes
+
+	var exhausted = !usage.Exhausted &&
+		((c.SeedMaxURLs > 0 && usage.URLs >= c.SeedMaxURLs) || (c.SeedMaxBytes > 0 && usage.Bytes >= c.SeedMaxBytes))
+	if exhausted {
+		usage.Exhausted = true
+	}
+	var URLs, totalBytes = usage.URLs, usage.Bytes
+	c.SeedBudgets.Unlock()
+
+	if exhausted {
+		logWarning.WithFields(logrus.Fields{
+			"seed":  seed,
+			"urls":  URLs,
+			"bytes": totalBytes,
+		}).Warning("Seed exhausted its capture budget, its outlinks won't be followed anymore")
+	}
+}
+
+// isSeedBudgetExhausted return true if the seed of the item exhausted
+// its budget, the children of the item are then not queued
+func (c *Crawl) isSeedBudgetExhausted(item *frontier.Item) bool {
+	if !c.isSeedBudgetEnabled() {
+		return false
+	}
+
+	c.SeedBudgets.Lock()
+	defer c.SeedBudgets.Unlock()
+
+	usage, ok := c.SeedBudgets.seeds[item.Seed().URL.String()]
+	return ok && usage.Exhausted
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedBudgetURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.SeedMaxURLs = 2
+	c.MaxHops = 10
+	c.Frontier.PushChan = make(chan *frontier.Item, 10)
+
+	seedURL, _ := url.Parse(server.URL + "/seed")
+	otherURL, _ := url.Parse(server.URL + "/other")
+	seed := frontier.NewItem(seedURL, nil, "seed", 0)
+	other := frontier.NewItem(otherURL, nil, "seed", 0)
+
+	// The seed and one of its assets exhaust the budget of the seed
+	pageURL, _ := url.Parse(server.URL + "/page")
+	page := frontier.NewItem(pageURL, seed, "seed", 1)
+	for _, item := range []*frontier.Item{seed, frontier.NewItem(pageURL, seed, "asset", 0)} {
+		req, _ := http.NewRequest("GET", item.URL.String(), nil)
+		resp, _, err := c.executeGET(item, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	assert.True(t, c.isSeedBudgetExhausted(page))
+	assert.False(t, c.isSeedBudgetExhausted(other))
+
+	// The outlinks of its tree are dropped, the other seeds are unaffected
+	outlink, _ := url.Parse(server.URL + "/outlink")
+	c.queueOutlinks([]url.URL{*outlink}, page)
+	assert.Len(t, c.Frontier.PushChan, 0)
+
+	c.queueOutlinks([]url.URL{*outlink}, other)
+	assert.Len(t, c.Frontier.PushChan, 1)
+}
+
+func TestSeedBudgetBytes(t *testing.T) {
+	c := newTestCrawl()
+	c.SeedMaxBytes = 1000
+
+	seedURL, _ := url.Parse("https://example.com/")
+	seed := frontier.NewItem(seedURL, nil, "seed", 0)
+	asset := frontier.NewItem(seedURL, seed, "asset", 0)
+
+	c.consumeSeedBudget(seed, 600)
+	assert.False(t, c.isSeedBudgetExhausted(asset))
+
+	c.consumeSeedBudget(asset, 400)
+	assert.True(t, c.isSeedBudgetExhausted(asset))
+
+	// Without budget, nothing is tracked
+	c = newTestCrawl()
+	c.consumeSeedBudget(seed, 2000)
+	assert.False(t, c.isSeedBudgetExhausted(seed))
+	assert.Empty(t, c.SeedBudgets.seeds)
+}
@@ -0,0 +1,67 @@
+package crawl
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// AssetSelectorRule is a user-defined rule to extract assets from the
+// value of an attribute of the elements matching a CSS selector
+type AssetSelectorRule struct {
+	Raw       string
+	Attribute string
+	Selector  cascadia.Selector
+}
+
+var regexLastAttributeSelector = regexp.MustCompile(`\[\s*([^\]=~|^$*\s]+)\s*\]\s*$`)
+
+// ParseAssetSelectorRules parse and validate the rules given with
+// --asset-selector, a rule is either a CSS selector followed by the attribute
+// to extract, separated by an @ (div.hero@data-bg), or a CSS selector ending
+// with an attribute presence selector, that is the attribute to extract
+// (div.hero[data-bg])
+func ParseAssetSelectorRules(rawRules []string) (rules []AssetSelectorRule, err error) {
+	for _, rawRule := range rawRules {
+		var rule = AssetSelectorRule{Raw: rawRule}
+		var selector = strings.TrimSpace(rawRule)
+
+		if index := strings.LastIndex(selector, "@"); index != -1 {
+			rule.Attribute = strings.TrimSpace(selector[index+1:])
+			selector = strings.TrimSpace(selector[:index])
+		} else if match := regexLastAttributeSelector.FindStringSubmatch(selector); match != nil {
+			rule.Attribute = match[1]
+		}
+
+		if len(selector) == 0 || len(rule.Attribute) == 0 {
+			return nil, errors.New("invalid asset selector rule " + rawRule + ", no attribute to extract")
+		}
+
+		rule.Selector, err = cascadia.Compile(selector)
+		if err != nil {
+			return nil, errors.New("invalid asset selector rule " + rawRule + ": " + err.Error())
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// extractAssetsFromSelectorRules return the values of the attributes
+// designated by the rules, on the elements matching their selectors
+func extractAssetsFromSelectorRules(doc *goquery.Document, rules []AssetSelectorRule) (rawAssets []string) {
+	for _, rule := range rules {
+		doc.FindMatcher(rule.Selector).Each(func(index int, item *goquery.Selection) {
+			link, exists := item.Attr(rule.Attribute)
+			if exists && len(strings.TrimSpace(link)) > 0 {
+				rawAssets = append(rawAssets, link)
+			}
+		})
+	}
+
+	return rawAssets
+}
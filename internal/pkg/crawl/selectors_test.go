@@ -0,0 +1,44 @@
+package crawl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAssetSelectorRules(t *testing.T) {
+	rules, err := ParseAssetSelectorRules([]string{"div.hero[data-bg]", "img.lazy@data-hi-res"})
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "data-bg", rules[0].Attribute)
+		assert.Equal(t, "data-hi-res", rules[1].Attribute)
+	}
+
+	// No attribute to extract
+	_, err = ParseAssetSelectorRules([]string{"div.hero"})
+	assert.Error(t, err)
+
+	// Invalid selector
+	_, err = ParseAssetSelectorRules([]string{"div[[@data-bg"})
+	assert.Error(t, err)
+}
+
+func TestExtractAssetsSelectorRules(t *testing.T) {
+	rules, err := ParseAssetSelectorRules([]string{"div.hero[data-bg]", "img.lazy@data-hi-res"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := new(Crawl)
+	c.AssetSelectorRules = rules
+
+	assets := extractTestAssets(t, c, `<html><body>
+		<div class="hero" data-bg="/images/hero.jpg"></div>
+		<div class="other" data-bg="/images/other.jpg"></div>
+		<img class="lazy" data-hi-res="/images/large.jpg">
+	</body></html>`)
+
+	assert.Contains(t, assets, "https://example.com/images/hero.jpg")
+	assert.Contains(t, assets, "https://example.com/images/large.jpg")
+	assert.NotContains(t, assets, "https://example.com/images/other.jpg")
+}
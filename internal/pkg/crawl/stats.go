@@ -45,10 +45,15 @@ func (c *Crawl) printLiveStats() {
 		stats.AddRow("", "")
 		stats.AddRow("  - Job:", c.Job)
 		stats.AddRow("  - State:", c.getCrawlState())
-		stats.AddRow("  - Active workers:", strconv.Itoa(int(c.ActiveWorkers.Value()))+"/"+strconv.Itoa(c.Workers))
+		stats.AddRow("  - Active workers:", strconv.Itoa(int(c.ActiveWorkers.Value()))+"/"+strconv.Itoa(c.GetWorkersCount()))
 		stats.AddRow("  - URI/s:", c.URIsPerSecond.Rate())
 		stats.AddRow("  - Crawled:", c.Crawled.Value())
 		stats.AddRow("  - Queued:", c.Frontier.QueueCount.Value())
+		stats.AddRow("  - Errors:", c.ErrorStats.String())
+		if c.WARCPool != nil {
+			warcPoolStats := c.WARCPool.Snapshot()
+			stats.AddRow("  - WARC writers:", strconv.FormatInt(warcPoolStats.Writers, 10)+" ("+strconv.FormatInt(warcPoolStats.Waits, 10)+" waits, "+strconv.FormatInt(warcPoolStats.Spilled, 10)+" spilled)")
+		}
 		stats.AddRow("", "")
 		stats.AddRow("  - Elapsed time:", fmt.Sprintf("%s", time.Since(c.StartTime)))
 		stats.AddRow("  - Allocated (heap):", bToMb(m.Alloc))
@@ -0,0 +1,152 @@
+package crawl
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// minThrottleEviction is the number of hosts from which the idle
+// hosts are evicted from the throttling states
+const minThrottleEviction = 1000
+
+// hostThrottle is the state of the throttling of a host: the slots of
+// its requests in flight, and the time at which its next request can start
+type hostThrottle struct {
+	slots    chan struct{}
+	next     time.Time
+	inflight int
+}
+
+// requestThrottle applies --max-concurrent-requests-per-host, --crawl-delay
+// and --max-requests-per-second to the requests sent, the retries included
+type requestThrottle struct {
+	sync.Mutex
+	hosts map[string]*hostThrottle
+	next  time.Time
+	// evictAt is the number of hosts at which the idle hosts are evicted,
+	// it grows with the hosts left so the eviction cost is amortized
+	evictAt int
+}
+
+func newRequestThrottle() *requestThrottle {
+	return &requestThrottle{
+		hosts:   make(map[string]*hostThrottle),
+		evictAt: minThrottleEviction,
+	}
+}
+
+// isThrottled return true if the requests are throttled at all
+func (c *Crawl) isThrottled() bool {
+	return c.MaxConcurrentPerHost > 0 || c.CrawlDelay > 0 || c.MaxRequestsPerSecond > 0
+}
+
+// evictIdle remove the states of the hosts without request in flight,
+// whose next request can start right away, as a new state is the same
+func (throttle *requestThrottle) evictIdle(now time.Time) {
+	for host, state := range throttle.hosts {
+		if state.inflight == 0 && !state.next.After(now) {
+			delete(throttle.hosts, host)
+		}
+	}
+
+	throttle.evictAt = 2 * len(throttle.hosts)
+	if throttle.evictAt < minThrottleEviction {
+		throttle.evictAt = minThrottleEviction
+	}
+}
+
+// host return the throttling state of a host, created the first time
+func (throttle *requestThrottle) host(host string, maxConcurrent int) *hostThrottle {
+	state, ok := throttle.hosts[host]
+	if !ok {
+		if len(throttle.hosts) >= throttle.evictAt {
+			throttle.evictIdle(time.Now())
+		}
+
+		state = new(hostThrottle)
+		if maxConcurrent > 0 {
+			state.slots = make(chan struct{}, maxConcurrent)
+		}
+		throttle.hosts[host] = state
+	}
+
+	return state
+}
+
+// reserve return how long to wait before starting a request to the host,
+// so it is at least --crawl-delay after the previous request to the host,
+// jittered by --rate-limit-jitter, and at least 1/--max-requests-per-second
+// after the previous request
+func (c *Crawl) reserve(state *hostThrottle) time.Duration {
+	c.Throttle.Lock()
+	defer c.Throttle.Unlock()
+
+	var now = time.Now()
+	var start = now
+	if state.next.After(start) {
+		start = state.next
+	}
+	if c.Throttle.next.After(start) {
+		start = c.Throttle.next
+	}
+
+	if c.CrawlDelay > 0 {
+		state.next = start.Add(withJitter(c.CrawlDelay, c.RateLimitJitter))
+	}
+	if c.MaxRequestsPerSecond > 0 {
+		c.Throttle.next = start.Add(time.Duration(float64(time.Second) / c.MaxRequestsPerSecond))
+	}
+
+	return start.Sub(now)
+}
+
+// throttle wait until a request to the host can be sent, it returns the
+// function to call once the response is received, or an error if the
+// context of the request is done while waiting
+func (c *Crawl) throttle(ctx context.Context, host string) (release func(), err error) {
+	if !c.isThrottled() || c.Throttle == nil {
+		return func() {}, nil
+	}
+
+	// The host is counted in flight from now on, so
+	// it isn't evicted while the request waits
+	c.Throttle.Lock()
+	state := c.Throttle.host(host, c.MaxConcurrentPerHost)
+	state.inflight++
+	c.Throttle.Unlock()
+
+	done := func() {
+		c.Throttle.Lock()
+		state.inflight--
+		c.Throttle.Unlock()
+	}
+
+	release = done
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		case <-ctx.Done():
+			done()
+			return nil, ctx.Err()
+		}
+		release = func() {
+			<-state.slots
+			done()
+		}
+	}
+
+	if wait := c.reserve(state); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
@@ -0,0 +1,136 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleMaxConcurrentPerHost(t *testing.T) {
+	var inflight, maxInflight int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt64(&inflight, 1)
+		for {
+			previous := atomic.LoadInt64(&maxInflight)
+			if current <= previous || atomic.CompareAndSwapInt64(&maxInflight, previous, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt64(&inflight, -1)
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.MaxConcurrentPerHost = 2
+
+	var requests sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			resp, err := c.Client.Get(server.URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+	requests.Wait()
+
+	assert.Equal(t, int64(2), maxInflight)
+}
+
+func TestThrottleDelays(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The same server under two host names
+	otherHost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	get := func(c *Crawl, URLs ...string) time.Duration {
+		start := time.Now()
+		for _, URL := range URLs {
+			resp, err := c.Client.Get(URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}
+		return time.Since(start)
+	}
+
+	// --crawl-delay spaces the requests to the same host only
+	c := newTestCrawl()
+	c.CrawlDelay = 200 * time.Millisecond
+	assert.True(t, get(c, server.URL, server.URL) >= 200*time.Millisecond)
+
+	c = newTestCrawl()
+	c.CrawlDelay = 200 * time.Millisecond
+	assert.True(t, get(c, server.URL, otherHost) < 200*time.Millisecond)
+
+	// --max-requests-per-second spaces all the requests
+	c = newTestCrawl()
+	c.MaxRequestsPerSecond = 5
+	assert.True(t, get(c, server.URL, otherHost) >= 200*time.Millisecond)
+}
+
+func TestThrottleEvictIdleHosts(t *testing.T) {
+	c := newTestCrawl()
+	c.CrawlDelay = time.Hour
+
+	// A host with a request in flight, and one waiting for its delay
+	release, err := c.throttle(context.Background(), "inflight.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.throttle(context.Background(), "delayed.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.CrawlDelay = 0
+	c.MaxConcurrentPerHost = 1
+	for i := 0; i < minThrottleEviction; i++ {
+		release, err := c.throttle(context.Background(), fmt.Sprintf("host%d.com", i))
+		if assert.NoError(t, err) {
+			release()
+		}
+	}
+
+	// The idle hosts were evicted, but not the others
+	c.Throttle.Lock()
+	assert.True(t, len(c.Throttle.hosts) < minThrottleEviction)
+	assert.Contains(t, c.Throttle.hosts, "inflight.com")
+	assert.Contains(t, c.Throttle.hosts, "delayed.com")
+	c.Throttle.Unlock()
+
+	release()
+}
+
+func TestThrottleCrawlDelayJitter(t *testing.T) {
+	c := newTestCrawl()
+	c.CrawlDelay = time.Second
+	c.RateLimitJitter = 0.5
+
+	var delays = make(map[time.Duration]bool)
+	for i := 0; i < 10; i++ {
+		state := new(hostThrottle)
+		start := time.Now()
+		c.reserve(state)
+
+		delay := state.next.Sub(start)
+		assert.True(t, delay >= 500*time.Millisecond && delay <= 1510*time.Millisecond, "delay: %s", delay)
+		delays[delay.Round(time.Millisecond)] = true
+	}
+
+	// The delays aren't all the same
+	assert.True(t, len(delays) > 1)
+}
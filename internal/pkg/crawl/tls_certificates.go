@@ -0,0 +1,164 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/CorentinB/warc"
+	"github.com/sirupsen/logrus"
+)
+
+// tlsCertificateHosts keep track of the hosts whose
+// TLS certificate chain was already written in the WARC
+type tlsCertificateHosts struct {
+	sync.Mutex
+	hosts map[string]bool
+}
+
+func newTLSCertificateHosts() *tlsCertificateHosts {
+	return &tlsCertificateHosts{
+		hosts: make(map[string]bool),
+	}
+}
+
+// add mark a host as captured, it returns
+// false if the host was already captured
+func (index *tlsCertificateHosts) add(host string) bool {
+	index.Lock()
+	defer index.Unlock()
+
+	if index.hosts[host] {
+		return false
+	}
+	index.hosts[host] = true
+
+	return true
+}
+
+// encodeCertificateChain return the certificates presented by the
+// server, from the leaf to the root, as concatenated PEM blocks
+func encodeCertificateChain(state *tls.ConnectionState) []byte {
+	var chain bytes.Buffer
+
+	for _, certificate := range state.PeerCertificates {
+		pem.Encode(&chain, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certificate.Raw,
+		})
+	}
+
+	return chain.Bytes()
+}
+
+// writeTLSCertificates write the TLS certificate chain presented by the
+// host of a response as a resource record, once per host for the crawl.
+// Its URI is x-tls-certificates:host, like the dns: records, so it can't
+// be taken for a capture of the homepage of the host when replaying.
+func (c *Crawl) writeTLSCertificates(URL *url.URL, state *tls.ConnectionState) {
+	if state == nil || len(state.PeerCertificates) == 0 || !c.TLSCertHosts.add(URL.Host) {
+		return
+	}
+
+	var resourceRecord = warc.NewRecord()
+	resourceRecord.Header.Set("WARC-Type", "resource")
+	resourceRecord.Header.Set("WARC-Target-URI", "x-tls-certificates:"+URL.Host)
+	resourceRecord.Header.Set("Content-Type", "application/x-pem-file")
+	resourceRecord.Content = bytes.NewReader(encodeCertificateChain(state))
+
+	var batch = warc.NewRecordBatch()
+	batch.Records = append(batch.Records, resourceRecord)
+	c.setCollection(batch)
+	c.WARCWriter <- batch
+}
+
+// tlsValidations keep the result of the validation of the certificate
+// chains presented by the hosts, so each chain is only verified once
+type tlsValidations struct {
+	sync.Mutex
+	errors map[string]string
+}
+
+func newTLSValidations() *tlsValidations {
+	return &tlsValidations{
+		errors: make(map[string]string),
+	}
+}
+
+// verify return the error of the validation of a certificate chain
+// presented for hostname, or an empty string if the chain is valid
+func (validations *tlsValidations) verify(hostname string, certificates []*x509.Certificate) string {
+	fingerprint := sha256.Sum256(certificates[0].Raw)
+	key := hostname + " " + hex.EncodeToString(fingerprint[:])
+
+	validations.Lock()
+	validationError, ok := validations.errors[key]
+	validations.Unlock()
+	if ok {
+		return validationError
+	}
+
+	if err := verifyCertificateChain(certificates, hostname); err != nil {
+		validationError = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+
+	validations.Lock()
+	validations.errors[key] = validationError
+	validations.Unlock()
+
+	return validationError
+}
+
+// recordTLSErrors verify the certificate chain of a response captured
+// without validating it, and if it is invalid, expired or self-signed,
+// log the error and write it in a metadata record about the response, so
+// the archive documents which captures were made over TLS issues
+func (c *Crawl) recordTLSErrors(resp *http.Response) {
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return
+	}
+
+	// The certificates of the other hosts were already validated
+	if c.CertValidation && !utils.IsHostExcluded(resp.TLS.ServerName, c.InsecureHosts) {
+		return
+	}
+
+	validationError := c.TLSValidations.verify(resp.Request.URL.Hostname(), resp.TLS.PeerCertificates)
+	if validationError == "" {
+		return
+	}
+
+	logWarning.WithFields(logrus.Fields{
+		"url":       resp.Request.URL.String(),
+		"tls_error": validationError,
+	}).Warning("Captured over a TLS connection whose certificate isn't valid")
+
+	if !c.WARC {
+		return
+	}
+
+	fingerprint := sha256.Sum256(resp.TLS.PeerCertificates[0].Raw)
+
+	var metadataRecord = warc.NewRecord()
+	metadataRecord.Header.Set("WARC-Type", "metadata")
+	metadataRecord.Header.Set("WARC-Target-URI", utils.CleanURL(resp.Request.URL.String()))
+	if recordID := getResponseRecordID(resp); recordID != "" {
+		metadataRecord.Header.Set("WARC-Concurrent-To", recordID)
+	}
+	metadataRecord.Header.Set("Content-Type", "application/warc-fields")
+	metadataRecord.Content = strings.NewReader("tls-validation-error: " + validationError + "\r\n" +
+		"tls-certificate-sha256: " + hex.EncodeToString(fingerprint[:]) + "\r\n")
+
+	var batch = warc.NewRecordBatch()
+	batch.Records = append(batch.Records, metadataRecord)
+	c.setCollection(batch)
+	c.WARCWriter <- batch
+}
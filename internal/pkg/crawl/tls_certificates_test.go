@@ -0,0 +1,126 @@
+package crawl
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureTLSCertsOncePerHost(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.WARC = true
+	c.CaptureTLSCerts = true
+	c.WARCWriter = make(chan *warc.RecordBatch)
+
+	var certificates []*warc.Record
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				if record.Header.Get("WARC-Type") == "resource" {
+					certificates = append(certificates, record)
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	for _, path := range []string{"/a", "/b"} {
+		URL, _ := url.Parse(server.URL + path)
+		item := frontier.NewItem(URL, nil, "seed", 0)
+		req, _ := http.NewRequest("GET", URL.String(), nil)
+
+		resp, _, err := c.executeGET(item, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	close(c.WARCWriter)
+	<-done
+
+	if !assert.Len(t, certificates, 1) {
+		return
+	}
+
+	serverURL, _ := url.Parse(server.URL)
+	assert.Equal(t, "x-tls-certificates:"+serverURL.Host, certificates[0].Header.Get("WARC-Target-URI"))
+	assert.Equal(t, "application/x-pem-file", certificates[0].Header.Get("Content-Type"))
+
+	content, _ := ioutil.ReadAll(certificates[0].Content)
+	block, _ := pem.Decode(content)
+	if assert.NotNil(t, block) {
+		assert.Equal(t, server.Certificate().Raw, block.Bytes)
+	}
+}
+
+func TestRecordTLSErrorsSelfSigned(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("self-signed"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.WARC = true
+	c.RecordTLSErrors = true
+	c.WARCWriter = make(chan *warc.RecordBatch)
+
+	var metadata []*warc.Record
+	var responseID string
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				switch record.Header.Get("WARC-Type") {
+				case "metadata":
+					metadata = append(metadata, record)
+				case "response":
+					responseID = record.Header.Get("WARC-Record-ID")
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	URL, _ := url.Parse(server.URL + "/page")
+	item := frontier.NewItem(URL, nil, "seed", 0)
+	req, _ := http.NewRequest("GET", URL.String(), nil)
+
+	resp, _, err := c.executeGET(item, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	close(c.WARCWriter)
+	<-done
+
+	if !assert.NotEmpty(t, responseID, "no response record was written") || !assert.Len(t, metadata, 1) {
+		return
+	}
+
+	assert.Equal(t, URL.String(), metadata[0].Header.Get("WARC-Target-URI"))
+	assert.Equal(t, "application/warc-fields", metadata[0].Header.Get("Content-Type"))
+	assert.Equal(t, responseID, metadata[0].Header.Get("WARC-Concurrent-To"))
+
+	content, _ := ioutil.ReadAll(metadata[0].Content)
+	assert.Contains(t, string(content), "tls-validation-error: ")
+	assert.Contains(t, string(content), "tls-certificate-sha256: ")
+}
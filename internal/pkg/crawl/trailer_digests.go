@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/CorentinB/warc"
+	"github.com/sirupsen/logrus"
+)
+
+// bodyHashes are the hashes of a body computed while it is read, by
+// the name of their algorithm in the Digest and Content-Digest headers
+type bodyHashes map[string]hash.Hash
+
+// newBodyHashes return the hashes to compute for a body, according to the
+// trailers announced in the Trailer header of its response. A
+// X-Content-SHA256 trailer only needs a SHA-256, but the algorithms of the
+// Digest, Content-Digest and Repr-Digest trailers are only known once the
+// trailers are received, so all the supported algorithms are then hashed.
+func newBodyHashes(trailer http.Header) bodyHashes {
+	var hashes = make(bodyHashes)
+
+	for _, header := range []string{"Digest", "Content-Digest", "Repr-Digest"} {
+		if _, announced := trailer[header]; announced {
+			hashes["sha-256"] = sha256.New()
+			hashes["sha-512"] = sha512.New()
+			hashes["sha"] = sha1.New()
+			hashes["md5"] = md5.New()
+			return hashes
+		}
+	}
+
+	if _, announced := trailer["X-Content-Sha256"]; announced {
+		hashes["sha-256"] = sha256.New()
+	}
+
+	return hashes
+}
+
+func (hashes bodyHashes) list() (list []hash.Hash) {
+	for _, h := range hashes {
+		list = append(list, h)
+	}
+	return list
+}
+
+// trailerDigest is a digest of the body declared in a trailer
+type trailerDigest struct {
+	Header    string
+	Algorithm string
+	Expected  []byte
+}
+
+// decodeDigest decode a digest encoded in base64, or in hexadecimal if it
+// has the length of an hexadecimal MD5, SHA-1, SHA-256 or SHA-512 digest
+func decodeDigest(value string) []byte {
+	value = strings.Trim(strings.TrimSpace(value), ":")
+
+	switch len(value) {
+	case 32, 40, 64, 128:
+		if decoded, err := hex.DecodeString(value); err == nil {
+			return decoded
+		}
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return decoded
+	}
+	if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
+		return decoded
+	}
+
+	return nil
+}
+
+// parseTrailerDigests return the digests of the body declared in the
+// trailers, either as a list of algorithm=value in the Digest header
+// (SHA-256=base64) or in the Content-Digest and Repr-Digest headers
+// (sha-256=:base64:), or as a single SHA-256 in X-Content-SHA256
+func parseTrailerDigests(trailer http.Header) (digests []trailerDigest) {
+	for _, header := range []string{"Digest", "Content-Digest", "Repr-Digest"} {
+		for _, value := range trailer.Values(header) {
+			for _, digest := range strings.Split(value, ",") {
+				parts := strings.SplitN(strings.TrimSpace(digest), "=", 2)
+				if len(parts) != 2 {
+					continue
+				}
+
+				expected := decodeDigest(parts[1])
+				if expected == nil {
+					continue
+				}
+
+				digests = append(digests, trailerDigest{
+					Header:    header,
+					Algorithm: strings.ToLower(parts[0]),
+					Expected:  expected,
+				})
+			}
+		}
+	}
+
+	if value := trailer.Get("X-Content-SHA256"); value != "" {
+		if expected := decodeDigest(value); expected != nil {
+			digests = append(digests, trailerDigest{
+				Header:    "X-Content-SHA256",
+				Algorithm: "sha-256",
+				Expected:  expected,
+			})
+		}
+	}
+
+	return digests
+}
+
+// checkTrailerDigests compare the hashes of a body with the digests
+// declared in the trailers of its response, the digests with an unknown
+// algorithm are ignored, it returns the content of a metadata record
+// listing the digests verified and the mismatches
+func (c *Crawl) checkTrailerDigests(resp *http.Response, hashes bodyHashes) (content string) {
+	var builder strings.Builder
+
+	for _, digest := range parseTrailerDigests(resp.Trailer) {
+		h, ok := hashes[digest.Algorithm]
+		if !ok {
+			continue
+		}
+
+		if bytes.Equal(h.Sum(nil), digest.Expected) {
+			fmt.Fprintf(&builder, "trailer-digest-verified: %s %s\r\n", digest.Header, digest.Algorithm)
+			continue
+		}
+
+		logWarning.WithFields(logrus.Fields{
+			"url":       resp.Request.URL.String(),
+			"header":    digest.Header,
+			"algorithm": digest.Algorithm,
+			"expected":  hex.EncodeToString(digest.Expected),
+			"actual":    hex.EncodeToString(h.Sum(nil)),
+		}).Warning("The body doesn't match the digest of its trailers, it may be corrupted")
+
+		fmt.Fprintf(&builder, "trailer-digest-mismatch: %s %s expected=%s actual=%s\r\n",
+			digest.Header, digest.Algorithm, hex.EncodeToString(digest.Expected), hex.EncodeToString(h.Sum(nil)))
+	}
+
+	return builder.String()
+}
+
+// newTrailerDigestsRecord return a metadata record with the result of the
+// verification of the trailer digests, concurrent to the response record
+func newTrailerDigestsRecord(responseRecord *warc.Record, content string) *warc.Record {
+	var metadataRecord = warc.NewRecord()
+	metadataRecord.Header.Set("WARC-Type", "metadata")
+	metadataRecord.Header.Set("WARC-Target-URI", responseRecord.Header.Get("WARC-Target-URI"))
+	metadataRecord.Header.Set("WARC-Concurrent-To", responseRecord.Header.Get("WARC-Record-ID"))
+	metadataRecord.Header.Set("Content-Type", "application/warc-fields")
+	metadataRecord.Content = strings.NewReader(content)
+
+	return metadataRecord
+}
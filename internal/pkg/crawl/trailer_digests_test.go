@@ -0,0 +1,127 @@
+package crawl
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrailerDigests(t *testing.T) {
+	var trailer = make(http.Header)
+	trailer.Set("Digest", "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=, adler32=%%, MD5=HUXZLQLMuI/KZ5KDcJPcOA==")
+	trailer.Set("Content-Digest", "sha-512=:invalid base64:")
+	trailer.Set("X-Content-SHA256", "5f8f04f6a3a892aaabbddb6cf273894493773960d4a325b105fee46eef4304f1")
+
+	digests := parseTrailerDigests(trailer)
+	if assert.Len(t, digests, 3) {
+		assert.Equal(t, "sha-256", digests[0].Algorithm)
+		assert.Equal(t, "md5", digests[1].Algorithm)
+		assert.Equal(t, "X-Content-SHA256", digests[2].Header)
+		assert.Equal(t, digests[0].Expected, digests[2].Expected)
+	}
+}
+
+func TestNewBodyHashes(t *testing.T) {
+	algorithms := func(hashes bodyHashes) (list []string) {
+		for algorithm := range hashes {
+			list = append(list, algorithm)
+		}
+		return list
+	}
+
+	// Nothing is hashed without a digest announced
+	assert.Empty(t, newBodyHashes(nil))
+	assert.Empty(t, newBodyHashes(http.Header{"Expires": nil}))
+
+	// A SHA-256 is enough for X-Content-SHA256
+	assert.ElementsMatch(t, []string{"sha-256"}, algorithms(newBodyHashes(http.Header{"X-Content-Sha256": nil})))
+
+	// The algorithms of the other digests aren't known in advance
+	for _, header := range []string{"Digest", "Content-Digest", "Repr-Digest"} {
+		assert.ElementsMatch(t, []string{"sha-256", "sha-512", "sha", "md5"},
+			algorithms(newBodyHashes(http.Header{header: nil, "X-Content-Sha256": nil})))
+	}
+}
+
+func TestVerifyTrailerDigests(t *testing.T) {
+	var body = "integrity"
+	var sum = sha256.Sum256([]byte(body))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Digest, X-Content-SHA256")
+		w.Write([]byte(body))
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/match" {
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		w.Header().Set("X-Content-SHA256", hex.EncodeToString(sum[:len(sum)-1])+"00")
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+	os.MkdirAll(filepath.Join(jobPath, "temp"), os.ModePerm)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.WARC = true
+	c.WARCTrailerDigests = true
+	c.WARCWriter = make(chan *warc.RecordBatch)
+
+	var metadata = make(map[string]string)
+	var responseIDs = make(map[string]string)
+	done := make(chan bool)
+	go func() {
+		for batch := range c.WARCWriter {
+			for _, record := range batch.Records {
+				switch record.Header.Get("WARC-Type") {
+				case "response":
+					responseIDs[record.Header.Get("WARC-Target-URI")] = record.Header.Get("WARC-Record-ID")
+				case "metadata":
+					content, _ := ioutil.ReadAll(record.Content)
+					metadata[record.Header.Get("WARC-Target-URI")] = string(content)
+					assert.Equal(t, responseIDs[record.Header.Get("WARC-Target-URI")], record.Header.Get("WARC-Concurrent-To"))
+				}
+			}
+			if batch.Done != nil {
+				batch.Done <- true
+			}
+		}
+		done <- true
+	}()
+
+	for _, path := range []string{"/match", "/mismatch"} {
+		URL, _ := url.Parse(server.URL + path)
+		req, _ := http.NewRequest("GET", URL.String(), nil)
+
+		resp, respPath, err := c.executeGET(frontier.NewItem(URL, nil, "seed", 0), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		markTempFileDone(respPath)
+	}
+	close(c.WARCWriter)
+	<-done
+
+	assert.Equal(t, "trailer-digest-verified: Digest sha-256\r\n"+
+		"trailer-digest-mismatch: X-Content-SHA256 sha-256 expected="+hex.EncodeToString(sum[:len(sum)-1])+"00 actual="+hex.EncodeToString(sum[:])+"\r\n",
+		metadata[server.URL+"/match"])
+	assert.Equal(t, "trailer-digest-mismatch: X-Content-SHA256 sha-256 expected="+hex.EncodeToString(sum[:len(sum)-1])+"00 actual="+hex.EncodeToString(sum[:])+"\r\n",
+		metadata[server.URL+"/mismatch"])
+}
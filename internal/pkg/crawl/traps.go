@@ -0,0 +1,114 @@
+package crawl
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxTrapPathSegments is the number of segments of a path looked at
+	// to find repeated blocks, a trap repeats itself well before that
+	maxTrapPathSegments = 64
+	// maxTrapPeriod is the maximum number of segments of a repeated block
+	maxTrapPeriod = 8
+)
+
+// similarSegments return true if two blocks of path segments of the same
+// length are the same, or for blocks of 3 segments or more, if more than
+// half of their segments are, like /calendar/2021/05 and /calendar/2021/06
+func similarSegments(a, b []string) bool {
+	var equal int
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+
+	return equal*2 > len(a)
+}
+
+// isTrapPath return true if a block of segments of the path is repeated
+// right after itself more than maxRepetitions times, like the /a/b/a/b/a/b/
+// paths that calendars and faceted navigations generate endlessly. Only the
+// consecutive repetitions count, so deep paths that reuse a segment here
+// and there, like /en/docs/en/api/en/, aren't traps. Only the blocks of
+// up to maxTrapPeriod segments in the first maxTrapPathSegments segments
+// are looked at, to bound the cost of the check. A maxRepetitions of 0 or
+// less disables the check.
+func isTrapPath(path string, maxRepetitions int) bool {
+	if maxRepetitions <= 0 {
+		return false
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+		if len(segments) == maxTrapPathSegments {
+			break
+		}
+	}
+
+	for period := 1; period <= maxTrapPeriod && period*(maxRepetitions+1) <= len(segments); period++ {
+		for start := 0; start+period*(maxRepetitions+1) <= len(segments); start++ {
+			var repetitions = 1
+			for next := start + period; next+period <= len(segments); next += period {
+				if !similarSegments(segments[next-period:next], segments[next:next+period]) {
+					break
+				}
+
+				repetitions++
+				if repetitions > maxRepetitions {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// isItemTreeTooDeep return true if the children of the item would be
+// deeper than --max-item-depth in the tree of their seed. Every item keeps
+// all its ancestors, so the chains of outlinks, redirections and assets
+// of pathological seeds would use more and more memory. A maximum depth
+// of 0 or less disables the check.
+func (c *Crawl) isItemTreeTooDeep(item *frontier.Item) bool {
+	if c.MaxItemDepth <= 0 || item.Depth < c.MaxItemDepth {
+		return false
+	}
+
+	logWarning.WithFields(logrus.Fields{
+		"url":   item.URL.String(),
+		"depth": item.Depth,
+	}).Warning("Maximum item tree depth reached, not adding the children of the item")
+
+	return true
+}
+
+// isCrawlerTrap return true if the URL looks like it comes from a crawler
+// trap, because it is longer than --max-url-length or because its path
+// repeats a block of segments more than --max-path-segment-repetitions
+// times in a row
+func (c *Crawl) isCrawlerTrap(URL *url.URL) bool {
+	var reason string
+
+	if c.MaxURLLength > 0 && len(URL.String()) > c.MaxURLLength {
+		reason = "URL too long"
+	} else if isTrapPath(URL.EscapedPath(), c.MaxPathRepetitions) {
+		reason = "repeating path segments"
+	} else {
+		return false
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"url":    URL.String(),
+		"reason": reason,
+	}).Debug("URL looks like a crawler trap, dropping it")
+
+	return true
+}
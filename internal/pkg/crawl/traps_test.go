@@ -0,0 +1,96 @@
+package crawl
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCrawlerTrap(t *testing.T) {
+	logInfo = logrus.New()
+
+	c := new(Crawl)
+	c.MaxURLLength = 2048
+	c.MaxPathRepetitions = 2
+
+	isTrap := func(rawURL string) bool {
+		URL, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.isCrawlerTrap(URL)
+	}
+
+	// Trap-shaped URLs
+	assert.True(t, isTrap("https://example.com/a/b/a/b/a/b/"))
+	assert.True(t, isTrap("https://example.com/calendar/2021/05/calendar/2021/06/calendar/2021/07"))
+	assert.True(t, isTrap("https://example.com/shop/color/red/color/red/color/red"))
+	assert.True(t, isTrap("https://example.com/next/next/next/page"))
+	assert.True(t, isTrap("https://example.com/search?q="+strings.Repeat("a", 2048)))
+
+	// Normal deep URLs
+	assert.False(t, isTrap("https://example.com/"))
+	assert.False(t, isTrap("https://example.com/2021/05/05/a-post-about-05/"))
+	assert.False(t, isTrap("https://example.com/docs/v1/api/v1/reference/index.html"))
+	assert.False(t, isTrap("https://example.com/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p"))
+	assert.False(t, isTrap("https://example.com//double//slashes//page"))
+	assert.False(t, isTrap("https://example.com/search?q=a&q=a&q=a&q=a"))
+	assert.False(t, isTrap("https://example.com/en/docs/en/api/en/"))
+	assert.False(t, isTrap("https://example.com/en/docs/en/api/en/reference/en/"))
+	assert.False(t, isTrap("https://example.com/a/index/b/index/c/index/d/index"))
+	assert.False(t, isTrap("https://example.com/shop/color/red/color/blue/color/green"))
+	assert.False(t, isTrap("https://example.com/blog/blog/post"))
+	assert.False(t, isTrap("https://example.com/wiki/Main/Page/wiki/Talk/Page"))
+
+	// Only the first segments are looked at, with blocks of bounded length
+	assert.True(t, isTrap("https://example.com/"+strings.Repeat("a/", 100)))
+	var distinct string
+	for i := 0; i < maxTrapPathSegments; i++ {
+		distinct += strconv.Itoa(i) + "/"
+	}
+	assert.False(t, isTrap("https://example.com/"+distinct+strings.Repeat("a/b/", 3)))
+	assert.False(t, isTrap("https://example.com/"+strings.Repeat("1/2/3/4/5/6/7/8/9/", 3)))
+
+	// The checks can be disabled
+	c.MaxURLLength = 0
+	c.MaxPathRepetitions = 0
+	assert.False(t, isTrap("https://example.com/a/b/a/b/a/b/"))
+	assert.False(t, isTrap("https://example.com/search?q="+strings.Repeat("a", 2048)))
+}
+
+func TestIsItemTreeTooDeep(t *testing.T) {
+	c := newTestCrawl()
+	c.MaxItemDepth = 3
+	c.MaxHops = 10
+	c.Frontier.PushChan = make(chan *frontier.Item, 10)
+
+	// A chain of redirections and assets, each item is one level deeper
+	URL, _ := url.Parse("https://example.com/")
+	item := frontier.NewItem(URL, nil, "seed", 0)
+	for depth := 0; depth < 3; depth++ {
+		assert.Equal(t, depth, item.Depth)
+		assert.False(t, c.isItemTreeTooDeep(item))
+		item = frontier.NewItem(URL, item, "asset", 0)
+	}
+	assert.Equal(t, 3, item.Depth)
+	assert.True(t, c.isItemTreeTooDeep(item))
+
+	// The outlinks of the deepest item are dropped
+	outlink, _ := url.Parse("https://example.com/outlink")
+	c.queueOutlinks([]url.URL{*outlink}, item)
+	assert.Len(t, c.Frontier.PushChan, 0)
+
+	c.queueOutlinks([]url.URL{*outlink}, item.ParentItem)
+	if assert.Len(t, c.Frontier.PushChan, 1) {
+		assert.Equal(t, 3, (<-c.Frontier.PushChan).Depth)
+	}
+
+	// The check can be disabled
+	c.MaxItemDepth = 0
+	assert.False(t, c.isItemTreeTooDeep(item))
+}
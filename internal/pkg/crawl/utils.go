@@ -18,9 +18,15 @@ import (
 var regexOutlinks *regexp.Regexp
 
 func (crawl *Crawl) writeFrontierToDisk() {
+	var interval = crawl.SyncInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
 	for !crawl.Finished.Get() {
 		crawl.Frontier.Save()
-		time.Sleep(time.Minute * 1)
+		crawl.Frontier.Sync()
+		time.Sleep(interval)
 	}
 }
 
@@ -44,6 +50,51 @@ func extractLinksFromText(source string) (links []url.URL) {
 	return links
 }
 
+// extractScriptURLs return the absolute URLs found in the source of a
+// script, and its protocol-relative URLs, that the regex matches without
+// their leading slashes, they have to be resolved against the page's URL
+func extractScriptURLs(source string) (rawURLs []string) {
+	for _, match := range regexOutlinks.FindAllStringIndex(source, -1) {
+		link := source[match[0]:match[1]]
+
+		if strings.HasPrefix(link, "http") {
+			rawURLs = append(rawURLs, link)
+		} else if match[0] >= 2 && source[match[0]-2:match[0]] == "//" && utils.IsProtocolRelativeURL("//"+link) {
+			rawURLs = append(rawURLs, "//"+link)
+		}
+	}
+
+	return utils.DedupeStrings(rawURLs)
+}
+
+// filterSchemes drop the URLs that have a scheme that isn't in the
+// allowed schemes, like mailto:, tel: or javascript: URLs
+func (crawl *Crawl) filterSchemes(URLs []url.URL) (filtered []url.URL) {
+	for _, URL := range URLs {
+		URL := URL
+		if !utils.IsSchemeAllowed(&URL, crawl.AllowedSchemes) {
+			logInfo.WithFields(logrus.Fields{
+				"url": URL.String(),
+			}).Debug("URL scheme not allowed, dropping URL")
+			continue
+		}
+		filtered = append(filtered, URL)
+	}
+
+	return filtered
+}
+
+// removeURL return the URLs without the occurrences of a given URL
+func removeURL(URLs []url.URL, removed url.URL) (filtered []url.URL) {
+	for _, URL := range URLs {
+		if URL.String() != removed.String() {
+			filtered = append(filtered, URL)
+		}
+	}
+
+	return filtered
+}
+
 func needBrowser(item *frontier.Item) bool {
 	res, err := http.Head(item.URL.String())
 	if err != nil {
@@ -61,18 +112,36 @@ func needBrowser(item *frontier.Item) bool {
 
 func (crawl *Crawl) handleCrawlPause() {
 	for {
-		if float64(utils.GetFreeDiskSpace(crawl.JobPath).Avail)/float64(GB) <= 20 {
-			crawl.Paused.Set(true)
-			crawl.Frontier.Paused.Set(true)
-		} else {
-			crawl.Paused.Set(false)
-			crawl.Frontier.Paused.Set(false)
-		}
-
+		crawl.checkFreeDiskSpace(utils.GetFreeDiskSpace(crawl.JobPath).Avail)
 		time.Sleep(time.Second)
 	}
 }
 
+// checkFreeDiskSpace pause the crawl if the available disk space is under
+// the minimum required, so no WARC get corrupted by a full disk, and resume
+// the crawl once enough space is available again
+func (crawl *Crawl) checkFreeDiskSpace(available uint64) {
+	if float64(available)/float64(GB) <= crawl.MinSpaceRequired {
+		if !crawl.Paused.Get() {
+			logWarning.WithFields(logrus.Fields{
+				"available_gb": float64(available) / float64(GB),
+				"required_gb":  crawl.MinSpaceRequired,
+			}).Warning("NOT ENOUGH DISK SPACE, PAUSING THE CRAWL UNTIL SPACE IS FREED")
+		}
+		crawl.Paused.Set(true)
+		crawl.Frontier.Paused.Set(true)
+	} else {
+		if crawl.Paused.Get() {
+			logWarning.WithFields(logrus.Fields{
+				"available_gb": float64(available) / float64(GB),
+				"required_gb":  crawl.MinSpaceRequired,
+			}).Warning("Enough disk space available again, resuming the crawl")
+		}
+		crawl.Paused.Set(false)
+		crawl.Frontier.Paused.Set(false)
+	}
+}
+
 func (crawl *Crawl) tempFilesCleaner() {
 	for {
 		files, err := ioutil.ReadDir(path.Join(crawl.JobPath, "temp"))
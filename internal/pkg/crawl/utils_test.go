@@ -0,0 +1,30 @@
+package crawl
+
+import (
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFreeDiskSpace(t *testing.T) {
+	logWarning = logrus.New()
+
+	c := new(Crawl)
+	c.Paused = new(utils.TAtomBool)
+	c.Frontier = new(frontier.Frontier)
+	c.Frontier.Paused = new(utils.TAtomBool)
+	c.MinSpaceRequired = 20
+
+	// Simulate a disk that is getting full
+	c.checkFreeDiskSpace(10 * GB)
+	assert.True(t, c.Paused.Get())
+	assert.True(t, c.Frontier.Paused.Get())
+
+	// Simulate some WARCs being deleted from the disk
+	c.checkFreeDiskSpace(30 * GB)
+	assert.False(t, c.Paused.Get())
+	assert.False(t, c.Frontier.Paused.Get())
+}
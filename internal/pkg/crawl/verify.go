@@ -0,0 +1,271 @@
+package crawl
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/sirupsen/logrus"
+)
+
+// The results of the verification of an archived URL
+const (
+	verifyUnchanged     = "unchanged"
+	verifyChanged       = "changed"
+	verifyStatusChanged = "status-changed"
+	verifyFailed        = "failed"
+)
+
+// archivedCapture is the capture of a URL found in a WARC or CDXJ file
+type archivedCapture struct {
+	URL       string
+	Timestamp string
+	Status    string
+	Digest    string
+}
+
+// verifyResult is a line of the report of --verify, comparing the
+// archived capture of a URL with its live version
+type verifyResult struct {
+	URL            string `json:"url"`
+	Result         string `json:"result"`
+	ArchivedAt     string `json:"archived_at"`
+	ArchivedStatus string `json:"archived_status"`
+	ArchivedDigest string `json:"archived_digest"`
+	LiveStatus     string `json:"live_status,omitempty"`
+	LiveDigest     string `json:"live_digest,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// loadArchivedCaptures return the most recent capture of each URL archived
+// with a response record in the given WARC files or CDXJ indexes, the
+// truncated records are ignored as their digest can't match the live payload
+func loadArchivedCaptures(inputs []string) (captures map[string]archivedCapture, err error) {
+	captures = make(map[string]archivedCapture)
+
+	add := func(capture archivedCapture) {
+		if capture.Status == "" || !strings.HasPrefix(capture.URL, "http") {
+			return
+		}
+
+		if previous, ok := captures[capture.URL]; !ok || capture.Timestamp > previous.Timestamp {
+			captures[capture.URL] = capture
+		}
+	}
+
+	for _, input := range inputs {
+		extension := filepath.Ext(input)
+		if extension == ".cdx" || extension == ".cdxj" {
+			err = readCDXJCaptures(input, add)
+		} else {
+			err = walkWARC(input, func(fields *recordCDXFields) error {
+				if fields.truncated {
+					return nil
+				}
+
+				captureTime, err := time.Parse(time.RFC3339, fields.date)
+				if err != nil {
+					return err
+				}
+
+				add(archivedCapture{
+					URL:       fields.URL,
+					Timestamp: captureTime.UTC().Format("20060102150405"),
+					Status:    fields.Status,
+					Digest:    fields.Digest,
+				})
+
+				return nil
+			})
+		}
+
+		if err != nil {
+			return nil, errors.New(input + ": " + err.Error())
+		}
+	}
+
+	return captures, nil
+}
+
+// readCDXJCaptures call add with the capture of each line of a CDXJ index
+func readCDXJCaptures(path string, add func(archivedCapture)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*KB), 1*MB)
+	for scanner.Scan() {
+		// SURT, timestamp and JSON fields
+		parts := strings.SplitN(scanner.Text(), " ", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		var fields cdxjFields
+		if json.Unmarshal([]byte(parts[2]), &fields) != nil {
+			continue
+		}
+
+		add(archivedCapture{
+			URL:       fields.URL,
+			Timestamp: parts[1],
+			Status:    fields.Status,
+			Digest:    fields.Digest,
+		})
+	}
+
+	return scanner.Err()
+}
+
+// verifyCapture fetch the live version of an archived URL, without writing
+// it, and compare its status and the digest of its payload with the archive
+func (c *Crawl) verifyCapture(capture archivedCapture) (result verifyResult) {
+	result = verifyResult{
+		URL:            capture.URL,
+		ArchivedAt:     capture.Timestamp,
+		ArchivedStatus: capture.Status,
+		ArchivedDigest: capture.Digest,
+	}
+
+	req, err := http.NewRequest("GET", utils.CleanURL(capture.URL), nil)
+	if err != nil {
+		result.Result = verifyFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	client, err := c.httpClient(req.URL)
+	if err != nil {
+		result.Result = verifyFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Result = verifyFailed
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	hash := sha1.New()
+	_, err = io.Copy(hash, resp.Body)
+	if err != nil {
+		result.Result = verifyFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.LiveStatus = strconv.Itoa(resp.StatusCode)
+	result.LiveDigest = "sha1:" + base32.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	switch {
+	case result.LiveStatus != result.ArchivedStatus:
+		result.Result = verifyStatusChanged
+	case result.LiveDigest != result.ArchivedDigest:
+		result.Result = verifyChanged
+	default:
+		result.Result = verifyUnchanged
+	}
+
+	return result
+}
+
+// Verify fetch again the URLs archived in WARC files or CDXJ indexes and
+// write in reportPath, as one JSON object per line, which ones changed
+// since they were archived, by status code or by payload digest. Nothing
+// is written in WARC files.
+func (c *Crawl) Verify(inputs []string, reportPath string) error {
+	c.StartTime = time.Now()
+	c.Paused = new(utils.TAtomBool)
+	c.Finished = new(utils.TAtomBool)
+	c.ErrorStats = newErrorStats()
+	c.WARC = false
+
+	logInfo, logWarning = c.SetupLogging()
+
+	err := c.initHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	captures, err := loadArchivedCaptures(inputs)
+	if err != nil {
+		return err
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"urls": len(captures),
+	}).Info("Verifying the archived URLs against their live version")
+
+	report, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	// The results are written in the report as soon as they are known,
+	// in the order they come in, instead of being kept in memory
+	var results = make(chan verifyResult, 100)
+	var writeErr = make(chan error, 1)
+	var counts = make(map[string]int)
+	go func() {
+		var err error
+		encoder := json.NewEncoder(report)
+		for result := range results {
+			counts[result.Result]++
+
+			if err == nil {
+				err = encoder.Encode(result)
+			}
+		}
+		writeErr <- err
+	}()
+
+	var workers = c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var pool = sizedwaitgroup.New(workers)
+	for _, capture := range captures {
+		pool.Add()
+		go func(capture archivedCapture) {
+			defer pool.Done()
+
+			results <- c.verifyCapture(capture)
+		}(capture)
+	}
+	pool.Wait()
+	close(results)
+
+	err = <-writeErr
+	if err != nil {
+		return err
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"report":         reportPath,
+		"unchanged":      counts[verifyUnchanged],
+		"changed":        counts[verifyChanged],
+		"status_changed": counts[verifyStatusChanged],
+		"failed":         counts[verifyFailed],
+	}).Info("Verification finished")
+
+	return report.Sync()
+}
@@ -0,0 +1,158 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	var pagesMutex sync.Mutex
+	var pages = map[string]string{
+		"/same":    "same content",
+		"/changed": "old content",
+		"/gone":    "soon gone",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pagesMutex.Lock()
+		defer pagesMutex.Unlock()
+
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	// Archive the pages
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.WARC = true
+	c.initWARCWriter()
+
+	for path := range pages {
+		URL, _ := url.Parse(server.URL + path)
+		req, _ := http.NewRequest("GET", URL.String(), nil)
+		resp, respPath, err := c.executeGET(frontier.NewItem(URL, nil, "seed", 0), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		markTempFileDone(respPath)
+	}
+	close(c.WARCWriter)
+	<-c.WARCWriterFinish
+
+	warcs, _ := filepath.Glob(filepath.Join(jobPath, "warcs", "*.warc.gz"))
+	if !assert.Len(t, warcs, 1) {
+		return
+	}
+
+	// The CDXJ index of the WARC gives the same captures as the WARC itself
+	lines, err := indexWARC(warcs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPath := filepath.Join(jobPath, "index.cdxj")
+	ioutil.WriteFile(indexPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	fromWARC, err := loadArchivedCaptures(warcs)
+	assert.NoError(t, err)
+	fromIndex, err := loadArchivedCaptures([]string{indexPath})
+	assert.NoError(t, err)
+	assert.Len(t, fromWARC, 3)
+	assert.Equal(t, fromWARC, fromIndex)
+
+	// The pages change
+	pagesMutex.Lock()
+	pages["/changed"] = "new content"
+	delete(pages, "/gone")
+	pagesMutex.Unlock()
+
+	verifier := newTestCrawl()
+	verifier.JobPath = jobPath
+	verifier.Workers = 2
+	reportPath := filepath.Join(jobPath, "report.jsonl")
+
+	err = verifier.Verify(warcs, reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer report.Close()
+
+	var results = make(map[string]verifyResult)
+	scanner := bufio.NewScanner(report)
+	for scanner.Scan() {
+		var result verifyResult
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results[strings.TrimPrefix(result.URL, server.URL)] = result
+	}
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, verifyUnchanged, results["/same"].Result)
+	assert.Equal(t, verifyChanged, results["/changed"].Result)
+	assert.NotEqual(t, results["/changed"].ArchivedDigest, results["/changed"].LiveDigest)
+	assert.Equal(t, verifyStatusChanged, results["/gone"].Result)
+	assert.Equal(t, "200", results["/gone"].ArchivedStatus)
+	assert.Equal(t, "404", results["/gone"].LiveStatus)
+
+	// Nothing was written in the WARCs
+	warcsAfter, _ := filepath.Glob(filepath.Join(jobPath, "warcs", "*"))
+	assert.Len(t, warcsAfter, 1)
+}
+
+func TestVerifyCaptureThroughProxy(t *testing.T) {
+	var proxied = make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+		w.Write([]byte("hidden content"))
+	}))
+	defer proxy.Close()
+
+	capture := archivedCapture{
+		URL:       "http://example.onion/page",
+		Timestamp: "20200101000000",
+		Status:    "200",
+	}
+
+	// Onion services are never requested outside of the proxy
+	c := newTestCrawl()
+	c.initHTTPClient()
+
+	result := c.verifyCapture(capture)
+	assert.Equal(t, verifyFailed, result.Result)
+	assert.Equal(t, errOnionWithoutProxy.Error(), result.Error)
+
+	c = newTestCrawl()
+	c.Proxy = proxy.URL
+	c.initHTTPClient()
+
+	result = c.verifyCapture(capture)
+	assert.Equal(t, "200", result.LiveStatus)
+	assert.Equal(t, "http://example.onion/page", <-proxied)
+}
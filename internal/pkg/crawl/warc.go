@@ -1,12 +1,20 @@
 package crawl
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/CorentinB/warc"
@@ -38,27 +46,269 @@ func (c *Crawl) dumpResponseToFile(resp *http.Response) (string, error) {
 	return filePath, nil
 }
 
-func (c *Crawl) initWARCWriter() {
-	var rotatorSettings = warc.NewRotatorSettings()
-	var err error
+// bufferBodyForTrailers read the whole body of a chunked response into a
+// temporary file, so the trailers sent after the body are known before the
+// response is dumped, the body is then replaced by the temporary file. The
+// body is also written to the given hashes while it is read.
+func (c *Crawl) bufferBodyForTrailers(resp *http.Response, hashes ...hash.Hash) error {
+	UUID := uuid.NewV4()
+	filePath := filepath.Join(c.JobPath, "temp", UUID.String()+".body.temp")
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
 
-	os.MkdirAll(path.Join(c.JobPath, "temp"), os.ModePerm)
-	go c.tempFilesCleaner()
+	var writers = []io.Writer{file}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+
+	_, err = io.Copy(io.MultiWriter(writers...), resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return err
+	}
+
+	resp.Body = &tempFileBody{File: file}
+
+	return nil
+}
+
+// tempFileBody is a response body backed by a temporary file
+// that is marked as done when the body is closed
+type tempFileBody struct {
+	*os.File
+}
+
+func (b *tempFileBody) Close() error {
+	err := b.File.Close()
+	markTempFileDone(b.Name())
+	return err
+}
+
+// isMIMETypeCaptured return true if the body of a response
+// with the given Content-Type should be written in the WARC
+func (c *Crawl) isMIMETypeCaptured(contentType string) bool {
+	if len(c.OnlyMIMETypes) > 0 && !utils.MatchMIMEType(contentType, c.OnlyMIMETypes) {
+		return false
+	}
+
+	return !utils.MatchMIMEType(contentType, c.SkipMIMETypes)
+}
+
+// truncateBody drop the body of a response, only its
+// status line and headers are then written in the WARC
+func truncateBody(resp *http.Response) {
+	resp.Body.Close()
+	resp.Body = http.NoBody
+	resp.ContentLength = 0
+	resp.TransferEncoding = nil
+	resp.Trailer = nil
+}
+
+// truncateBodyToHead keep only the first n bytes of the body of a response,
+// the rest of the body isn't downloaded and the connection is closed,
+// it returns true if the body was longer than n bytes
+func truncateBodyToHead(resp *http.Response, n int64) (truncated bool, err error) {
+	head, err := ioutil.ReadAll(io.LimitReader(resp.Body, n+1))
+	resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+
+	if int64(len(head)) > n {
+		head = head[:n]
+		truncated = true
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(head))
+	resp.ContentLength = int64(len(head))
+	resp.TransferEncoding = nil
+	resp.Trailer = nil
+
+	return truncated, nil
+}
+
+func isChunked(resp *http.Response) bool {
+	return len(resp.TransferEncoding) > 0 && resp.TransferEncoding[0] == "chunked"
+}
+
+// newWARCRotatorSettings return the settings of a
+// WARC writer whose files are named with prefix
+func (c *Crawl) newWARCRotatorSettings(prefix string) *warc.RotatorSettings {
+	var rotatorSettings = warc.NewRotatorSettings()
 
 	rotatorSettings.OutputDirectory = path.Join(c.JobPath, "warcs")
 	rotatorSettings.Compression = "GZIP"
-	rotatorSettings.Prefix = c.WARCPrefix
-	rotatorSettings.WarcinfoContent.Set("software", "Zeno")
+	rotatorSettings.Prefix = prefix
+	rotatorSettings.WarcSize = c.WARCSize
+	rotatorSettings.WarcinfoContent.Set("software", "Zeno/"+c.Version)
+	rotatorSettings.WarcinfoContent.Set("http-header-user-agent", c.UserAgent)
+	if hostname, err := os.Hostname(); err == nil {
+		rotatorSettings.WarcinfoContent.Set("hostname", hostname)
+	}
 	if len(c.WARCOperator) > 0 {
 		rotatorSettings.WarcinfoContent.Set("operator", c.WARCOperator)
 	}
+	if len(c.WARCDescription) > 0 {
+		rotatorSettings.WarcinfoContent.Set("description", c.WARCDescription)
+	}
+	if len(c.WARCRobotsPolicy) > 0 {
+		rotatorSettings.WarcinfoContent.Set("robots", c.WARCRobotsPolicy)
+	}
+	if len(c.WARCCollection) > 0 {
+		rotatorSettings.WarcinfoContent.Set("isPartOf", c.WARCCollection)
+	}
+
+	return rotatorSettings
+}
+
+func (c *Crawl) initWARCWriter() {
+	var err error
+
+	os.MkdirAll(path.Join(c.JobPath, "temp"), os.ModePerm)
+	go c.tempFilesCleaner()
 
-	c.WARCWriter, c.WARCWriterFinish, err = rotatorSettings.NewWARCRotator()
+	// The batches are written by a pool of WARC writers, if asked the
+	// channel in front of the pool is buffered, closing it closes the
+	// WARC files once all the batches are written
+	if c.StageBufferSize > 0 {
+		c.WARCWriter = make(chan *warc.RecordBatch, c.StageBufferSize)
+	} else {
+		c.WARCWriter = make(chan *warc.RecordBatch)
+	}
+
+	var size = c.WARCPoolSize
+	if size <= 0 {
+		size = 1
+	}
+
+	c.WARCPool, err = c.newWARCPool(c.WARCWriter, size, c.WARCPoolPolicy)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"error": err,
 		}).Fatal("Error when initialize WARC writer")
 	}
+	c.WARCWriterFinish = c.WARCPool.finish
+}
+
+// newTimingRecord create a metadata record containing the timing
+// breakdown of a request, concurrent to the given response record
+func newTimingRecord(responseRecord *warc.Record, timing *requestTiming) *warc.Record {
+	var content strings.Builder
+
+	if responseRecord.Header.Get("WARC-Record-ID") == "" {
+		responseRecord.Header.Set("WARC-Record-ID", "<urn:uuid:"+uuid.NewV4().String()+">")
+	}
+
+	writeDuration := func(name string, start, end time.Time) {
+		if start.IsZero() || end.IsZero() {
+			return
+		}
+		fmt.Fprintf(&content, "%s: %d\r\n", name, end.Sub(start).Milliseconds())
+	}
+
+	writeDuration("fetchTimeMs", timing.Start, time.Now())
+	writeDuration("dnsTimeMs", timing.DNSStart, timing.DNSDone)
+	writeDuration("connectTimeMs", timing.ConnectStart, timing.ConnectDone)
+	writeDuration("tlsHandshakeTimeMs", timing.TLSStart, timing.TLSDone)
+	writeDuration("timeToFirstByteMs", timing.Start, timing.FirstByte)
+	writeDuration("serverTimeMs", timing.WroteRequest, timing.FirstByte)
+
+	var metadataRecord = warc.NewRecord()
+	metadataRecord.Header.Set("WARC-Type", "metadata")
+	metadataRecord.Header.Set("WARC-Target-URI", responseRecord.Header.Get("WARC-Target-URI"))
+	metadataRecord.Header.Set("WARC-Concurrent-To", responseRecord.Header.Get("WARC-Record-ID"))
+	metadataRecord.Header.Set("Content-Type", "application/warc-fields")
+	metadataRecord.Content = strings.NewReader(content.String())
+
+	return metadataRecord
+}
+
+// DefaultMaxInMemoryBodySize is the size above which the bodies
+// are written to a temporary file on disk instead of being buffered
+const DefaultMaxInMemoryBodySize = 4 * MB
+
+// inMemoryBodySize return the maximum size of the bodies buffered
+// in memory, with a default of DefaultMaxInMemoryBodySize
+func (c *Crawl) inMemoryBodySize() int64 {
+	if c.MaxInMemoryBodySize <= 0 {
+		return DefaultMaxInMemoryBodySize
+	}
+	return c.MaxInMemoryBodySize
+}
+
+// responseRecordKey is the context key of the
+// ID of the response record written for a request
+type responseRecordKey struct{}
+
+// getResponseRecordID return the ID of the response record
+// written for a response, or an empty string if none was written
+func getResponseRecordID(resp *http.Response) string {
+	recordID, _ := resp.Request.Context().Value(responseRecordKey{}).(string)
+	return recordID
+}
+
+// writeOutlinksRecord write a metadata record listing the outlinks and
+// the assets extracted from a response, concurrent to its response record
+func (c *Crawl) writeOutlinksRecord(resp *http.Response, outlinks, assets []url.URL) {
+	var content strings.Builder
+
+	for _, outlink := range outlinks {
+		fmt.Fprintf(&content, "outlink: %s\r\n", utils.CleanURL(outlink.String()))
+	}
+
+	for _, asset := range assets {
+		fmt.Fprintf(&content, "asset: %s\r\n", utils.CleanURL(asset.String()))
+	}
+
+	var batch = warc.NewRecordBatch()
+
+	var metadataRecord = warc.NewRecord()
+	metadataRecord.Header.Set("WARC-Type", "metadata")
+	metadataRecord.Header.Set("WARC-Target-URI", utils.CleanURL(resp.Request.URL.String()))
+	if recordID := getResponseRecordID(resp); recordID != "" {
+		metadataRecord.Header.Set("WARC-Concurrent-To", recordID)
+	}
+	metadataRecord.Header.Set("Content-Type", "application/warc-fields")
+	metadataRecord.Content = strings.NewReader(content.String())
+
+	batch.Records = append(batch.Records, metadataRecord)
+	c.setCollection(batch)
+	c.WARCWriter <- batch
+}
+
+// setCollection tag all the records of a batch with the
+// collection they belong to, using the WARC-Collection header
+func (c *Crawl) setCollection(batch *warc.RecordBatch) {
+	if len(c.WARCCollection) == 0 {
+		return
+	}
+
+	for _, record := range batch.Records {
+		record.Header.Set("WARC-Collection", c.WARCCollection)
+	}
+}
+
+// writeMetadataRecord write a metadata record about
+// the target URI with the given application/warc-fields content
+func (c *Crawl) writeMetadataRecord(targetURI string, content string) {
+	var batch = warc.NewRecordBatch()
+
+	var metadataRecord = warc.NewRecord()
+	metadataRecord.Header.Set("WARC-Type", "metadata")
+	metadataRecord.Header.Set("WARC-Target-URI", utils.CleanURL(targetURI))
+	metadataRecord.Header.Set("Content-Type", "application/warc-fields")
+	metadataRecord.Content = strings.NewReader(content)
+
+	batch.Records = append(batch.Records, metadataRecord)
+	c.setCollection(batch)
+	c.WARCWriter <- batch
 }
 
 func (c *Crawl) writeWARC(resp *http.Response) (string, error) {
@@ -72,18 +322,69 @@ func (c *Crawl) writeWARC(resp *http.Response) (string, error) {
 	var responseRecord = warc.NewRecord()
 	responseRecord.Header.Set("WARC-Type", "response")
 	responseRecord.Header.Set("WARC-Target-URI", utils.CleanURL(resp.Request.URL.String()))
+	responseRecord.Header.Set("WARC-Record-ID", "<urn:uuid:"+uuid.NewV4().String()+">")
 	responseRecord.Header.Set("Content-Type", "application/http; msgtype=response")
 
-	// If the Content-Length is unknown or if it is higher than 2MB, then
-	// we process the response directly on disk to not risk maxing-out the RAM.
-	// Else, we use the httputil.DumpResponse function to dump the response.
-	if resp.ContentLength == -1 || resp.ContentLength > 4194304 {
+	// Responses without a body are written with their status
+	// line and headers only, even if the server sent a body
+	if hasNoBody(resp.StatusCode) {
+		truncateBody(resp)
+	}
+
+	// If the MIME type of the response is filtered out, we don't download
+	// its body and only write a truncated record with the headers
+	if !c.isMIMETypeCaptured(resp.Header.Get("Content-Type")) {
+		truncateBody(resp)
+		responseRecord.Header.Set("WARC-Truncated", "unspecified")
+	}
+
+	// If asked, we only keep the first bytes of the body
+	if c.CaptureHeadBytes > 0 {
+		truncated, err := truncateBodyToHead(resp, c.CaptureHeadBytes)
+		if err != nil {
+			return responsePath, err
+		}
+
+		if truncated {
+			responseRecord.Header.Set("WARC-Truncated", "length")
+		}
+	}
+
+	// If asked, we read the whole body of chunked responses before dumping
+	// them, so the trailers that may follow the body end up in the record,
+	// and the body can be verified against the digests of the trailers
+	var trailerDigests string
+	if (c.WARCCaptureTrailers || c.WARCTrailerDigests) && isChunked(resp) {
+		var hashes bodyHashes
+		if c.WARCTrailerDigests {
+			hashes = newBodyHashes(resp.Trailer)
+		}
+
+		err = c.bufferBodyForTrailers(resp, hashes.list()...)
+		if err != nil {
+			return responsePath, err
+		}
+
+		if c.WARCTrailerDigests {
+			trailerDigests = c.checkTrailerDigests(resp, hashes)
+		}
+	}
+
+	// If the Content-Length is unknown or if it is higher than
+	// --max-in-memory-body-size, then we process the response directly on
+	// disk to not risk maxing-out the RAM, the extraction then reads it back
+	// from there. Else, we use the httputil.DumpResponse function to dump it.
+	if resp.ContentLength == -1 || resp.ContentLength > c.inMemoryBodySize() {
 		responsePath, err = c.dumpResponseToFile(resp)
 		if err != nil {
 			return responsePath, err
 		}
 
 		responseRecord.PayloadPath = responsePath
+
+		if fileInfo, err := os.Stat(responsePath); err == nil {
+			c.Frontier.HostStats.AddBytes(resp.Request.URL.Host, fileInfo.Size())
+		}
 	} else {
 		responseDump, err = httputil.DumpResponse(resp, true)
 		if err != nil {
@@ -91,10 +392,22 @@ func (c *Crawl) writeWARC(resp *http.Response) (string, error) {
 		}
 
 		responseRecord.Content = strings.NewReader(string(responseDump))
+
+		c.Frontier.HostStats.AddBytes(resp.Request.URL.Host, int64(len(responseDump)))
 	}
 
-	// Dump request
-	requestDump, err = httputil.DumpRequestOut(resp.Request, true)
+	// If the request had a body, it was consumed when the request
+	// was sent, so we get a fresh copy of it to be able to dump it
+	if resp.Request.GetBody != nil {
+		resp.Request.Body, err = resp.Request.GetBody()
+		if err != nil {
+			os.Remove(responsePath)
+			return responsePath, err
+		}
+	}
+
+	// Dump request, without the credentials of --auth-file
+	requestDump, err = httputil.DumpRequestOut(c.redactAuthorization(resp.Request), true)
 	if err != nil {
 		os.Remove(responsePath)
 		return responsePath, err
@@ -104,14 +417,56 @@ func (c *Crawl) writeWARC(resp *http.Response) (string, error) {
 	var requestRecord = warc.NewRecord()
 	requestRecord.Header.Set("WARC-Type", "request")
 	requestRecord.Header.Set("WARC-Target-URI", utils.CleanURL(resp.Request.URL.String()))
+	requestRecord.Header.Set("WARC-Record-ID", "<urn:uuid:"+uuid.NewV4().String()+">")
 	requestRecord.Header.Set("Host", resp.Request.URL.Host)
+	requestRecord.Header.Set("WARC-Concurrent-To", responseRecord.Header.Get("WARC-Record-ID"))
 	requestRecord.Header.Set("Content-Type", "application/http; msgtype=request")
 
 	requestRecord.Content = strings.NewReader(string(requestDump))
 
+	// If asked, an identical request that was already written is replaced
+	// by a metadata record referring to the first request record, unless
+	// it was written longer than --warc-dedupe-requests-window ago. The
+	// revisit profiles are about response payloads, so they aren't used.
+	if c.WARCDedupeRequests {
+		original, duplicate := c.RequestDedupe.check(hashRequest(requestDump), writtenRequest{
+			RecordID:  requestRecord.Header.Get("WARC-Record-ID"),
+			TargetURI: requestRecord.Header.Get("WARC-Target-URI"),
+			Time:      time.Now(),
+		}, c.WARCDedupeReqWindow)
+
+		if duplicate {
+			requestRecord.Header.Set("WARC-Type", "metadata")
+			requestRecord.Header.Set("WARC-Refers-To", original.RecordID)
+			requestRecord.Header.Set("WARC-Refers-To-Target-URI", original.TargetURI)
+			requestRecord.Header.Set("Content-Type", "application/warc-fields")
+			requestRecord.Content = strings.NewReader("identicalRequest: " + original.RecordID + "\r\n")
+		}
+	}
+
 	// Append records to the record batch
 	batch.Records = append(batch.Records, responseRecord, requestRecord)
 
+	// If asked, write the timing breakdown of the request in a metadata
+	// record linked to the response record
+	if c.WARCRecordTiming {
+		if timing := getRequestTiming(resp.Request); timing != nil {
+			batch.Records = append(batch.Records, newTimingRecord(responseRecord, timing))
+		}
+	}
+
+	// The result of the verification of the trailer digests
+	// is written in a metadata record linked to the response record
+	if trailerDigests != "" {
+		batch.Records = append(batch.Records, newTrailerDigestsRecord(responseRecord, trailerDigests))
+	}
+
+	c.setCollection(batch)
+
+	// Keep the ID of the response record on the request, so the records
+	// written later about this response can refer to it
+	resp.Request = resp.Request.WithContext(context.WithValue(resp.Request.Context(), responseRecordKey{}, responseRecord.Header.Get("WARC-Record-ID")))
+
 	// If we used a temporary file on disk, we create a "response channel"
 	// that we fit in the batch, so the WARC writer is able to tell us when
 	// the writing is done, so we can delete the temporary file safely
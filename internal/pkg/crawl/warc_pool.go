@@ -0,0 +1,262 @@
+package crawl
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CorentinB/warc"
+	"github.com/sirupsen/logrus"
+)
+
+// The policies applied when all the WARC writers of the pool are busy:
+// block waits for one of them, spill writes in an extra WARC file
+const (
+	warcPoolBlock = "block"
+	warcPoolSpill = "spill"
+)
+
+// warcRotator is a WARC writer of the pool, writing in its own files
+type warcRotator struct {
+	batches chan *warc.RecordBatch
+	finish  chan bool
+}
+
+// warcPoolResize is a request to change the number of WARC writers
+type warcPoolResize struct {
+	size int
+	done chan error
+}
+
+// warcPoolStats is the state of the pool of WARC writers
+type warcPoolStats struct {
+	Writers int64  `json:"writers"`
+	Policy  string `json:"policy"`
+	Waits   int64  `json:"waits"`
+	Spilled int64  `json:"spilled"`
+}
+
+// warcPool is a pool of WARC writers, each writing in its own files, the
+// batches sent to its input are written by a writer that isn't busy. The
+// pool is owned by a single goroutine, that dispatches the batches and
+// resizes the pool between two batches.
+type warcPool struct {
+	writers int64
+	waits   int64
+	spilled int64
+
+	c        *Crawl
+	input    chan *warc.RecordBatch
+	finish   chan bool
+	stopped  chan struct{}
+	resizes  chan warcPoolResize
+	rotators []*warcRotator
+	spill    *warcRotator
+	policy   string
+	nextID   int
+	closing  sync.WaitGroup
+}
+
+// newWARCPool start a pool of size WARC writers reading the batches from
+// input, the pool sends true on its finish channel once input is closed
+// and all the WARC files are closed
+func (c *Crawl) newWARCPool(input chan *warc.RecordBatch, size int, policy string) (*warcPool, error) {
+	if policy == "" {
+		policy = warcPoolBlock
+	}
+	if policy != warcPoolBlock && policy != warcPoolSpill {
+		return nil, errors.New("unknown WARC pool policy: " + policy)
+	}
+
+	pool := &warcPool{
+		c:       c,
+		input:   input,
+		finish:  make(chan bool),
+		stopped: make(chan struct{}),
+		resizes: make(chan warcPoolResize),
+		policy:  policy,
+	}
+
+	err := pool.resize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	go pool.run()
+
+	return pool, nil
+}
+
+// newRotator start a WARC writer, the writers after the first one add
+// their ID to the prefix of their files, so the names never collide
+func (pool *warcPool) newRotator() (*warcRotator, error) {
+	var prefix = pool.c.WARCPrefix
+	if pool.nextID > 0 {
+		prefix += "-" + strconv.Itoa(pool.nextID)
+	}
+	pool.nextID++
+
+	batches, finish, err := pool.c.newWARCRotatorSettings(prefix).NewWARCRotator()
+	if err != nil {
+		return nil, err
+	}
+
+	return &warcRotator{batches: batches, finish: finish}, nil
+}
+
+// closeRotator close the files of a WARC writer once
+// it wrote the batch it may be writing
+func (pool *warcPool) closeRotator(rotator *warcRotator) {
+	pool.closing.Add(1)
+	close(rotator.batches)
+	go func() {
+		<-rotator.finish
+		pool.closing.Done()
+	}()
+}
+
+// resize start or close WARC writers so there are size of them
+func (pool *warcPool) resize(size int) error {
+	if size < 1 {
+		return errors.New("the number of WARC writers must be at least 1")
+	}
+
+	for len(pool.rotators) < size {
+		rotator, err := pool.newRotator()
+		if err != nil {
+			return err
+		}
+		pool.rotators = append(pool.rotators, rotator)
+	}
+
+	for len(pool.rotators) > size {
+		pool.closeRotator(pool.rotators[len(pool.rotators)-1])
+		pool.rotators = pool.rotators[:len(pool.rotators)-1]
+	}
+
+	atomic.StoreInt64(&pool.writers, int64(size))
+
+	return nil
+}
+
+// dispatch send a batch to a WARC writer that isn't busy, if they all
+// are the batch waits for one of them, or with the spill policy it can
+// also be written by an extra writer, started the first time it's needed
+func (pool *warcPool) dispatch(batch *warc.RecordBatch) {
+	var cases []reflect.SelectCase
+	for _, rotator := range pool.rotators {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectSend,
+			Chan: reflect.ValueOf(rotator.batches),
+			Send: reflect.ValueOf(batch),
+		})
+	}
+
+	chosen, _, _ := reflect.Select(append(cases, reflect.SelectCase{Dir: reflect.SelectDefault}))
+	if chosen < len(pool.rotators) {
+		return
+	}
+
+	atomic.AddInt64(&pool.waits, 1)
+
+	if pool.policy == warcPoolSpill {
+		if pool.spill == nil {
+			spill, err := pool.newRotator()
+			if err != nil {
+				logWarning.WithFields(logrus.Fields{
+					"error": err,
+				}).Warning("Unable to start the spill WARC writer")
+			} else {
+				pool.spill = spill
+			}
+		}
+
+		if pool.spill != nil {
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(pool.spill.batches),
+				Send: reflect.ValueOf(batch),
+			})
+		}
+	}
+
+	chosen, _, _ = reflect.Select(cases)
+	if chosen == len(pool.rotators) {
+		atomic.AddInt64(&pool.spilled, 1)
+	}
+}
+
+// run dispatch the batches until the input is closed, then close all the
+// WARC writers and wait for their files to be closed
+func (pool *warcPool) run() {
+	for {
+		select {
+		case request := <-pool.resizes:
+			request.done <- pool.resize(request.size)
+		case batch, more := <-pool.input:
+			if more {
+				pool.dispatch(batch)
+				continue
+			}
+
+			for _, rotator := range pool.rotators {
+				pool.closeRotator(rotator)
+			}
+			if pool.spill != nil {
+				pool.closeRotator(pool.spill)
+			}
+			pool.closing.Wait()
+
+			// The pool can't be resized anymore
+			close(pool.stopped)
+
+			pool.finish <- true
+			return
+		}
+	}
+}
+
+// Snapshot return the state of the pool
+func (pool *warcPool) Snapshot() warcPoolStats {
+	return warcPoolStats{
+		Writers: atomic.LoadInt64(&pool.writers),
+		Policy:  pool.policy,
+		Waits:   atomic.LoadInt64(&pool.waits),
+		Spilled: atomic.LoadInt64(&pool.spilled),
+	}
+}
+
+// ScaleWARCWriters change the number of WARC writers while the crawl is
+// running, the writers removed close their files once they wrote the
+// batch they may be writing
+func (c *Crawl) ScaleWARCWriters(size int) error {
+	if c.WARCPool == nil {
+		return errors.New("WARC writing is disabled")
+	}
+
+	if c.Finished.Get() {
+		return errors.New("the crawl is finishing")
+	}
+
+	var previous = c.WARCPool.Snapshot().Writers
+	var done = make(chan error)
+	select {
+	case c.WARCPool.resizes <- warcPoolResize{size: size, done: done}:
+	case <-c.WARCPool.stopped:
+		return errors.New("the WARC writers are stopped")
+	}
+
+	err := <-done
+	if err != nil {
+		return err
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"from": previous,
+		"to":   size,
+	}).Info("WARC writers pool scaled")
+
+	return nil
+}
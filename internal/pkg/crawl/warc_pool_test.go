@@ -0,0 +1,146 @@
+package crawl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBatch(URL string) *warc.RecordBatch {
+	var record = warc.NewRecord()
+	record.Header.Set("WARC-Type", "resource")
+	record.Header.Set("WARC-Target-URI", URL)
+	record.Content = bytes.NewReader([]byte("content"))
+
+	var batch = warc.NewRecordBatch()
+	batch.Records = append(batch.Records, record)
+
+	return batch
+}
+
+// countWARCRecords return the number of WARC files in the job
+// and the number of records they hold besides the warcinfo
+func countWARCRecords(t *testing.T, jobPath string) (files, records int) {
+	warcs, _ := filepath.Glob(filepath.Join(jobPath, "warcs", "*.warc.gz"))
+	for _, warcPath := range warcs {
+		lines, err := indexWARC(warcPath)
+		assert.NoError(t, err)
+		records += len(lines)
+	}
+
+	return len(warcs), records
+}
+
+func TestWARCPoolScale(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-warc-pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.WARCPrefix = "ZENO"
+	c.WARCPoolSize = 2
+	c.initWARCWriter()
+
+	for i := 0; i < 10; i++ {
+		c.WARCWriter <- newTestBatch("https://example.com/" + strconv.Itoa(i))
+	}
+
+	// Scale up, then down, the removed writers close their files
+	assert.NoError(t, c.ScaleWARCWriters(3))
+	assert.Equal(t, int64(3), c.WARCPool.Snapshot().Writers)
+	for i := 10; i < 20; i++ {
+		c.WARCWriter <- newTestBatch("https://example.com/" + strconv.Itoa(i))
+	}
+
+	assert.NoError(t, c.ScaleWARCWriters(1))
+	assert.Error(t, c.ScaleWARCWriters(0))
+	assert.Equal(t, int64(1), c.WARCPool.Snapshot().Writers)
+	for i := 20; i < 30; i++ {
+		c.WARCWriter <- newTestBatch("https://example.com/" + strconv.Itoa(i))
+	}
+
+	close(c.WARCWriter)
+	<-c.WARCWriterFinish
+
+	// Every writer had its own files, and no record was lost
+	files, records := countWARCRecords(t, jobPath)
+	assert.Equal(t, 3, files)
+	assert.Equal(t, 30, records)
+
+	open, _ := filepath.Glob(filepath.Join(jobPath, "warcs", "*.open"))
+	assert.Empty(t, open)
+}
+
+func TestWARCPoolSpill(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-warc-pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.WARCPoolPolicy = warcPoolSpill
+	c.initWARCWriter()
+
+	// The only writer stays busy until its batch is acknowledged
+	busy := newTestBatch("https://example.com/busy")
+	busy.Done = make(chan bool)
+	c.WARCWriter <- busy
+
+	// So the next batch is written by the spill writer
+	spilled := newTestBatch("https://example.com/spilled")
+	spilled.Done = make(chan bool)
+	c.WARCWriter <- spilled
+	<-spilled.Done
+	<-busy.Done
+
+	// The batches are counted once the dispatcher is done with them
+	close(c.WARCWriter)
+	<-c.WARCWriterFinish
+
+	stats := c.WARCPool.Snapshot()
+	assert.Equal(t, int64(1), stats.Writers)
+	assert.Equal(t, int64(1), stats.Spilled)
+
+	// The first batch also waits if the writer wasn't receiving yet
+	assert.GreaterOrEqual(t, stats.Waits, int64(1))
+	assert.LessOrEqual(t, stats.Waits, int64(2))
+
+	files, records := countWARCRecords(t, jobPath)
+	assert.Equal(t, 2, files)
+	assert.Equal(t, 2, records)
+}
+
+func TestWARCPoolScaleAfterStop(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-warc-pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.initWARCWriter()
+
+	close(c.WARCWriter)
+	<-c.WARCWriterFinish
+
+	// Once the dispatcher stopped, scaling fails instead of blocking
+	assert.Error(t, c.ScaleWARCWriters(2))
+}
+
+func TestWARCPoolInvalidPolicy(t *testing.T) {
+	c := newTestCrawl()
+	_, err := c.newWARCPool(make(chan *warc.RecordBatch), 1, "drop")
+	assert.Error(t, err)
+}
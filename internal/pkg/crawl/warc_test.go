@@ -0,0 +1,378 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/CorentinB/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferBodyForTrailers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Server-Timing")
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		w.Header().Set("Server-Timing", "db;dur=53")
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+	os.MkdirAll(filepath.Join(jobPath, "temp"), os.ModePerm)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+
+	resp, err := c.Client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, isChunked(resp))
+
+	err = c.bufferBodyForTrailers(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, "db;dur=53", resp.Trailer.Get("Server-Timing"))
+
+	var dump bytes.Buffer
+	err = resp.Write(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, dump.String(), "hello")
+	assert.Contains(t, dump.String(), "Server-Timing: db;dur=53")
+}
+
+func TestIsMIMETypeCaptured(t *testing.T) {
+	c := newTestCrawl()
+	assert.True(t, c.isMIMETypeCaptured("video/mp4"))
+
+	c.SkipMIMETypes = []string{"image/gif", "video/*"}
+	assert.False(t, c.isMIMETypeCaptured("video/mp4"))
+	assert.False(t, c.isMIMETypeCaptured("image/gif"))
+	assert.True(t, c.isMIMETypeCaptured("text/html; charset=utf-8"))
+
+	c.OnlyMIMETypes = []string{"text/*", "image/*"}
+	assert.True(t, c.isMIMETypeCaptured("text/html; charset=utf-8"))
+	assert.True(t, c.isMIMETypeCaptured("image/png"))
+	assert.False(t, c.isMIMETypeCaptured("image/gif"))
+	assert.False(t, c.isMIMETypeCaptured("application/pdf"))
+}
+
+func TestTruncateBodyToHead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>this page is longer than the head</body></html>"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+
+	resp, err := c.Client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated, err := truncateBodyToHead(resp, 12)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, int64(12), resp.ContentLength)
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "<html><body>", string(body))
+
+	resp, err = c.Client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated, err = truncateBodyToHead(resp, 1024)
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "<html><body>this page is longer than the head</body></html>", string(body))
+}
+
+func TestWriteWARCDedupeRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asset"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.WARCDedupeRequests = true
+	c.WARCWriter = make(chan *warc.RecordBatch, 2)
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Client.Get(server.URL + "/asset.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.writeWARC(resp)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	firstBatch, secondBatch := <-c.WARCWriter, <-c.WARCWriter
+	first, second := firstBatch.Records[1], secondBatch.Records[1]
+
+	assert.Equal(t, "request", first.Header.Get("WARC-Type"))
+	assert.Equal(t, "metadata", second.Header.Get("WARC-Type"))
+	assert.Equal(t, "", second.Header.Get("WARC-Profile"))
+	assert.Equal(t, secondBatch.Records[0].Header.Get("WARC-Record-ID"), second.Header.Get("WARC-Concurrent-To"))
+	assert.Equal(t, first.Header.Get("WARC-Record-ID"), second.Header.Get("WARC-Refers-To"))
+	assert.Equal(t, server.URL+"/asset.png", second.Header.Get("WARC-Refers-To-Target-URI"))
+}
+
+func TestWriteWARCDedupeRequestsWithRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asset"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.SendRequestID = true
+	c.WARCDedupeRequests = true
+	c.WARCWriter = make(chan *warc.RecordBatch, 2)
+
+	assetURL, _ := url.Parse(server.URL + "/asset.png")
+	item := frontier.NewItem(assetURL, nil, "asset", 0)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", assetURL.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.setRequestID(req, item)
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.writeWARC(resp)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The requests only differ by their ID, so they are still identical
+	firstBatch, secondBatch := <-c.WARCWriter, <-c.WARCWriter
+	first, second := firstBatch.Records[1], secondBatch.Records[1]
+
+	assert.Equal(t, "request", first.Header.Get("WARC-Type"))
+	assert.Equal(t, "metadata", second.Header.Get("WARC-Type"))
+	assert.Equal(t, first.Header.Get("WARC-Record-ID"), second.Header.Get("WARC-Refers-To"))
+}
+
+func TestHashRequestIgnoresRequestID(t *testing.T) {
+	request := "GET /asset.png HTTP/1.1\r\nHost: example.com\r\n%s\r\nbody"
+
+	first := hashRequest([]byte(fmt.Sprintf(request, "X-Crawler-Request-ID: 0000000000000001-00000001\r\n")))
+	second := hashRequest([]byte(fmt.Sprintf(request, "x-crawler-request-id: 0000000000000001-00000002\r\n")))
+	assert.Equal(t, first, second)
+
+	// The other headers and the body are still covered
+	assert.NotEqual(t, first, hashRequest([]byte(fmt.Sprintf(request, "Accept: */*\r\n"))))
+	assert.NotEqual(t, first, hashRequest([]byte(strings.Replace(fmt.Sprintf(request, ""), "body", "other", 1))))
+}
+
+func TestRequestDedupeWindow(t *testing.T) {
+	var index = newRequestDedupeIndex()
+	var start = time.Now()
+
+	first := writtenRequest{RecordID: "<urn:uuid:1>", Time: start}
+	_, duplicate := index.check("hash", first, time.Hour)
+	assert.False(t, duplicate)
+
+	// Within the window, the first request is referred to
+	original, duplicate := index.check("hash", writtenRequest{RecordID: "<urn:uuid:2>", Time: start.Add(30 * time.Minute)}, time.Hour)
+	assert.True(t, duplicate)
+	assert.Equal(t, first, original)
+
+	// Past the window, the request is written again and is referred to next
+	third := writtenRequest{RecordID: "<urn:uuid:3>", Time: start.Add(2 * time.Hour)}
+	_, duplicate = index.check("hash", third, time.Hour)
+	assert.False(t, duplicate)
+
+	original, duplicate = index.check("hash", writtenRequest{RecordID: "<urn:uuid:4>", Time: start.Add(150 * time.Minute)}, time.Hour)
+	assert.True(t, duplicate)
+	assert.Equal(t, third, original)
+
+	// Without window, the requests are always duplicates
+	original, duplicate = index.check("hash", writtenRequest{RecordID: "<urn:uuid:5>", Time: start.Add(100 * time.Hour)}, 0)
+	assert.True(t, duplicate)
+	assert.Equal(t, third, original)
+}
+
+func TestInitWARCWriterStageBuffer(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.StageBufferSize = 8
+	c.initWARCWriter()
+
+	// The batches are buffered without waiting for the rotator
+	assert.Equal(t, 8, cap(c.WARCWriter))
+	for i := 0; i < 8; i++ {
+		var record = warc.NewRecord()
+		record.Header.Set("WARC-Type", "resource")
+		record.Header.Set("WARC-Target-URI", "https://example.com/")
+		record.Content = bytes.NewReader([]byte("content"))
+
+		var batch = warc.NewRecordBatch()
+		batch.Records = append(batch.Records, record)
+		c.WARCWriter <- batch
+	}
+
+	// Closing the buffered channel flushes it to the rotator
+	close(c.WARCWriter)
+	<-c.WARCWriterFinish
+
+	warcs, _ := filepath.Glob(filepath.Join(jobPath, "warcs", "*.warc.gz"))
+	if assert.Len(t, warcs, 1) {
+		lines, err := indexWARC(warcs[0])
+		assert.NoError(t, err)
+		assert.Len(t, lines, 8)
+	}
+}
+
+// drainWARCWriter consume the batches sent to the WARC writer without
+// writing them, until the channel is closed
+func drainWARCWriter(c *Crawl) {
+	for batch := range c.WARCWriter {
+		if batch.Done != nil {
+			batch.Done <- true
+		}
+	}
+}
+
+func TestWriteWARCInMemoryBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", r.URL.Query().Get("size"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		w.Write(bytes.Repeat([]byte("a"), size))
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+	os.MkdirAll(filepath.Join(jobPath, "temp"), os.ModePerm)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.MaxInMemoryBodySize = 1024
+	c.WARCWriter = make(chan *warc.RecordBatch)
+	go drainWARCWriter(c)
+	defer close(c.WARCWriter)
+
+	for _, test := range []struct {
+		size   int
+		onDisk bool
+	}{
+		{512, false},
+		{1024, false},
+		{2048, true},
+	} {
+		resp, err := c.Client.Get(server.URL + "/?size=" + strconv.Itoa(test.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respPath, err := c.writeWARC(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, test.onDisk, respPath != "", test.size)
+
+		// The body is readable for the extraction either way
+		body, err := readResponseBody(resp, respPath, int64(test.size)+1)
+		assert.NoError(t, err)
+		assert.Len(t, body, test.size)
+
+		resp.Body.Close()
+		os.Remove(respPath)
+	}
+}
+
+// benchmarkLargePages capture and read back for the extraction pages
+// of 8MB, with the given maximum size of the bodies kept in memory
+func benchmarkLargePages(b *testing.B, maxInMemoryBodySize int64) {
+	page := bytes.Repeat([]byte("<p>large page</p>"), 8*MB/17)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	jobPath, err := ioutil.TempDir("", "zeno")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+	os.MkdirAll(filepath.Join(jobPath, "temp"), os.ModePerm)
+
+	c := newTestCrawl()
+	c.JobPath = jobPath
+	c.MaxInMemoryBodySize = maxInMemoryBodySize
+	c.WARCWriter = make(chan *warc.RecordBatch)
+	go drainWARCWriter(c)
+	defer close(c.WARCWriter)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := c.Client.Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		respPath, err := c.writeWARC(resp)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, err = readResponseBody(resp, respPath, 64*KB)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		resp.Body.Close()
+		os.Remove(respPath)
+	}
+}
+
+// The allocations per capture stay bounded when the large bodies go to disk
+func BenchmarkLargePagesInMemory(b *testing.B) { benchmarkLargePages(b, 16*MB) }
+func BenchmarkLargePagesOnDisk(b *testing.B)   { benchmarkLargePages(b, 1*MB) }
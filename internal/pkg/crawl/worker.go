@@ -1,10 +1,13 @@
 package crawl
 
 import (
+	"errors"
 	"time"
 
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/remeh/sizedwaitgroup"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -18,28 +21,150 @@ const (
 	GB = 1024 * MB
 )
 
+// isHostPagesLimitReached return true if the maximum
+// number of pages to capture for the host is reached
+func (c *Crawl) isHostPagesLimitReached(host string) bool {
+	return c.MaxPagesPerHost > 0 && c.PagesPerHost.GetCount(host) >= c.MaxPagesPerHost
+}
+
 // Worker is the key component of a crawl, it's a background processed dispatched
 // when the crawl starts, it listens on a channel to get new URLs to archive,
 // and eventually push newly discovered URLs back in the frontier.
-func (c *Crawl) Worker(wg *sizedwaitgroup.SizedWaitGroup) {
+// The worker stops when stop is closed, once it is done with its item.
+func (c *Crawl) Worker(wg *sizedwaitgroup.SizedWaitGroup, stop <-chan struct{}) {
+	defer wg.Done()
+
+	// Expose the state of the worker in the API
+	workerID := c.WorkerStates.register()
+	defer c.WorkerStates.unregister(workerID)
+
 	// Start archiving the URLs!
-	for item := range c.Frontier.PullChan {
-		item := item
+	for {
+		var item *frontier.Item
+		var ok bool
+		var admitted bool
+
+		// Workers stop when the pool is scaled down, before taking
+		// another item, the seeds deferred by --max-active-seeds
+		// are received once admitted
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		select {
+		case <-stop:
+			return
+		case item = <-c.ActiveSeeds.admitted:
+			// The seed was queued again if the crawl is finishing
+			if !c.ActiveSeeds.received(item) {
+				continue
+			}
+			admitted = true
+		case item, ok = <-c.Frontier.PullChan:
+			if !ok {
+				return
+			}
+		}
 
 		// Check if the crawl is paused
 		for c.Paused.Get() {
 			time.Sleep(time.Second)
 		}
 
-		// If the host of the item is in the host exclusion list, we skip it
-		if utils.IsHostExcluded(item.Host, c.ExcludedHosts) {
-			continue
+		// The deferred seeds already went through the checks
+		if !admitted {
+			// If the host of the item is in the host exclusion list, we skip it
+			if utils.IsHostExcluded(item.Host, c.ExcludedHosts) || c.isBlocklisted(item.URL) {
+				c.releaseSeedTree(item)
+				continue
+			}
+
+			// If the host already reached the maximum number of pages, we skip it
+			if c.isHostPagesLimitReached(item.Host) {
+				logInfo.WithFields(logrus.Fields{
+					"url":  item.URL.String(),
+					"host": item.Host,
+				}).Debug("Maximum number of pages reached for host, skipping")
+				c.releaseSeedTree(item)
+				continue
+			}
+			c.PagesPerHost.Incr(item.Host)
+
+			// New seeds are deferred while --max-active-seeds trees are being captured
+			if !c.admitSeedTree(item) {
+				continue
+			}
 		}
 
 		c.ActiveWorkers.Incr(1)
+		c.WorkerStates.working(workerID, item.URL.String())
 		c.Capture(item)
+		c.WorkerStates.idle(workerID)
 		c.ActiveWorkers.Incr(-1)
+
+		c.releaseSeedTree(item)
 	}
+}
+
+// GetWorkersCount return the current number of workers
+func (c *Crawl) GetWorkersCount() int {
+	c.workersMutex.Lock()
+	defer c.workersMutex.Unlock()
+
+	return c.Workers
+}
+
+// startWorker start a worker, with its own channel to stop it when
+// the pool is scaled down, workersMutex must be held
+func (c *Crawl) startWorker() {
+	stop := make(chan struct{})
+	c.workerStops = append(c.workerStops, stop)
+
+	c.WorkerPool.Add()
+	go c.Worker(&c.WorkerPool, stop)
+}
+
+// ScaleWorkers change the number of workers while the crawl is running,
+// new workers are started right away, and when the pool is scaled down,
+// workers stop once they are done with the item they are capturing
+func (c *Crawl) ScaleWorkers(count int) error {
+	if count < 1 {
+		return errors.New("the number of workers must be at least 1")
+	}
+
+	c.workersMutex.Lock()
+	defer c.workersMutex.Unlock()
+
+	if c.Finished.Get() {
+		return errors.New("the crawl is finishing")
+	}
+
+	for len(c.workerStops) < count {
+		c.startWorker()
+	}
+
+	// The workers are stopped by closing their channel, so a worker
+	// can't receive the stop of a scaling that was superseded
+	for len(c.workerStops) > count {
+		last := len(c.workerStops) - 1
+		close(c.workerStops[last])
+		c.workerStops = c.workerStops[:last]
+	}
+
+	// The extraction pool follows the number of workers,
+	// unless its size was set with --postprocessor-concurrency
+	if c.PostprocessorConcurrency <= 0 {
+		c.ExtractionPool.Resize(count)
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"from": c.Workers,
+		"to":   count,
+	}).Info("Workers pool scaled")
+
+	c.Workers = count
 
-	wg.Done()
+	return nil
 }
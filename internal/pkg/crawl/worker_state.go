@@ -0,0 +1,103 @@
+package crawl
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// workerState is the state of a worker as exposed by the API
+type workerState struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	// CurrentURL is the URL the worker is capturing, or
+	// the last URL it captured if it is idle
+	CurrentURL string    `json:"current_url"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// workerStates keep track of the state of the running workers
+type workerStates struct {
+	sync.Mutex
+	workers map[int]*workerState
+	nextID  int
+}
+
+func newWorkerStates() *workerStates {
+	return &workerStates{
+		workers: make(map[int]*workerState),
+	}
+}
+
+// register add a new idle worker and return its ID
+func (states *workerStates) register() int {
+	states.Lock()
+	defer states.Unlock()
+
+	states.nextID++
+	states.workers[states.nextID] = &workerState{
+		ID:       states.nextID,
+		Status:   "idle",
+		LastSeen: time.Now(),
+	}
+
+	return states.nextID
+}
+
+// unregister remove a stopped worker
+func (states *workerStates) unregister(ID int) {
+	states.Lock()
+	delete(states.workers, ID)
+	states.Unlock()
+}
+
+// working mark a worker as capturing the given URL
+func (states *workerStates) working(ID int, URL string) {
+	states.Lock()
+	if state, ok := states.workers[ID]; ok {
+		state.Status = "working"
+		state.CurrentURL = URL
+		state.LastSeen = time.Now()
+	}
+	states.Unlock()
+}
+
+// idle mark a worker as waiting for a new item
+func (states *workerStates) idle(ID int) {
+	states.Lock()
+	if state, ok := states.workers[ID]; ok {
+		state.Status = "idle"
+		state.LastSeen = time.Now()
+	}
+	states.Unlock()
+}
+
+// Get return the state of a worker, and false if there is no such worker
+func (states *workerStates) Get(ID int) (workerState, bool) {
+	states.Lock()
+	defer states.Unlock()
+
+	state, ok := states.workers[ID]
+	if !ok {
+		return workerState{}, false
+	}
+
+	return *state, true
+}
+
+// Snapshot return a copy of the state of all the workers, sorted by ID
+func (states *workerStates) Snapshot() []workerState {
+	states.Lock()
+	defer states.Unlock()
+
+	var snapshot = make([]workerState, 0, len(states.workers))
+	for _, state := range states.workers {
+		snapshot = append(snapshot, *state)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+
+	return snapshot
+}
@@ -0,0 +1,60 @@
+package crawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerStatesCurrentURL(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("slow"))
+	}))
+	defer server.Close()
+
+	c := newTestCrawl()
+	c.PagesPerHost = new(frontier.HostPool)
+	c.PagesPerHost.Mutex = new(sync.Mutex)
+	c.PagesPerHost.Hosts = make(map[string]*ratecounter.Counter)
+	c.Frontier.PullChan = make(chan *frontier.Item)
+
+	var wg = sizedwaitgroup.New(1)
+	wg.Add()
+	go c.Worker(&wg, nil)
+
+	URL, _ := url.Parse(server.URL + "/hanging")
+	c.Frontier.PullChan <- frontier.NewItem(URL, nil, "seed", 0)
+
+	// The worker shows the URL it is capturing while it hangs
+	assert.Eventually(t, func() bool {
+		state, ok := c.WorkerStates.Get(1)
+		return ok && state.Status == "working"
+	}, time.Second, 10*time.Millisecond)
+
+	states := c.WorkerStates.Snapshot()
+	if assert.Len(t, states, 1) {
+		assert.Equal(t, URL.String(), states[0].CurrentURL)
+	}
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		state, _ := c.WorkerStates.Get(1)
+		return state.Status == "idle"
+	}, time.Second, 10*time.Millisecond)
+
+	// Stopped workers are removed
+	close(c.Frontier.PullChan)
+	wg.Wait()
+	_, ok := c.WorkerStates.Get(1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,86 @@
+package crawl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/frontier"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/remeh/sizedwaitgroup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleWorkers(t *testing.T) {
+	c := newTestCrawl()
+	c.Workers = 2
+	c.PagesPerHost = new(frontier.HostPool)
+	c.PagesPerHost.Mutex = new(sync.Mutex)
+	c.PagesPerHost.Hosts = make(map[string]*ratecounter.Counter)
+	c.Frontier.PullChan = make(chan *frontier.Item)
+	c.WorkerPool = sizedwaitgroup.New(0)
+	c.ExtractionPool = newExtractionPool(c.Workers)
+
+	c.workersMutex.Lock()
+	for i := 0; i < c.Workers; i++ {
+		c.startWorker()
+	}
+	c.workersMutex.Unlock()
+
+	running := func() int {
+		return len(c.WorkerStates.Snapshot())
+	}
+	assert.Eventually(t, func() bool { return running() == 2 }, time.Second, 10*time.Millisecond)
+
+	// A scale down superseded by a scale up doesn't
+	// stop any of the workers started afterwards
+	assert.NoError(t, c.ScaleWorkers(1))
+	assert.NoError(t, c.ScaleWorkers(3))
+	assert.Eventually(t, func() bool { return running() == 3 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 3, running())
+	assert.Equal(t, 3, c.GetWorkersCount())
+
+	// The extraction pool follows the number of workers
+	assert.Equal(t, 3, c.ExtractionPool.size)
+
+	assert.NoError(t, c.ScaleWorkers(1))
+	assert.Eventually(t, func() bool { return running() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, c.ExtractionPool.size)
+
+	// Unless its size was set explicitly
+	c.PostprocessorConcurrency = 4
+	assert.NoError(t, c.ScaleWorkers(2))
+	assert.Equal(t, 1, c.ExtractionPool.size)
+
+	assert.Error(t, c.ScaleWorkers(0))
+
+	close(c.Frontier.PullChan)
+	c.WorkerPool.Wait()
+	assert.Equal(t, 0, running())
+}
+
+func TestExtractionPoolResize(t *testing.T) {
+	pool := newExtractionPool(1)
+	pool.Add()
+
+	taken := make(chan bool)
+	go func() {
+		pool.Add()
+		taken <- true
+	}()
+
+	select {
+	case <-taken:
+		t.Fatal("a slot was taken while the pool is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Growing the pool gives the waiting slot right away
+	pool.Resize(2)
+	select {
+	case <-taken:
+	case <-time.After(time.Second):
+		t.Fatal("the slot wasn't taken once the pool grew")
+	}
+}
@@ -0,0 +1,77 @@
+package frontier
+
+import (
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// CompactQueue rewrite the queue's database on disk to reclaim the space
+// used by the items that have been dequeued, and drop the drained hosts
+// from the hosts pool, the queue is unavailable during the compaction
+func (f *Frontier) CompactQueue() (err error) {
+	var start = time.Now()
+
+	f.QueueMutex.Lock()
+	defer f.QueueMutex.Unlock()
+
+	err = f.Queue.Close()
+	if err != nil {
+		return err
+	}
+
+	// The queue doesn't expose its database, so we open it directly
+	// while the queue is closed to compact it
+	db, err := leveldb.OpenFile(path.Join(f.JobPath, "queue"), nil)
+	if err == nil {
+		err = db.CompactRange(util.Range{})
+		db.Close()
+	}
+
+	// Whatever happened, we reopen the queue so the crawl can continue
+	queue, openErr := newPersistentQueue(f.JobPath)
+	if openErr != nil {
+		return openErr
+	}
+	f.Queue = queue
+
+	if err != nil {
+		return err
+	}
+
+	f.HostPool.DeleteEmptyHosts()
+	atomic.StoreInt64(&f.dequeuedSinceCompaction, 0)
+
+	logInfo.WithFields(logrus.Fields{
+		"duration": time.Since(start),
+	}).Info("Queue compacted")
+
+	return nil
+}
+
+// queueCompactor compact the queue every time QueueCompactionThreshold
+// items have been dequeued since the last compaction
+func (f *Frontier) queueCompactor() {
+	for {
+		time.Sleep(10 * time.Second)
+
+		if f.FinishingQueueReader.Get() {
+			return
+		}
+
+		if atomic.LoadInt64(&f.dequeuedSinceCompaction) < f.QueueCompactionThreshold {
+			continue
+		}
+
+		err := f.CompactQueue()
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("Unable to compact the queue")
+		}
+	}
+}
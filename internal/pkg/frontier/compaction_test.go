@@ -0,0 +1,52 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactQueue(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-compaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	f := newTestFrontier(jobPath)
+	f.Queue, err = newPersistentQueue(jobPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { f.Queue.Close() }()
+
+	URL, _ := url.Parse("https://example.com/")
+	for i := 0; i < 100; i++ {
+		_, err = f.Queue.EnqueueObject([]byte("example.com"), NewItem(URL, nil, "seed", 0))
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 90; i++ {
+		_, err = f.Queue.DequeueString("example.com")
+		assert.NoError(t, err)
+	}
+	f.dequeuedSinceCompaction = 90
+
+	assert.NoError(t, f.CompactQueue())
+	assert.Equal(t, int64(0), f.dequeuedSinceCompaction)
+
+	// The items that were still queued survive the compaction
+	assert.Equal(t, uint64(10), f.Queue.Length())
+
+	var item *Item
+	queueItem, err := f.Queue.DequeueString("example.com")
+	assert.NoError(t, err)
+	assert.NoError(t, queueItem.ToObject(&item))
+	assert.Equal(t, "https://example.com/", item.URL.String())
+}
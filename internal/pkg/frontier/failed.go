@@ -0,0 +1,120 @@
+package frontier
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// FailedItem is the representation of an item that couldn't be captured,
+// as written in the failed.jsonl file of a job
+type FailedItem struct {
+	URL       string `json:"url"`
+	Hop       uint8  `json:"hop"`
+	Type      string `json:"type"`
+	ParentURL string `json:"parent_url,omitempty"`
+	Error     string `json:"error"`
+}
+
+// NewFailedItem initialize a *FailedItem from an item and the error
+// that made its capture fail
+func NewFailedItem(item *Item, err error) *FailedItem {
+	failedItem := new(FailedItem)
+
+	failedItem.URL = item.URL.String()
+	failedItem.Hop = item.Hop
+	failedItem.Type = item.Type
+	if item.ParentItem != nil {
+		failedItem.ParentURL = item.ParentItem.URL.String()
+	}
+	if err != nil {
+		failedItem.Error = err.Error()
+	}
+
+	return failedItem
+}
+
+// LoadFailedItems read a failed.jsonl file written by a previous crawl
+// and return its items, so they can be crawled again with their original
+// type, hop and parent URL. Malformed lines are skipped.
+func LoadFailedItems(path string) (items []Item, err error) {
+	var totalCount int
+
+	file, err := os.Open(path)
+	if err != nil {
+		return items, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		totalCount++
+
+		var failedItem = new(FailedItem)
+		err = json.Unmarshal(scanner.Bytes(), failedItem)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"line":  totalCount,
+				"error": err.Error(),
+			}).Warning("Malformed line in failed items file, skipping")
+			continue
+		}
+
+		URL, err := url.Parse(utils.CleanURL(failedItem.URL))
+		if err == nil {
+			err = utils.ValidateURL(URL)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"line":  totalCount,
+				"url":   failedItem.URL,
+				"error": err.Error(),
+			}).Warning("Invalid URL in failed items file, skipping")
+			continue
+		}
+
+		// We preserve the type, the hop and the parent URL of the item, the
+		// assets have the hop of their parent, the outlinks the next one
+		var parentItem *Item
+		if len(failedItem.ParentURL) > 0 {
+			parentURL, err := url.Parse(utils.CleanURL(failedItem.ParentURL))
+			if err == nil {
+				var parentHop = failedItem.Hop
+				if failedItem.Type != "asset" && parentHop > 0 {
+					parentHop--
+				}
+				parentItem = NewItem(parentURL, nil, "seed", parentHop)
+			}
+		}
+
+		// An asset can only be captured as such with its parent,
+		// the other items, and the files of old crawls, are seeds
+		var itemType = "seed"
+		if failedItem.Type == "asset" && parentItem != nil {
+			itemType = "asset"
+		}
+
+		items = append(items, *NewItem(URL, parentItem, itemType, failedItem.Hop))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return items, err
+	}
+
+	if len(items) == 0 {
+		return items, errors.New("no valid item found in failed items file")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":  path,
+		"items": len(items),
+		"lines": totalCount,
+	}).Info("Failed items loaded")
+
+	return items, nil
+}
@@ -0,0 +1,49 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFailedItems(t *testing.T) {
+	file, err := ioutil.TempFile("", "zeno-failed-*.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(`{"url":"https://example.com/a","hop":2,"type":"seed","parent_url":"https://example.com/","error":"timeout"}` + "\n")
+	file.WriteString(`this is not JSON` + "\n")
+	file.WriteString(`{"url":"mailto:foo@example.com","hop":0,"type":"seed"}` + "\n")
+	file.WriteString(`{"url":"https://example.com/b","hop":0,"type":"seed"}` + "\n")
+	file.WriteString(`{"url":"https://example.com/c.css","hop":1,"type":"asset","parent_url":"https://example.com/b"}` + "\n")
+	file.WriteString(`{"url":"https://example.com/d.css","hop":0,"type":"asset"}` + "\n")
+	file.Close()
+
+	items, err := LoadFailedItems(file.Name())
+	assert.NoError(t, err)
+	if assert.Len(t, items, 4) {
+		assert.Equal(t, "https://example.com/a", items[0].URL.String())
+		assert.Equal(t, "seed", items[0].Type)
+		assert.Equal(t, uint8(2), items[0].Hop)
+		if assert.NotNil(t, items[0].ParentItem) {
+			assert.Equal(t, "https://example.com/", items[0].ParentItem.URL.String())
+			assert.Equal(t, uint8(1), items[0].ParentItem.Hop)
+		}
+
+		assert.Equal(t, "https://example.com/b", items[1].URL.String())
+		assert.Nil(t, items[1].ParentItem)
+
+		// The assets keep their type, and have the hop of their parent
+		assert.Equal(t, "asset", items[2].Type)
+		if assert.NotNil(t, items[2].ParentItem) {
+			assert.Equal(t, uint8(1), items[2].ParentItem.Hop)
+		}
+
+		// Without a parent, an asset can only be retried as a seed
+		assert.Equal(t, "seed", items[3].Type)
+	}
+}
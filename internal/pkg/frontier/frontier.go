@@ -1,12 +1,15 @@
 package frontier
 
 import (
+	"errors"
+	"math/rand"
+	"os"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/beeker1121/goque"
-	"github.com/dgraph-io/badger/v3"
 	"github.com/paulbellamy/ratecounter"
 	"github.com/sirupsen/logrus"
 )
@@ -33,6 +36,13 @@ type Frontier struct {
 	// Queue is a local queue storing all the URLs to crawl
 	// it's a prefixed queue, basically one sub-queue per host
 	Queue *goque.PrefixQueue
+	// QueueMutex is held for reading when using the queue, and
+	// for writing when the queue is closed to be compacted
+	QueueMutex sync.RWMutex
+	// QueueCompactionThreshold is the number of items dequeued
+	// after which the queue is compacted, 0 disables the compaction
+	QueueCompactionThreshold int64
+	dequeuedSinceCompaction  int64
 	// QueueCount store the number of URLs currently queued
 	QueueCount *ratecounter.Counter
 
@@ -41,13 +51,80 @@ type Frontier struct {
 	// with a counter for each, going through that map gives us
 	// the prefix to query from the queue
 	HostPool *HostPool
+	// MaxHostsInMemory is the number of hosts of the hosts pool kept
+	// in memory, the others are spilled to disk, 0 means no limit
+	MaxHostsInMemory int
+	// StageBufferSize is the size of the buffer of PushChan, when
+	// it's full the workers block until the queue writer catches up,
+	// 0 means the buffer has the size of PullChan
+	StageBufferSize int
+	// RandomHostSelection shuffle the order in which the
+	// hosts are dequeued from at every round
+	RandomHostSelection bool
+	// round is the snapshot of the hosts the queue reader is going
+	// through, and roundPosition the index of the host it dequeues from
+	round         []string
+	roundPosition int
+	roundMutex    sync.Mutex
+	// PrioritizeSeeds enqueue the seeds in their own lane, dequeued before
+	// the items discovered during the crawl, or SeedPriorityRatio seeds for
+	// each discovered item of the same host if the ratio is more than 0
+	PrioritizeSeeds   bool
+	SeedPriorityRatio int
+	seedsInARow       map[string]int
+	// CompressDump gzip the dump of the hosts pool and hosts statistics
+	// written in frontier.gob, compressed dumps are always loaded
+	CompressDump bool
+	// saveMutex prevent two dumps from being written at the same time,
+	// as they can be triggered from the API while the crawl is running
+	saveMutex sync.Mutex
+
+	// HostStats holds the number of URLs captured and failed and the
+	// number of bytes captured for each host, across the job's sessions
+	HostStats *HostStats
 
 	UseSeencheck bool
-	Seencheck    *Seencheck
+	Seencheck    Seencheck
+
+	// Dropped, if set, is called with the items pushed
+	// to the frontier that aren't enqueued
+	Dropped func(item *Item)
+
+	// RequeueShallowerHops enqueue again the URLs that were already seen
+	// if they are discovered at a lower hop than the first time
+	RequeueShallowerHops bool
+
+	// SeencheckWindow enqueue again the URLs that were seen longer than
+	// this duration ago, so they are captured again even if their content
+	// didn't change, 0 means that the URLs are only captured once
+	SeencheckWindow time.Duration
+
+	// SeencheckBackend is the backend used for the seencheck, either
+	// local or redis, RedisAddr and RedisKey configure the redis backend
+	SeencheckBackend string
+	RedisAddr        string
+	RedisKey         string
+
+	// BloomCapacity is the number of URLs that the bloom filter in front
+	// of the seencheck is sized for, with a false positive rate of
+	// BloomFPRate once full, the filter is disabled if it is 0
+	BloomCapacity int64
+	BloomFPRate   float64
+
+	// TrailingSlashEquivalence make the seencheck consider that URLs only
+	// differing by a trailing slash are the same, for every host or only
+	// for the hosts in TrailingSlashHosts
+	TrailingSlashEquivalence bool
+	TrailingSlashHosts       []string
+
+	// SyncWrites make every write to the seencheck database fsynced,
+	// if it's false the database is only fsynced periodically by Sync
+	SyncWrites bool
 }
 
-// Init ininitialize the components of a frontier
-func (f *Frontier) Init(jobPath string, logInf, logWarn *logrus.Logger, workers int, useSeencheck bool) (err error) {
+// Init ininitialize the components of a frontier, maxInflight is the
+// number of items that can be dequeued and waiting for a worker at the same time
+func (f *Frontier) Init(jobPath string, logInf, logWarn *logrus.Logger, maxInflight int, useSeencheck bool) (err error) {
 	f.JobPath = jobPath
 
 	logInfo = logInf
@@ -55,16 +132,44 @@ func (f *Frontier) Init(jobPath string, logInf, logWarn *logrus.Logger, workers
 
 	f.Paused = new(utils.TAtomBool)
 
+	if f.RandomHostSelection {
+		rand.Seed(time.Now().UnixNano())
+	}
+
 	// Initialize host pool
 	f.HostPool = new(HostPool)
 	f.HostPool.Mutex = new(sync.Mutex)
 	f.HostPool.Hosts = make(map[string]*ratecounter.Counter, 0)
 
-	// Initialize the frontier channels
-	f.PullChan = make(chan *Item, workers)
-	f.PushChan = make(chan *Item, workers)
+	// If asked, only a part of the hosts pool is kept in memory, the hosts
+	// spilled during a previous session are loaded back in any case
+	spillPath := path.Join(jobPath, "hostpool")
+	if _, statErr := os.Stat(spillPath); f.MaxHostsInMemory > 0 || statErr == nil {
+		err = f.HostPool.EnableSpill(spillPath, f.MaxHostsInMemory)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Initialize hosts statistics
+	f.HostStats = NewHostStats()
+
+	// Initialize the frontier channels, the discovered items are
+	// buffered according to StageBufferSize if it is set
+	f.PullChan = make(chan *Item, maxInflight)
+	if f.StageBufferSize > 0 {
+		f.PushChan = make(chan *Item, f.StageBufferSize)
+	} else {
+		f.PushChan = make(chan *Item, maxInflight)
+	}
+
+	// Initialize the queue, after making sure that its format
+	// is supported, and migrating it if it comes from an older Zeno
+	err = checkQueueVersion(jobPath)
+	if err != nil {
+		return err
+	}
 
-	// Initialize the queue
 	f.Queue, err = newPersistentQueue(jobPath)
 	if err != nil {
 		return err
@@ -76,13 +181,24 @@ func (f *Frontier) Init(jobPath string, logInf, logWarn *logrus.Logger, workers
 	// Initialize the seencheck
 	f.UseSeencheck = useSeencheck
 	if f.UseSeencheck {
-		f.Seencheck = new(Seencheck)
-		f.Seencheck.SeenCount = new(ratecounter.Counter)
-		f.Seencheck.SeenDB, err = badger.Open(badger.DefaultOptions(path.Join(jobPath, "seencheck")))
+		switch f.SeencheckBackend {
+		case "", "local":
+			f.Seencheck, err = NewLocalSeencheck(path.Join(jobPath, "seencheck"), f.SyncWrites)
+		case "redis":
+			f.Seencheck, err = NewRedisSeencheck(f.RedisAddr, f.RedisKey)
+		default:
+			err = errors.New("unknown seencheck backend: " + f.SeencheckBackend)
+		}
 		if err != nil {
 			return err
 		}
-		logrus.Info("Seencheck initialized")
+		logrus.WithFields(logrus.Fields{
+			"backend": f.SeencheckBackend,
+		}).Info("Seencheck initialized")
+
+		if f.BloomCapacity > 0 {
+			f.Seencheck = f.withBloomFilter(f.Seencheck)
+		}
 	}
 
 	f.FinishingQueueReader = new(utils.TAtomBool)
@@ -93,6 +209,21 @@ func (f *Frontier) Init(jobPath string, logInf, logWarn *logrus.Logger, workers
 	return nil
 }
 
+// Sync fsync the seencheck database to disk, it's a no-op
+// if every write is already synced
+func (f *Frontier) Sync() {
+	if !f.UseSeencheck || f.SyncWrites {
+		return
+	}
+
+	err := f.Seencheck.Sync()
+	if err != nil {
+		logWarning.WithFields(logrus.Fields{
+			"error": err,
+		}).Warning("Unable to sync seencheck database")
+	}
+}
+
 // Start fire up the background processes that handle the frontier
 func (f *Frontier) Start() {
 	// Function responsible for writing the items push on PushChan to the
@@ -103,4 +234,10 @@ func (f *Frontier) Start() {
 	// Function responsible for reading the items from the queue and dispatching
 	// them to the workers listening on PullChan
 	go f.readItemsFromQueue()
+
+	// Function responsible for periodically compacting the queue
+	// on disk, to reclaim the space used by the dequeued items
+	if f.QueueCompactionThreshold > 0 {
+		go f.queueCompactor()
+	}
 }
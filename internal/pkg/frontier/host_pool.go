@@ -1,15 +1,77 @@
 package frontier
 
 import (
+	"container/list"
+	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/paulbellamy/ratecounter"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // HostPool holds all the active hosts in the pool
 type HostPool struct {
 	*sync.Mutex
 	Hosts map[string]*ratecounter.Counter
+
+	// When spilling is enabled, only the maxHosts most recently used
+	// hosts are kept in Hosts, the counters of the other hosts are
+	// stored on disk until there is room for them again
+	maxHosts int
+	spill    *leveldb.DB
+	recent   *list.List
+	elements map[string]*list.Element
+}
+
+// EnableSpill keep at most maxHosts hosts in memory, the least recently
+// used ones are moved to a database on disk at the given path, and loaded
+// back when hosts are drained. A maxHosts of 0 means no limit, the hosts
+// spilled during a previous session are then all loaded back in memory
+func (pool *HostPool) EnableSpill(path string, maxHosts int) (err error) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.spill, err = leveldb.OpenFile(path, nil)
+	if err != nil {
+		return err
+	}
+	pool.maxHosts = maxHosts
+
+	pool.recent = list.New()
+	pool.elements = make(map[string]*list.Element)
+	for host := range pool.Hosts {
+		pool.touch(host)
+	}
+
+	pool.spillColdHosts()
+	pool.loadSpilledHosts()
+
+	return nil
+}
+
+// AddHosts add hosts and their counters to the pool, spilling
+// the least recently used hosts if there are too many of them
+func (pool *HostPool) AddHosts(hosts map[string]*ratecounter.Counter) {
+	pool.Lock()
+	for host, hostCount := range hosts {
+		pool.Hosts[host] = hostCount
+		pool.touch(host)
+	}
+	pool.spillColdHosts()
+	pool.Unlock()
+}
+
+// Close close the database of the spilled hosts
+func (pool *HostPool) Close() error {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if pool.spill == nil {
+		return nil
+	}
+
+	return pool.spill.Close()
 }
 
 // IsHostInPool return true if the Host is in the pool
@@ -23,15 +85,70 @@ func (pool *HostPool) IsHostInPool(host string) bool {
 	return false
 }
 
+// Snapshot return a copy of the number of items queued for each
+// host in memory, the hosts spilled to disk aren't included
+func (pool *HostPool) Snapshot() map[string]int64 {
+	pool.Lock()
+	snapshot := make(map[string]int64, len(pool.Hosts))
+	for host, hostCount := range pool.Hosts {
+		snapshot[host] = hostCount.Value()
+	}
+	pool.Unlock()
+
+	return snapshot
+}
+
+// HostQueued is the number of items queued for a host
+type HostQueued struct {
+	Host   string `json:"host"`
+	Queued int64  `json:"queued"`
+}
+
+// Composition return the number of items queued for every host of the
+// pool, the hosts spilled to disk included, from the host with the most
+// items queued to the host with the fewest
+func (pool *HostPool) Composition() (hosts []HostQueued) {
+	pool.Lock()
+	for host, hostCount := range pool.Hosts {
+		if hostCount.Value() > 0 {
+			hosts = append(hosts, HostQueued{Host: host, Queued: hostCount.Value()})
+		}
+	}
+
+	if pool.spill != nil {
+		iterator := pool.spill.NewIterator(nil, nil)
+		for iterator.Next() {
+			spilledCount, _ := strconv.ParseInt(string(iterator.Value()), 10, 64)
+			if spilledCount > 0 {
+				hosts = append(hosts, HostQueued{Host: string(iterator.Key()), Queued: spilledCount})
+			}
+		}
+		iterator.Release()
+	}
+	pool.Unlock()
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Queued != hosts[j].Queued {
+			return hosts[i].Queued > hosts[j].Queued
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+
+	return hosts
+}
+
 // DeleteEmptyHosts remove all the hosts that have a count
-// of zero from the hosts pool
+// of zero from the hosts pool, and load spilled hosts back
+// in memory if there is room for them
 func (pool *HostPool) DeleteEmptyHosts() {
 	pool.Lock()
 	for host, hostCount := range pool.Hosts {
 		if hostCount.Value() <= 0 {
 			delete(pool.Hosts, host)
+			pool.forget(host)
 		}
 	}
+	pool.loadSpilledHosts()
 	pool.Unlock()
 }
 
@@ -40,8 +157,11 @@ func (pool *HostPool) Incr(host string) {
 	pool.Lock()
 	if _, ok := pool.Hosts[host]; !ok {
 		pool.Hosts[host] = new(ratecounter.Counter)
+		pool.Hosts[host].Incr(pool.takeSpilledCount(host))
 	}
 	pool.Hosts[host].Incr(1)
+	pool.touch(host)
+	pool.spillColdHosts()
 	pool.Unlock()
 }
 
@@ -49,17 +169,22 @@ func (pool *HostPool) Incr(host string) {
 func (pool *HostPool) Decr(host string) {
 	pool.Lock()
 	if _, ok := pool.Hosts[host]; !ok {
+		if spilledCount := pool.takeSpilledCount(host); spilledCount > 1 {
+			pool.putSpilledCount(host, spilledCount-1)
+		}
 		pool.Unlock()
 		return
 	}
 
 	if pool.Hosts[host].Value()-1 <= 0 {
 		delete(pool.Hosts, host)
+		pool.forget(host)
 		pool.Unlock()
 		return
 	}
 
 	pool.Hosts[host].Incr(-1)
+	pool.touch(host)
 	pool.Unlock()
 }
 
@@ -67,11 +192,111 @@ func (pool *HostPool) Decr(host string) {
 func (pool *HostPool) GetCount(host string) (value int64) {
 	pool.Lock()
 	if _, ok := pool.Hosts[host]; !ok {
+		value = pool.getSpilledCount(host)
 		pool.Unlock()
-		return 0
+		return value
 	}
 	value = pool.Hosts[host].Value()
 	pool.Unlock()
 
 	return value
 }
+
+// touch mark a host as the most recently used one
+func (pool *HostPool) touch(host string) {
+	if pool.recent == nil {
+		return
+	}
+
+	if element, ok := pool.elements[host]; ok {
+		pool.recent.MoveToFront(element)
+		return
+	}
+	pool.elements[host] = pool.recent.PushFront(host)
+}
+
+// forget remove a host from the recently used hosts
+func (pool *HostPool) forget(host string) {
+	if pool.recent == nil {
+		return
+	}
+
+	if element, ok := pool.elements[host]; ok {
+		pool.recent.Remove(element)
+		delete(pool.elements, host)
+	}
+}
+
+// spillColdHosts move the least recently used hosts to
+// disk until there are no more than maxHosts in memory
+func (pool *HostPool) spillColdHosts() {
+	if pool.spill == nil || pool.maxHosts <= 0 {
+		return
+	}
+
+	for len(pool.Hosts) > pool.maxHosts {
+		host := pool.recent.Back().Value.(string)
+
+		pool.putSpilledCount(host, pool.Hosts[host].Value())
+		delete(pool.Hosts, host)
+		pool.forget(host)
+	}
+}
+
+// loadSpilledHosts load spilled hosts back in memory
+// until there are maxHosts in memory
+func (pool *HostPool) loadSpilledHosts() {
+	if pool.spill == nil {
+		return
+	}
+
+	var batch = new(leveldb.Batch)
+	iterator := pool.spill.NewIterator(nil, nil)
+	for (pool.maxHosts <= 0 || len(pool.Hosts) < pool.maxHosts) && iterator.Next() {
+		host := string(iterator.Key())
+		spilledCount, _ := strconv.ParseInt(string(iterator.Value()), 10, 64)
+
+		pool.Hosts[host] = new(ratecounter.Counter)
+		pool.Hosts[host].Incr(spilledCount)
+		pool.touch(host)
+		batch.Delete(iterator.Key())
+	}
+	iterator.Release()
+
+	pool.spill.Write(batch, nil)
+}
+
+// getSpilledCount return the counter of a spilled host
+func (pool *HostPool) getSpilledCount(host string) int64 {
+	if pool.spill == nil {
+		return 0
+	}
+
+	value, err := pool.spill.Get([]byte(host), nil)
+	if err != nil {
+		return 0
+	}
+
+	spilledCount, _ := strconv.ParseInt(string(value), 10, 64)
+	return spilledCount
+}
+
+// takeSpilledCount return the counter of a spilled host and remove
+// it from the disk, as the host is about to be in memory
+func (pool *HostPool) takeSpilledCount(host string) int64 {
+	spilledCount := pool.getSpilledCount(host)
+	if spilledCount != 0 {
+		pool.spill.Delete([]byte(host), nil)
+	}
+
+	return spilledCount
+}
+
+// putSpilledCount write the counter of a spilled host on disk
+func (pool *HostPool) putSpilledCount(host string, count int64) {
+	if count <= 0 {
+		return
+	}
+
+	pool.spill.Put([]byte(host), []byte(strconv.FormatInt(count, 10)), nil)
+}
@@ -0,0 +1,123 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/paulbellamy/ratecounter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHostPool(t testing.TB, maxHosts int) (pool *HostPool, spillPath string) {
+	jobPath, err := ioutil.TempDir("", "zeno-host-pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool = new(HostPool)
+	pool.Mutex = new(sync.Mutex)
+	pool.Hosts = make(map[string]*ratecounter.Counter, 0)
+
+	spillPath = path.Join(jobPath, "hostpool")
+	err = pool.EnableSpill(spillPath, maxHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pool, spillPath
+}
+
+func TestHostPoolSpill(t *testing.T) {
+	pool, spillPath := newTestHostPool(t, 2)
+	defer os.RemoveAll(path.Dir(spillPath))
+
+	pool.Incr("a.com")
+	pool.Incr("a.com")
+	pool.Incr("b.com")
+	pool.Incr("c.com")
+
+	// a.com is the least recently used host, it is spilled
+	assert.Len(t, pool.Hosts, 2)
+	assert.False(t, pool.IsHostInPool("a.com"))
+	assert.Equal(t, int64(2), pool.GetCount("a.com"))
+
+	// Enqueuing for a spilled host brings it back in memory
+	pool.Incr("a.com")
+	assert.True(t, pool.IsHostInPool("a.com"))
+	assert.False(t, pool.IsHostInPool("b.com"))
+	assert.Equal(t, int64(3), pool.GetCount("a.com"))
+
+	// Draining a host makes room for a spilled one
+	pool.Decr("c.com")
+	pool.DeleteEmptyHosts()
+	assert.True(t, pool.IsHostInPool("b.com"))
+	assert.Equal(t, int64(1), pool.GetCount("b.com"))
+
+	// In a new session without limit, the spilled hosts are all loaded back
+	pool.Incr("d.com")
+	assert.NoError(t, pool.Close())
+
+	resumed := new(HostPool)
+	resumed.Mutex = new(sync.Mutex)
+	resumed.Hosts = make(map[string]*ratecounter.Counter, 0)
+	assert.NoError(t, resumed.EnableSpill(spillPath, 0))
+	defer resumed.Close()
+
+	resumed.AddHosts(pool.Hosts)
+	assert.Len(t, resumed.Hosts, 3)
+	assert.Equal(t, int64(3), resumed.GetCount("a.com"))
+	assert.Equal(t, int64(1), resumed.GetCount("b.com"))
+	assert.Equal(t, int64(1), resumed.GetCount("d.com"))
+}
+
+func TestHostPoolComposition(t *testing.T) {
+	pool, spillPath := newTestHostPool(t, 2)
+	defer os.RemoveAll(path.Dir(spillPath))
+	defer pool.Close()
+
+	for host, count := range map[string]int{"a.com": 3, "b.com": 1, "c.com": 2, "d.com": 1} {
+		for i := 0; i < count; i++ {
+			pool.Incr(host)
+		}
+	}
+
+	// The spilled hosts are included, ordered by number of items queued
+	composition := pool.Composition()
+	assert.Len(t, pool.Hosts, 2)
+	assert.Equal(t, []HostQueued{
+		{Host: "a.com", Queued: 3},
+		{Host: "c.com", Queued: 2},
+		{Host: "b.com", Queued: 1},
+		{Host: "d.com", Queued: 1},
+	}, composition)
+}
+
+func benchmarkHostPool(b *testing.B, hosts, maxHosts int) {
+	for i := 0; i < b.N; i++ {
+		pool, spillPath := newTestHostPool(b, maxHosts)
+
+		for j := 0; j < hosts; j++ {
+			pool.Incr("host-" + strconv.Itoa(j) + ".com")
+		}
+
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		b.ReportMetric(float64(stats.HeapAlloc)/float64(1024*1024), "heap-MB")
+		b.ReportMetric(float64(len(pool.Hosts)), "hosts-in-memory")
+
+		pool.Close()
+		os.RemoveAll(path.Dir(spillPath))
+	}
+}
+
+// The heap stays bounded with a limit as the number of hosts grows
+func BenchmarkHostPoolUnlimited10k(b *testing.B)  { benchmarkHostPool(b, 10000, 0) }
+func BenchmarkHostPoolUnlimited100k(b *testing.B) { benchmarkHostPool(b, 100000, 0) }
+func BenchmarkHostPoolLimited10k(b *testing.B)    { benchmarkHostPool(b, 10000, 1000) }
+func BenchmarkHostPoolLimited100k(b *testing.B)   { benchmarkHostPool(b, 100000, 1000) }
@@ -0,0 +1,68 @@
+package frontier
+
+import (
+	"sync"
+)
+
+// HostStats holds the counters of what was captured for each host, they
+// are saved with the hosts pool so they are cumulative across the
+// sessions of a job
+type HostStats struct {
+	*sync.Mutex
+	Hosts map[string]*HostCounters
+}
+
+// HostCounters are the capture counters of a single host
+type HostCounters struct {
+	Captured int64 `json:"captured"`
+	Failed   int64 `json:"failed"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// NewHostStats initialize an empty *HostStats
+func NewHostStats() *HostStats {
+	return &HostStats{
+		Mutex: new(sync.Mutex),
+		Hosts: make(map[string]*HostCounters),
+	}
+}
+
+func (stats *HostStats) get(host string) *HostCounters {
+	if _, ok := stats.Hosts[host]; !ok {
+		stats.Hosts[host] = new(HostCounters)
+	}
+	return stats.Hosts[host]
+}
+
+// IncrCaptured increment by 1 the number of URLs captured for an host
+func (stats *HostStats) IncrCaptured(host string) {
+	stats.Lock()
+	stats.get(host).Captured++
+	stats.Unlock()
+}
+
+// IncrFailed increment by 1 the number of URLs that failed for an host
+func (stats *HostStats) IncrFailed(host string) {
+	stats.Lock()
+	stats.get(host).Failed++
+	stats.Unlock()
+}
+
+// AddBytes add n to the number of bytes captured for an host
+func (stats *HostStats) AddBytes(host string, n int64) {
+	stats.Lock()
+	stats.get(host).Bytes += n
+	stats.Unlock()
+}
+
+// Snapshot return a copy of the counters of every host
+func (stats *HostStats) Snapshot() map[string]HostCounters {
+	stats.Lock()
+	snapshot := make(map[string]HostCounters, len(stats.Hosts))
+	for host, counters := range stats.Hosts {
+		snapshot[host] = *counters
+	}
+	stats.Unlock()
+
+	return snapshot
+}
@@ -0,0 +1,85 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/paulbellamy/ratecounter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFrontier(jobPath string) *Frontier {
+	f := new(Frontier)
+	f.JobPath = jobPath
+	f.HostPool = new(HostPool)
+	f.HostPool.Mutex = new(sync.Mutex)
+	f.HostPool.Hosts = make(map[string]*ratecounter.Counter, 0)
+	f.HostStats = NewHostStats()
+
+	return f
+}
+
+func TestHostStatsPersistence(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-host-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	f := newTestFrontier(jobPath)
+	f.HostStats.IncrCaptured("example.com")
+	f.HostStats.IncrCaptured("example.com")
+	f.HostStats.IncrFailed("example.com")
+	f.HostStats.AddBytes("example.com", 1024)
+	f.HostStats.IncrFailed("example.org")
+	f.Save()
+
+	// A new session of the job continues from the saved counters
+	resumed := newTestFrontier(jobPath)
+	assert.NoError(t, resumed.Load())
+	resumed.HostStats.IncrCaptured("example.com")
+
+	stats := resumed.HostStats.Snapshot()
+	assert.Equal(t, HostCounters{Captured: 3, Failed: 1, Bytes: 1024}, stats["example.com"])
+	assert.Equal(t, HostCounters{Failed: 1}, stats["example.org"])
+}
+
+func TestCompressedDump(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-compressed-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	f := newTestFrontier(jobPath)
+	f.CompressDump = true
+	for i := 0; i < 1000; i++ {
+		f.HostPool.Incr("host" + strconv.Itoa(i) + ".example.com")
+	}
+	f.HostStats.IncrCaptured("host1.example.com")
+	f.Save()
+
+	dump, err := ioutil.ReadFile(path.Join(jobPath, "frontier.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, gzipMagic, dump[:2])
+
+	// Compressed dumps are loaded without the flag
+	resumed := newTestFrontier(jobPath)
+	assert.NoError(t, resumed.Load())
+	assert.Len(t, resumed.HostPool.Hosts, 1000)
+	assert.Equal(t, HostCounters{Captured: 1}, resumed.HostStats.Snapshot()["host1.example.com"])
+
+	// An uncompressed dump replaces the compressed one entirely
+	resumed.HostPool.Incr("other.example.com")
+	resumed.Save()
+
+	uncompressed := newTestFrontier(jobPath)
+	assert.NoError(t, uncompressed.Load())
+	assert.Len(t, uncompressed.HostPool.Hosts, 1001)
+}
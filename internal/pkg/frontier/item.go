@@ -2,7 +2,9 @@ package frontier
 
 import (
 	"net/url"
+	"strconv"
 
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/zeebo/xxh3"
 )
 
@@ -15,6 +17,28 @@ type Item struct {
 	Redirect   int
 	URL        *url.URL
 	ParentItem *Item
+
+	// Depth is the number of ancestors of the item, they are all kept
+	// with the item, in memory and when it is written in the queue
+	Depth int
+
+	// Canonical is the URL declared by the page
+	// with <link rel="canonical">, if any
+	Canonical *url.URL
+
+	// PaginationPage is the number of rel="next" links
+	// followed from a page captured normally to reach the item
+	PaginationPage int
+
+	// Method, Body and ContentType describe the request to send for the
+	// seeds that aren't plain GET requests, Method is empty for GET
+	Method      string
+	Body        string
+	ContentType string
+
+	// SeedTreeSession identify the session in which the item was counted
+	// in the pending items of its seed tree by --max-active-seeds
+	SeedTreeSession string
 }
 
 // NewItem initialize an *Item
@@ -25,8 +49,33 @@ func NewItem(URL *url.URL, parentItem *Item, itemType string, hop uint8) *Item {
 	item.Host = URL.Host
 	item.Hop = hop
 	item.ParentItem = parentItem
+	if parentItem != nil {
+		item.Depth = parentItem.Depth + 1
+	}
 	item.Hash = xxh3.HashString(URL.String())
 	item.Type = itemType
 
 	return item
 }
+
+// Seed return the seed at the root of the tree of the item,
+// that is the item itself if it has no parent
+func (item *Item) Seed() *Item {
+	var seed = item
+	for seed.ParentItem != nil {
+		seed = seed.ParentItem
+	}
+
+	return seed
+}
+
+// SeencheckHash return the hash under which the item is stored in the
+// seencheck, if trailing-slash equivalence applies to the item's host then
+// the trailing slash of the URL is ignored, the item's URL is left untouched
+func (f *Frontier) SeencheckHash(item *Item) string {
+	if f.TrailingSlashEquivalence || utils.IsHostExcluded(item.Host, f.TrailingSlashHosts) {
+		return strconv.FormatUint(xxh3.HashString(utils.TrimTrailingSlash(item.URL)), 10)
+	}
+
+	return strconv.FormatUint(item.Hash, 10)
+}
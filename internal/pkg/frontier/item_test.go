@@ -0,0 +1,28 @@
+package frontier
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeencheckHashTrailingSlash(t *testing.T) {
+	withSlash, _ := url.Parse("http://example.com/foo/")
+	withoutSlash, _ := url.Parse("http://example.com/foo")
+	other, _ := url.Parse("http://example.org/foo/")
+	otherWithoutSlash, _ := url.Parse("http://example.org/foo")
+
+	f := new(Frontier)
+	assert.NotEqual(t, f.SeencheckHash(NewItem(withSlash, nil, "seed", 0)), f.SeencheckHash(NewItem(withoutSlash, nil, "seed", 0)))
+
+	f.TrailingSlashHosts = []string{"example.com"}
+	assert.Equal(t, f.SeencheckHash(NewItem(withSlash, nil, "seed", 0)), f.SeencheckHash(NewItem(withoutSlash, nil, "seed", 0)))
+	assert.NotEqual(t, f.SeencheckHash(NewItem(other, nil, "seed", 0)), f.SeencheckHash(NewItem(otherWithoutSlash, nil, "seed", 0)))
+
+	f.TrailingSlashEquivalence = true
+	assert.Equal(t, f.SeencheckHash(NewItem(other, nil, "seed", 0)), f.SeencheckHash(NewItem(otherWithoutSlash, nil, "seed", 0)))
+
+	// The URL to capture is left untouched
+	assert.Equal(t, "http://example.com/foo/", NewItem(withSlash, nil, "seed", 0).URL.String())
+}
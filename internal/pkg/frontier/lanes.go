@@ -0,0 +1,66 @@
+package frontier
+
+import (
+	"errors"
+
+	"github.com/beeker1121/goque"
+)
+
+// seedLanePrefix is prepended to the host to get the prefix of the
+// queue holding the seeds of the host, it can't be part of a host
+const seedLanePrefix = "\x00seed\x00"
+
+// queuePrefix return the prefix of the queue in which an item is enqueued,
+// when PrioritizeSeeds is on the seeds (the items at hop 0) have their own
+// lane, so they aren't stuck behind the items discovered during the crawl
+func (f *Frontier) queuePrefix(item *Item) string {
+	if f.PrioritizeSeeds && item.Hop == 0 {
+		return seedLanePrefix + item.Host
+	}
+
+	return item.Host
+}
+
+// isQueueEmptyError return true if the error means that there is nothing
+// to dequeue with the prefix
+func isQueueEmptyError(err error) bool {
+	return errors.Is(err, goque.ErrEmpty) || errors.Is(err, goque.ErrOutOfBounds)
+}
+
+// dequeue return the next item of a host, its seeds are dequeued before
+// the items discovered during the crawl, or SeedPriorityRatio seeds for
+// each discovered item if the ratio is set, the seeds lane is read even
+// if PrioritizeSeeds is off so the seeds queued by a previous session
+// aren't lost
+func (f *Frontier) dequeue(host string) (queueItem *goque.Item, err error) {
+	lanes := []string{seedLanePrefix + host, host}
+	if f.SeedPriorityRatio > 0 && f.seedsInARow[host] >= f.SeedPriorityRatio {
+		lanes[0], lanes[1] = lanes[1], lanes[0]
+	}
+
+	f.QueueMutex.RLock()
+	defer f.QueueMutex.RUnlock()
+
+	for _, lane := range lanes {
+		queueItem, err = f.Queue.DequeueString(lane)
+		if err != nil {
+			if isQueueEmptyError(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		if f.seedsInARow == nil {
+			f.seedsInARow = make(map[string]int)
+		}
+		if lane == host {
+			delete(f.seedsInARow, host)
+		} else {
+			f.seedsInARow[host]++
+		}
+
+		return queueItem, nil
+	}
+
+	return nil, err
+}
@@ -1,10 +1,11 @@
 package frontier
 
 import (
-	"strconv"
+	"math/rand"
+	"sort"
+	"sync/atomic"
 	"time"
 
-	"github.com/paulbellamy/ratecounter"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,17 +21,11 @@ func (f *Frontier) writeItemsToQueue() {
 
 		// If --seencheck is enabled, then we check if the URI is in the
 		// seencheck DB before doing anything. If it is in it, we skip the item
-		if f.UseSeencheck {
-			hash := strconv.FormatUint(item.Hash, 10)
-			found, value, err := f.Seencheck.IsSeen(hash)
-			if err != nil {
-				f.Seencheck.Seen(hash, item.Type)
-			}
-			if !found || (value == "asset" && item.Type == "seed") {
-				f.Seencheck.Seen(hash, item.Type)
-			} else {
-				continue
+		if f.UseSeencheck && !f.checkSeen(item) {
+			if f.Dropped != nil {
+				f.Dropped(item)
 			}
+			continue
 		}
 
 		// Increment the counter of the host in the hosts pool,
@@ -38,12 +33,17 @@ func (f *Frontier) writeItemsToQueue() {
 		f.HostPool.Incr(item.Host)
 
 		// Add the item to the host's queue
-		_, err := f.Queue.EnqueueObject([]byte(item.Host), item)
+		f.QueueMutex.RLock()
+		_, err := f.Queue.EnqueueObject([]byte(f.queuePrefix(item)), item)
+		f.QueueMutex.RUnlock()
 		if err != nil {
 			logWarning.WithFields(logrus.Fields{
 				"error": err,
 				"item":  item,
 			}).Error("Unable to enqueue item")
+			if f.Dropped != nil {
+				f.Dropped(item)
+			}
 		}
 		f.QueueCount.Incr(1)
 
@@ -58,9 +58,86 @@ func (f *Frontier) writeItemsToQueue() {
 	}
 }
 
-func (f *Frontier) readItemsFromQueue() {
-	var mapCopy map[string]*ratecounter.Counter
+// Requeue put back in the queue an item that was dequeued but won't be
+// captured in this session, without the seencheck that already saw it
+func (f *Frontier) Requeue(item *Item) error {
+	f.QueueMutex.RLock()
+	_, err := f.Queue.EnqueueObject([]byte(f.queuePrefix(item)), item)
+	f.QueueMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	f.HostPool.Incr(item.Host)
+	f.QueueCount.Incr(1)
+
+	return nil
+}
+
+// hostsSelectionOrder return a snapshot of the hosts of the hosts pool, in
+// the order in which they are dequeued from, the order is shuffled if
+// RandomHostSelection is turned on so the workers spread across the hosts
+func (f *Frontier) hostsSelectionOrder() (hosts []string) {
+	f.HostPool.Lock()
+	for host := range f.HostPool.Hosts {
+		hosts = append(hosts, host)
+	}
+	f.HostPool.Unlock()
+
+	if f.RandomHostSelection {
+		rand.Shuffle(len(hosts), func(i, j int) {
+			hosts[i], hosts[j] = hosts[j], hosts[i]
+		})
+	}
+
+	return hosts
+}
 
+// setRound record the hosts the queue reader is about to go through
+func (f *Frontier) setRound(hosts []string) {
+	f.roundMutex.Lock()
+	f.round = hosts
+	f.roundPosition = 0
+	f.roundMutex.Unlock()
+}
+
+// setRoundPosition record the index of the host the queue reader dequeues from
+func (f *Frontier) setRoundPosition(position int) {
+	f.roundMutex.Lock()
+	f.roundPosition = position
+	f.roundMutex.Unlock()
+}
+
+// UpcomingHosts return up to limit hosts with items queued, in the order
+// in which the queue reader is going to dequeue from them: the rest of
+// its current round first, then the hosts it already went through, that
+// come again in the next round. Before the first round, the order isn't
+// known yet, the hosts are then sorted. A limit of 0 or less means no limit.
+func (f *Frontier) UpcomingHosts(limit int) (hosts []string) {
+	f.roundMutex.Lock()
+	round, position := f.round, f.roundPosition
+	f.roundMutex.Unlock()
+
+	if len(round) == 0 {
+		round, position = f.hostsSelectionOrder(), 0
+		sort.Strings(round)
+	}
+
+	for i := 0; i < len(round); i++ {
+		if limit > 0 && len(hosts) >= limit {
+			break
+		}
+
+		host := round[(position+i)%len(round)]
+		if f.HostPool.GetCount(host) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+func (f *Frontier) readItemsFromQueue() {
 	f.IsQueueReaderActive.Set(true)
 
 	if f.QueueCount.Value() == 0 {
@@ -77,18 +154,16 @@ func (f *Frontier) readItemsFromQueue() {
 		// we make a snapshot of the hosts
 		// pool that we will iterate on
 		f.HostPool.DeleteEmptyHosts()
-		mapCopy = make(map[string]*ratecounter.Counter, 0)
-		f.HostPool.Lock()
-		for key, val := range f.HostPool.Hosts {
-			mapCopy[key] = val
-		}
-		f.HostPool.Unlock()
+		hosts := f.hostsSelectionOrder()
+		f.setRound(hosts)
 
 		// We iterate over the copied pool, and dequeue
 		// new URLs to crawl based on that hosts pool
 		// that allow us to crawl a wide variety of domains
 		// at the same time, maximizing our speed
-		for host := range mapCopy {
+		for i, host := range hosts {
+			f.setRoundPosition(i)
+
 			if f.Paused.Get() {
 				time.Sleep(time.Second)
 			}
@@ -98,18 +173,18 @@ func (f *Frontier) readItemsFromQueue() {
 			}
 
 			// Dequeue an item from the local queue
-			queueItem, err := f.Queue.DequeueString(host)
+			queueItem, err := f.dequeue(host)
 			if err != nil {
 				logWarning.WithFields(logrus.Fields{
 					"error": err,
 				}).Debug("Unable to dequeue item")
-				if err.Error() == "goque: Stack or queue is empty" ||
-					err.Error() == "goque: ID used is outside range of stack or queue" {
+				if isQueueEmptyError(err) {
 					f.HostPool.Decr(host)
 				}
 				continue
 			}
 			f.QueueCount.Incr(-1)
+			atomic.AddInt64(&f.dequeuedSinceCompaction, 1)
 
 			// Turn the item from the queue into an Item
 			var item *Item
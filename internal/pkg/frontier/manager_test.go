@@ -0,0 +1,174 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/Zeno/internal/pkg/utils"
+	"github.com/paulbellamy/ratecounter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostsSelectionOrderRandom(t *testing.T) {
+	f := newTestFrontier("")
+	for i := 0; i < 20; i++ {
+		f.HostPool.Incr("host-" + strconv.Itoa(i) + ".com")
+	}
+
+	// Over many rounds, every host is selected first at least once
+	f.RandomHostSelection = true
+	var firsts = make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		hosts := f.hostsSelectionOrder()
+		assert.Len(t, hosts, 20)
+		firsts[hosts[0]] = true
+	}
+
+	assert.Len(t, firsts, 20)
+}
+
+func TestReadItemsFromQueueRandomHostSelection(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-host-selection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	f := newTestFrontier(jobPath)
+	f.RandomHostSelection = true
+	f.Paused = new(utils.TAtomBool)
+	f.FinishingQueueReader = new(utils.TAtomBool)
+	f.IsQueueReaderActive = new(utils.TAtomBool)
+	f.QueueCount = new(ratecounter.Counter)
+	f.PullChan = make(chan *Item)
+	f.Queue, err = newPersistentQueue(jobPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Queue.Close()
+
+	const hostsCount, itemsPerHost = 5, 10
+	for i := 0; i < hostsCount; i++ {
+		host := "host-" + strconv.Itoa(i) + ".com"
+		for j := 0; j < itemsPerHost; j++ {
+			URL, _ := url.Parse("https://" + host + "/" + strconv.Itoa(j))
+			_, err = f.Queue.EnqueueObject([]byte(host), NewItem(URL, nil, "seed", 0))
+			assert.NoError(t, err)
+			f.HostPool.Incr(host)
+			f.QueueCount.Incr(1)
+		}
+	}
+
+	go f.readItemsFromQueue()
+
+	var hosts []string
+	for i := 0; i < hostsCount*itemsPerHost; i++ {
+		hosts = append(hosts, (<-f.PullChan).Host)
+	}
+
+	f.FinishingQueueReader.Set(true)
+	for f.IsQueueReaderActive.Get() {
+		select {
+		case <-f.PullChan:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Every round dequeues one item of each host, but the rounds
+	// don't all start with the same host
+	var firsts = make(map[string]bool)
+	for round := 0; round < itemsPerHost; round++ {
+		var roundHosts = make(map[string]bool)
+		for _, host := range hosts[round*hostsCount : (round+1)*hostsCount] {
+			roundHosts[host] = true
+		}
+		assert.Len(t, roundHosts, hostsCount)
+		firsts[hosts[round*hostsCount]] = true
+	}
+	assert.True(t, len(firsts) > 1)
+}
+
+func TestDequeueSeedsFirst(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-seed-lane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	logWarning = logrus.New()
+
+	f := newTestFrontier(jobPath)
+	f.PrioritizeSeeds = true
+	f.Queue, err = newPersistentQueue(jobPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Queue.Close()
+
+	enqueue := func(path string, hop uint8) {
+		URL, _ := url.Parse("https://example.com/" + path)
+		item := NewItem(URL, nil, "seed", hop)
+		_, err := f.Queue.EnqueueObject([]byte(f.queuePrefix(item)), item)
+		assert.NoError(t, err)
+	}
+
+	dequeueAll := func() (paths []string) {
+		for {
+			queueItem, err := f.dequeue("example.com")
+			if err != nil {
+				assert.True(t, isQueueEmptyError(err))
+				return paths
+			}
+
+			var item *Item
+			assert.NoError(t, queueItem.ToObject(&item))
+			paths = append(paths, item.URL.Path)
+		}
+	}
+
+	// A backlog of discovered URLs is queued before new seeds
+	for _, path := range []string{"d1", "d2", "d3"} {
+		enqueue(path, 1)
+	}
+	for _, path := range []string{"s1", "s2", "s3", "s4"} {
+		enqueue(path, 0)
+	}
+	assert.Equal(t, []string{"/s1", "/s2", "/s3", "/s4", "/d1", "/d2", "/d3"}, dequeueAll())
+
+	// With a ratio, the discovered URLs still progress
+	f.SeedPriorityRatio = 2
+	for _, path := range []string{"d1", "d2", "d3"} {
+		enqueue(path, 1)
+	}
+	for _, path := range []string{"s1", "s2", "s3", "s4"} {
+		enqueue(path, 0)
+	}
+	assert.Equal(t, []string{"/s1", "/s2", "/d1", "/s3", "/s4", "/d2", "/d3"}, dequeueAll())
+}
+
+func TestUpcomingHosts(t *testing.T) {
+	f := newTestFrontier("")
+	for i := 0; i < 5; i++ {
+		f.HostPool.Incr("host-" + strconv.Itoa(i) + ".com")
+	}
+
+	// Before the queue reader started, the hosts are sorted
+	assert.Equal(t, []string{"host-0.com", "host-1.com", "host-2.com"}, f.UpcomingHosts(3))
+
+	// In the middle of a round, the rest of the round comes first, and the
+	// hosts without items queued are skipped
+	f.setRound([]string{"host-0.com", "host-1.com", "host-2.com", "host-3.com", "host-4.com"})
+	f.setRoundPosition(3)
+	f.HostPool.Decr("host-4.com")
+
+	assert.Equal(t, []string{"host-3.com", "host-0.com", "host-1.com", "host-2.com"}, f.UpcomingHosts(0))
+	assert.Equal(t, []string{"host-3.com", "host-0.com"}, f.UpcomingHosts(2))
+}
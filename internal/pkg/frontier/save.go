@@ -1,71 +1,144 @@
 package frontier
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/gob"
+	"errors"
+	"io"
 	"os"
 	"path"
+	"strconv"
 
 	"github.com/paulbellamy/ratecounter"
 	"github.com/sirupsen/logrus"
 )
 
+// gzipMagic is the first bytes of gzip streams, used to recognize
+// compressed dumps regardless of the --compress-frontier-dump setting
+var gzipMagic = []byte{0x1f, 0x8b}
+
 type frontierStats struct {
+	Version     int
 	Hosts       map[string]*ratecounter.Counter
 	QueuedCount int64
+	HostStats   map[string]HostCounters
 }
 
 // Load take the path to the frontier's hosts pool and status dump
 // it decodes that file and load it in the job's frontier
-func (f *Frontier) Load() {
+func (f *Frontier) Load() error {
 	// Open a RO file
 	decodeFile, err := os.OpenFile(path.Join(f.JobPath, "frontier.gob"), os.O_RDONLY, 0644)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"error": err,
 		}).Warning("Unable to load Frontier stats and host pool, it is not a problem if you are starting this job for the first time")
-		return
+		return nil
 	}
 	defer decodeFile.Close()
 
+	// The dump may have been compressed, by this session
+	// or by a previous session of the job
+	var reader io.Reader = bufio.NewReader(decodeFile)
+	if magic, _ := reader.(*bufio.Reader).Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return errors.New("unable to decompress the frontier's hosts pool dump: " + err.Error())
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
 	// Create a decoder
-	decoder := gob.NewDecoder(decodeFile)
+	decoder := gob.NewDecoder(reader)
 
 	// We create the structure to load the file's content
 	var dump = new(frontierStats)
 	dump.Hosts = make(map[string]*ratecounter.Counter, 0)
 
 	// Decode the content of the file in the structure
-	decoder.Decode(&dump)
+	err = decoder.Decode(&dump)
+	if err == io.EOF {
+		logrus.Warning("Frontier stats and host pool dump is empty, ignoring it")
+		return nil
+	}
+	if err != nil {
+		return errors.New("unable to decode the frontier's hosts pool dump, it may have been written " +
+			"by an incompatible version of Zeno: " + err.Error())
+	}
+
+	// Dumps written before the versioning was introduced have a version of 0,
+	// their format is the same as version 1
+	if dump.Version > queueVersion {
+		return errors.New("the frontier's hosts pool dump was written by a more recent version of Zeno (version " +
+			strconv.Itoa(dump.Version) + ", supported version " + strconv.Itoa(queueVersion) +
+			"), please upgrade Zeno to resume this job")
+	}
 
 	// Copy the loaded data to our actual frontier
-	f.HostPool.Hosts = dump.Hosts
+	f.HostPool.AddHosts(dump.Hosts)
+
+	// Dumps written by older versions of Zeno don't have hosts statistics
+	f.HostStats.Lock()
+	for host, counters := range dump.HostStats {
+		counters := counters
+		f.HostStats.Hosts[host] = &counters
+	}
+	f.HostStats.Unlock()
 
 	logrus.WithFields(logrus.Fields{
 		"hosts": len(f.HostPool.Hosts),
 	}).Info("Successfully loaded previous frontier's hosts pool")
+
+	return nil
 }
 
 // Save write the in-memory hosts pool to resume properly the next time the job is loaded
 func (f *Frontier) Save() {
+	f.saveMutex.Lock()
+	defer f.saveMutex.Unlock()
+
 	// Create a file for IO
-	encodeFile, err := os.OpenFile(path.Join(f.JobPath, "frontier.gob"), os.O_CREATE|os.O_WRONLY, 0644)
+	encodeFile, err := os.OpenFile(path.Join(f.JobPath, "frontier.gob"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		logrus.Warning(err)
+		return
 	}
 	defer encodeFile.Close()
 
+	// If asked, the dump is compressed, it is a lot smaller
+	// for large hosts pools as the host names compress well
+	var writer io.Writer = encodeFile
+	var gzipWriter *gzip.Writer
+	if f.CompressDump {
+		gzipWriter = gzip.NewWriter(encodeFile)
+		writer = gzipWriter
+	}
+
 	// We create the structure to save to the file,
 	// it's a copy of the hosts pool and the count
 	// of the queued items
 	var dump = new(frontierStats)
+	dump.Version = queueVersion
 	dump.Hosts = make(map[string]*ratecounter.Counter, 0)
+	dump.HostStats = f.HostStats.Snapshot()
 
 	f.HostPool.Lock()
 	dump.Hosts = f.HostPool.Hosts
 	// Write to the file
-	var encoder = gob.NewEncoder(encodeFile)
+	var encoder = gob.NewEncoder(writer)
 	if err := encoder.Encode(dump); err != nil {
 		logrus.Warning(err)
 	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			logrus.Warning(err)
+		}
+	}
+	if err := encodeFile.Sync(); err != nil {
+		logrus.Warning(err)
+	}
 	f.HostPool.Unlock()
 }
@@ -1,18 +1,138 @@
 package frontier
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/dgraph-io/badger/v3"
 	"github.com/paulbellamy/ratecounter"
+	"github.com/sirupsen/logrus"
 )
 
-// Seencheck holds the Seencheck database and the seen counter
-type Seencheck struct {
+// Seencheck is implemented by the seencheck backends, it keeps track
+// of the hashes of the URIs that have already been seen, with the type
+// of the item that was seen for each hash
+type Seencheck interface {
+	// IsSeen check if the hash is in the seencheck
+	IsSeen(hash string) (found bool, value string, err error)
+	// Seen mark a hash as seen and increment the seen counter
+	Seen(hash, value string) error
+	// Count return the number of hashes marked as seen during the crawl
+	Count() int64
+	// Sync flush the seencheck to disk if the backend has something to flush
+	Sync() error
+	// Close release the resources used by the backend
+	Close() error
+}
+
+// checkSeen mark an item as seen and return true if it has to be enqueued,
+// an item already seen is only enqueued again if it was seen as an asset
+// and is now a seed, if RequeueShallowerHops is turned on and it was
+// seen at a deeper hop, so that hop-limited crawls follow its outlinks, or
+// if it was seen longer than SeencheckWindow ago, so it is captured again
+func (f *Frontier) checkSeen(item *Item) bool {
+	hash := f.SeencheckHash(item)
+	found, value, err := f.Seencheck.IsSeen(hash)
+	if err != nil {
+		f.Seencheck.Seen(hash, f.seencheckValue(item))
+	}
+
+	seenType, seenHop, seenTime := parseSeencheckValue(value)
+	if !found || (seenType == "asset" && item.Type == "seed") {
+		f.Seencheck.Seen(hash, f.seencheckValue(item))
+		return true
+	}
+
+	if f.SeencheckWindow > 0 && !seenTime.IsZero() && time.Since(seenTime) >= f.SeencheckWindow {
+		logInfo.WithFields(logrus.Fields{
+			"url":       item.URL.String(),
+			"seen_time": seenTime,
+		}).Debug("URL seen longer than the seencheck window ago, enqueuing it again")
+		f.Seencheck.Seen(hash, f.seencheckValue(item))
+		return true
+	}
+
+	if f.RequeueShallowerHops && item.Type == "seed" && seenType == "seed" && seenHop > int(item.Hop) {
+		logInfo.WithFields(logrus.Fields{
+			"url":      item.URL.String(),
+			"hop":      item.Hop,
+			"seen_hop": seenHop,
+		}).Debug("URL seen at a deeper hop, enqueuing it again")
+		f.Seencheck.Seen(hash, f.seencheckValue(item))
+		return true
+	}
+
+	return false
+}
+
+// CheckSeen mark an item captured without going through the queue, like
+// an asset, as seen and return true if it has to be captured, with the
+// same rules as the items pushed to the frontier
+func (f *Frontier) CheckSeen(item *Item) bool {
+	return f.checkSeen(item)
+}
+
+// seencheckValue return the value stored in the seencheck for an item,
+// that is its type, followed by its hop if RequeueShallowerHops is on,
+// and by the Unix time at which it is seen if SeencheckWindow is set,
+// like seed:2@1600000000
+func (f *Frontier) seencheckValue(item *Item) string {
+	value := item.Type
+	if f.RequeueShallowerHops && item.Type == "seed" {
+		value += ":" + strconv.Itoa(int(item.Hop))
+	}
+
+	if f.SeencheckWindow > 0 {
+		value += "@" + strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
+	return value
+}
+
+// parseSeencheckValue return the type, the hop and the time stored in a
+// seencheck value, the hop is -1 and the time is zero if they weren't stored
+func parseSeencheckValue(value string) (itemType string, hop int, seen time.Time) {
+	if separator := strings.LastIndex(value, "@"); separator != -1 {
+		seconds, err := strconv.ParseInt(value[separator+1:], 10, 64)
+		if err == nil {
+			value, seen = value[:separator], time.Unix(seconds, 0)
+		}
+	}
+
+	separator := strings.LastIndex(value, ":")
+	if separator == -1 {
+		return value, -1, seen
+	}
+
+	hop, err := strconv.Atoi(value[separator+1:])
+	if err != nil {
+		return value, -1, seen
+	}
+
+	return value[:separator], hop, seen
+}
+
+// LocalSeencheck is a seencheck stored in a local badger database
+type LocalSeencheck struct {
 	SeenCount *ratecounter.Counter
 	SeenDB    *badger.DB
 }
 
+// NewLocalSeencheck open the badger database located at path as a seencheck
+func NewLocalSeencheck(path string, syncWrites bool) (seencheck *LocalSeencheck, err error) {
+	seencheck = new(LocalSeencheck)
+	seencheck.SeenCount = new(ratecounter.Counter)
+	seencheck.SeenDB, err = badger.Open(badger.DefaultOptions(path).WithSyncWrites(syncWrites))
+	if err != nil {
+		return nil, err
+	}
+
+	return seencheck, nil
+}
+
 // IsSeen check if the hash is in the seencheck database
-func (seencheck *Seencheck) IsSeen(hash string) (found bool, value string, err error) {
+func (seencheck *LocalSeencheck) IsSeen(hash string) (found bool, value string, err error) {
 	var item *badger.Item
 
 	err = seencheck.SeenDB.View(func(txn *badger.Txn) error {
@@ -38,7 +158,7 @@ func (seencheck *Seencheck) IsSeen(hash string) (found bool, value string, err e
 }
 
 // Seen mark a hash as seen and increment the seen counter
-func (seencheck *Seencheck) Seen(hash, value string) error {
+func (seencheck *LocalSeencheck) Seen(hash, value string) error {
 	err := seencheck.SeenDB.Update(func(txn *badger.Txn) error {
 		err := txn.Set([]byte(hash), []byte(value))
 		return err
@@ -49,3 +169,18 @@ func (seencheck *Seencheck) Seen(hash, value string) error {
 	seencheck.SeenCount.Incr(1)
 	return nil
 }
+
+// Count return the number of hashes marked as seen
+func (seencheck *LocalSeencheck) Count() int64 {
+	return seencheck.SeenCount.Value()
+}
+
+// Sync fsync the seencheck database to disk
+func (seencheck *LocalSeencheck) Sync() error {
+	return seencheck.SeenDB.Sync()
+}
+
+// Close close the seencheck database
+func (seencheck *LocalSeencheck) Close() error {
+	return seencheck.SeenDB.Close()
+}
@@ -0,0 +1,211 @@
+package frontier
+
+import (
+	"encoding/gob"
+	"hash/fnv"
+	"math"
+	"os"
+	"path"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// bloomFilter is a bloom filter safe for concurrent use, it answers whether
+// a value was possibly added, or definitely not added
+type bloomFilter struct {
+	Bits   []uint64
+	Size   uint64
+	Hashes uint64
+}
+
+// newBloomFilter return a bloom filter sized to hold capacity values with
+// a false positive rate of falsePositiveRate once it is full
+func newBloomFilter(capacity int64, falsePositiveRate float64) *bloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	size := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Round(size/float64(capacity)*math.Ln2))
+
+	return &bloomFilter{
+		Bits:   make([]uint64, (uint64(size)+63)/64),
+		Size:   uint64(size),
+		Hashes: uint64(hashes),
+	}
+}
+
+// positions return the bits of a value, using double hashing
+// to derive all the hashes from the two halves of a FNV hash
+func (filter *bloomFilter) positions(value string, walkFn func(word, mask uint64) bool) bool {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	sum := hash.Sum64()
+	low, high := sum&0xffffffff, sum>>32|1
+
+	for i := uint64(0); i < filter.Hashes; i++ {
+		bit := (low + i*high) % filter.Size
+		if !walkFn(bit/64, 1<<(bit%64)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add add a value to the filter
+func (filter *bloomFilter) add(value string) {
+	filter.positions(value, func(word, mask uint64) bool {
+		for {
+			previous := atomic.LoadUint64(&filter.Bits[word])
+			if previous&mask != 0 || atomic.CompareAndSwapUint64(&filter.Bits[word], previous, previous|mask) {
+				return true
+			}
+		}
+	})
+}
+
+// test return false if the value was definitely not added to the filter
+func (filter *bloomFilter) test(value string) bool {
+	return filter.positions(value, func(word, mask uint64) bool {
+		return atomic.LoadUint64(&filter.Bits[word])&mask != 0
+	})
+}
+
+// BloomSeencheck is a bloom filter in front of a seencheck: the hashes
+// that the filter has definitely never seen are new, so they don't need
+// to be looked up in the seencheck, only the hashes possibly seen are
+type BloomSeencheck struct {
+	Seencheck
+	filter *bloomFilter
+	path   string
+}
+
+// IsSeen check if the hash is in the seencheck, if the bloom filter
+// says that it was possibly seen
+func (seencheck *BloomSeencheck) IsSeen(hash string) (found bool, value string, err error) {
+	if !seencheck.filter.test(hash) {
+		return false, "", nil
+	}
+
+	return seencheck.Seencheck.IsSeen(hash)
+}
+
+// Seen add the hash to the bloom filter and mark it as seen in the seencheck
+func (seencheck *BloomSeencheck) Seen(hash, value string) error {
+	seencheck.filter.add(hash)
+	return seencheck.Seencheck.Seen(hash, value)
+}
+
+// Close save the bloom filter so it can be loaded when the job is
+// resumed, and close the seencheck
+func (seencheck *BloomSeencheck) Close() error {
+	file, err := os.Create(seencheck.path)
+	if err != nil {
+		seencheck.Seencheck.Close()
+		return err
+	}
+
+	err = gob.NewEncoder(file).Encode(seencheck.filter)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(seencheck.path)
+		seencheck.Seencheck.Close()
+		return err
+	}
+
+	return seencheck.Seencheck.Close()
+}
+
+// addHashesTo add all the hashes of the database to a bloom filter,
+// and return the number of hashes added
+func (seencheck *LocalSeencheck) addHashesTo(filter *bloomFilter) (count int64, err error) {
+	err = seencheck.SeenDB.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+
+		iterator := txn.NewIterator(options)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			filter.add(string(iterator.Item().Key()))
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// withBloomFilter put a bloom filter of BloomCapacity hashes in front of
+// the local seencheck. The filter of the previous session is loaded if
+// there is one, it is deleted once loaded and saved again when the
+// seencheck is closed, so a filter is only loaded if Zeno exited cleanly.
+// Otherwise, the filter is rebuilt from the hashes of the seencheck.
+func (f *Frontier) withBloomFilter(seencheck Seencheck) Seencheck {
+	local, ok := seencheck.(*LocalSeencheck)
+	if !ok {
+		// The Redis seencheck may be shared by several instances, the
+		// hashes seen by the others would be missing from the filter
+		logWarning.Warning("The bloom filter is only supported with the local seencheck, it is disabled")
+		return seencheck
+	}
+
+	var bloomPath = path.Join(f.JobPath, "seencheck.bloom")
+	var filter *bloomFilter
+
+	file, err := os.Open(bloomPath)
+	if err == nil {
+		filter = new(bloomFilter)
+		err = gob.NewDecoder(file).Decode(filter)
+		file.Close()
+		os.Remove(bloomPath)
+
+		if err != nil || filter.Size == 0 || uint64(len(filter.Bits)) != (filter.Size+63)/64 {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning("Unable to load the bloom filter of the seencheck, it is rebuilt from the seencheck")
+			filter = nil
+		}
+	}
+
+	// Without a saved filter, probably because Zeno didn't exit cleanly,
+	// the hashes already in the seencheck are added to a new filter
+	if filter == nil {
+		filter = newBloomFilter(f.BloomCapacity, f.BloomFPRate)
+
+		count, err := local.addHashesTo(filter)
+		if err != nil {
+			logWarning.WithFields(logrus.Fields{
+				"error": err,
+			}).Warning("Unable to rebuild the bloom filter of the seencheck, it is disabled for this session")
+			return seencheck
+		}
+
+		if count > 0 {
+			logInfo.WithFields(logrus.Fields{
+				"hashes": count,
+			}).Info("Bloom filter of the seencheck rebuilt from the seencheck")
+		}
+	}
+
+	logInfo.WithFields(logrus.Fields{
+		"size_mb": len(filter.Bits) * 8 / (1024 * 1024),
+		"hashes":  filter.Hashes,
+	}).Info("Bloom filter of the seencheck initialized")
+
+	return &BloomSeencheck{
+		Seencheck: seencheck,
+		filter:    filter,
+		path:      bloomPath,
+	}
+}
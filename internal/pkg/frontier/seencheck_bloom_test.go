@@ -0,0 +1,140 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingSeencheck count the lookups made in a seencheck
+type countingSeencheck struct {
+	memorySeencheck
+	lookups int
+}
+
+func (seencheck *countingSeencheck) IsSeen(hash string) (bool, string, error) {
+	seencheck.lookups++
+	return seencheck.memorySeencheck.IsSeen(hash)
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	for _, rate := range []float64{0.01, 0.001} {
+		filter := newBloomFilter(100000, rate)
+
+		for i := 0; i < 100000; i++ {
+			filter.add("seen-" + strconv.Itoa(i))
+		}
+
+		// No false negatives
+		for i := 0; i < 100000; i++ {
+			if !filter.test("seen-" + strconv.Itoa(i)) {
+				t.Fatalf("false negative for seen-%d", i)
+			}
+		}
+
+		var falsePositives int
+		for i := 0; i < 100000; i++ {
+			if filter.test("unseen-" + strconv.Itoa(i)) {
+				falsePositives++
+			}
+		}
+
+		assert.Less(t, float64(falsePositives)/100000, rate*1.5, "false positive rate of %f", rate)
+	}
+}
+
+func TestBloomSeencheckSkipsLookups(t *testing.T) {
+	backend := &countingSeencheck{memorySeencheck: memorySeencheck{}}
+	seencheck := &BloomSeencheck{
+		Seencheck: backend,
+		filter:    newBloomFilter(1000, 0.01),
+	}
+
+	// The hashes never seen aren't looked up
+	found, _, err := seencheck.IsSeen("1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 0, backend.lookups)
+
+	// The hashes seen are
+	assert.NoError(t, seencheck.Seen("1", "seed"))
+	found, value, err := seencheck.IsSeen("1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "seed", value)
+	assert.Equal(t, 1, backend.lookups)
+	assert.Equal(t, int64(1), seencheck.Count())
+}
+
+func TestBloomSeencheckResume(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-bloom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	logInfo = logrus.New()
+	logWarning = logrus.New()
+
+	f := newTestFrontier(jobPath)
+	f.BloomCapacity = 1000
+	f.BloomFPRate = 0.01
+
+	open := func() Seencheck {
+		local, err := NewLocalSeencheck(path.Join(jobPath, "seencheck"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.withBloomFilter(local)
+	}
+
+	// The filter is saved when the seencheck is closed, and loaded again
+	seencheck := open()
+	if assert.IsType(t, &BloomSeencheck{}, seencheck) {
+		assert.NoError(t, seencheck.Seen("1", "seed"))
+	}
+	assert.NoError(t, seencheck.Close())
+	assert.FileExists(t, path.Join(jobPath, "seencheck.bloom"))
+
+	seencheck = open()
+	if assert.IsType(t, &BloomSeencheck{}, seencheck) {
+		assert.True(t, seencheck.(*BloomSeencheck).filter.test("1"))
+		found, _, err := seencheck.IsSeen("1")
+		assert.NoError(t, err)
+		assert.True(t, found)
+	}
+
+	// Without a clean exit, the filter isn't saved: it is rebuilt
+	// from the hashes of the seencheck
+	assert.NoError(t, seencheck.Seen("2", "asset"))
+	seencheck.(*BloomSeencheck).Seencheck.Close()
+	assert.NoFileExists(t, path.Join(jobPath, "seencheck.bloom"))
+
+	seencheck = open()
+	if assert.IsType(t, &BloomSeencheck{}, seencheck) {
+		filter := seencheck.(*BloomSeencheck).filter
+		assert.True(t, filter.test("1"))
+		assert.True(t, filter.test("2"))
+		assert.False(t, filter.test("3"))
+
+		found, value, err := seencheck.IsSeen("2")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "asset", value)
+	}
+	seencheck.Close()
+
+	// A corrupted filter is rebuilt too
+	ioutil.WriteFile(path.Join(jobPath, "seencheck.bloom"), []byte("corrupted"), 0644)
+
+	seencheck = open()
+	if assert.IsType(t, &BloomSeencheck{}, seencheck) {
+		assert.True(t, seencheck.(*BloomSeencheck).filter.test("2"))
+	}
+	seencheck.(*BloomSeencheck).Seencheck.Close()
+}
@@ -0,0 +1,255 @@
+package frontier
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulbellamy/ratecounter"
+)
+
+// redisSeencheckValues are the types of item that can be marked as seen,
+// each of them is stored in its own Redis set
+var redisSeencheckValues = []string{"seed", "asset"}
+
+// redisHopsSuffix is appended to the key to get the Redis hash holding
+// the hop at which the hashes were seen, when the value has one
+const redisHopsSuffix = ":hops"
+
+// redisSeenTimesSuffix is appended to the key to get the Redis hash holding
+// the Unix time at which the hashes were seen, when the value has one
+const redisSeenTimesSuffix = ":seen"
+
+// RedisSeencheck is a seencheck stored in Redis sets keyed by item type,
+// and in Redis hashes for the hops and the times at which the hashes were
+// seen, it makes possible for several Zeno instances to share the same
+// seencheck
+type RedisSeencheck struct {
+	SeenCount *ratecounter.Counter
+	Addr      string
+	Key       string
+
+	conns chan *redisConn
+}
+
+// NewRedisSeencheck connect to the Redis server at addr and return a
+// seencheck storing the hashes in sets prefixed by key
+func NewRedisSeencheck(addr, key string) (seencheck *RedisSeencheck, err error) {
+	seencheck = new(RedisSeencheck)
+	seencheck.SeenCount = new(ratecounter.Counter)
+	seencheck.Addr = addr
+	seencheck.Key = key
+	seencheck.conns = make(chan *redisConn, 16)
+
+	// Make sure that the server is reachable before starting the crawl
+	_, err = seencheck.do("PING")
+	if err != nil {
+		return nil, err
+	}
+
+	return seencheck, nil
+}
+
+// IsSeen check if the hash is in one of the Redis sets, the value is the
+// type of the set, followed by the hop and the time if they were stored
+func (seencheck *RedisSeencheck) IsSeen(hash string) (found bool, value string, err error) {
+	for _, itemType := range redisSeencheckValues {
+		reply, err := seencheck.do("SISMEMBER", seencheck.Key+":"+itemType, hash)
+		if err != nil {
+			return false, "", err
+		}
+
+		if reply.integer != 1 {
+			continue
+		}
+
+		value = itemType
+
+		reply, err = seencheck.do("HGET", seencheck.Key+redisHopsSuffix+":"+itemType, hash)
+		if err != nil {
+			return false, "", err
+		}
+		if !reply.null {
+			value += ":" + reply.bulk
+		}
+
+		reply, err = seencheck.do("HGET", seencheck.Key+redisSeenTimesSuffix+":"+itemType, hash)
+		if err != nil {
+			return false, "", err
+		}
+		if !reply.null {
+			value += "@" + reply.bulk
+		}
+
+		return true, value, nil
+	}
+
+	return false, "", nil
+}
+
+// Seen add the hash to the Redis set of its type, store its hop and its
+// time in the Redis hashes of the hops and of the times if the value has
+// them, and increment the seen counter
+func (seencheck *RedisSeencheck) Seen(hash, value string) error {
+	itemType, hop, seen := parseSeencheckValue(value)
+
+	// The hop and the time are stored first, so the instances sharing
+	// the seencheck never find the hash without them
+	if hop >= 0 {
+		_, err := seencheck.do("HSET", seencheck.Key+redisHopsSuffix+":"+itemType, hash, strconv.Itoa(hop))
+		if err != nil {
+			return err
+		}
+	}
+
+	if !seen.IsZero() {
+		_, err := seencheck.do("HSET", seencheck.Key+redisSeenTimesSuffix+":"+itemType, hash, strconv.FormatInt(seen.Unix(), 10))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := seencheck.do("SADD", seencheck.Key+":"+itemType, hash)
+	if err != nil {
+		return err
+	}
+	seencheck.SeenCount.Incr(1)
+	return nil
+}
+
+// Count return the number of hashes marked as seen by this instance
+func (seencheck *RedisSeencheck) Count() int64 {
+	return seencheck.SeenCount.Value()
+}
+
+// Sync is a no-op, persisting the data is up to the Redis server
+func (seencheck *RedisSeencheck) Sync() error {
+	return nil
+}
+
+// Close close all the idle connections to the Redis server
+func (seencheck *RedisSeencheck) Close() error {
+	for {
+		select {
+		case conn := <-seencheck.conns:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// redisReply is the reply of Redis to a command, either an
+// integer, a bulk string, or a null bulk string
+type redisReply struct {
+	integer int64
+	bulk    string
+	null    bool
+}
+
+// do execute a command on an idle connection, or on a new one if
+// they are all busy, and return its reply
+func (seencheck *RedisSeencheck) do(args ...string) (reply redisReply, err error) {
+	var conn *redisConn
+
+	select {
+	case conn = <-seencheck.conns:
+	default:
+		conn, err = dialRedis(seencheck.Addr)
+		if err != nil {
+			return reply, err
+		}
+	}
+
+	reply, err = conn.do(args...)
+	if err != nil {
+		// The state of the connection is unknown, so we don't reuse it
+		conn.Close()
+		return reply, err
+	}
+
+	select {
+	case seencheck.conns <- conn:
+	default:
+		conn.Close()
+	}
+
+	return reply, nil
+}
+
+// redisConn is a connection to a Redis server speaking the RESP protocol
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func dialRedis(addr string) (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (conn *redisConn) do(args ...string) (reply redisReply, err error) {
+	var command strings.Builder
+
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	_, err = conn.Write([]byte(command.String()))
+	if err != nil {
+		return reply, err
+	}
+
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return reply, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	if len(line) == 0 {
+		return reply, errors.New("empty reply from Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return reply, nil
+	case ':':
+		reply.integer, err = strconv.ParseInt(line[1:], 10, 64)
+		return reply, err
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return reply, err
+		}
+
+		if length < 0 {
+			reply.null = true
+			return reply, nil
+		}
+
+		// The bulk string is followed by a CRLF
+		bulk := make([]byte, length+2)
+		_, err = io.ReadFull(conn.reader, bulk)
+		if err != nil {
+			return reply, err
+		}
+		reply.bulk = string(bulk[:length])
+		return reply, nil
+	case '-':
+		return reply, errors.New("Redis error: " + line[1:])
+	default:
+		return reply, errors.New("unexpected reply from Redis: " + line)
+	}
+}
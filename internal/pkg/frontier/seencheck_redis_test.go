@@ -0,0 +1,198 @@
+package frontier
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeRedis start a server answering to the PING, SADD, SISMEMBER,
+// HSET and HGET commands the same way Redis does, and return its address
+func newFakeRedis(t *testing.T) (addr string, close func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	sets := make(map[string]map[string]bool)
+	hashes := make(map[string]map[string]string)
+
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, count)
+			for i := range args {
+				reader.ReadString('\n')
+				arg, _ := reader.ReadString('\n')
+				args[i] = strings.TrimSuffix(arg, "\r\n")
+			}
+
+			mutex.Lock()
+			switch strings.ToUpper(args[0]) {
+			case "PING":
+				fmt.Fprint(conn, "+PONG\r\n")
+			case "SADD":
+				if sets[args[1]] == nil {
+					sets[args[1]] = make(map[string]bool)
+				}
+				if sets[args[1]][args[2]] {
+					fmt.Fprint(conn, ":0\r\n")
+				} else {
+					sets[args[1]][args[2]] = true
+					fmt.Fprint(conn, ":1\r\n")
+				}
+			case "SISMEMBER":
+				if sets[args[1]][args[2]] {
+					fmt.Fprint(conn, ":1\r\n")
+				} else {
+					fmt.Fprint(conn, ":0\r\n")
+				}
+			case "HSET":
+				if hashes[args[1]] == nil {
+					hashes[args[1]] = make(map[string]string)
+				}
+				_, exists := hashes[args[1]][args[2]]
+				hashes[args[1]][args[2]] = args[3]
+				if exists {
+					fmt.Fprint(conn, ":0\r\n")
+				} else {
+					fmt.Fprint(conn, ":1\r\n")
+				}
+			case "HGET":
+				if value, ok := hashes[args[1]][args[2]]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+				} else {
+					fmt.Fprint(conn, "$-1\r\n")
+				}
+			default:
+				fmt.Fprint(conn, "-ERR unknown command\r\n")
+			}
+			mutex.Unlock()
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestRedisSeencheck(t *testing.T) {
+	addr, close := newFakeRedis(t)
+	defer close()
+
+	seencheck, err := NewRedisSeencheck(addr, "zeno:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer seencheck.Close()
+
+	found, _, err := seencheck.IsSeen("42")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, seencheck.Seen("42", "asset"))
+	assert.NoError(t, seencheck.Seen("43", "seed"))
+
+	found, value, err := seencheck.IsSeen("42")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "asset", value)
+
+	found, value, err = seencheck.IsSeen("43")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "seed", value)
+
+	assert.Equal(t, int64(2), seencheck.Count())
+
+	// Another instance sharing the same key see the same hashes
+	other, err := NewRedisSeencheck(addr, "zeno:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	found, _, err = other.IsSeen("42")
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
+
+func TestRedisSeencheckRequeueShallowerHops(t *testing.T) {
+	addr, close := newFakeRedis(t)
+	defer close()
+
+	seencheck, err := NewRedisSeencheck(addr, "zeno:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer seencheck.Close()
+
+	// The hop is stored along with the type
+	assert.NoError(t, seencheck.Seen("42", "seed:3"))
+	found, value, err := seencheck.IsSeen("42")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "seed:3", value)
+
+	// And so is the time
+	assert.NoError(t, seencheck.Seen("43", "asset@1600000000"))
+	found, value, err = seencheck.IsSeen("43")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "asset@1600000000", value)
+
+	assert.NoError(t, seencheck.Seen("44", "seed:1@1600000000"))
+	_, value, _ = seencheck.IsSeen("44")
+	assert.Equal(t, "seed:1@1600000000", value)
+
+	logInfo = logrus.New()
+
+	f := new(Frontier)
+	f.Seencheck = seencheck
+	f.RequeueShallowerHops = true
+
+	URL, _ := url.Parse("https://example.com/page")
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 3)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 3)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 4)))
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 1)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 2)))
+
+	// Assets don't have a hop to compare, seeds always replace them
+	other, _ := url.Parse("https://example.com/image.png")
+	assert.True(t, f.checkSeen(NewItem(other, nil, "asset", 2)))
+	assert.False(t, f.checkSeen(NewItem(other, nil, "asset", 1)))
+	assert.True(t, f.checkSeen(NewItem(other, nil, "seed", 2)))
+	assert.False(t, f.checkSeen(NewItem(other, nil, "seed", 2)))
+	assert.True(t, f.checkSeen(NewItem(other, nil, "seed", 1)))
+}
+
+func TestRedisSeencheckUnreachable(t *testing.T) {
+	_, err := NewRedisSeencheck("127.0.0.1:1", "zeno:test")
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package frontier
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySeencheck is a seencheck kept in a map, for the tests
+type memorySeencheck map[string]string
+
+func (seencheck memorySeencheck) IsSeen(hash string) (bool, string, error) {
+	value, found := seencheck[hash]
+	return found, value, nil
+}
+
+func (seencheck memorySeencheck) Seen(hash, value string) error {
+	seencheck[hash] = value
+	return nil
+}
+
+func (seencheck memorySeencheck) Count() int64 { return int64(len(seencheck)) }
+func (seencheck memorySeencheck) Sync() error  { return nil }
+func (seencheck memorySeencheck) Close() error { return nil }
+
+func TestCheckSeenShallowerHops(t *testing.T) {
+	logInfo = logrus.New()
+
+	URL, _ := url.Parse("https://example.com/page")
+
+	f := new(Frontier)
+	f.Seencheck = memorySeencheck{}
+
+	// By default, a URL is only enqueued the first time
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 3)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 1)))
+
+	f = new(Frontier)
+	f.Seencheck = memorySeencheck{}
+	f.RequeueShallowerHops = true
+
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 3)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 3)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 4)))
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 1)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 2)))
+
+	// Assets don't have a hop to compare, seeds always replace them
+	other, _ := url.Parse("https://example.com/image.png")
+	assert.True(t, f.checkSeen(NewItem(other, nil, "asset", 2)))
+	assert.False(t, f.checkSeen(NewItem(other, nil, "asset", 1)))
+	assert.True(t, f.checkSeen(NewItem(other, nil, "seed", 2)))
+	assert.True(t, f.checkSeen(NewItem(other, nil, "seed", 1)))
+}
+
+func TestCheckSeenWindow(t *testing.T) {
+	logInfo = logrus.New()
+
+	URL, _ := url.Parse("https://example.com/page")
+	seencheck := memorySeencheck{}
+
+	f := new(Frontier)
+	f.Seencheck = seencheck
+	f.SeencheckWindow = time.Hour
+
+	// Within the window, the URL isn't enqueued again
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+
+	// Past the window, it is enqueued again, once
+	hash := f.SeencheckHash(NewItem(URL, nil, "seed", 0))
+	seencheck[hash] = "seed@" + strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+	assert.True(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+
+	// The URLs seen without a time are never enqueued again
+	seencheck[hash] = "seed"
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+
+	// Without window, the time isn't looked at
+	f.SeencheckWindow = 0
+	seencheck[hash] = "seed@" + strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+	assert.False(t, f.checkSeen(NewItem(URL, nil, "seed", 0)))
+}
+
+func TestParseSeencheckValue(t *testing.T) {
+	itemType, hop, seen := parseSeencheckValue("seed")
+	assert.Equal(t, "seed", itemType)
+	assert.Equal(t, -1, hop)
+	assert.True(t, seen.IsZero())
+
+	itemType, hop, seen = parseSeencheckValue("seed:2")
+	assert.Equal(t, "seed", itemType)
+	assert.Equal(t, 2, hop)
+	assert.True(t, seen.IsZero())
+
+	itemType, hop, seen = parseSeencheckValue("seed:2@1600000000")
+	assert.Equal(t, "seed", itemType)
+	assert.Equal(t, 2, hop)
+	assert.Equal(t, time.Unix(1600000000, 0), seen)
+
+	itemType, hop, seen = parseSeencheckValue("asset@1600000000")
+	assert.Equal(t, "asset", itemType)
+	assert.Equal(t, -1, hop)
+	assert.Equal(t, time.Unix(1600000000, 0), seen)
+}
@@ -2,16 +2,69 @@ package frontier
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/CorentinB/Zeno/internal/pkg/utils"
 	"github.com/gosuri/uilive"
 	"github.com/sirupsen/logrus"
+	"github.com/zeebo/xxh3"
 )
 
+// jsonSeed is a seed written as a JSON object in a seed list,
+// to capture it with another method than GET
+type jsonSeed struct {
+	URL         string `json:"url"`
+	Method      string `json:"method"`
+	Body        string `json:"body"`
+	ContentType string `json:"content_type"`
+}
+
+// parseSeed parse a line of a seed list, either a plain URL or a JSON
+// object like {"url": "...", "method": "POST", "body": "...", "content_type": "..."}
+func parseSeed(line string) (*Item, error) {
+	var seed jsonSeed
+
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		err := json.Unmarshal([]byte(line), &seed)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		seed.URL = line
+	}
+
+	URL, err := url.Parse(seed.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = utils.ValidateURL(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	item := NewItem(URL, nil, "seed", 0)
+
+	seed.Method = strings.ToUpper(seed.Method)
+	if (seed.Method != "" && seed.Method != http.MethodGet) || seed.Body != "" {
+		item.Method = seed.Method
+		item.Body = seed.Body
+		item.ContentType = seed.ContentType
+
+		// The same URL can be captured with different methods or bodies
+		item.Hash = xxh3.HashString(item.Method + " " + URL.String() + "\n" + item.Body)
+	}
+
+	return item, nil
+}
+
 // IsSeedList validates if the path is a seed list, and return an array of
 // frontier.Item made of the seeds if it can
 func IsSeedList(path string) (seeds []Item, err error) {
@@ -39,16 +92,7 @@ func IsSeedList(path string) (seeds []Item, err error) {
 	}).Info("Start reading input list")
 	for scanner.Scan() {
 		totalCount++
-		URL, err := url.Parse(scanner.Text())
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"url":   scanner.Text(),
-				"error": err.Error(),
-			}).Debug("This is not a valid URL")
-			continue
-		}
-
-		err = utils.ValidateURL(URL)
+		item, err := parseSeed(scanner.Text())
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"url":   scanner.Text(),
@@ -57,7 +101,6 @@ func IsSeedList(path string) (seeds []Item, err error) {
 			continue
 		}
 
-		item := NewItem(URL, nil, "seed", 0)
 		seeds = append(seeds, *item)
 		validCount++
 		fmt.Fprintf(writer, "\t   Reading input list.. Found %d valid URLs out of %d URLs read.\n", validCount, totalCount)
@@ -0,0 +1,37 @@
+package frontier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeed(t *testing.T) {
+	item, err := parseSeed("https://example.com/api")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/api", item.URL.String())
+	assert.Empty(t, item.Method)
+
+	post, err := parseSeed(`{"url": "https://example.com/api", "method": "post", "body": "{\"page\":1}", "content_type": "application/json"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/api", post.URL.String())
+	assert.Equal(t, "POST", post.Method)
+	assert.Equal(t, `{"page":1}`, post.Body)
+	assert.Equal(t, "application/json", post.ContentType)
+	assert.NotEqual(t, item.Hash, post.Hash)
+
+	other, err := parseSeed(`{"url": "https://example.com/api", "method": "POST", "body": "{\"page\":2}"}`)
+	assert.NoError(t, err)
+	assert.NotEqual(t, post.Hash, other.Hash)
+
+	get, err := parseSeed(`{"url": "https://example.com/api", "method": "GET"}`)
+	assert.NoError(t, err)
+	assert.Empty(t, get.Method)
+	assert.Equal(t, item.Hash, get.Hash)
+
+	_, err = parseSeed(`{"url": "mailto:foo@example.com"}`)
+	assert.Error(t, err)
+
+	_, err = parseSeed(`{"url": `)
+	assert.Error(t, err)
+}
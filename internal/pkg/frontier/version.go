@@ -0,0 +1,90 @@
+package frontier
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// queueVersion is the version of the on-disk format of the queue and
+// of the frontier's stats dump, it needs to be incremented, and a migration
+// added to migrateQueue, each time the format of the Item struct or of the
+// frontierStats struct changes in an incompatible way
+const queueVersion = 1
+
+// checkQueueVersion read the version of the on-disk queue of the job, and
+// migrate it to the current version if it was written by an older Zeno
+func checkQueueVersion(jobPath string) error {
+	var versionPath = path.Join(jobPath, "queue_version")
+
+	version, err := readQueueVersion(jobPath)
+	if err != nil {
+		return err
+	}
+
+	if version > queueVersion {
+		return errors.New("the queue of this job was written by a more recent version of Zeno (queue version " +
+			strconv.Itoa(version) + ", supported version " + strconv.Itoa(queueVersion) +
+			"), please upgrade Zeno to resume this job")
+	}
+
+	if version < queueVersion {
+		err = migrateQueue(jobPath, version)
+		if err != nil {
+			return errors.New("unable to migrate the queue from version " + strconv.Itoa(version) +
+				" to version " + strconv.Itoa(queueVersion) + ": " + err.Error() +
+				", you can start a new job or use the Zeno version that created this job to resume it")
+		}
+	}
+
+	return ioutil.WriteFile(versionPath, []byte(strconv.Itoa(queueVersion)), 0644)
+}
+
+// readQueueVersion return the version of the on-disk queue, queues written
+// before the versioning was introduced are considered to be version 0
+func readQueueVersion(jobPath string) (version int, err error) {
+	content, err := ioutil.ReadFile(path.Join(jobPath, "queue_version"))
+	if os.IsNotExist(err) {
+		// If the queue doesn't exist either, it's a new job
+		if _, err := os.Stat(path.Join(jobPath, "queue")); os.IsNotExist(err) {
+			return queueVersion, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	version, err = strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, errors.New("invalid queue version file: " + err.Error())
+	}
+
+	return version, nil
+}
+
+// migrateQueue upgrade the on-disk queue from an older version,
+// one version at a time
+func migrateQueue(jobPath string, version int) error {
+	for ; version < queueVersion; version++ {
+		switch version {
+		case 0:
+			// Version 1 only introduced the versioning,
+			// the format itself didn't change
+		default:
+			return errors.New("no migration available for queue version " + strconv.Itoa(version))
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"from": version,
+			"to":   version + 1,
+		}).Info("Queue migrated")
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package frontier
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckQueueVersion(t *testing.T) {
+	jobPath, err := ioutil.TempDir("", "zeno-queue-version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jobPath)
+
+	// New job
+	assert.NoError(t, checkQueueVersion(jobPath))
+	version, err := readQueueVersion(jobPath)
+	assert.NoError(t, err)
+	assert.Equal(t, queueVersion, version)
+
+	// Queue written before the versioning was introduced
+	os.Remove(path.Join(jobPath, "queue_version"))
+	os.MkdirAll(path.Join(jobPath, "queue"), os.ModePerm)
+	version, err = readQueueVersion(jobPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.NoError(t, checkQueueVersion(jobPath))
+
+	// Queue written by a more recent Zeno
+	ioutil.WriteFile(path.Join(jobPath, "queue_version"), []byte("999"), 0644)
+	assert.Error(t, checkQueueVersion(jobPath))
+}
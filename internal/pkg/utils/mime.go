@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"mime"
+	"strings"
+)
+
+// MatchMIMEType return true if the MIME type of the given Content-Type
+// matches one of the patterns, patterns can be exact MIME types like
+// image/gif or wildcards like video/* or */*
+func MatchMIMEType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		if pattern == "*" || pattern == "*/*" || pattern == mediaType {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
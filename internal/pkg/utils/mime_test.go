@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchMIMEType(t *testing.T) {
+	patterns := []string{"image/gif", "video/*"}
+
+	assert.True(t, MatchMIMEType("image/gif", patterns))
+	assert.True(t, MatchMIMEType("Image/GIF; charset=binary", patterns))
+	assert.True(t, MatchMIMEType("video/mp4", patterns))
+	assert.False(t, MatchMIMEType("image/png", patterns))
+	assert.False(t, MatchMIMEType("videogame/x", patterns))
+	assert.False(t, MatchMIMEType("", patterns))
+	assert.True(t, MatchMIMEType("text/html", []string{"*/*"}))
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// SimHash compute the 64 bits SimHash of a text, texts that are similar
+// have hashes with a small Hamming distance
+func SimHash(text string) uint64 {
+	var weights [64]int
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	// Use shingles of 2 words as features, so the order of the words matters
+	for i := 0; i < len(words); i++ {
+		feature := words[i]
+		if i+1 < len(words) {
+			feature += " " + words[i+1]
+		}
+
+		hasher := fnv.New64a()
+		hasher.Write([]byte(feature))
+		hash := hasher.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if hash&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			simhash |= 1 << uint(bit)
+		}
+	}
+
+	return simhash
+}
+
+// SimHashSimilarity return the similarity between two SimHash,
+// between 0 (totally different) and 1 (identical)
+func SimHashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
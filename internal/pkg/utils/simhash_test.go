@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimHash(t *testing.T) {
+	page := "Zeno is a web crawler designed to operate wide crawls or to simply archive one web page. " +
+		"Zeno's key concepts are: portability, performance, simplicity. With an emphasis on performance. " +
+		"The name Zeno comes from Zenodotus, a Greek grammarian, literary critic, Homeric scholar, " +
+		"and the first librarian of the Library of Alexandria."
+	nearDuplicate := page + " Page 2 of 10."
+	different := "Badger is an embeddable, persistent and fast key-value database written in pure Go. " +
+		"It is the underlying database for Dgraph, a fast, distributed graph database."
+
+	assert.Equal(t, float64(1), SimHashSimilarity(SimHash(page), SimHash(page)))
+	assert.True(t, SimHashSimilarity(SimHash(page), SimHash(nearDuplicate)) > 0.85)
+	assert.True(t, SimHashSimilarity(SimHash(page), SimHash(different)) < 0.85)
+}
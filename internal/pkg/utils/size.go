@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"TB", 1024 * 1024 * 1024 * 1024},
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+// ParseSize parse a human readable size like "1GB", "500MB" or "1.5 GB" and
+// return it in bytes, a size without unit is considered to be in bytes
+func ParseSize(size string) (bytes float64, err error) {
+	var value = strings.ToUpper(strings.TrimSpace(size))
+	var multiplier float64 = 1
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	bytes, err = strconv.ParseFloat(value, 64)
+	if err != nil || bytes <= 0 {
+		return 0, errors.New("invalid size: " + size)
+	}
+
+	return bytes * multiplier, nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]float64{
+		"1GB":    1024 * 1024 * 1024,
+		"500MB":  500 * 1024 * 1024,
+		"1.5 gb": 1.5 * 1024 * 1024 * 1024,
+		"10KB":   10 * 1024,
+		"2048":   2048,
+	} {
+		bytes, err := ParseSize(size)
+		assert.NoError(t, err, size)
+		assert.Equal(t, expected, bytes, size)
+	}
+
+	for _, size := range []string{"", "GB", "-1GB", "one GB", "0"} {
+		_, err := ParseSize(size)
+		assert.Error(t, err, size)
+	}
+}
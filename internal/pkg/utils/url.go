@@ -2,13 +2,16 @@ package utils
 
 import (
 	"errors"
+	"net"
 	"net/url"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 )
 
 // MakeAbsolute turn all URLs in a slice of url.URL into absolute URLs, based
-// on a given base *url.URL
+// on a given base *url.URL, the protocol-relative URLs like
+// //cdn.example.com/app.js take the scheme of the base
 func MakeAbsolute(base *url.URL, URLs []url.URL) []url.URL {
 	for i, URL := range URLs {
 		if URL.IsAbs() == false {
@@ -19,6 +22,18 @@ func MakeAbsolute(base *url.URL, URLs []url.URL) []url.URL {
 	return URLs
 }
 
+// IsProtocolRelativeURL return true if the string is a protocol-relative
+// URL like //cdn.example.com/app.js, whose host has at least two labels so
+// that comments and paths starting with two slashes aren't mistaken for one
+func IsProtocolRelativeURL(rawURL string) bool {
+	if !strings.HasPrefix(rawURL, "//") {
+		return false
+	}
+
+	URL, err := url.Parse(rawURL)
+	return err == nil && strings.Contains(strings.Trim(URL.Hostname(), "."), ".")
+}
+
 // DedupeURLs take a slice of *url.URL and dedupe it
 func DedupeURLs(URLs []url.URL) []url.URL {
 	keys := make(map[string]bool)
@@ -26,20 +41,77 @@ func DedupeURLs(URLs []url.URL) []url.URL {
 	for _, entry := range URLs {
 		if _, value := keys[entry.String()]; !value {
 			keys[entry.String()] = true
-
-			if entry.Scheme == "http" || entry.Scheme == "https" {
-				list = append(list, entry)
-			}
+			list = append(list, entry)
 		}
 	}
 	return list
 }
 
+// IsSchemeAllowed return true if the scheme of the URL
+// is one of the allowed schemes, case-insensitively
+func IsSchemeAllowed(u *url.URL, allowedSchemes []string) bool {
+	for _, scheme := range allowedSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrimTrailingSlash return the URL as a string without the trailing
+// slashes of its path, so that /foo and /foo/ give the same string
+func TrimTrailingSlash(u *url.URL) string {
+	trimmed := *u
+	trimmed.Path = strings.TrimRight(u.Path, "/")
+	trimmed.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	return trimmed.String()
+}
+
+// IsOnionHost return true if the host is a Tor onion service, that can
+// only be reached through Tor and must never be resolved with the DNS
+func IsOnionHost(host string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	return strings.HasSuffix(strings.TrimSuffix(strings.ToLower(host), "."), ".onion")
+}
+
+// SURT return the Sort-friendly URI Reordering Transform of a URL, used as
+// the key of CDX indexes: the host is lowercased, stripped of its www prefix
+// and of the default port, and its labels are reversed
+func SURT(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	key := strings.Join(labels, ",")
+
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		key += ":" + port
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	key += ")" + strings.ToLower(path)
+
+	if u.RawQuery != "" {
+		key += "?" + strings.ToLower(u.RawQuery)
+	}
+
+	return key
+}
+
 // ValidateURL validates a *url.URL
 func ValidateURL(u *url.URL) error {
 	valid := govalidator.IsURL(u.String())
 
-	if u.Scheme != "http" && u.Scheme != "https" {
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp" {
 		valid = false
 	}
 
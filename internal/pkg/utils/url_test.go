@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSchemeAllowed(t *testing.T) {
+	var allowedSchemes = []string{"http", "https"}
+
+	for _, rawURL := range []string{"http://example.com", "https://example.com/a", "HTTPS://example.com/b"} {
+		URL, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		assert.True(t, IsSchemeAllowed(URL, allowedSchemes), rawURL)
+	}
+
+	for _, rawURL := range []string{"mailto:foo@example.com", "tel:+33123456789", "javascript:void(0)", "ftp://example.com/file"} {
+		URL, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		assert.False(t, IsSchemeAllowed(URL, allowedSchemes), rawURL)
+	}
+}
+
+func TestTrimTrailingSlash(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"http://example.com/foo/":      "http://example.com/foo",
+		"http://example.com/foo":       "http://example.com/foo",
+		"http://example.com/":          "http://example.com",
+		"http://example.com/foo/?a=b/": "http://example.com/foo?a=b/",
+	} {
+		URL, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, TrimTrailingSlash(URL), rawURL)
+	}
+}
+
+func TestSURT(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"https://www.Example.com/Foo/Bar.html?B=2": "com,example)/foo/bar.html?b=2",
+		"http://example.com":                       "com,example)/",
+		"http://sub.example.co.uk:8080/a":          "uk,co,example,sub:8080)/a",
+		"https://example.com:443/":                 "com,example)/",
+	} {
+		URL, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, SURT(URL), rawURL)
+	}
+}
+
+func TestIsOnionHost(t *testing.T) {
+	for _, host := range []string{"expyuzz4wqqyqhjn.onion", "www.duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion:80", "FOO.ONION."} {
+		assert.True(t, IsOnionHost(host), host)
+	}
+
+	for _, host := range []string{"example.com", "onion.example.com", "127.0.0.1:9050", "onion"} {
+		assert.False(t, IsOnionHost(host), host)
+	}
+}
+
+func TestMakeAbsoluteProtocolRelative(t *testing.T) {
+	for rawBase, expected := range map[string]string{
+		"http://example.com/page":      "http://cdn.example.com/app.js?v=1",
+		"https://example.com/page":     "https://cdn.example.com/app.js?v=1",
+		"https://example.com:8443/a/b": "https://cdn.example.com/app.js?v=1",
+	} {
+		base, _ := url.Parse(rawBase)
+		relative, _ := url.Parse("//cdn.example.com/app.js?v=1")
+
+		URLs := MakeAbsolute(base, []url.URL{*relative})
+		assert.Equal(t, expected, URLs[0].String(), rawBase)
+	}
+}
+
+func TestIsProtocolRelativeURL(t *testing.T) {
+	for _, rawURL := range []string{"//cdn.example.com/app.js", "//example.org", "//img.example.com:8080/a.png?b=c"} {
+		assert.True(t, IsProtocolRelativeURL(rawURL), rawURL)
+	}
+
+	for _, value := range []string{"https://example.com/", "/path", "// a comment", "//localhost/", "//", "//path//to", ""} {
+		assert.False(t, IsProtocolRelativeURL(value), value)
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/CorentinB/Zeno/cmd"
 	_ "github.com/CorentinB/Zeno/cmd/all"
+	"github.com/CorentinB/Zeno/config"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
@@ -33,6 +34,7 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "Zeno"
 	app.Version = Version
+	config.App.Version = Version
 	app.Authors = append(app.Authors, &cli.Author{Name: "Corentin Barreau", Email: "corentin@archive.org"})
 	app.Usage = ""
 
@@ -40,7 +42,7 @@ func main() {
 	app.Commands = cmd.Commands
 	app.CommandNotFound = cmd.CommandNotFound
 	app.Before = func(context *cli.Context) error {
-		return nil
+		return cmd.ApplyPolitenessPreset(context)
 	}
 	app.After = func(context *cli.Context) error {
 		return nil